@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// identityHeaders are the headers set by authenticating reverse proxies
+// (Authelia, Authentik, oauth2-proxy, nginx auth_request...).
+// Home Agent has no login of its own, so this is the only source of identity.
+var identityHeaders = []string{"Remote-User", "X-Forwarded-User", "X-Auth-Request-User"}
+
+// RequestActor identifies who issued an HTTP request
+func RequestActor(c *fiber.Ctx) string {
+	for _, header := range identityHeaders {
+		if user := c.Get(header); user != "" {
+			return user
+		}
+	}
+	return c.IP()
+}
+
+// connActor identifies who opened a WebSocket connection
+func connActor(c *websocket.Conn) string {
+	for _, header := range identityHeaders {
+		if user := c.Headers(header); user != "" {
+			return user
+		}
+	}
+	host, _, err := net.SplitHostPort(c.RemoteAddr().String())
+	if err != nil {
+		return c.RemoteAddr().String()
+	}
+	return host
+}
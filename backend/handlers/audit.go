@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/repositories"
+)
+
+// AuditHandler handles audit log API endpoints
+type AuditHandler struct {
+	audit repositories.AuditRepository
+}
+
+// NewAuditHandler creates a new AuditHandler
+func NewAuditHandler(audit repositories.AuditRepository) *AuditHandler {
+	return &AuditHandler{audit: audit}
+}
+
+// AuditResponse represents the audit list API response
+type AuditResponse struct {
+	Events []*models.AuditEvent `json:"events"`
+	Total  int                  `json:"total"`
+	Limit  int                  `json:"limit"`
+	Offset int                  `json:"offset"`
+}
+
+// RegisterRoutes registers audit API routes
+func (h *AuditHandler) RegisterRoutes(app *fiber.App) {
+	app.Get("/api/audit", h.List)
+	app.Get("/api/audit/export", h.Export)
+}
+
+// List handles GET /api/audit?type=&actor=&session_id=&machine_id=&outcome=&since=&until=&limit=50&offset=0
+func (h *AuditHandler) List(c *fiber.Ctx) error {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	filter.Limit = c.QueryInt("limit", 50)
+	if filter.Limit < 1 || filter.Limit > 500 {
+		filter.Limit = 50
+	}
+	filter.Offset = c.QueryInt("offset", 0)
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	events, total, err := h.audit.List(filter)
+	if err != nil {
+		log.Printf("Failed to list audit events: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list audit events",
+		})
+	}
+
+	// Return empty array if no events
+	if events == nil {
+		events = []*models.AuditEvent{}
+	}
+
+	return c.JSON(AuditResponse{
+		Events: events,
+		Total:  total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	})
+}
+
+// Export handles GET /api/audit/export?format=csv|json with the same filters as List
+func (h *AuditHandler) Export(c *fiber.Ctx) error {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	events, _, err := h.audit.List(filter)
+	if err != nil {
+		log.Printf("Failed to export audit events: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export audit events",
+		})
+	}
+
+	if events == nil {
+		events = []*models.AuditEvent{}
+	}
+
+	filename := fmt.Sprintf("audit-%s", time.Now().Format("20060102-150405"))
+
+	switch c.Query("format", "json") {
+	case "json":
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		return c.JSON(fiber.Map{
+			"version": "1.0",
+			"events":  events,
+		})
+
+	case "csv":
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+
+		w := csv.NewWriter(c)
+		w.Write([]string{"id", "created_at", "event_type", "actor", "session_id", "machine_id", "target", "outcome", "details"})
+		for _, e := range events {
+			w.Write([]string{
+				strconv.Itoa(e.ID),
+				e.CreatedAt.UTC().Format(time.RFC3339),
+				e.EventType,
+				e.Actor,
+				e.SessionID,
+				e.MachineID,
+				e.Target,
+				e.Outcome,
+				e.Details,
+			})
+		}
+		w.Flush()
+		return w.Error()
+
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid format. Must be one of: json, csv",
+		})
+	}
+}
+
+// parseAuditFilter reads the audit filter from query parameters
+func parseAuditFilter(c *fiber.Ctx) (models.AuditFilter, error) {
+	filter := models.AuditFilter{
+		EventType: c.Query("type"),
+		Actor:     c.Query("actor"),
+		SessionID: c.Query("session_id"),
+		MachineID: c.Query("machine_id"),
+		Outcome:   c.Query("outcome"),
+	}
+
+	if since := c.Query("since"); since != "" {
+		t, err := parseQueryTime(since)
+		if err != nil {
+			return filter, fmt.Errorf("invalid 'since' parameter: %w", err)
+		}
+		filter.Since = &t
+	}
+
+	if until := c.Query("until"); until != "" {
+		t, err := parseQueryTime(until)
+		if err != nil {
+			return filter, fmt.Errorf("invalid 'until' parameter: %w", err)
+		}
+		filter.Until = &t
+	}
+
+	return filter, nil
+}
+
+// parseQueryTime accepts RFC 3339 timestamps or plain dates (YYYY-MM-DD)
+func parseQueryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -9,7 +10,10 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/ronan/home-agent/models"
 	"github.com/ronan/home-agent/repositories"
 	"github.com/ronan/home-agent/services"
 )
@@ -26,6 +30,14 @@ type ChatHandler struct {
 	toolCalls      repositories.ToolCallRepository
 	logService     *services.LogService
 	cryptoService  *services.CryptoService
+	audit          *services.AuditService
+	approvals      *services.ApprovalService
+	policies       *services.ToolPolicyService
+	budgets        *services.BudgetService
+	compaction     *services.CompactionService
+	forks          *services.ForkService
+	documents      *services.DocumentExtractor
+	workspaces     *services.WorkspaceService
 }
 
 // NewChatHandler creates a new ChatHandler instance
@@ -40,6 +52,14 @@ func NewChatHandler(
 	toolCalls repositories.ToolCallRepository,
 	logService *services.LogService,
 	cryptoService *services.CryptoService,
+	audit *services.AuditService,
+	approvals *services.ApprovalService,
+	policies *services.ToolPolicyService,
+	budgets *services.BudgetService,
+	compaction *services.CompactionService,
+	forks *services.ForkService,
+	documents *services.DocumentExtractor,
+	workspaces *services.WorkspaceService,
 ) *ChatHandler {
 	return &ChatHandler{
 		sessionManager: sessionManager,
@@ -52,6 +72,14 @@ func NewChatHandler(
 		toolCalls:      toolCalls,
 		logService:     logService,
 		cryptoService:  cryptoService,
+		audit:          audit,
+		approvals:      approvals,
+		policies:       policies,
+		budgets:        budgets,
+		compaction:     compaction,
+		forks:          forks,
+		documents:      documents,
+		workspaces:     workspaces,
 	}
 }
 
@@ -62,6 +90,9 @@ type MessageAttachment struct {
 	Path     string `json:"path"`
 	Type     string `json:"type"` // "image" or "file"
 	MimeType string `json:"mime_type,omitempty"`
+	// Files of an archive to include in the prompt, and to extract into the session's workspace
+	Inline  []string `json:"inline,omitempty"`
+	Extract []string `json:"extract,omitempty"`
 }
 
 // MessageRequest represents an incoming message from the client
@@ -72,6 +103,7 @@ type MessageRequest struct {
 	Attachments []MessageAttachment `json:"attachments,omitempty"`
 	Thinking    bool                `json:"thinking,omitempty"`   // Enable extended thinking mode
 	MachineID   string              `json:"machine_id,omitempty"` // Target SSH machine ID
+	Actor       string              `json:"-"`                    // Who sent the message (for auditing)
 }
 
 // ToolInfo represents tool information for WebSocket responses
@@ -90,9 +122,20 @@ type UsageInfo struct {
 	TotalCostUSD             float64 `json:"total_cost_usd,omitempty"`
 }
 
+// ApprovalInfo describes a tool call waiting for (or resolved by) a user decision
+type ApprovalInfo struct {
+	ID              string                 `json:"id"`
+	ToolName        string                 `json:"tool_name"`
+	Input           map[string]interface{} `json:"input,omitempty"`
+	TimeoutSeconds  int                    `json:"timeout_seconds,omitempty"`
+	DefaultDecision string                 `json:"default_decision,omitempty"` // Applied on timeout
+	Decision        string                 `json:"decision,omitempty"`         // "allow" or "deny" once resolved
+	Reason          string                 `json:"reason,omitempty"`           // "user" or "timeout"
+}
+
 // MessageResponse represents a response chunk sent to the client
 type MessageResponse struct {
-	Type      string `json:"type"` // "chunk", "thinking", "thinking_end", "done", "error", "session_id", "session_title", "tool_start", "tool_progress", "tool_result", "tool_error", "tool_input_delta", "usage"
+	Type      string `json:"type"` // "chunk", "thinking", "thinking_end", "done", "error", "session_id", "session_title", "tool_start", "tool_progress", "tool_result", "tool_error", "tool_input_delta", "usage", "approval_request", "approval_resolved", "budget_warning", "compacted", "forked"
 	Content   string `json:"content,omitempty"`
 	SessionID string `json:"session_id,omitempty"`
 	Title     string `json:"title,omitempty"` // Session title for session_title type
@@ -105,6 +148,8 @@ type MessageResponse struct {
 	InputDelta         string    `json:"input_delta,omitempty"` // JSON delta for streaming tool input
 	// Usage information
 	Usage *UsageInfo `json:"usage,omitempty"`
+	// Approval workflow
+	Approval *ApprovalInfo `json:"approval,omitempty"`
 }
 
 // HandleMessage processes a user message and streams Claude's response
@@ -121,29 +166,76 @@ func (ch *ChatHandler) HandleMessage(ctx context.Context, request MessageRequest
 		return nil, fmt.Errorf("message content cannot be empty")
 	}
 
-	// Build prompt with attachments
-	prompt := ch.buildPromptWithAttachments(request.Content, request.Attachments)
-
-	// Prepare user content for saving (original content, not the augmented prompt)
-	userContent := request.Content
-	if len(request.Attachments) > 0 {
-		// Include attachment info in saved message for display
-		userContent = ch.buildDisplayContentWithAttachments(request.Content, request.Attachments)
+	// Enforce spending budgets before anything is saved or executed
+	// A failing check does not block the conversation
+	budgetStatuses, err := ch.budgets.Check(request.Actor, model)
+	if err != nil {
+		log.Printf("Warning: failed to check budgets: %v", err)
+	}
+	var budgetWarnings []string
+	for _, status := range budgetStatuses {
+		switch status.State {
+		case services.BudgetStateExceeded:
+			ch.audit.Record(models.AuditEvent{
+				EventType: models.AuditBudgetBlocked,
+				Actor:     request.Actor,
+				SessionID: request.SessionID,
+				Target:    model,
+				Details:   services.AuditDetails(map[string]interface{}{"budget": status.Describe()}),
+				Outcome:   models.AuditOutcomeDenied,
+			})
+			ch.logService.Warning("Request blocked: " + status.Describe())
+			return nil, fmt.Errorf("budget exceeded: %s", status.Describe())
+		case services.BudgetStateWarning:
+			budgetWarnings = append(budgetWarnings, status.Describe())
+		}
 	}
 
 	// Determine if this is a new conversation or a resume
 	isNewConversation := request.SessionID == ""
 	sessionID := request.SessionID
 
+	// The session's own ID. A new conversation gets a Home Agent UUID now; its
+	// session is created under it once Claude returns its SDK session ID.
+	turnSessionID := sessionID
+	if isNewConversation {
+		turnSessionID = uuid.New().String()
+	}
+
+	// Build prompt with attachments, extracting archives into the session's workspace
+	prompt, images := ch.buildPromptWithAttachments(request.Content, request.Attachments, turnSessionID)
+
+	// The original content is saved, not the augmented prompt; files are linked to it
+	attachments := ch.messageAttachments(request.Attachments)
+
+	// The Claude SDK session to resume, never the session's own ID. A fork has
+	// no Claude session yet: its first turn starts one from the copied transcript.
+	claudeSessionID := ""
+	seeded := false
+
 	// For existing sessions, verify it exists and save user message now
 	if !isNewConversation {
-		if !ch.sessionManager.SessionExists(sessionID) {
-			return nil, fmt.Errorf("session not found: %s", sessionID)
+		// A compaction moves the session to a new Claude session: wait for it
+		if err := ch.compaction.Wait(ctx, sessionID); err != nil {
+			return nil, err
+		}
+		session, err := ch.sessionManager.GetSession(sessionID)
+		if err != nil {
+			return nil, err
+		}
+		claudeSessionID = session.ClaudeSessionID
+		if session.SeedPending {
+			seeded = true
+			if prompt, err = ch.forks.SeedPrompt(sessionID, prompt); err != nil {
+				return nil, fmt.Errorf("failed to seed forked session: %w", err)
+			}
 		}
 		// Update model if changed
 		ch.sessionManager.UpdateSessionModel(sessionID, model)
-		// Save user message before calling SDK (it will be updated with new session_id)
-		ch.sessionManager.SaveMessage(sessionID, "user", userContent)
+		// Save user message before calling SDK
+		if err := ch.sessionManager.SaveUserMessage(sessionID, request.Content, attachments); err != nil {
+			ch.logService.Error(fmt.Sprintf("Failed to save user message: %v", err))
+		}
 	}
 
 	// Get custom instructions from settings
@@ -234,10 +326,19 @@ Choisis la machine appropriee selon le contexte de la demande de l'utilisateur.`
 		} else {
 			// Specific machine mode: force execution on this machine
 			machine, err := ch.machines.GetWithAuth(request.MachineID)
-			if err != nil {
+			if err != nil || machine == nil {
 				return nil, fmt.Errorf("machine not found: %s", request.MachineID)
 			}
 
+			ch.audit.Record(models.AuditEvent{
+				EventType: models.AuditMachineTarget,
+				Actor:     request.Actor,
+				SessionID: turnSessionID,
+				MachineID: machine.ID,
+				Target:    machine.Name,
+				Details:   services.AuditDetails(map[string]interface{}{"host": machine.Host, "username": machine.Username}),
+			})
+
 			// Decrypt auth value
 			authValue, err := ch.cryptoService.Decrypt(machine.AuthValue)
 			if err != nil {
@@ -280,10 +381,24 @@ IMPORTANT: Execute les commandes sur cette machine distante, pas en local.`,
 		}
 	}
 
+	// Resolve which tools Claude may use for this user, machine and session
+	toolPolicy, err := ch.policies.Effective(request.Actor, request.MachineID, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve tool policy: %w", err)
+	}
+	if toolPolicy.Restricted() {
+		ch.logService.Info(fmt.Sprintf("Tool policy applied (%s): allowed=%v denied=%v",
+			strings.Join(toolPolicy.Scopes, ", "), toolPolicy.AllowedTools, toolPolicy.DeniedTools))
+	}
+
 	// Execute Claude
-	// For new conversations: sessionID is empty, SDK will generate one
-	// For resume: sessionID is provided, SDK will resume and return new ID
-	claudeResponseChan, err := ch.claudeExecutor.ExecuteClaude(ctx, prompt, sessionID, isNewConversation, model, fullInstructions, request.Thinking)
+	// For new conversations: no SDK session yet, SDK will generate one
+	// For resume: the SDK session is resumed and may come back with a new ID
+	// For seeded forks: SDK starts a new session for the fork
+	if seeded {
+		claudeSessionID = ""
+	}
+	claudeResponseChan, err := ch.claudeExecutor.ExecuteClaude(ctx, prompt, claudeSessionID, isNewConversation || seeded, model, fullInstructions, request.Thinking, toolPolicy, images)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute claude: %w", err)
 	}
@@ -291,28 +406,55 @@ IMPORTANT: Execute les commandes sur cette machine distante, pas en local.`,
 	// Create response channel
 	responseChan := make(chan MessageResponse, 100)
 
+	// Soft limits crossed: warn before the response starts
+	for _, warning := range budgetWarnings {
+		responseChan <- MessageResponse{
+			Type:    "budget_warning",
+			Content: warning,
+		}
+	}
+
 	// Start goroutine to process Claude's responses
-	go ch.processClaudeResponse(sessionID, isNewConversation, model, userContent, request.Content, claudeResponseChan, responseChan)
+	go ch.processClaudeResponse(sessionID, isNewConversation, seeded, model, request.Content, attachments, turnSessionID, request.Actor, request.MachineID, claudeResponseChan, responseChan)
 
 	return responseChan, nil
 }
 
 // processClaudeResponse processes the Claude response stream and sends formatted responses
-// oldSessionID: the session ID provided by frontend (empty for new conversations)
+// sessionID: the session ID provided by frontend (empty for new conversations)
 // isNewConversation: true if this is a new conversation
+// seeded: true if a fork's transcript was sent to start its Claude session
 // model: the model to use
-// userContent: the user message content to save (with attachment markers)
-// userMessage: the original user message (for title generation)
-func (ch *ChatHandler) processClaudeResponse(oldSessionID string, isNewConversation bool, model string, userContent string, userMessage string, claudeResponseChan <-chan services.ClaudeResponse, responseChan chan<- MessageResponse) {
+// userMessage: the original user message (saved, and used for title generation)
+// attachments: the files sent with the user message
+// newSessionID: the ID a new conversation's session is created with
+// actor, machineID: who sent the message and the targeted machine (for auditing)
+func (ch *ChatHandler) processClaudeResponse(sessionID string, isNewConversation bool, seeded bool, model string, userMessage string, attachments []*models.Attachment, newSessionID string, actor string, machineID string, claudeResponseChan <-chan services.ClaudeResponse, responseChan chan<- MessageResponse) {
 	// Note: We don't defer close here because we need to send session_title after done
 	// The channel will be closed at the end of this function
 
 	var fullAssistantResponse strings.Builder
 	var currentThinkingContent strings.Builder // Current thinking block being accumulated
-	var currentSessionID string = oldSessionID
+	var currentSessionID string = sessionID
 	var pendingTitleGeneration bool = false
+	var compaction *services.PendingCompaction // Context threshold crossed during this turn
 	var titleUserMessage, titleAssistantMessage string
 	var wasThinking bool = false // Track if we were receiving thinking content
+	turnStart := time.Now()      // Turn duration is recorded with its usage
+	var toolCount int
+	results := make(toolResults)                                // Tool calls whose result was forwarded
+	turn := ch.sessionManager.NewTurnRecorder(currentSessionID) // Ordered parts of the assistant turn
+	defer func() {
+		if compaction != nil {
+			compaction.Release()
+		}
+	}()
+	// A turn that fails or is interrupted keeps its parts, with the response received so far
+	defer func() {
+		if err := turn.Finish(fullAssistantResponse.String()); err != nil {
+			ch.logService.Error(fmt.Sprintf("Failed to save partial assistant message: %v", err))
+		}
+	}()
 
 	// Helper function to finalize current thinking block
 	finalizeThinkingBlock := func() {
@@ -322,6 +464,7 @@ func (ch *ChatHandler) processClaudeResponse(oldSessionID string, isNewConversat
 			responseChan <- MessageResponse{
 				Type: "thinking_end",
 			}
+			turn.Thinking(thinkingContent)
 			// Save thinking content to database
 			if currentSessionID != "" {
 				if err := ch.sessionManager.SaveMessage(currentSessionID, "thinking", thinkingContent); err != nil {
@@ -344,6 +487,7 @@ func (ch *ChatHandler) processClaudeResponse(oldSessionID string, isNewConversat
 
 			// Accumulate the full response
 			fullAssistantResponse.WriteString(claudeResp.Content)
+			turn.Text(claudeResp.Content)
 
 			// Send chunk to client
 			responseChan <- MessageResponse{
@@ -367,8 +511,8 @@ func (ch *ChatHandler) processClaudeResponse(oldSessionID string, isNewConversat
 			sdkSessionID := claudeResp.SessionID
 
 			if isNewConversation {
-				// New conversation: create session with SDK's session_id
-				_, err := ch.sessionManager.CreateSessionWithID(sdkSessionID, model)
+				// New conversation: create the session, resuming the SDK's session_id
+				_, err := ch.sessionManager.CreateSessionWithID(newSessionID, sdkSessionID, model)
 				if err != nil {
 					ch.logService.Error(fmt.Sprintf("Failed to create session: %v", err))
 					responseChan <- MessageResponse{
@@ -378,22 +522,28 @@ func (ch *ChatHandler) processClaudeResponse(oldSessionID string, isNewConversat
 					return
 				}
 				// Save user message now that we have a session
-				if err := ch.sessionManager.SaveMessage(sdkSessionID, "user", userContent); err != nil {
+				if err := ch.sessionManager.SaveUserMessage(newSessionID, userMessage, attachments); err != nil {
 					ch.logService.Error(fmt.Sprintf("Failed to save user message: %v", err))
 				}
-				currentSessionID = sdkSessionID
+				currentSessionID = newSessionID
+				turn.SetSessionID(newSessionID)
 			} else {
-				// Resume: update session_id if it changed
-				if sdkSessionID != oldSessionID {
-					err := ch.sessionManager.UpdateSessionID(oldSessionID, sdkSessionID)
-					if err != nil {
-						ch.logService.Warning(fmt.Sprintf("Failed to update session ID: %v", err))
+				// Resume: the session keeps its ID, only the SDK session it resumes changes
+				reason := models.ClaudeSessionResume
+				if seeded {
+					reason = models.ClaudeSessionSeed
+				}
+				if err := ch.sessionManager.UpdateClaudeSessionID(sessionID, sdkSessionID, reason); err != nil {
+					ch.logService.Warning(fmt.Sprintf("Failed to update Claude session ID: %v", err))
+				}
+				if seeded {
+					if err := ch.forks.MarkSeeded(currentSessionID); err != nil {
+						ch.logService.Warning(fmt.Sprintf("Failed to mark fork as seeded: %v", err))
 					}
-					currentSessionID = sdkSessionID
 				}
 			}
 
-			// Send session_id to client (always use the current/new ID)
+			// Send session_id to client
 			responseChan <- MessageResponse{
 				Type:      "session_id",
 				SessionID: currentSessionID,
@@ -405,12 +555,10 @@ func (ch *ChatHandler) processClaudeResponse(oldSessionID string, isNewConversat
 				finalizeThinkingBlock()
 			}
 
-			// Save assistant's full response to database
+			// Save assistant's full response to database, with the parts of the turn
 			assistantMessage := fullAssistantResponse.String()
-			if assistantMessage != "" {
-				if err := ch.sessionManager.SaveMessage(currentSessionID, "assistant", assistantMessage); err != nil {
-					ch.logService.Error(fmt.Sprintf("Failed to save assistant message: %v", err))
-				}
+			if err := turn.Finish(assistantMessage); err != nil {
+				ch.logService.Error(fmt.Sprintf("Failed to save assistant message: %v", err))
 			}
 
 			// Send done signal to client
@@ -442,6 +590,7 @@ func (ch *ChatHandler) processClaudeResponse(oldSessionID string, isNewConversat
 			}
 
 			log.Printf("[Chat] Received tool_start: %s (%s)", claudeResp.Tool.ToolName, claudeResp.Tool.ToolUseID)
+			toolCount++
 			// Create tool call in database
 			if claudeResp.Tool != nil && currentSessionID != "" && ch.toolCalls != nil {
 				inputJSON, _ := json.Marshal(claudeResp.Tool.Input)
@@ -450,6 +599,9 @@ func (ch *ChatHandler) processClaudeResponse(oldSessionID string, isNewConversat
 					log.Printf("Warning: failed to create tool call: %v", err)
 				}
 			}
+			if claudeResp.Tool != nil {
+				turn.ToolUse(claudeResp.Tool.ToolUseID, claudeResp.Tool.ToolName, claudeResp.Tool.Input)
+			}
 
 			// Forward to client
 			var toolInfo *ToolInfo
@@ -513,6 +665,30 @@ func (ch *ChatHandler) processClaudeResponse(oldSessionID string, isNewConversat
 					); err != nil {
 						log.Printf("Warning: failed to save session usage: %v", err)
 					}
+					if err := ch.budgets.RecordUsage(models.UsageEvent{
+						SessionID:                currentSessionID,
+						Actor:                    actor,
+						Model:                    model,
+						InputTokens:              claudeResp.Usage.InputTokens,
+						OutputTokens:             claudeResp.Usage.OutputTokens,
+						CacheCreationInputTokens: claudeResp.Usage.CacheCreationInputTokens,
+						CacheReadInputTokens:     claudeResp.Usage.CacheReadInputTokens,
+						TotalCostUSD:             claudeResp.Usage.TotalCostUSD,
+						DurationMS:               time.Since(turnStart).Milliseconds(),
+						ToolCalls:                toolCount,
+					}); err != nil {
+						log.Printf("Warning: failed to record usage event: %v", err)
+					}
+					needsCompaction, err := ch.compaction.Track(currentSessionID, model, *claudeResp.Usage)
+					if err != nil {
+						log.Printf("Warning: failed to track context usage: %v", err)
+					}
+					// Announced before "done", so that the next message waits for the compacted session
+					if needsCompaction && compaction == nil {
+						if compaction, err = ch.compaction.Begin(currentSessionID); err != nil {
+							log.Printf("Warning: not compacting session %s: %v", currentSessionID, err)
+						}
+					}
 				}
 
 				responseChan <- MessageResponse{
@@ -527,13 +703,29 @@ func (ch *ChatHandler) processClaudeResponse(oldSessionID string, isNewConversat
 				}
 			}
 
-		case "tool_result", "tool_error":
+		case "approval_request":
+			// If we were thinking, finalize that block first
+			if wasThinking {
+				finalizeThinkingBlock()
+			}
+			if claudeResp.Approval != nil {
+				ch.handleApprovalRequest(claudeResp.Approval, currentSessionID, actor, machineID, responseChan)
+			}
+
+		case "tool_result", "tool_error", "policy_violation":
 			log.Printf("[Chat] Received %s for tool %s", claudeResp.Type, claudeResp.Tool.ToolUseID)
+			// A call blocked by the tool policy is reported by the proxy, then
+			// answered with an error by the SDK: only the first one is kept
+			if claudeResp.Tool != nil && !results.first(claudeResp.Tool.ToolUseID) {
+				continue
+			}
+			failed := claudeResp.IsError || claudeResp.Type != "tool_result"
+
 			// Update tool call in database with input and result
 			var inputMap map[string]interface{}
 			if claudeResp.Tool != nil && ch.toolCalls != nil {
 				status := "success"
-				if claudeResp.IsError || claudeResp.Type == "tool_error" {
+				if failed {
 					status = "error"
 				}
 				// Convert input map to JSON string
@@ -546,6 +738,36 @@ func (ch *ChatHandler) processClaudeResponse(oldSessionID string, isNewConversat
 				}
 			}
 
+			if claudeResp.Tool != nil {
+				turn.ToolResult(claudeResp.Tool.ToolUseID, claudeResp.Tool.ToolName, claudeResp.Tool.Input,
+					claudeResp.ToolOutput, failed)
+			}
+
+			// Keep a permanent record of the execution, independent of the session
+			if claudeResp.Tool != nil {
+				details := map[string]interface{}{
+					"tool_use_id": claudeResp.Tool.ToolUseID,
+					"input":       claudeResp.Tool.Input,
+				}
+				outcome := models.AuditOutcomeSuccess
+				switch {
+				case claudeResp.Type == "policy_violation":
+					outcome = models.AuditOutcomeDenied
+					details["reason"] = claudeResp.ToolOutput
+				case failed:
+					outcome = models.AuditOutcomeError
+				}
+				ch.audit.Record(models.AuditEvent{
+					EventType: models.AuditToolExecute,
+					Actor:     actor,
+					SessionID: currentSessionID,
+					MachineID: machineID,
+					Target:    claudeResp.Tool.ToolName,
+					Details:   services.AuditDetails(details),
+					Outcome:   outcome,
+				})
+			}
+
 			// Forward to client (include input for frontend to display)
 			var toolInfo *ToolInfo
 			if claudeResp.Tool != nil {
@@ -555,11 +777,15 @@ func (ch *ChatHandler) processClaudeResponse(oldSessionID string, isNewConversat
 					Input:     inputMap,
 				}
 			}
+			responseType := claudeResp.Type
+			if responseType == "policy_violation" {
+				responseType = "tool_error"
+			}
 			responseChan <- MessageResponse{
-				Type:       claudeResp.Type,
+				Type:       responseType,
 				Tool:       toolInfo,
 				ToolOutput: claudeResp.ToolOutput,
-				IsError:    claudeResp.IsError,
+				IsError:    failed,
 			}
 		}
 	}
@@ -578,10 +804,195 @@ func (ch *ChatHandler) processClaudeResponse(oldSessionID string, isNewConversat
 		}
 	}
 
+	// Compact once the turn is complete, so the next message starts from the summary
+	if compaction != nil {
+		ch.compactSession(compaction, currentSessionID, actor, responseChan)
+	}
+
 	// Close the channel now that we're done
 	close(responseChan)
 }
 
+// toolResults records the tool calls whose result was forwarded
+type toolResults map[string]bool
+
+// first reports whether toolUseID has no result yet, and records it
+func (tr toolResults) first(toolUseID string) bool {
+	if toolUseID == "" {
+		return true
+	}
+	if tr[toolUseID] {
+		return false
+	}
+	tr[toolUseID] = true
+	return true
+}
+
+// compactionTimeout bounds an automatic compaction, which messages to the session wait for
+const compactionTimeout = 5 * time.Minute
+
+// compactSession compacts a session whose context is full and notifies the client.
+// A failure leaves the session as it was: the next turn simply resumes it.
+func (ch *ChatHandler) compactSession(compaction *services.PendingCompaction, sessionID, actor string, responseChan chan<- MessageResponse) {
+	ch.logService.Info(fmt.Sprintf("Context threshold reached, compacting session %s", sessionID))
+
+	ctx, cancel := context.WithTimeout(context.Background(), compactionTimeout)
+	defer cancel()
+	result, err := compaction.Run(ctx, actor)
+	ch.recordCompaction(sessionID, actor, result, err)
+	if err != nil {
+		ch.logService.Warning(fmt.Sprintf("Failed to compact session %s: %v", sessionID, err))
+		return
+	}
+
+	responseChan <- MessageResponse{
+		Type:      "compacted",
+		SessionID: result.SessionID,
+		Content:   result.Summary,
+	}
+}
+
+// recordCompaction audits the outcome of a compaction
+func (ch *ChatHandler) recordCompaction(sessionID, actor string, result *services.CompactionResult, err error) {
+	event := models.AuditEvent{
+		EventType: models.AuditSessionCompact,
+		Actor:     actor,
+		SessionID: sessionID,
+		Outcome:   models.AuditOutcomeSuccess,
+	}
+	if err != nil {
+		event.Outcome = models.AuditOutcomeError
+		event.Details = services.AuditDetails(map[string]interface{}{"error": err.Error()})
+	} else {
+		event.Details = services.AuditDetails(map[string]interface{}{
+			"claude_session_id":     result.ClaudeSessionID,
+			"context_tokens_before": result.ContextTokensBefore,
+			"context_tokens":        result.ContextTokens,
+		})
+	}
+	ch.audit.Record(event)
+}
+
+// ForkForEdit forks a session before one of its user messages, so that the
+// message can be sent again with new content in the fork
+func (ch *ChatHandler) ForkForEdit(sessionID string, messageID int) (*models.Session, error) {
+	fork, err := ch.forks.ForkForEdit(sessionID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	ch.logService.Info(fmt.Sprintf("Session %s forked into %s to edit message %d", sessionID, fork.SessionID, messageID))
+	return fork, nil
+}
+
+// CompactSession compacts a session on request and returns the result
+func (ch *ChatHandler) CompactSession(ctx context.Context, sessionID, actor string) (*services.CompactionResult, error) {
+	result, err := ch.compaction.Compact(ctx, sessionID, actor)
+	ch.recordCompaction(sessionID, actor, result, err)
+	if err == nil {
+		ch.logService.Info(fmt.Sprintf("Session %s compacted into Claude session %s", sessionID, result.ClaudeSessionID))
+	}
+	return result, err
+}
+
+// handleApprovalRequest decides on a tool permission request from the proxy.
+// Matching rules answer immediately; "ask" relays the request to the client and
+// waits for its decision, falling back to the configured default on timeout.
+func (ch *ChatHandler) handleApprovalRequest(req *services.ApprovalRequest, sessionID, actor, machineID string, responseChan chan<- MessageResponse) {
+	config := ch.approvalConfig()
+	decision := config.Evaluate(req.ToolName, req.Input)
+	reason := "rule"
+
+	if decision == services.ApprovalAsk {
+		if ch.approvals == nil {
+			decision, reason = config.TimeoutDecision, "timeout"
+		} else {
+			wait := ch.approvals.Register(req.ID)
+			responseChan <- MessageResponse{
+				Type: "approval_request",
+				Approval: &ApprovalInfo{
+					ID:              req.ID,
+					ToolName:        req.ToolName,
+					Input:           req.Input,
+					TimeoutSeconds:  config.TimeoutSeconds,
+					DefaultDecision: config.TimeoutDecision,
+				},
+			}
+
+			select {
+			case decision = <-wait:
+				reason = "user"
+			case <-time.After(time.Duration(config.TimeoutSeconds) * time.Second):
+				ch.approvals.Cancel(req.ID)
+				decision, reason = config.TimeoutDecision, "timeout"
+				ch.logService.Warning(fmt.Sprintf("Approval for %s timed out, applying default decision: %s", req.ToolName, decision))
+			}
+
+			responseChan <- MessageResponse{
+				Type: "approval_resolved",
+				Approval: &ApprovalInfo{
+					ID:       req.ID,
+					ToolName: req.ToolName,
+					Decision: decision,
+					Reason:   reason,
+				},
+			}
+		}
+	}
+
+	message := ""
+	if decision == services.ApprovalDeny {
+		switch reason {
+		case "user":
+			message = "The user denied this tool call."
+		case "timeout":
+			message = "No approval was given in time for this tool call."
+		default:
+			message = "This tool call is blocked by an approval rule."
+		}
+
+		ch.audit.Record(models.AuditEvent{
+			EventType: models.AuditToolExecute,
+			Actor:     actor,
+			SessionID: sessionID,
+			MachineID: machineID,
+			Target:    req.ToolName,
+			Details: services.AuditDetails(map[string]interface{}{
+				"input":  req.Input,
+				"reason": reason,
+			}),
+			Outcome: models.AuditOutcomeDenied,
+		})
+	}
+
+	if err := req.Respond(decision, message); err != nil {
+		ch.logService.Error(fmt.Sprintf("Failed to send approval decision: %v", err))
+	}
+}
+
+// approvalConfig loads the approval rules from settings
+func (ch *ChatHandler) approvalConfig() services.ApprovalConfig {
+	if ch.settings == nil {
+		return services.DefaultApprovalConfig()
+	}
+	raw, err := ch.settings.Get(services.ApprovalSettingsKey)
+	if err != nil {
+		return services.DefaultApprovalConfig()
+	}
+	config, err := services.ParseApprovalConfig(raw)
+	if err != nil {
+		ch.logService.Warning(fmt.Sprintf("Ignoring invalid approval rules: %v", err))
+	}
+	return config
+}
+
+// ResolveApproval delivers the user's decision for a pending approval request
+func (ch *ChatHandler) ResolveApproval(approvalID, decision string) error {
+	if ch.approvals == nil {
+		return fmt.Errorf("approvals are not enabled")
+	}
+	return ch.approvals.Resolve(approvalID, decision)
+}
+
 // GetHistory retrieves the conversation history for a session
 func (ch *ChatHandler) GetHistory(sessionID string) ([]MessageResponse, error) {
 	if sessionID == "" {
@@ -626,12 +1037,15 @@ func (ch *ChatHandler) ValidateSession(sessionID string) error {
 }
 
 // buildPromptWithAttachments builds a prompt that includes attachment content for Claude
-func (ch *ChatHandler) buildPromptWithAttachments(content string, attachments []MessageAttachment) string {
+// Images are sent to Claude as content blocks; files are inlined in the prompt
+// and archives are extracted into the workspace of sessionID
+func (ch *ChatHandler) buildPromptWithAttachments(content string, attachments []MessageAttachment, sessionID string) (string, []services.ImageInput) {
 	if len(attachments) == 0 {
-		return content
+		return content, nil
 	}
 
 	var sb strings.Builder
+	var images []services.ImageInput
 
 	// Process each attachment
 	for _, att := range attachments {
@@ -641,11 +1055,22 @@ func (ch *ChatHandler) buildPromptWithAttachments(content string, attachments []
 		}
 
 		if att.Type == "image" {
+			image, err := ch.readImage(physicalPath)
+			if err == nil {
+				images = append(images, *image)
+				sb.WriteString(fmt.Sprintf("[Image %d: %s]\n\n", len(images), att.Filename))
+				continue
+			}
+			log.Printf("Sending image %s by path: %v", att.Filename, err)
+			// Fall back to the Read tool for images that cannot be sent inline
 			claudePath := ch.getClaudePath(physicalPath)
 			sb.WriteString(fmt.Sprintf("[Image: %s]\nPlease read and analyze this image file: %s\n\n", att.Filename, claudePath))
+		} else if services.IsArchive(physicalPath) {
+			ch.writeArchive(&sb, att, physicalPath, sessionID)
 		} else {
 			fileContent, err := ch.readFileContent(physicalPath)
 			if err != nil {
+				log.Printf("Reading attachment %s: %v", att.Filename, err)
 				sb.WriteString(fmt.Sprintf("[File: %s - Error reading content]\n\n", att.Filename))
 				continue
 			}
@@ -660,7 +1085,82 @@ func (ch *ChatHandler) buildPromptWithAttachments(content string, attachments []
 		sb.WriteString("Please analyze the attached file(s).")
 	}
 
-	return sb.String()
+	return sb.String(), images
+}
+
+// maxArchiveListing is the number of archive files listed in the prompt
+const maxArchiveListing = 200
+
+// writeArchive adds an archive to the prompt: the files picked to be inlined,
+// the directory the files picked for extraction were written to, and a
+// listing of the archive when nothing was picked
+func (ch *ChatHandler) writeArchive(sb *strings.Builder, att MessageAttachment, archivePath, sessionID string) {
+	listing, err := services.ListArchive(archivePath)
+	if err != nil {
+		log.Printf("Reading archive %s: %v", att.Filename, err)
+		sb.WriteString(fmt.Sprintf("[Archive: %s - Error reading content]\n\n", att.Filename))
+		return
+	}
+	sb.WriteString(fmt.Sprintf("[Archive: %s, %d files, %d bytes]\n", att.Filename, len(listing.Entries), listing.TotalSize))
+
+	if len(att.Extract) > 0 {
+		dir, count, err := ch.workspaces.ExtractArchive(sessionID, archivePath, att.Filename, att.Extract)
+		if err != nil {
+			log.Printf("Extracting archive %s: %v", att.Filename, err)
+			sb.WriteString("Error extracting the archive\n")
+		} else {
+			sb.WriteString(fmt.Sprintf("%d files extracted to %s\n", count, ch.getClaudePath(dir)))
+		}
+	}
+
+	for _, name := range att.Inline {
+		data, size, err := services.ReadArchiveFile(archivePath, name, maxPromptFileSize)
+		switch {
+		case err != nil:
+			sb.WriteString(fmt.Sprintf("[File: %s/%s - Error reading content]\n", att.Filename, name))
+		case bytes.IndexByte(data, 0) >= 0:
+			sb.WriteString(fmt.Sprintf("[File: %s/%s - Binary content not included]\n", att.Filename, name))
+		default:
+			text := strings.ToValidUTF8(string(data), "")
+			if size > maxPromptFileSize {
+				text += "\n... [file truncated, showing first 100KB]"
+			}
+			sb.WriteString(fmt.Sprintf("[File: %s/%s]\n```\n%s\n```\n", att.Filename, name, text))
+		}
+	}
+
+	if len(att.Extract) == 0 && len(att.Inline) == 0 {
+		for i, entry := range listing.Entries {
+			if i == maxArchiveListing {
+				sb.WriteString(fmt.Sprintf("... and %d more files\n", len(listing.Entries)-i))
+				break
+			}
+			sb.WriteString(fmt.Sprintf("%s (%d bytes)\n", entry.Path, entry.Size))
+		}
+	}
+	sb.WriteString("\n")
+}
+
+// maxInlineImageFile is the largest image file read to send as a content block,
+// the upload request limit
+const maxInlineImageFile = 100 * 1024 * 1024
+
+// readImage reads an image to send as a content block, downscaled when it is
+// over the API limit
+func (ch *ChatHandler) readImage(filePath string) (*services.ImageInput, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxInlineImageFile {
+		return nil, fmt.Errorf("image too large (%d bytes)", info.Size())
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return services.InlineImage(data)
 }
 
 // getClaudePath returns the path that Claude CLI should use to access the file
@@ -693,12 +1193,29 @@ func (ch *ChatHandler) getPhysicalPath(apiPath string) string {
 		return ""
 	}
 
-	filename := strings.TrimPrefix(apiPath, prefix)
+	// Uploads are stored directly in uploadDir: never resolve outside of it
+	filename := filepath.Base(strings.TrimPrefix(apiPath, prefix))
 	return filepath.Join(ch.uploadDir, filename)
 }
 
-// readFileContent reads the content of a text file
+// maxPromptFileSize limits the content of a file included in the prompt
+const maxPromptFileSize = 100 * 1024
+
+// readFileContent reads the content of a text file, or the extracted text of
+// a PDF, Office or EPUB document
 func (ch *ChatHandler) readFileContent(filePath string) (string, error) {
+	if services.IsDocument(filePath) {
+		text, err := ch.documents.Text(filePath)
+		if err != nil {
+			return "", err
+		}
+		if len(text) > maxPromptFileSize {
+			text = strings.ToValidUTF8(text[:maxPromptFileSize], "") +
+				"\n... [document truncated, showing first 100KB of extracted text]"
+		}
+		return text, nil
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		return "", err
@@ -706,8 +1223,7 @@ func (ch *ChatHandler) readFileContent(filePath string) (string, error) {
 	defer file.Close()
 
 	// Limit reading to 100KB to avoid huge prompts
-	const maxSize = 100 * 1024
-	limitedReader := io.LimitReader(file, maxSize)
+	limitedReader := io.LimitReader(file, maxPromptFileSize)
 
 	content, err := io.ReadAll(limitedReader)
 	if err != nil {
@@ -718,36 +1234,30 @@ func (ch *ChatHandler) readFileContent(filePath string) (string, error) {
 
 	// Check if file was truncated
 	stat, err := file.Stat()
-	if err == nil && stat.Size() > maxSize {
+	if err == nil && stat.Size() > maxPromptFileSize {
 		result += "\n... [file truncated, showing first 100KB]"
 	}
 
 	return result, nil
 }
 
-// buildDisplayContentWithAttachments builds content for display that shows attachment info
-func (ch *ChatHandler) buildDisplayContentWithAttachments(content string, attachments []MessageAttachment) string {
-	if len(attachments) == 0 {
-		return content
-	}
-
-	var sb strings.Builder
-
-	// Add the user's message first
-	if content != "" {
-		sb.WriteString(content)
-		sb.WriteString("\n\n")
-	}
-
-	// Add attachment markers for frontend to parse
-	sb.WriteString("<!-- attachments:")
-	for i, att := range attachments {
-		if i > 0 {
-			sb.WriteString(",")
+// messageAttachments describes the files of a message for saving. Files are
+// identified by their upload ID; the other fields are used for uploads the
+// database has no record of.
+func (ch *ChatHandler) messageAttachments(attachments []MessageAttachment) []*models.Attachment {
+	var result []*models.Attachment
+	for _, att := range attachments {
+		if !strings.HasPrefix(att.Path, models.AttachmentPathPrefix) {
+			continue
 		}
-		sb.WriteString(fmt.Sprintf("%s|%s|%s|%s", att.ID, att.Filename, att.Path, att.Type))
+		result = append(result, &models.Attachment{
+			UploadID:   att.ID,
+			Filename:   att.Filename,
+			StoredName: filepath.Base(strings.TrimPrefix(att.Path, models.AttachmentPathPrefix)),
+			Type:       att.Type,
+			MimeType:   att.MimeType,
+			UploadedAt: time.Now(),
+		})
 	}
-	sb.WriteString(" -->")
-
-	return sb.String()
+	return result
 }
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/services"
+)
+
+// memoryAudit is an in-memory AuditRepository
+type memoryAudit struct {
+	events []*models.AuditEvent
+}
+
+func (m *memoryAudit) Record(event *models.AuditEvent) error {
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *memoryAudit) List(models.AuditFilter) ([]*models.AuditEvent, int, error) {
+	return m.events, len(m.events), nil
+}
+
+func TestProcessClaudeResponsePolicyViolation(t *testing.T) {
+	audit := &memoryAudit{}
+	ch := &ChatHandler{
+		sessionManager: services.NewSessionManager(nil, nil, nil, nil, nil),
+		audit:          services.NewAuditService(audit, nil),
+	}
+
+	blocked := &services.ToolCallInfo{ToolUseID: "toolu_1", ToolName: "Bash", Input: map[string]interface{}{"command": "rm -rf /"}}
+	allowed := &services.ToolCallInfo{ToolUseID: "toolu_2", ToolName: "Read"}
+	claudeResponses := make(chan services.ClaudeResponse, 10)
+	for _, resp := range []services.ClaudeResponse{
+		{Type: "tool_start", Tool: blocked},
+		{Type: "policy_violation", Tool: blocked, ToolOutput: "Tool Bash is not permitted by the tool policy", IsError: true},
+		// The SDK then answers the denied call with an error of its own
+		{Type: "tool_error", Tool: blocked, ToolOutput: "Tool Bash is not permitted by the tool policy", IsError: true},
+		{Type: "tool_start", Tool: allowed},
+		{Type: "tool_result", Tool: allowed, ToolOutput: "contents"},
+	} {
+		claudeResponses <- resp
+	}
+	close(claudeResponses)
+
+	responses := make(chan MessageResponse, 20)
+	ch.processClaudeResponse("", false, false, "haiku", "hello", nil, "", "alice", "", claudeResponses, responses)
+
+	results := map[string][]MessageResponse{}
+	for resp := range responses {
+		if resp.Type == "tool_result" || resp.Type == "tool_error" {
+			results[resp.Tool.ToolUseID] = append(results[resp.Tool.ToolUseID], resp)
+		}
+	}
+	if got := results["toolu_1"]; len(got) != 1 || got[0].Type != "tool_error" || !got[0].IsError {
+		t.Errorf("blocked tool results = %+v, want a single tool_error", got)
+	}
+	if got := results["toolu_2"]; len(got) != 1 || got[0].Type != "tool_result" || got[0].IsError {
+		t.Errorf("allowed tool results = %+v, want a single tool_result", got)
+	}
+
+	outcomes := map[string]string{}
+	for _, event := range audit.events {
+		if event.EventType != models.AuditToolExecute {
+			continue
+		}
+		if _, ok := outcomes[event.Target]; ok {
+			t.Errorf("tool %s audited more than once", event.Target)
+		}
+		outcomes[event.Target] = event.Outcome
+	}
+	if outcomes["Bash"] != models.AuditOutcomeDenied || outcomes["Read"] != models.AuditOutcomeSuccess {
+		t.Errorf("audit outcomes = %v, want Bash denied and Read success", outcomes)
+	}
+}
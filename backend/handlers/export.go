@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ronan/home-agent/repositories"
+	"github.com/ronan/home-agent/services"
+)
+
+// ExportHandler handles conversation export endpoints
+type ExportHandler struct {
+	sessions repositories.SessionRepository
+	exporter *services.SessionExporter
+}
+
+// NewExportHandler creates a new ExportHandler
+func NewExportHandler(sessions repositories.SessionRepository, exporter *services.SessionExporter) *ExportHandler {
+	return &ExportHandler{sessions: sessions, exporter: exporter}
+}
+
+// RegisterRoutes registers export API routes
+func (h *ExportHandler) RegisterRoutes(app *fiber.App) {
+	app.Get("/api/sessions/:id/export", h.ExportSession)
+	app.Get("/api/export/sessions", h.ExportAll)
+}
+
+// exportContentTypes maps export formats to their content type
+var exportContentTypes = map[string]string{
+	services.ExportFormatMarkdown: "text/markdown; charset=utf-8",
+	services.ExportFormatJSON:     "application/json; charset=utf-8",
+	services.ExportFormatHTML:     "text/html; charset=utf-8",
+}
+
+// ExportSession handles GET /api/sessions/:id/export?format=md|json|html&attachments=none|inline|zip
+// HTML inlines attachments by default; zip bundles the transcript and files.
+func (h *ExportHandler) ExportSession(c *fiber.Ctx) error {
+	format := c.Query("format", services.ExportFormatMarkdown)
+	if !services.ValidExportFormat(format) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid 'format' parameter: must be 'md', 'json' or 'html'",
+		})
+	}
+
+	defaultAttachments := services.ExportAttachmentsNone
+	if format == services.ExportFormatHTML {
+		defaultAttachments = services.ExportAttachmentsInline
+	}
+	attachments := c.Query("attachments", defaultAttachments)
+	switch attachments {
+	case services.ExportAttachmentsNone, services.ExportAttachmentsInline, services.ExportAttachmentsZip:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid 'attachments' parameter: must be 'none', 'inline' or 'zip'",
+		})
+	}
+
+	export, err := h.exporter.Build(c.Params("id"))
+	if err != nil {
+		log.Printf("Failed to export session: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export session",
+		})
+	}
+	if export == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Session not found",
+		})
+	}
+
+	filename := services.ExportFilename(export.Session)
+
+	var buf bytes.Buffer
+	if attachments == services.ExportAttachmentsZip {
+		archive := zip.NewWriter(&buf)
+		err = h.exporter.WriteZip(archive, export, format, filename, map[string]bool{})
+		if err == nil {
+			err = archive.Close()
+		}
+	} else {
+		err = h.exporter.Render(&buf, export, format, attachments)
+	}
+	if err != nil {
+		log.Printf("Failed to export session: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export session",
+		})
+	}
+
+	if attachments == services.ExportAttachmentsZip {
+		c.Set(fiber.HeaderContentType, "application/zip")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.zip"`, filename))
+	} else {
+		c.Set(fiber.HeaderContentType, exportContentTypes[format])
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.%s"`, filename, format))
+	}
+	return c.Send(buf.Bytes())
+}
+
+// ExportAll handles GET /api/export/sessions?format=md|json|html
+// Every session is written to a zip archive, with attachments in attachments/.
+func (h *ExportHandler) ExportAll(c *fiber.Ctx) error {
+	format := c.Query("format", services.ExportFormatMarkdown)
+	if !services.ValidExportFormat(format) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid 'format' parameter: must be 'md', 'json' or 'html'",
+		})
+	}
+
+	sessions, err := h.sessions.List()
+	if err != nil {
+		log.Printf("Failed to list sessions for export: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export sessions",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="sessions-%s.zip"`, time.Now().Format("20060102-150405")))
+
+	// Stream the archive: a full history can be large
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		archive := zip.NewWriter(w)
+		written := map[string]bool{}
+		for _, session := range sessions {
+			export, err := h.exporter.Build(session.SessionID)
+			if err != nil || export == nil {
+				log.Printf("Skipping session %s in export: %v", session.SessionID, err)
+				continue
+			}
+			if err := h.exporter.WriteZip(archive, export, format, services.ExportFilename(session), written); err != nil {
+				log.Printf("Failed to export session %s: %v", session.SessionID, err)
+				return
+			}
+		}
+		if err := archive.Close(); err != nil {
+			log.Printf("Failed to finish sessions export: %v", err)
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ronan/home-agent/repositories"
+)
+
+// maxFolderNameLen bounds folder names
+const maxFolderNameLen = 100
+
+// FoldersHandler handles session folder API endpoints
+type FoldersHandler struct {
+	folders repositories.FolderRepository
+}
+
+// NewFoldersHandler creates a new FoldersHandler
+func NewFoldersHandler(folders repositories.FolderRepository) *FoldersHandler {
+	return &FoldersHandler{folders: folders}
+}
+
+// RegisterRoutes registers folder API routes
+func (h *FoldersHandler) RegisterRoutes(app *fiber.App) {
+	app.Get("/api/folders", h.List)
+	app.Post("/api/folders", h.Create)
+	app.Put("/api/folders/:id", h.Update)
+	app.Delete("/api/folders/:id", h.Delete)
+}
+
+// FolderRequest represents the request body for creating or updating a folder
+// A null parent_id places the folder at the top level
+type FolderRequest struct {
+	Name     string `json:"name"`
+	ParentID *int   `json:"parent_id"`
+}
+
+// List returns all folders as a flat list; nesting is given by parent_id
+func (h *FoldersHandler) List(c *fiber.Ctx) error {
+	folders, err := h.folders.List()
+	if err != nil {
+		log.Printf("Failed to list folders: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list folders",
+		})
+	}
+
+	return c.JSON(folders)
+}
+
+// Create creates a folder
+func (h *FoldersHandler) Create(c *fiber.Ctx) error {
+	req, errMsg := parseFolderRequest(c)
+	if errMsg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errMsg})
+	}
+
+	folder, err := h.folders.Create(req.Name, req.ParentID)
+	if errors.Is(err, repositories.ErrFolderNotFound) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Parent folder not found"})
+	}
+	if err != nil {
+		log.Printf("Failed to create folder: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create folder",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(folder)
+}
+
+// Update renames and/or moves a folder
+func (h *FoldersHandler) Update(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid folder ID"})
+	}
+
+	existing, err := h.folders.Get(id)
+	if err != nil {
+		log.Printf("Failed to get folder: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get folder",
+		})
+	}
+	if existing == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Folder not found"})
+	}
+
+	req, errMsg := parseFolderRequest(c)
+	if errMsg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": errMsg})
+	}
+
+	err = h.folders.Update(id, req.Name, req.ParentID)
+	if errors.Is(err, repositories.ErrFolderNotFound) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Parent folder not found"})
+	}
+	if errors.Is(err, repositories.ErrFolderCycle) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A folder cannot be moved inside itself"})
+	}
+	if err != nil {
+		log.Printf("Failed to update folder: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update folder",
+		})
+	}
+
+	folder, _ := h.folders.Get(id)
+	return c.JSON(folder)
+}
+
+// Delete removes a folder; its sessions and subfolders move up to its parent
+func (h *FoldersHandler) Delete(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid folder ID"})
+	}
+
+	err = h.folders.Delete(id)
+	if errors.Is(err, repositories.ErrFolderNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Folder not found"})
+	}
+	if err != nil {
+		log.Printf("Failed to delete folder: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete folder",
+		})
+	}
+
+	return c.JSON(fiber.Map{"deleted": id})
+}
+
+// parseFolderRequest parses and validates a folder body, returning an error message on failure
+func parseFolderRequest(c *fiber.Ctx) (*FolderRequest, string) {
+	var req FolderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return nil, "Invalid request body"
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return nil, "Name is required"
+	}
+	if len([]rune(req.Name)) > maxFolderNameLen {
+		return nil, "Name is too long"
+	}
+	if req.ParentID != nil && *req.ParentID <= 0 {
+		return nil, "Invalid parent folder ID"
+	}
+
+	return &req, ""
+}
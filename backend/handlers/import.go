@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ronan/home-agent/services"
+)
+
+// maxImportFileSize bounds each uploaded transcript
+const maxImportFileSize = 100 * 1024 * 1024
+
+// ImportHandler handles conversation import endpoints
+type ImportHandler struct {
+	importer *services.ClaudeCodeImporter
+}
+
+// NewImportHandler creates a new ImportHandler
+func NewImportHandler(importer *services.ClaudeCodeImporter) *ImportHandler {
+	return &ImportHandler{importer: importer}
+}
+
+// RegisterRoutes registers import API routes
+func (h *ImportHandler) RegisterRoutes(app *fiber.App) {
+	app.Post("/api/import/claude-code", h.ImportClaudeCode)
+}
+
+// ImportClaudeCode handles POST /api/import/claude-code with one or more
+// Claude Code .jsonl transcripts in the multipart "files" field.
+// Sessions that already exist are skipped.
+func (h *ImportHandler) ImportClaudeCode(c *fiber.Ctx) error {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid multipart form",
+		})
+	}
+
+	files := form.File["files"]
+	if len(files) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "No transcript provided",
+		})
+	}
+
+	result := services.NewImportResult()
+	for _, fileHeader := range files {
+		if fileHeader.Size > maxImportFileSize {
+			result.Errors = append(result.Errors, fileHeader.Filename+": file too large")
+			continue
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			result.Errors = append(result.Errors, fileHeader.Filename+": "+err.Error())
+			continue
+		}
+		result.Merge(h.importer.ImportReader(file, fileHeader.Filename))
+		file.Close()
+	}
+
+	log.Printf("Claude Code import: %d imported, %d skipped, %d errors",
+		len(result.Imported), len(result.Skipped), len(result.Errors))
+
+	return c.JSON(result)
+}
@@ -14,11 +14,14 @@ import (
 type MachinesHandler struct {
 	machines repositories.MachineRepository
 	crypto   *services.CryptoService
+	audit    *services.AuditService
+	redactor *services.Redactor
 }
 
 // NewMachinesHandler creates a new MachinesHandler
-func NewMachinesHandler(machines repositories.MachineRepository, crypto *services.CryptoService) *MachinesHandler {
-	return &MachinesHandler{machines: machines, crypto: crypto}
+// The redactor is refreshed whenever credentials change
+func NewMachinesHandler(machines repositories.MachineRepository, crypto *services.CryptoService, audit *services.AuditService, redactor *services.Redactor) *MachinesHandler {
+	return &MachinesHandler{machines: machines, crypto: crypto, audit: audit, redactor: redactor}
 }
 
 // RegisterRoutes registers machine API routes
@@ -128,10 +131,13 @@ func (h *MachinesHandler) Create(c *fiber.Ctx) error {
 	machine, err := h.machines.Create(id, req.Name, req.Description, req.Host, port, req.Username, req.AuthType, encryptedAuthValue)
 	if err != nil {
 		log.Printf("Failed to create machine: %v", err)
+		h.recordCredentialChange(c, models.AuditCredentialCreate, id, req.Name, req.AuthType, models.AuditOutcomeError)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create machine",
 		})
 	}
+	h.recordCredentialChange(c, models.AuditCredentialCreate, id, req.Name, req.AuthType, models.AuditOutcomeSuccess)
+	h.redactor.Refresh()
 
 	// Clear auth value before returning
 	machine.AuthValue = ""
@@ -226,10 +232,13 @@ func (h *MachinesHandler) Update(c *fiber.Ctx) error {
 	err = h.machines.Update(id, req.Name, req.Description, req.Host, port, req.Username, req.AuthType, encryptedAuthValue)
 	if err != nil {
 		log.Printf("Failed to update machine: %v", err)
+		h.recordCredentialChange(c, models.AuditCredentialUpdate, id, req.Name, req.AuthType, models.AuditOutcomeError)
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Machine not found",
 		})
 	}
+	h.recordCredentialChange(c, models.AuditCredentialUpdate, id, req.Name, req.AuthType, models.AuditOutcomeSuccess)
+	h.redactor.Refresh()
 
 	// Return updated machine
 	machine, _ := h.machines.Get(id)
@@ -245,13 +254,22 @@ func (h *MachinesHandler) Delete(c *fiber.Ctx) error {
 		})
 	}
 
+	// Keep the name for the audit trail before it disappears
+	name := ""
+	if existing, err := h.machines.Get(id); err == nil && existing != nil {
+		name = existing.Name
+	}
+
 	err := h.machines.Delete(id)
 	if err != nil {
 		log.Printf("Failed to delete machine: %v", err)
+		h.recordCredentialChange(c, models.AuditCredentialDelete, id, name, "", models.AuditOutcomeError)
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Machine not found",
 		})
 	}
+	h.recordCredentialChange(c, models.AuditCredentialDelete, id, name, "", models.AuditOutcomeSuccess)
+	h.redactor.Refresh()
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
@@ -303,3 +321,20 @@ func (h *MachinesHandler) TestConnection(c *fiber.Ctx) error {
 
 	return c.JSON(result)
 }
+
+// recordCredentialChange writes a machine credential change to the audit log.
+// The credential itself is never recorded.
+func (h *MachinesHandler) recordCredentialChange(c *fiber.Ctx, eventType, machineID, name, authType, outcome string) {
+	details := map[string]interface{}{}
+	if authType != "" {
+		details["auth_type"] = authType
+	}
+	h.audit.Record(models.AuditEvent{
+		EventType: eventType,
+		Actor:     RequestActor(c),
+		MachineID: machineID,
+		Target:    name,
+		Details:   services.AuditDetails(details),
+		Outcome:   outcome,
+	})
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/repositories"
+	"github.com/ronan/home-agent/services"
+)
+
+// PoliciesHandler handles tool policy API endpoints
+type PoliciesHandler struct {
+	policies repositories.ToolPolicyRepository
+	resolver *services.ToolPolicyService
+	audit    *services.AuditService
+}
+
+// NewPoliciesHandler creates a new PoliciesHandler
+func NewPoliciesHandler(policies repositories.ToolPolicyRepository, resolver *services.ToolPolicyService, audit *services.AuditService) *PoliciesHandler {
+	return &PoliciesHandler{policies: policies, resolver: resolver, audit: audit}
+}
+
+// RegisterRoutes registers tool policy API routes
+func (h *PoliciesHandler) RegisterRoutes(app *fiber.App) {
+	app.Get("/api/policies", h.List)
+	app.Get("/api/policies/tools", h.Tools)
+	app.Get("/api/policies/effective", h.Effective)
+	app.Put("/api/policies/:scope/:scopeId?", h.Update)
+	app.Delete("/api/policies/:scope/:scopeId?", h.Delete)
+}
+
+// UpdatePolicyRequest represents the request body for setting a policy
+type UpdatePolicyRequest struct {
+	AllowedTools []string `json:"allowed_tools"`
+	DeniedTools  []string `json:"denied_tools"`
+}
+
+// List returns all tool policies
+func (h *PoliciesHandler) List(c *fiber.Ctx) error {
+	policies, err := h.policies.List()
+	if err != nil {
+		log.Printf("Failed to list tool policies: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list tool policies",
+		})
+	}
+
+	// Return empty array if no policies
+	if policies == nil {
+		policies = []*models.ToolPolicy{}
+	}
+
+	return c.JSON(policies)
+}
+
+// Tools returns the tool names a policy can reference
+func (h *PoliciesHandler) Tools(c *fiber.Ctx) error {
+	return c.JSON(services.KnownTools)
+}
+
+// Effective handles GET /api/policies/effective?user=&machine_id=&session_id=
+func (h *PoliciesHandler) Effective(c *fiber.Ctx) error {
+	policy, err := h.resolver.Effective(c.Query("user"), c.Query("machine_id"), c.Query("session_id"))
+	if err != nil {
+		log.Printf("Failed to resolve tool policy: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to resolve tool policy",
+		})
+	}
+
+	if policy.DeniedTools == nil {
+		policy.DeniedTools = []string{}
+	}
+	if policy.Scopes == nil {
+		policy.Scopes = []string{}
+	}
+
+	return c.JSON(policy)
+}
+
+// Update creates or replaces the policy of a scope
+func (h *PoliciesHandler) Update(c *fiber.Ctx) error {
+	var req UpdatePolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	policy := &models.ToolPolicy{
+		Scope:        c.Params("scope"),
+		ScopeID:      strings.TrimSpace(c.Params("scopeId")),
+		AllowedTools: req.AllowedTools,
+		DeniedTools:  req.DeniedTools,
+	}
+	if err := services.ValidateToolPolicy(policy); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := h.policies.Upsert(policy); err != nil {
+		log.Printf("Failed to save tool policy: %v", err)
+		h.recordPolicyChange(c, models.AuditPolicyUpdate, policy, models.AuditOutcomeError)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save tool policy",
+		})
+	}
+
+	h.recordPolicyChange(c, models.AuditPolicyUpdate, policy, models.AuditOutcomeSuccess)
+	return c.JSON(policy)
+}
+
+// Delete removes the policy of a scope
+func (h *PoliciesHandler) Delete(c *fiber.Ctx) error {
+	policy := &models.ToolPolicy{
+		Scope:   c.Params("scope"),
+		ScopeID: strings.TrimSpace(c.Params("scopeId")),
+	}
+	if err := services.ValidateToolPolicy(policy); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	existing, err := h.policies.Get(policy.Scope, policy.ScopeID)
+	if err != nil {
+		log.Printf("Failed to get tool policy: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete tool policy",
+		})
+	}
+	if existing == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Tool policy not found",
+		})
+	}
+
+	if err := h.policies.Delete(policy.Scope, policy.ScopeID); err != nil {
+		log.Printf("Failed to delete tool policy: %v", err)
+		h.recordPolicyChange(c, models.AuditPolicyDelete, existing, models.AuditOutcomeError)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete tool policy",
+		})
+	}
+
+	h.recordPolicyChange(c, models.AuditPolicyDelete, existing, models.AuditOutcomeSuccess)
+	return c.JSON(fiber.Map{
+		"message": "Tool policy deleted successfully",
+	})
+}
+
+// recordPolicyChange adds a policy change to the audit log
+func (h *PoliciesHandler) recordPolicyChange(c *fiber.Ctx, eventType string, policy *models.ToolPolicy, outcome string) {
+	event := models.AuditEvent{
+		EventType: eventType,
+		Actor:     RequestActor(c),
+		Target:    policy.Scope,
+		Details: services.AuditDetails(map[string]interface{}{
+			"scope_id":      policy.ScopeID,
+			"allowed_tools": policy.AllowedTools,
+			"denied_tools":  policy.DeniedTools,
+		}),
+		Outcome: outcome,
+	}
+	switch policy.Scope {
+	case models.PolicyScopeMachine:
+		event.MachineID = policy.ScopeID
+	case models.PolicyScopeSession:
+		event.SessionID = policy.ScopeID
+	}
+	h.audit.Record(event)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/services"
+)
+
+// RetentionHandler handles retention API endpoints
+type RetentionHandler struct {
+	retention *services.RetentionService
+	audit     *services.AuditService
+}
+
+// NewRetentionHandler creates a new RetentionHandler
+func NewRetentionHandler(retention *services.RetentionService, audit *services.AuditService) *RetentionHandler {
+	return &RetentionHandler{retention: retention, audit: audit}
+}
+
+// RetentionResponse represents the retention status API response
+type RetentionResponse struct {
+	Config     services.RetentionConfig  `json:"config"`
+	LastReport *services.RetentionReport `json:"last_report"` // null until the first run
+}
+
+// RegisterRoutes registers retention API routes
+// The rules are edited through the "retention" setting
+func (h *RetentionHandler) RegisterRoutes(app *fiber.App) {
+	app.Get("/api/retention", h.Status)
+	app.Post("/api/retention/run", h.Run)
+}
+
+// Status returns the retention rules and the report of the last cleanup
+func (h *RetentionHandler) Status(c *fiber.Ctx) error {
+	config, err := h.retention.Config()
+	if err != nil {
+		log.Printf("Failed to get retention rules: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get retention rules",
+		})
+	}
+
+	return c.JSON(RetentionResponse{Config: config, LastReport: h.retention.LastReport()})
+}
+
+// Run applies the retention rules immediately
+func (h *RetentionHandler) Run(c *fiber.Ctx) error {
+	report, err := h.retention.Apply()
+
+	event := models.AuditEvent{
+		EventType: models.AuditRetentionRun,
+		Actor:     RequestActor(c),
+		Outcome:   models.AuditOutcomeSuccess,
+	}
+	if err != nil || len(report.Errors) > 0 {
+		event.Outcome = models.AuditOutcomeError
+	}
+	if report != nil {
+		if details, err := json.Marshal(report); err == nil {
+			event.Details = string(details)
+		}
+	}
+	h.audit.Record(event)
+
+	if err != nil {
+		log.Printf("Failed to apply retention rules: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to apply retention rules",
+		})
+	}
+
+	return c.JSON(report)
+}
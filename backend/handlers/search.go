@@ -1,20 +1,24 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/ronan/home-agent/models"
 	"github.com/ronan/home-agent/repositories"
+	"github.com/ronan/home-agent/services"
 )
 
 // SearchHandler handles search-related API endpoints
 type SearchHandler struct {
-	search repositories.SearchRepository
+	search *services.SearchService
 }
 
 // NewSearchHandler creates a new SearchHandler
-func NewSearchHandler(search repositories.SearchRepository) *SearchHandler {
+func NewSearchHandler(search *services.SearchService) *SearchHandler {
 	return &SearchHandler{search: search}
 }
 
@@ -23,6 +27,8 @@ type SearchResponse struct {
 	Results []*models.SearchResult `json:"results"`
 	Total   int                    `json:"total"`
 	Query   string                 `json:"query"`
+	Mode    string                 `json:"mode"`
+	Sort    string                 `json:"sort"`
 	Limit   int                    `json:"limit"`
 	Offset  int                    `json:"offset"`
 }
@@ -30,9 +36,38 @@ type SearchResponse struct {
 // RegisterRoutes registers search API routes
 func (h *SearchHandler) RegisterRoutes(app *fiber.App) {
 	app.Get("/api/search", h.Search)
+	app.Get("/api/search/status", h.Status)
 }
 
-// Search handles GET /api/search?q=term&limit=20&offset=0
+// SearchStatusResponse describes the available search modes
+type SearchStatusResponse struct {
+	Modes    []string               `json:"modes"`
+	Semantic *models.EmbeddingStats `json:"semantic,omitempty"` // Index progress, when enabled
+}
+
+// Status handles GET /api/search/status
+func (h *SearchHandler) Status(c *fiber.Ctx) error {
+	response := SearchStatusResponse{Modes: []string{models.SearchModeKeyword}}
+	if !h.search.SemanticEnabled() {
+		return c.JSON(response)
+	}
+
+	stats, err := h.search.Stats()
+	if err != nil {
+		log.Printf("Failed to get semantic index stats: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get search status",
+		})
+	}
+
+	response.Modes = append(response.Modes, models.SearchModeSemantic, models.SearchModeHybrid)
+	response.Semantic = stats
+	return c.JSON(response)
+}
+
+// Search handles GET /api/search?q=term&mode=keyword|semantic|hybrid&type=message,tool_call,memory,session&match=words|substring&role=&session_id=&model=&since=&until=&sort=rank|date&limit=20&offset=0
+// q accepts "phrases", prefix*, OR, AND, NOT and parentheses; accents are ignored.
+// Semantic and hybrid modes also rank messages by embedding similarity.
 func (h *SearchHandler) Search(c *fiber.Ctx) error {
 	query := c.Query("q", "")
 	if query == "" {
@@ -41,18 +76,33 @@ func (h *SearchHandler) Search(c *fiber.Ctx) error {
 		})
 	}
 
-	// Parse pagination parameters
-	limit := c.QueryInt("limit", 20)
-	if limit < 1 || limit > 100 {
-		limit = 20
+	searchQuery, err := parseSearchQuery(c, query)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
 
-	offset := c.QueryInt("offset", 0)
-	if offset < 0 {
-		offset = 0
+	mode := c.Query("mode", models.SearchModeKeyword)
+	switch mode {
+	case models.SearchModeKeyword, models.SearchModeSemantic, models.SearchModeHybrid:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid 'mode' parameter: must be 'keyword', 'semantic' or 'hybrid'",
+		})
 	}
 
-	results, total, err := h.search.SearchMessages(query, limit, offset)
+	results, total, err := h.search.Search(c.Context(), mode, searchQuery)
+	if errors.Is(err, services.ErrSemanticSearchDisabled) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Semantic search is not configured",
+		})
+	}
+	if errors.Is(err, repositories.ErrInvalidSearchQuery) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid search query",
+		})
+	}
 	if err != nil {
 		log.Printf("Search failed: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -69,7 +119,86 @@ func (h *SearchHandler) Search(c *fiber.Ctx) error {
 		Results: results,
 		Total:   total,
 		Query:   query,
-		Limit:   limit,
-		Offset:  offset,
+		Mode:    mode,
+		Sort:    searchQuery.Sort,
+		Limit:   searchQuery.Limit,
+		Offset:  searchQuery.Offset,
 	})
 }
+
+// parseSearchQuery reads the search filters, sort and pagination from query parameters
+func parseSearchQuery(c *fiber.Ctx, text string) (models.SearchQuery, error) {
+	query := models.SearchQuery{
+		Text:      text,
+		Role:      c.Query("role"),
+		SessionID: c.Query("session_id"),
+		Model:     c.Query("model"),
+		Sort:      c.Query("sort", models.SearchSortRank),
+	}
+
+	switch c.Query("match", "words") {
+	case "words":
+	case "substring":
+		// The trigram index cannot match fewer than 3 characters
+		if len([]rune(strings.TrimSpace(text))) < 3 {
+			return query, fmt.Errorf("substring search requires at least 3 characters")
+		}
+		query.Substring = true
+	default:
+		return query, fmt.Errorf("invalid 'match' parameter: must be 'words' or 'substring'")
+	}
+
+	if query.Role != "" && query.Role != "user" && query.Role != "assistant" && query.Role != "thinking" {
+		return query, fmt.Errorf("invalid 'role' parameter: must be 'user', 'assistant' or 'thinking'")
+	}
+	if query.Sort != models.SearchSortRank && query.Sort != models.SearchSortDate {
+		return query, fmt.Errorf("invalid 'sort' parameter: must be 'rank' or 'date'")
+	}
+
+	if types := c.Query("type"); types != "" {
+		for _, searchType := range strings.Split(types, ",") {
+			searchType = strings.TrimSpace(searchType)
+			if !isSearchType(searchType) {
+				return query, fmt.Errorf("invalid 'type' parameter: must be one of %s", strings.Join(models.SearchTypes, ", "))
+			}
+			query.Types = append(query.Types, searchType)
+		}
+	}
+
+	if since := c.Query("since"); since != "" {
+		t, err := parseQueryTime(since)
+		if err != nil {
+			return query, fmt.Errorf("invalid 'since' parameter: %w", err)
+		}
+		query.Since = &t
+	}
+	if until := c.Query("until"); until != "" {
+		t, err := parseQueryTime(until)
+		if err != nil {
+			return query, fmt.Errorf("invalid 'until' parameter: %w", err)
+		}
+		query.Until = &t
+	}
+
+	// Parse pagination parameters
+	query.Limit = c.QueryInt("limit", 20)
+	if query.Limit < 1 || query.Limit > 100 {
+		query.Limit = 20
+	}
+
+	query.Offset = c.QueryInt("offset", 0)
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+
+	return query, nil
+}
+
+func isSearchType(name string) bool {
+	for _, searchType := range models.SearchTypes {
+		if searchType == name {
+			return true
+		}
+	}
+	return false
+}
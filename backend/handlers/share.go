@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"bytes"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/repositories"
+	"github.com/ronan/home-agent/services"
+)
+
+// maxShareExpiryHours bounds the lifetime of an expiring share link (one year)
+const maxShareExpiryHours = 24 * 365
+
+// shareCSP only allows the inline styles and data URI images of the rendered transcript
+const shareCSP = "default-src 'none'; style-src 'unsafe-inline'; img-src data:; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+
+// ShareHandler handles share link endpoints
+type ShareHandler struct {
+	sessions repositories.SessionRepository
+	shares   *services.ShareService
+	audit    *services.AuditService
+}
+
+// NewShareHandler creates a new ShareHandler
+func NewShareHandler(sessions repositories.SessionRepository, shares *services.ShareService, audit *services.AuditService) *ShareHandler {
+	return &ShareHandler{sessions: sessions, shares: shares, audit: audit}
+}
+
+// RegisterRoutes registers share routes
+// /share/:token is public: the reverse proxy must let it through without authentication
+func (h *ShareHandler) RegisterRoutes(app *fiber.App) {
+	app.Get("/api/sessions/:id/shares", h.List)
+	app.Post("/api/sessions/:id/shares", h.Create)
+	app.Delete("/api/shares/:id", h.Revoke)
+	app.Get("/share/:token", h.View)
+}
+
+// CreateShareRequest represents the request body for creating a share link
+type CreateShareRequest struct {
+	HideToolOutputs bool `json:"hide_tool_outputs"`
+	HideThinking    bool `json:"hide_thinking"`
+	ExpiresInHours  int  `json:"expires_in_hours"` // 0 for a link that never expires
+}
+
+// ShareResponse is a share link with its relative URL
+type ShareResponse struct {
+	*models.SessionShare
+	URL     string `json:"url"`
+	Expired bool   `json:"expired"`
+}
+
+// newShareResponse adds the URL and expiry state to a share link
+func newShareResponse(share *models.SessionShare) ShareResponse {
+	return ShareResponse{
+		SessionShare: share,
+		URL:          "/share/" + share.Token,
+		Expired:      share.Expired(time.Now()),
+	}
+}
+
+// List returns the share links of a session
+func (h *ShareHandler) List(c *fiber.Ctx) error {
+	shares, err := h.shares.List(c.Params("id"))
+	if err != nil {
+		log.Printf("Failed to list shares: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list shares",
+		})
+	}
+
+	response := make([]ShareResponse, 0, len(shares))
+	for _, share := range shares {
+		response = append(response, newShareResponse(share))
+	}
+	return c.JSON(response)
+}
+
+// Create creates a share link for a session
+func (h *ShareHandler) Create(c *fiber.Ctx) error {
+	sessionID := c.Params("id")
+
+	var req CreateShareRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+	if req.ExpiresInHours < 0 || req.ExpiresInHours > maxShareExpiryHours {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "expires_in_hours must be between 0 and " + strconv.Itoa(maxShareExpiryHours),
+		})
+	}
+
+	session, err := h.sessions.Get(sessionID)
+	if err != nil {
+		log.Printf("Failed to get session: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create share",
+		})
+	}
+	if session == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Session not found",
+		})
+	}
+
+	share, err := h.shares.Create(sessionID, RequestActor(c), services.ShareOptions{
+		HideToolOutputs: req.HideToolOutputs,
+		HideThinking:    req.HideThinking,
+		ExpiresIn:       time.Duration(req.ExpiresInHours) * time.Hour,
+	})
+	if err != nil {
+		log.Printf("Failed to create share: %v", err)
+		h.recordShareChange(c, models.AuditShareCreate, sessionID, 0, models.AuditOutcomeError)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create share",
+		})
+	}
+
+	h.recordShareChange(c, models.AuditShareCreate, sessionID, share.ID, models.AuditOutcomeSuccess)
+	return c.Status(fiber.StatusCreated).JSON(newShareResponse(share))
+}
+
+// Revoke deletes a share link; its URL stops working immediately
+func (h *ShareHandler) Revoke(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid share ID",
+		})
+	}
+
+	share, err := h.shares.Get(id)
+	if err != nil {
+		log.Printf("Failed to get share: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke share",
+		})
+	}
+	if share == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Share not found",
+		})
+	}
+
+	if err := h.shares.Revoke(id); err != nil {
+		log.Printf("Failed to revoke share: %v", err)
+		h.recordShareChange(c, models.AuditShareRevoke, share.SessionID, id, models.AuditOutcomeError)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke share",
+		})
+	}
+
+	h.recordShareChange(c, models.AuditShareRevoke, share.SessionID, id, models.AuditOutcomeSuccess)
+	return c.JSON(fiber.Map{"deleted": id})
+}
+
+// View serves the read-only transcript of a share link
+// Unknown, revoked and expired links all answer 404
+func (h *ShareHandler) View(c *fiber.Ctx) error {
+	c.Set("Cache-Control", "no-store")
+	c.Set("X-Robots-Tag", "noindex, nofollow")
+	c.Set("Referrer-Policy", "no-referrer")
+	c.Set("X-Content-Type-Options", "nosniff")
+	c.Set("Content-Security-Policy", shareCSP)
+
+	share, err := h.shares.Resolve(c.Params("token"))
+	if err != nil {
+		log.Printf("Failed to resolve share: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Internal error")
+	}
+	if share == nil {
+		return c.Status(fiber.StatusNotFound).SendString("This link does not exist or has expired")
+	}
+
+	var buf bytes.Buffer
+	found, err := h.shares.Render(&buf, share)
+	if err != nil {
+		log.Printf("Failed to render share: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Internal error")
+	}
+	if !found {
+		return c.Status(fiber.StatusNotFound).SendString("This link does not exist or has expired")
+	}
+
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	return c.Send(buf.Bytes())
+}
+
+// recordShareChange adds a share link change to the audit log
+func (h *ShareHandler) recordShareChange(c *fiber.Ctx, eventType, sessionID string, shareID int, outcome string) {
+	event := models.AuditEvent{
+		EventType: eventType,
+		Actor:     RequestActor(c),
+		SessionID: sessionID,
+		Outcome:   outcome,
+	}
+	if shareID > 0 {
+		event.Target = strconv.Itoa(shareID)
+	}
+	h.audit.Record(event)
+}
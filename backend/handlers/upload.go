@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -10,12 +12,16 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/services"
 )
 
 // UploadHandler handles file uploads
 type UploadHandler struct {
-	uploadDir string
-	maxSize   int64 // Max file size in bytes
+	uploadDir   string
+	attachments *services.AttachmentService
+	images      *services.ImageService
+	policy      *services.UploadPolicy
 }
 
 // UploadResponse represents the response after a successful upload
@@ -26,76 +32,28 @@ type UploadResponse struct {
 	Type     string `json:"type"` // "image" or "file"
 	Size     int64  `json:"size"`
 	MimeType string `json:"mime_type"`
+	Checksum string `json:"checksum"` // SHA-256, hex encoded
+	// Files of zip, tar and tar.gz uploads
+	Archive *services.ArchiveListing `json:"archive,omitempty"`
 }
 
 // NewUploadHandler creates a new UploadHandler
-func NewUploadHandler(uploadDir string) *UploadHandler {
+func NewUploadHandler(uploadDir string, attachments *services.AttachmentService, images *services.ImageService, policy *services.UploadPolicy) *UploadHandler {
 	return &UploadHandler{
-		uploadDir: uploadDir,
-		maxSize:   10 * 1024 * 1024, // 10MB default
+		uploadDir:   uploadDir,
+		attachments: attachments,
+		images:      images,
+		policy:      policy,
 	}
 }
 
-// AllowedMimeTypes defines allowed file types
-var AllowedMimeTypes = map[string]string{
-	// Images
-	"image/png":  "image",
-	"image/jpeg": "image",
-	"image/jpg":  "image",
-	"image/gif":  "image",
-	"image/webp": "image",
-	// Documents
-	"application/pdf":  "file",
-	"text/plain":       "file",
-	"text/markdown":    "file",
-	"application/json": "file",
-	"text/csv":         "file",
-	"application/xml":  "file",
-	"text/xml":         "file",
-	"text/yaml":        "file",
-	"application/x-yaml": "file",
-	// Code files (often sent as text/plain or application/octet-stream)
-	"text/html":       "file",
-	"text/css":        "file",
-	"text/javascript": "file",
-	"application/javascript": "file",
-}
-
-// AllowedExtensions for files that might not have proper MIME types
-var AllowedExtensions = map[string]string{
-	".png":  "image",
-	".jpg":  "image",
-	".jpeg": "image",
-	".gif":  "image",
-	".webp": "image",
-	".pdf":  "file",
-	".txt":  "file",
-	".md":   "file",
-	".json": "file",
-	".csv":  "file",
-	".xml":  "file",
-	".yaml": "file",
-	".yml":  "file",
-	".html": "file",
-	".css":  "file",
-	".js":   "file",
-	".ts":   "file",
-	".go":   "file",
-	".py":   "file",
-	".rs":   "file",
-	".java": "file",
-	".c":    "file",
-	".cpp":  "file",
-	".h":    "file",
-	".sh":   "file",
-	".sql":  "file",
-	".log":  "file",
-}
-
 // RegisterRoutes registers upload routes
 func (h *UploadHandler) RegisterRoutes(app *fiber.App) {
 	app.Post("/api/upload", h.HandleUpload)
 	app.Get("/api/uploads/:filename", h.ServeFile)
+	app.Get("/api/uploads/:filename/contents", h.ListArchive)
+	app.Get("/api/uploads/:filename/thumbnail", h.ServeThumbnail)
+	app.Get("/api/uploads/:filename/original", h.ServeOriginal)
 	app.Delete("/api/uploads/:id", h.DeleteFile)
 }
 
@@ -109,38 +67,80 @@ func (h *UploadHandler) HandleUpload(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check file size
-	if file.Size > h.maxSize {
+	// The name, or the declared MIME type, selects the type; the content must match it
+	uploadType, ok := services.LookupFileType(file.Filename, file.Header.Get("Content-Type"))
+	if !ok {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": fmt.Sprintf("File too large. Maximum size is %d MB", h.maxSize/(1024*1024)),
+			"error": "File type not allowed",
 		})
 	}
 
-	// Get MIME type and validate
-	mimeType := file.Header.Get("Content-Type")
-	ext := strings.ToLower(filepath.Ext(file.Filename))
-
-	// Determine file type (image or file)
-	fileType := ""
+	// Check file size against the limit of its category
+	config, err := h.policy.Config()
+	if err != nil {
+		log.Printf("Failed to read upload limits: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save file",
+		})
+	}
+	if maxSize := config.MaxSize(uploadType.Category); file.Size > maxSize {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("File too large. Maximum size for %s files is %d MB", uploadType.Category, maxSize/(1024*1024)),
+		})
+	}
 
-	// First try MIME type
-	if t, ok := AllowedMimeTypes[mimeType]; ok {
-		fileType = t
+	ext, mimeType := uploadType.Ext, uploadType.MimeType
+	fileType := "file"
+	if uploadType.IsImage() {
+		fileType = "image"
 	}
 
-	// Fall back to extension if MIME type not recognized
-	if fileType == "" {
-		if t, ok := AllowedExtensions[ext]; ok {
-			fileType = t
-		}
+	src, err := file.Open()
+	if err != nil {
+		log.Printf("Failed to open uploaded file: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save file",
+		})
 	}
+	defer src.Close()
 
-	// Reject if type is not recognized
-	if fileType == "" {
+	// Check the magic bytes: a script renamed photo.png is refused
+	header := make([]byte, services.SniffLength)
+	n, err := io.ReadFull(src, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		log.Printf("Failed to read uploaded file: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save file",
+		})
+	}
+	header = header[:n]
+	if err := services.CheckContent(uploadType, header); err != nil {
+		log.Printf("Rejected upload %s: %v", file.Filename, err)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "File type not allowed",
+			"error": fmt.Sprintf("File content does not match its type (%s)", uploadType.Ext),
 		})
 	}
+	content := io.MultiReader(bytes.NewReader(header), src)
+
+	// Images are stored downscaled and without metadata; HEIC becomes JPEG
+	var original []byte
+	var processed *services.ProcessedImage
+	if fileType == "image" {
+		if original, err = io.ReadAll(content); err != nil {
+			log.Printf("Failed to read uploaded file: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to save file",
+			})
+		}
+		if processed, err = h.images.Process(original); err != nil {
+			log.Printf("Rejected image %s: %v", file.Filename, err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid image: %v", err),
+			})
+		}
+		ext, mimeType = processed.Ext, processed.MimeType
+		content = bytes.NewReader(processed.Data)
+	}
 
 	// Generate unique ID for the file
 	fileID := uuid.New().String()
@@ -148,38 +148,80 @@ func (h *UploadHandler) HandleUpload(c *fiber.Ctx) error {
 	// Create filename using full GUID + extension (avoid collisions)
 	safeFilename := fmt.Sprintf("%s%s", fileID, ext)
 
-	// Ensure upload directory exists
-	if err := os.MkdirAll(h.uploadDir, 0755); err != nil {
-		log.Printf("Failed to create upload directory: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to save file",
-		})
+	// Save the file (directly in uploadDir, no session subdirectory) and record it
+	attachment := &models.Attachment{
+		UploadID:   fileID,
+		Filename:   file.Filename,
+		StoredName: safeFilename,
+		Type:       fileType,
+		MimeType:   mimeType,
 	}
-
-	// Full path for the file (directly in uploadDir, no session subdirectory)
-	filePath := filepath.Join(h.uploadDir, safeFilename)
-
-	// Save the file
-	if err := c.SaveFile(file, filePath); err != nil {
+	if err := h.attachments.Save(attachment, content); err != nil {
 		log.Printf("Failed to save file: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to save file",
 		})
 	}
+	if processed != nil {
+		if err := h.images.SaveDerived(safeFilename, original, processed); err != nil {
+			log.Printf("Failed to save thumbnail of %s: %v", safeFilename, err)
+		}
+	}
+
+	// List archives now so unreadable ones and zip bombs are refused
+	var listing *services.ArchiveListing
+	if services.IsArchive(safeFilename) {
+		listing, err = services.ListArchive(filepath.Join(h.uploadDir, safeFilename))
+		if err != nil {
+			log.Printf("Rejected archive %s: %v", file.Filename, err)
+			os.Remove(filepath.Join(h.uploadDir, safeFilename))
+			if err := h.attachments.DeletePending(fileID); err != nil {
+				log.Printf("Failed to delete attachment record: %v", err)
+			}
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid archive: %v", err),
+			})
+		}
+	}
 
-	log.Printf("File uploaded: %s (type: %s, size: %d)", safeFilename, fileType, file.Size)
+	log.Printf("File uploaded: %s (type: %s, size: %d)", safeFilename, fileType, attachment.Size)
 
 	// Return upload response
 	return c.JSON(UploadResponse{
 		ID:       fileID,
 		Filename: file.Filename,
-		Path:     fmt.Sprintf("/api/uploads/%s", safeFilename),
+		Path:     attachment.Path,
 		Type:     fileType,
-		Size:     file.Size,
+		Size:     attachment.Size,
 		MimeType: mimeType,
+		Checksum: attachment.Checksum,
+		Archive:  listing,
 	})
 }
 
+// ListArchive lists the files of an uploaded archive with their sizes
+func (h *UploadHandler) ListArchive(c *fiber.Ctx) error {
+	filename := filepath.Base(c.Params("filename"))
+	if !services.IsArchive(filename) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Not an archive",
+		})
+	}
+
+	listing, err := services.ListArchive(filepath.Join(h.uploadDir, filename))
+	if errors.Is(err, os.ErrNotExist) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "File not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Invalid archive: %v", err),
+		})
+	}
+	return c.JSON(listing)
+}
+
 // ServeFile serves uploaded files
 func (h *UploadHandler) ServeFile(c *fiber.Ctx) error {
 	filename := c.Params("filename")
@@ -200,9 +242,55 @@ func (h *UploadHandler) ServeFile(c *fiber.Ctx) error {
 		})
 	}
 
+	return sendUpload(c, filePath)
+}
+
+// sendUpload serves a stored file with the type of its extension only. HTML,
+// SVG and scripts would run in the app's origin if displayed: they are
+// downloaded, sandboxed should a browser render them anyway.
+func sendUpload(c *fiber.Ctx, filePath string) error {
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	if services.IsActiveContent(filePath) {
+		c.Set(fiber.HeaderContentSecurityPolicy, "default-src 'none'; sandbox")
+		c.Attachment(filepath.Base(filePath))
+	}
 	return c.SendFile(filePath)
 }
 
+// ServeThumbnail serves the thumbnail of an uploaded image, or the image
+// itself when it cannot be decoded (animated WebP)
+func (h *UploadHandler) ServeThumbnail(c *fiber.Ctx) error {
+	filename := filepath.Base(c.Params("filename"))
+	filePath := filepath.Join(h.uploadDir, filename)
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "File not found",
+		})
+	}
+
+	thumbnail, err := h.images.Thumbnail(filename)
+	if err != nil {
+		log.Printf("No thumbnail for %s: %v", filename, err)
+		return sendUpload(c, filePath)
+	}
+	return c.SendFile(thumbnail)
+}
+
+// ServeOriginal downloads an uploaded image as it was sent, when it was kept
+func (h *UploadHandler) ServeOriginal(c *fiber.Ctx) error {
+	filename := filepath.Base(c.Params("filename"))
+	originalPath := services.OriginalPath(h.uploadDir, filename)
+	if _, err := os.Stat(originalPath); os.IsNotExist(err) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Original not kept",
+		})
+	}
+
+	// The original may hold location metadata: download it rather than display it
+	c.Attachment(filename)
+	return sendUpload(c, originalPath)
+}
+
 // DeleteFile deletes an uploaded file
 func (h *UploadHandler) DeleteFile(c *fiber.Ctx) error {
 	fileID := c.Params("id")
@@ -225,6 +313,12 @@ func (h *UploadHandler) DeleteFile(c *fiber.Ctx) error {
 					"error": "Failed to delete file",
 				})
 			}
+			if err := h.attachments.DeletePending(fileID); err != nil {
+				log.Printf("Failed to delete attachment record: %v", err)
+			}
+			for _, path := range services.DerivedFiles(h.uploadDir, file.Name()) {
+				os.Remove(path)
+			}
 			log.Printf("File deleted: %s", filePath)
 			return c.JSON(fiber.Map{"deleted": fileID})
 		}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/repositories"
+	"github.com/ronan/home-agent/services"
+)
+
+// UsageHandler handles usage and budget API endpoints
+type UsageHandler struct {
+	budgets *services.BudgetService
+	usage   repositories.UsageRepository
+}
+
+// NewUsageHandler creates a new UsageHandler
+func NewUsageHandler(budgets *services.BudgetService, usage repositories.UsageRepository) *UsageHandler {
+	return &UsageHandler{budgets: budgets, usage: usage}
+}
+
+// UsageResponse represents the usage aggregation API response
+type UsageResponse struct {
+	GroupBy string                   `json:"group_by"`
+	Totals  *models.UsageAggregate   `json:"totals"`
+	Groups  []*models.UsageAggregate `json:"groups"`
+}
+
+// UsageEventsResponse represents the usage events API response
+type UsageEventsResponse struct {
+	Events []*models.UsageEvent `json:"events"`
+	Total  int                  `json:"total"`
+	Limit  int                  `json:"limit"`
+	Offset int                  `json:"offset"`
+}
+
+// BudgetResponse represents the budget API response
+type BudgetResponse struct {
+	Budgets []services.BudgetStatus `json:"budgets"`
+	Blocked bool                    `json:"blocked"` // At least one hard limit is reached
+}
+
+// RegisterRoutes registers usage API routes
+func (h *UsageHandler) RegisterRoutes(app *fiber.App) {
+	app.Get("/api/usage", h.Aggregate)
+	app.Get("/api/usage/events", h.Events)
+	app.Get("/api/usage/budget", h.Budget)
+}
+
+// Aggregate handles GET /api/usage?group_by=day|week|model|session|user&since=&until=&model=&user=&session_id=&format=json|csv
+func (h *UsageHandler) Aggregate(c *fiber.Ctx) error {
+	filter, err := parseUsageFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	groupBy := c.Query("group_by", models.UsageGroupDay)
+	switch groupBy {
+	case models.UsageGroupDay, models.UsageGroupWeek, models.UsageGroupModel, models.UsageGroupSession, models.UsageGroupUser:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid 'group_by' parameter: must be 'day', 'week', 'model', 'session' or 'user'",
+		})
+	}
+
+	groups, err := h.usage.Aggregate(filter, groupBy)
+	if err != nil {
+		log.Printf("Failed to aggregate usage: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to aggregate usage",
+		})
+	}
+	totals, err := h.usage.Aggregate(filter, "")
+	if err != nil {
+		log.Printf("Failed to aggregate usage: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to aggregate usage",
+		})
+	}
+
+	total := &models.UsageAggregate{Key: "total"}
+	if len(totals) > 0 {
+		total = totals[0]
+	}
+
+	switch c.Query("format", "json") {
+	case "json":
+		return c.JSON(UsageResponse{GroupBy: groupBy, Totals: total, Groups: groups})
+
+	case "csv":
+		setUsageCSVHeaders(c, "usage-"+groupBy)
+
+		w := csv.NewWriter(c)
+		if err := w.Write([]string{groupBy, "label", "turns", "input_tokens", "output_tokens", "cache_creation_input_tokens", "cache_read_input_tokens", "total_cost_usd", "duration_ms", "tool_calls"}); err != nil {
+			return err
+		}
+		for _, g := range groups {
+			err := w.Write([]string{
+				g.Key,
+				g.Label,
+				strconv.Itoa(g.Turns),
+				strconv.Itoa(g.InputTokens),
+				strconv.Itoa(g.OutputTokens),
+				strconv.Itoa(g.CacheCreationInputTokens),
+				strconv.Itoa(g.CacheReadInputTokens),
+				strconv.FormatFloat(g.TotalCostUSD, 'f', 6, 64),
+				strconv.FormatInt(g.DurationMS, 10),
+				strconv.Itoa(g.ToolCalls),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		return w.Error()
+
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid format. Must be one of: json, csv",
+		})
+	}
+}
+
+// Events handles GET /api/usage/events?sort=recent|cost|duration|tokens&limit=50&offset=0&format=json|csv
+// with the same filters as Aggregate. The CSV export ignores limit and offset.
+func (h *UsageHandler) Events(c *fiber.Ctx) error {
+	filter, err := parseUsageFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	sort := c.Query("sort", models.UsageSortRecent)
+	switch sort {
+	case models.UsageSortRecent, models.UsageSortCost, models.UsageSortDuration, models.UsageSortTokens:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid 'sort' parameter: must be 'recent', 'cost', 'duration' or 'tokens'",
+		})
+	}
+
+	format := c.Query("format", "json")
+	if format != "json" && format != "csv" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid format. Must be one of: json, csv",
+		})
+	}
+
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+	if format == "csv" {
+		limit, offset = 0, 0
+	}
+
+	events, total, err := h.usage.List(filter, sort, limit, offset)
+	if err != nil {
+		log.Printf("Failed to list usage events: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list usage events",
+		})
+	}
+
+	if format == "json" {
+		return c.JSON(UsageEventsResponse{Events: events, Total: total, Limit: limit, Offset: offset})
+	}
+
+	setUsageCSVHeaders(c, "usage-events")
+
+	w := csv.NewWriter(c)
+	if err := w.Write([]string{"id", "created_at", "session_id", "actor", "model", "input_tokens", "output_tokens", "cache_creation_input_tokens", "cache_read_input_tokens", "total_cost_usd", "duration_ms", "tool_calls"}); err != nil {
+		return err
+	}
+	for _, e := range events {
+		err := w.Write([]string{
+			strconv.Itoa(e.ID),
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			e.SessionID,
+			e.Actor,
+			e.Model,
+			strconv.Itoa(e.InputTokens),
+			strconv.Itoa(e.OutputTokens),
+			strconv.Itoa(e.CacheCreationInputTokens),
+			strconv.Itoa(e.CacheReadInputTokens),
+			strconv.FormatFloat(e.TotalCostUSD, 'f', 6, 64),
+			strconv.FormatInt(e.DurationMS, 10),
+			strconv.Itoa(e.ToolCalls),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// Budget returns the consumption of every configured limit over its current period
+func (h *UsageHandler) Budget(c *fiber.Ctx) error {
+	statuses, err := h.budgets.Status()
+	if err != nil {
+		log.Printf("Failed to get budget status: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get budget status",
+		})
+	}
+
+	response := BudgetResponse{Budgets: statuses}
+	for _, status := range statuses {
+		if status.State == services.BudgetStateExceeded {
+			response.Blocked = true
+		}
+	}
+
+	// Return empty array if no budgets
+	if response.Budgets == nil {
+		response.Budgets = []services.BudgetStatus{}
+	}
+
+	return c.JSON(response)
+}
+
+// parseUsageFilter reads the usage filter from query parameters
+func parseUsageFilter(c *fiber.Ctx) (models.UsageFilter, error) {
+	filter := models.UsageFilter{
+		Actor:     c.Query("user"),
+		Model:     c.Query("model"),
+		SessionID: c.Query("session_id"),
+	}
+
+	if since := c.Query("since"); since != "" {
+		t, err := parseQueryTime(since)
+		if err != nil {
+			return filter, fmt.Errorf("invalid 'since' parameter: %w", err)
+		}
+		filter.Since = &t
+	}
+
+	if until := c.Query("until"); until != "" {
+		t, err := parseQueryTime(until)
+		if err != nil {
+			return filter, fmt.Errorf("invalid 'until' parameter: %w", err)
+		}
+		filter.Until = &t
+	}
+
+	return filter, nil
+}
+
+// setUsageCSVHeaders marks the response as a CSV download
+func setUsageCSVHeaders(c *fiber.Ctx, name string) {
+	filename := fmt.Sprintf("%s-%s.csv", name, time.Now().Format("20060102-150405"))
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+}
@@ -3,47 +3,57 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/services"
 )
 
 // WebSocketHandler handles WebSocket connections
 type WebSocketHandler struct {
 	chatHandler *ChatHandler
+	audit       *services.AuditService
 }
 
 // NewWebSocketHandler creates a new WebSocketHandler instance
-func NewWebSocketHandler(chatHandler *ChatHandler) *WebSocketHandler {
+func NewWebSocketHandler(chatHandler *ChatHandler, audit *services.AuditService) *WebSocketHandler {
 	return &WebSocketHandler{
 		chatHandler: chatHandler,
+		audit:       audit,
 	}
 }
 
 // Attachment represents a file attachment in a message
 type Attachment struct {
-	ID       string `json:"id"`
-	Filename string `json:"filename"`
-	Path     string `json:"path"`
-	Type     string `json:"type"` // "image" or "file"
-	MimeType string `json:"mime_type,omitempty"`
+	ID       string   `json:"id"`
+	Filename string   `json:"filename"`
+	Path     string   `json:"path"`
+	Type     string   `json:"type"` // "image" or "file"
+	MimeType string   `json:"mime_type,omitempty"`
+	Inline   []string `json:"inline,omitempty"`  // Archive files to include in the prompt
+	Extract  []string `json:"extract,omitempty"` // Archive files to extract into the session's workspace
 }
 
 // ClientMessage represents a message from the WebSocket client
 type ClientMessage struct {
-	Type        string       `json:"type"`                  // "message", "ping", "history"
+	Type        string       `json:"type"`                  // "message", "edit", "ping", "history"
 	Content     string       `json:"content,omitempty"`     // Message content
 	SessionID   string       `json:"sessionId,omitempty"`   // Optional session ID
 	Model       string       `json:"model,omitempty"`       // Claude model: haiku, sonnet, opus
 	Attachments []Attachment `json:"attachments,omitempty"` // File attachments
 	Thinking    bool         `json:"thinking,omitempty"`    // Enable extended thinking mode
 	MachineID   string       `json:"machineId,omitempty"`   // Target SSH machine ID
+	ApprovalID  string       `json:"approvalId,omitempty"`  // Approval request being answered
+	Decision    string       `json:"decision,omitempty"`    // Approval decision: "allow" or "deny"
+	MessageID   int          `json:"messageId,omitempty"`   // User message replaced by an edit
 }
 
 // ServerMessage represents a message sent to the WebSocket client
 type ServerMessage struct {
-	Type      string `json:"type"`                // "chunk", "thinking", "thinking_end", "done", "error", "pong", "history", "session_id", "session_title", "tool_start", "tool_progress", "tool_result", "tool_error"
+	Type      string `json:"type"`                // "chunk", "thinking", "thinking_end", "done", "error", "pong", "history", "session_id", "session_title", "tool_start", "tool_progress", "tool_result", "tool_error", "approval_request", "approval_resolved", "budget_warning", "compacted", "forked"
 	Content   string `json:"content,omitempty"`   // Message content
 	SessionID string `json:"sessionId,omitempty"` // Session ID
 	Title     string `json:"title,omitempty"`     // Session title (for session_title type)
@@ -54,6 +64,22 @@ type ServerMessage struct {
 	ElapsedTimeSeconds float64   `json:"elapsedTimeSeconds,omitempty"`
 	ToolOutput         string    `json:"toolOutput,omitempty"`
 	IsError            bool      `json:"isError,omitempty"`
+	// Approval workflow
+	Approval *ApprovalInfo `json:"approval,omitempty"`
+}
+
+// wsConn serializes writes to a WebSocket connection, since responses are
+// streamed from a goroutine while the read loop keeps running
+type wsConn struct {
+	*websocket.Conn
+	mu sync.Mutex
+}
+
+// WriteMessage writes a message, one writer at a time
+func (c *wsConn) WriteMessage(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn.WriteMessage(messageType, data)
 }
 
 // UpgradeMiddleware checks if the request should be upgraded to WebSocket
@@ -69,8 +95,17 @@ func (wsh *WebSocketHandler) UpgradeMiddleware() fiber.Handler {
 }
 
 // HandleWebSocket handles WebSocket connections
-func (wsh *WebSocketHandler) HandleWebSocket(c *websocket.Conn) {
+func (wsh *WebSocketHandler) HandleWebSocket(conn *websocket.Conn) {
+	c := &wsConn{Conn: conn}
 	clientAddr := c.RemoteAddr().String()
+	actor := connActor(conn)
+
+	// Home Agent has no login screen: opening the chat socket is the closest equivalent
+	wsh.audit.Record(models.AuditEvent{
+		EventType: models.AuditLogin,
+		Actor:     actor,
+		Details:   services.AuditDetails(map[string]interface{}{"remote_addr": clientAddr, "user_agent": c.Headers("User-Agent")}),
+	})
 
 	// Set up connection parameters
 	c.SetReadDeadline(time.Now().Add(60 * time.Second))
@@ -86,6 +121,9 @@ func (wsh *WebSocketHandler) HandleWebSocket(c *websocket.Conn) {
 	// Channel to signal when to close
 	done := make(chan struct{})
 
+	// Tracks the streaming goroutines so they finish before the connection is released
+	var streams sync.WaitGroup
+
 	// Start goroutine to send pings
 	go func() {
 		for {
@@ -126,7 +164,25 @@ func (wsh *WebSocketHandler) HandleWebSocket(c *websocket.Conn) {
 		// Handle different message types
 		switch clientMsg.Type {
 		case "message":
-			wsh.handleChatMessage(c, clientMsg, clientAddr)
+			// Stream in the background so approval responses can still be read
+			streams.Add(1)
+			go func(msg ClientMessage) {
+				defer streams.Done()
+				wsh.handleChatMessage(c, msg, actor)
+			}(clientMsg)
+
+		case "edit":
+			// Same as a message, sent in a fork of the session before the edited message
+			streams.Add(1)
+			go func(msg ClientMessage) {
+				defer streams.Done()
+				wsh.handleEditMessage(c, msg, actor)
+			}(clientMsg)
+
+		case "approval_response":
+			if err := wsh.chatHandler.ResolveApproval(clientMsg.ApprovalID, clientMsg.Decision); err != nil {
+				wsh.sendError(c, err.Error())
+			}
 
 		case "ping":
 			wsh.sendPong(c)
@@ -141,10 +197,11 @@ func (wsh *WebSocketHandler) HandleWebSocket(c *websocket.Conn) {
 
 	// Clean up
 	close(done)
+	streams.Wait()
 }
 
 // handleChatMessage processes a chat message from the client
-func (wsh *WebSocketHandler) handleChatMessage(c *websocket.Conn, clientMsg ClientMessage, clientAddr string) {
+func (wsh *WebSocketHandler) handleChatMessage(c *wsConn, clientMsg ClientMessage, actor string) {
 	// Convert attachments
 	attachments := make([]MessageAttachment, len(clientMsg.Attachments))
 	for i, a := range clientMsg.Attachments {
@@ -154,6 +211,8 @@ func (wsh *WebSocketHandler) handleChatMessage(c *websocket.Conn, clientMsg Clie
 			Path:     a.Path,
 			Type:     a.Type,
 			MimeType: a.MimeType,
+			Inline:   a.Inline,
+			Extract:  a.Extract,
 		}
 	}
 
@@ -165,6 +224,7 @@ func (wsh *WebSocketHandler) handleChatMessage(c *websocket.Conn, clientMsg Clie
 		Attachments: attachments,
 		Thinking:    clientMsg.Thinking,
 		MachineID:   clientMsg.MachineID,
+		Actor:       actor,
 	}
 
 	// Create context with timeout
@@ -234,6 +294,25 @@ func (wsh *WebSocketHandler) handleChatMessage(c *websocket.Conn, clientMsg Clie
 				ToolOutput:         response.ToolOutput,
 				IsError:            response.IsError,
 			}
+
+		case "budget_warning":
+			serverMsg = ServerMessage{
+				Type:    "budget_warning",
+				Content: response.Content,
+			}
+
+		case "compacted":
+			serverMsg = ServerMessage{
+				Type:      "compacted",
+				SessionID: response.SessionID,
+				Content:   response.Content,
+			}
+
+		case "approval_request", "approval_resolved":
+			serverMsg = ServerMessage{
+				Type:     response.Type,
+				Approval: response.Approval,
+			}
 		}
 
 		// Send to client
@@ -243,8 +322,31 @@ func (wsh *WebSocketHandler) handleChatMessage(c *websocket.Conn, clientMsg Clie
 	}
 }
 
+// handleEditMessage forks the session before the edited user message and sends
+// the new content in the fork
+func (wsh *WebSocketHandler) handleEditMessage(c *wsConn, clientMsg ClientMessage, actor string) {
+	if clientMsg.SessionID == "" || clientMsg.MessageID == 0 {
+		wsh.sendError(c, "Session ID and message ID are required to edit a message")
+		return
+	}
+
+	fork, err := wsh.chatHandler.ForkForEdit(clientMsg.SessionID, clientMsg.MessageID)
+	if err != nil {
+		wsh.sendError(c, err.Error())
+		return
+	}
+
+	// The client switches to the fork before its response streams in
+	if err := wsh.sendMessage(c, ServerMessage{Type: "forked", SessionID: fork.SessionID}); err != nil {
+		return
+	}
+
+	clientMsg.SessionID = fork.SessionID
+	wsh.handleChatMessage(c, clientMsg, actor)
+}
+
 // handleHistory retrieves and sends conversation history
-func (wsh *WebSocketHandler) handleHistory(c *websocket.Conn, clientMsg ClientMessage, clientAddr string) {
+func (wsh *WebSocketHandler) handleHistory(c *wsConn, clientMsg ClientMessage, clientAddr string) {
 	if clientMsg.SessionID == "" {
 		wsh.sendError(c, "Session ID is required for history")
 		return
@@ -267,7 +369,7 @@ func (wsh *WebSocketHandler) handleHistory(c *websocket.Conn, clientMsg ClientMe
 }
 
 // sendMessage sends a message to the WebSocket client
-func (wsh *WebSocketHandler) sendMessage(c *websocket.Conn, msg ServerMessage) error {
+func (wsh *WebSocketHandler) sendMessage(c *wsConn, msg ServerMessage) error {
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return err
@@ -277,7 +379,7 @@ func (wsh *WebSocketHandler) sendMessage(c *websocket.Conn, msg ServerMessage) e
 }
 
 // sendError sends an error message to the client
-func (wsh *WebSocketHandler) sendError(c *websocket.Conn, errorMsg string) {
+func (wsh *WebSocketHandler) sendError(c *wsConn, errorMsg string) {
 	msg := ServerMessage{
 		Type:  "error",
 		Error: errorMsg,
@@ -286,7 +388,7 @@ func (wsh *WebSocketHandler) sendError(c *websocket.Conn, errorMsg string) {
 }
 
 // sendPong sends a pong response to the client
-func (wsh *WebSocketHandler) sendPong(c *websocket.Conn) {
+func (wsh *WebSocketHandler) sendPong(c *wsConn) {
 	msg := ServerMessage{
 		Type: "pong",
 	}
@@ -17,7 +17,11 @@ var migrationsFS embed.FS
 
 // LatestVersion is the current migration version
 // Update this when adding new migrations
-const LatestVersion = 9
+const LatestVersion = 24
+
+// legacySchemaVersion is the schema version of databases created before
+// migrations were introduced. Later migrations still run on top of it.
+const legacySchemaVersion = 9
 
 // Migrate runs database migrations
 func (db *DB) Migrate() error {
@@ -76,14 +80,13 @@ func (db *DB) runMigrations(isLegacy bool) error {
 	}
 	defer m.Close()
 
-	// For legacy databases, force the version to latest since schema is already complete
+	// For legacy databases, force the version to the pre-migration schema, then apply newer migrations
 	if isLegacy {
-		log.Printf("Legacy database detected, setting version to %d", LatestVersion)
-		if err := m.Force(LatestVersion); err != nil {
+		log.Printf("Legacy database detected, setting version to %d", legacySchemaVersion)
+		if err := m.Force(legacySchemaVersion); err != nil {
 			return fmt.Errorf("failed to force migration version: %w", err)
 		}
 		log.Println("Legacy database migrated successfully")
-		return nil
 	}
 
 	// Run migrations
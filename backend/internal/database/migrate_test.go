@@ -3,6 +3,7 @@ package database
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -43,7 +44,7 @@ func TestMigrations_NewDatabase(t *testing.T) {
 	}
 
 	// Verify tables exist
-	tables := []string{"sessions", "messages", "settings", "memory", "tool_calls", "machines", "messages_fts"}
+	tables := []string{"sessions", "messages", "settings", "memory", "tool_calls", "machines", "messages_fts", "audit_events", "tool_policies", "usage_events", "messages_trigram", "tool_calls_fts", "memory_fts", "sessions_fts", "message_embeddings", "embedding_failures", "folders", "session_shares", "claude_session_history", "message_parts", "attachments"}
 	for _, table := range tables {
 		exists, err := db.tableExists(table)
 		if err != nil {
@@ -55,7 +56,7 @@ func TestMigrations_NewDatabase(t *testing.T) {
 	}
 
 	// Verify sessions table has all columns
-	columns := []string{"id", "session_id", "title", "claude_session_id", "model", "created_at", "last_activity"}
+	columns := []string{"id", "session_id", "title", "claude_session_id", "model", "created_at", "last_activity", "pinned", "archived", "folder_id", "tags", "archived_at", "context_tokens", "compactions", "compacted_at", "forked_from", "forked_at_message", "seed_pending"}
 	for _, col := range columns {
 		exists, err := db.columnExists("sessions", col)
 		if err != nil {
@@ -74,6 +75,37 @@ func TestMigrations_NewDatabase(t *testing.T) {
 	if err != nil {
 		t.Errorf("Should be able to insert thinking role: %v", err)
 	}
+
+	// Verify search ignores accents and supports substrings
+	_, err = db.conn.Exec(`
+		INSERT INTO messages (session_id, role, content, created_at)
+		VALUES ('test-session', 'user', 'Un été très chaud', datetime('now'))
+	`)
+	if err != nil {
+		t.Errorf("Should be able to insert message: %v", err)
+	}
+	var matches int
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM messages_fts WHERE messages_fts MATCH 'ete'").Scan(&matches); err != nil || matches != 1 {
+		t.Errorf("Accent-insensitive search should match once, got %d (%v)", matches, err)
+	}
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM messages_trigram WHERE messages_trigram MATCH '"hau"'`).Scan(&matches); err != nil || matches != 1 {
+		t.Errorf("Substring search should match once, got %d (%v)", matches, err)
+	}
+
+	// Verify audit_events is append-only
+	_, err = db.conn.Exec(`
+		INSERT INTO audit_events (event_type, actor, outcome, created_at)
+		VALUES ('tool.execute', 'test', 'success', datetime('now'))
+	`)
+	if err != nil {
+		t.Errorf("Should be able to insert audit event: %v", err)
+	}
+	if _, err := db.conn.Exec("UPDATE audit_events SET outcome = 'error'"); err == nil {
+		t.Error("Updating audit events should be rejected")
+	}
+	if _, err := db.conn.Exec("DELETE FROM audit_events"); err == nil {
+		t.Error("Deleting audit events should be rejected")
+	}
 }
 
 func TestMigrations_LegacyDatabase(t *testing.T) {
@@ -134,6 +166,16 @@ func TestMigrations_LegacyDatabase(t *testing.T) {
 	_, err = db.conn.Exec(`
 		INSERT INTO sessions (session_id, created_at, last_activity) VALUES ('legacy-session', datetime('now'), datetime('now'));
 		INSERT INTO messages (session_id, role, content, created_at) VALUES ('legacy-session', 'user', 'Hello', datetime('now'));
+		INSERT INTO messages (session_id, role, content, created_at) VALUES ('legacy-session', 'user', 'See files
+
+<!-- attachments:a1|report.pdf|/api/uploads/a1.pdf|file,b2|photo.png|/api/uploads/b2.png|image -->', datetime('now'));
+		INSERT INTO messages (session_id, role, content, created_at) VALUES ('legacy-session', 'user', 'Report
+
+<!-- attachments:u1|report, final.pdf|/api/uploads/u1.pdf|file,u2|a,b|c.png|/api/uploads/u2.png|image -->', datetime('now'));
+		INSERT INTO messages (session_id, role, content, created_at) VALUES ('legacy-session', 'user', '<!-- attachments:u3|a|b.txt|/api/uploads/u3.txt|file -->', datetime('now'));
+		INSERT INTO messages (session_id, role, content, created_at) VALUES ('legacy-session', 'user', 'Unreadable
+
+<!-- attachments:u4|notes.txt|/elsewhere/u4.txt|file -->', datetime('now'));
 	`)
 	if err != nil {
 		t.Fatalf("Failed to insert test data: %v", err)
@@ -177,6 +219,60 @@ func TestMigrations_LegacyDatabase(t *testing.T) {
 	if count != 1 {
 		t.Errorf("Expected 1 legacy session, got %d", count)
 	}
+
+	// Verify the session keeps resuming its SDK session
+	var claudeSessionID string
+	err = db.conn.QueryRow("SELECT claude_session_id FROM sessions WHERE session_id = 'legacy-session'").Scan(&claudeSessionID)
+	if err != nil {
+		t.Fatalf("Failed to query claude_session_id: %v", err)
+	}
+	if claudeSessionID != "legacy-session" {
+		t.Errorf("Expected claude_session_id to be backfilled, got %q", claudeSessionID)
+	}
+
+	// Verify attachment markers became attachment records, including filenames
+	// containing the marker's separators. Unreadable markers are kept.
+	tests := []struct {
+		messageID   int
+		content     string
+		attachments []string
+	}{
+		{2, "See files", []string{"a1|report.pdf|a1.pdf|file", "b2|photo.png|b2.png|image"}},
+		{3, "Report", []string{"u1|report, final.pdf|u1.pdf|file", "u2|a,b|c.png|u2.png|image"}},
+		{4, "", []string{"u3|a|b.txt|u3.txt|file"}},
+		{5, "Unreadable\n\n<!-- attachments:u4|notes.txt|/elsewhere/u4.txt|file -->", nil},
+	}
+	for _, tt := range tests {
+		var content string
+		if err := db.conn.QueryRow("SELECT content FROM messages WHERE id = ?", tt.messageID).Scan(&content); err != nil {
+			t.Fatalf("Failed to query message: %v", err)
+		}
+		if content != tt.content {
+			t.Errorf("Message %d: expected content %q, got %q", tt.messageID, tt.content, content)
+		}
+		if attachments := queryAttachments(t, db, tt.messageID); !reflect.DeepEqual(attachments, tt.attachments) {
+			t.Errorf("Message %d: unexpected converted attachments: %v", tt.messageID, attachments)
+		}
+	}
+}
+
+// queryAttachments returns the attachments of a message as id|filename|stored_name|type
+func queryAttachments(t *testing.T, db *DB, messageID int) []string {
+	t.Helper()
+	rows, err := db.conn.Query("SELECT upload_id, filename, stored_name, type FROM attachments WHERE message_id = ? ORDER BY position", messageID)
+	if err != nil {
+		t.Fatalf("Failed to query attachments: %v", err)
+	}
+	defer rows.Close()
+	var attachments []string
+	for rows.Next() {
+		var uploadID, filename, storedName, fileType string
+		if err := rows.Scan(&uploadID, &filename, &storedName, &fileType); err != nil {
+			t.Fatalf("Failed to scan attachment: %v", err)
+		}
+		attachments = append(attachments, uploadID+"|"+filename+"|"+storedName+"|"+fileType)
+	}
+	return attachments
 }
 
 func TestMigrations_Idempotent(t *testing.T) {
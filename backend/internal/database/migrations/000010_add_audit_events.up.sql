@@ -0,0 +1,26 @@
+-- Add append-only audit log
+-- No foreign key to sessions: audit entries must survive session deletion
+CREATE TABLE IF NOT EXISTS audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_type TEXT NOT NULL,
+    actor TEXT NOT NULL DEFAULT '',
+    session_id TEXT DEFAULT '',
+    machine_id TEXT DEFAULT '',
+    target TEXT DEFAULT '',
+    details TEXT DEFAULT '',
+    outcome TEXT NOT NULL CHECK(outcome IN ('success', 'error', 'denied')),
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_event_type ON audit_events(event_type);
+CREATE INDEX IF NOT EXISTS idx_audit_events_session_id ON audit_events(session_id);
+
+-- Reject any modification of existing entries
+CREATE TRIGGER IF NOT EXISTS audit_events_no_update BEFORE UPDATE ON audit_events BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_delete BEFORE DELETE ON audit_events BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
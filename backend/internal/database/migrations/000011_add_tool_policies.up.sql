@@ -0,0 +1,11 @@
+-- Tool allow/deny policies, evaluated from the global scope down to the session scope
+CREATE TABLE IF NOT EXISTS tool_policies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    scope TEXT NOT NULL CHECK(scope IN ('global', 'user', 'machine', 'session')),
+    scope_id TEXT NOT NULL DEFAULT '',
+    allowed_tools TEXT NOT NULL DEFAULT '[]',
+    denied_tools TEXT NOT NULL DEFAULT '[]',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(scope, scope_id)
+);
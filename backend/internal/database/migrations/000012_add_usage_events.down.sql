@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_usage_events_model;
+DROP INDEX IF EXISTS idx_usage_events_actor;
+DROP INDEX IF EXISTS idx_usage_events_created_at;
+DROP TABLE IF EXISTS usage_events;
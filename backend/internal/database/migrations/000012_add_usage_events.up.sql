@@ -0,0 +1,17 @@
+-- Per-turn usage, used to enforce spending budgets
+CREATE TABLE IF NOT EXISTS usage_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL,
+    actor TEXT NOT NULL DEFAULT '',
+    model TEXT NOT NULL DEFAULT '',
+    input_tokens INTEGER DEFAULT 0,
+    output_tokens INTEGER DEFAULT 0,
+    cache_creation_input_tokens INTEGER DEFAULT 0,
+    cache_read_input_tokens INTEGER DEFAULT 0,
+    total_cost_usd REAL DEFAULT 0,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_events_created_at ON usage_events(created_at);
+CREATE INDEX IF NOT EXISTS idx_usage_events_actor ON usage_events(actor, created_at);
+CREATE INDEX IF NOT EXISTS idx_usage_events_model ON usage_events(model, created_at);
@@ -0,0 +1,54 @@
+-- Rebuild the message index so that accents are ignored ("ete" matches "été")
+DROP TRIGGER IF EXISTS messages_fts_ai;
+DROP TRIGGER IF EXISTS messages_fts_ad;
+DROP TRIGGER IF EXISTS messages_fts_au;
+DROP TABLE IF EXISTS messages_fts;
+
+CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
+    session_id UNINDEXED,
+    role UNINDEXED,
+    content,
+    content=messages,
+    content_rowid=id,
+    tokenize='unicode61 remove_diacritics 2'
+);
+
+INSERT INTO messages_fts(messages_fts) VALUES ('rebuild');
+
+-- Trigram index for substring search (parts of words, paths, identifiers)
+CREATE VIRTUAL TABLE IF NOT EXISTS messages_trigram USING fts5(
+    content,
+    content=messages,
+    content_rowid=id,
+    tokenize='trigram remove_diacritics 1'
+);
+
+INSERT INTO messages_trigram(messages_trigram) VALUES ('rebuild');
+
+-- Trigger for INSERT
+CREATE TRIGGER IF NOT EXISTS messages_fts_ai AFTER INSERT ON messages BEGIN
+    INSERT INTO messages_fts(rowid, session_id, role, content)
+    VALUES (new.id, new.session_id, new.role, new.content);
+    INSERT INTO messages_trigram(rowid, content)
+    VALUES (new.id, new.content);
+END;
+
+-- Trigger for DELETE
+CREATE TRIGGER IF NOT EXISTS messages_fts_ad AFTER DELETE ON messages BEGIN
+    INSERT INTO messages_fts(messages_fts, rowid, session_id, role, content)
+    VALUES ('delete', old.id, old.session_id, old.role, old.content);
+    INSERT INTO messages_trigram(messages_trigram, rowid, content)
+    VALUES ('delete', old.id, old.content);
+END;
+
+-- Trigger for UPDATE
+CREATE TRIGGER IF NOT EXISTS messages_fts_au AFTER UPDATE ON messages BEGIN
+    INSERT INTO messages_fts(messages_fts, rowid, session_id, role, content)
+    VALUES ('delete', old.id, old.session_id, old.role, old.content);
+    INSERT INTO messages_fts(rowid, session_id, role, content)
+    VALUES (new.id, new.session_id, new.role, new.content);
+    INSERT INTO messages_trigram(messages_trigram, rowid, content)
+    VALUES ('delete', old.id, old.content);
+    INSERT INTO messages_trigram(rowid, content)
+    VALUES (new.id, new.content);
+END;
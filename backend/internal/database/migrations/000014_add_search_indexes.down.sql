@@ -0,0 +1,14 @@
+DROP TRIGGER IF EXISTS sessions_fts_au;
+DROP TRIGGER IF EXISTS sessions_fts_ad;
+DROP TRIGGER IF EXISTS sessions_fts_ai;
+DROP TABLE IF EXISTS sessions_fts;
+
+DROP TRIGGER IF EXISTS memory_fts_au;
+DROP TRIGGER IF EXISTS memory_fts_ad;
+DROP TRIGGER IF EXISTS memory_fts_ai;
+DROP TABLE IF EXISTS memory_fts;
+
+DROP TRIGGER IF EXISTS tool_calls_fts_au;
+DROP TRIGGER IF EXISTS tool_calls_fts_ad;
+DROP TRIGGER IF EXISTS tool_calls_fts_ai;
+DROP TABLE IF EXISTS tool_calls_fts;
@@ -0,0 +1,89 @@
+-- Full-text indexes for tool calls, memory and session titles
+
+-- Tool calls: the command the agent ran and the output it saw
+CREATE VIRTUAL TABLE IF NOT EXISTS tool_calls_fts USING fts5(
+    session_id UNINDEXED,
+    tool_use_id UNINDEXED,
+    tool_name,
+    input,
+    output,
+    content=tool_calls,
+    content_rowid=id,
+    tokenize='unicode61 remove_diacritics 2'
+);
+
+INSERT INTO tool_calls_fts(tool_calls_fts) VALUES ('rebuild');
+
+CREATE TRIGGER IF NOT EXISTS tool_calls_fts_ai AFTER INSERT ON tool_calls BEGIN
+    INSERT INTO tool_calls_fts(rowid, session_id, tool_use_id, tool_name, input, output)
+    VALUES (new.id, new.session_id, new.tool_use_id, new.tool_name, new.input, new.output);
+END;
+
+CREATE TRIGGER IF NOT EXISTS tool_calls_fts_ad AFTER DELETE ON tool_calls BEGIN
+    INSERT INTO tool_calls_fts(tool_calls_fts, rowid, session_id, tool_use_id, tool_name, input, output)
+    VALUES ('delete', old.id, old.session_id, old.tool_use_id, old.tool_name, old.input, old.output);
+END;
+
+CREATE TRIGGER IF NOT EXISTS tool_calls_fts_au AFTER UPDATE ON tool_calls BEGIN
+    INSERT INTO tool_calls_fts(tool_calls_fts, rowid, session_id, tool_use_id, tool_name, input, output)
+    VALUES ('delete', old.id, old.session_id, old.tool_use_id, old.tool_name, old.input, old.output);
+    INSERT INTO tool_calls_fts(rowid, session_id, tool_use_id, tool_name, input, output)
+    VALUES (new.id, new.session_id, new.tool_use_id, new.tool_name, new.input, new.output);
+END;
+
+-- Memory entries (indexed by the implicit rowid, the primary key is text)
+CREATE VIRTUAL TABLE IF NOT EXISTS memory_fts USING fts5(
+    title,
+    content,
+    content=memory,
+    content_rowid=rowid,
+    tokenize='unicode61 remove_diacritics 2'
+);
+
+INSERT INTO memory_fts(memory_fts) VALUES ('rebuild');
+
+CREATE TRIGGER IF NOT EXISTS memory_fts_ai AFTER INSERT ON memory BEGIN
+    INSERT INTO memory_fts(rowid, title, content)
+    VALUES (new.rowid, new.title, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS memory_fts_ad AFTER DELETE ON memory BEGIN
+    INSERT INTO memory_fts(memory_fts, rowid, title, content)
+    VALUES ('delete', old.rowid, old.title, old.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS memory_fts_au AFTER UPDATE ON memory BEGIN
+    INSERT INTO memory_fts(memory_fts, rowid, title, content)
+    VALUES ('delete', old.rowid, old.title, old.content);
+    INSERT INTO memory_fts(rowid, title, content)
+    VALUES (new.rowid, new.title, new.content);
+END;
+
+-- Session titles
+CREATE VIRTUAL TABLE IF NOT EXISTS sessions_fts USING fts5(
+    session_id UNINDEXED,
+    title,
+    content=sessions,
+    content_rowid=id,
+    tokenize='unicode61 remove_diacritics 2'
+);
+
+INSERT INTO sessions_fts(sessions_fts) VALUES ('rebuild');
+
+CREATE TRIGGER IF NOT EXISTS sessions_fts_ai AFTER INSERT ON sessions BEGIN
+    INSERT INTO sessions_fts(rowid, session_id, title)
+    VALUES (new.id, new.session_id, new.title);
+END;
+
+CREATE TRIGGER IF NOT EXISTS sessions_fts_ad AFTER DELETE ON sessions BEGIN
+    INSERT INTO sessions_fts(sessions_fts, rowid, session_id, title)
+    VALUES ('delete', old.id, old.session_id, old.title);
+END;
+
+-- Only title changes: sessions are updated on every message (activity, usage)
+CREATE TRIGGER IF NOT EXISTS sessions_fts_au AFTER UPDATE OF session_id, title ON sessions BEGIN
+    INSERT INTO sessions_fts(sessions_fts, rowid, session_id, title)
+    VALUES ('delete', old.id, old.session_id, old.title);
+    INSERT INTO sessions_fts(rowid, session_id, title)
+    VALUES (new.id, new.session_id, new.title);
+END;
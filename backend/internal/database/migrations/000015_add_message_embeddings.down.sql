@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS message_embeddings_message_au;
+DROP TRIGGER IF EXISTS embedding_failures_message_ad;
+DROP TABLE IF EXISTS embedding_failures;
+DROP TRIGGER IF EXISTS message_embeddings_message_ad;
+DROP INDEX IF EXISTS idx_message_embeddings_model;
+DROP TABLE IF EXISTS message_embeddings;
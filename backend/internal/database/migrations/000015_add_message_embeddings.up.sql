@@ -0,0 +1,44 @@
+-- Message chunks and their embedding vectors, for semantic search.
+-- Vectors are little-endian float32 arrays; one row per chunk and model.
+CREATE TABLE IF NOT EXISTS message_embeddings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    message_id INTEGER NOT NULL,
+    chunk_index INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    model TEXT NOT NULL,
+    dimensions INTEGER NOT NULL,
+    vector BLOB NOT NULL,
+    created_at DATETIME NOT NULL,
+    UNIQUE(message_id, model, chunk_index),
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_embeddings_model ON message_embeddings(model);
+
+-- Foreign keys are not enforced on every connection: clean up explicitly
+CREATE TRIGGER IF NOT EXISTS message_embeddings_message_ad AFTER DELETE ON messages BEGIN
+    DELETE FROM message_embeddings WHERE message_id = old.id;
+END;
+
+-- Messages the embedding provider failed on. They are skipped until retry_at,
+-- so one failing message does not block the messages after it.
+CREATE TABLE IF NOT EXISTS embedding_failures (
+    message_id INTEGER NOT NULL,
+    model TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    error TEXT NOT NULL DEFAULT '',
+    failed_at DATETIME NOT NULL,
+    retry_at DATETIME NOT NULL,
+    PRIMARY KEY (message_id, model)
+);
+
+CREATE TRIGGER IF NOT EXISTS embedding_failures_message_ad AFTER DELETE ON messages BEGIN
+    DELETE FROM embedding_failures WHERE message_id = old.id;
+END;
+
+-- Edited messages are embedded again
+CREATE TRIGGER IF NOT EXISTS message_embeddings_message_au AFTER UPDATE OF content ON messages
+WHEN old.content IS NOT new.content BEGIN
+    DELETE FROM message_embeddings WHERE message_id = old.id;
+    DELETE FROM embedding_failures WHERE message_id = old.id;
+END;
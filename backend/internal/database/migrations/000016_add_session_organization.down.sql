@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_sessions_folder;
+ALTER TABLE sessions DROP COLUMN tags;
+ALTER TABLE sessions DROP COLUMN folder_id;
+ALTER TABLE sessions DROP COLUMN archived;
+ALTER TABLE sessions DROP COLUMN pinned;
+DROP INDEX IF EXISTS idx_folders_parent;
+DROP TABLE IF EXISTS folders;
@@ -0,0 +1,17 @@
+-- Nestable folders for organizing sessions
+CREATE TABLE IF NOT EXISTS folders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    parent_id INTEGER REFERENCES folders(id),
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_folders_parent ON folders(parent_id);
+
+-- Pinned and archived flags, folder and free-form tags (JSON array) on sessions
+ALTER TABLE sessions ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE sessions ADD COLUMN archived INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE sessions ADD COLUMN folder_id INTEGER REFERENCES folders(id);
+ALTER TABLE sessions ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+
+CREATE INDEX IF NOT EXISTS idx_sessions_folder ON sessions(folder_id);
@@ -0,0 +1,4 @@
+-- When a session was archived, used by retention rules
+ALTER TABLE sessions ADD COLUMN archived_at DATETIME;
+
+UPDATE sessions SET archived_at = last_activity WHERE archived = 1;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_session_shares_session;
+DROP TABLE IF EXISTS session_shares;
@@ -0,0 +1,13 @@
+-- Read-only share links for sessions
+CREATE TABLE IF NOT EXISTS session_shares (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token TEXT UNIQUE NOT NULL,
+    session_id TEXT NOT NULL,
+    hide_tool_outputs INTEGER NOT NULL DEFAULT 0,
+    hide_thinking INTEGER NOT NULL DEFAULT 0,
+    expires_at DATETIME,
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_shares_session ON session_shares(session_id);
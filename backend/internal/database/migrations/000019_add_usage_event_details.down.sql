@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_usage_events_session;
+ALTER TABLE usage_events DROP COLUMN tool_calls;
+ALTER TABLE usage_events DROP COLUMN duration_ms;
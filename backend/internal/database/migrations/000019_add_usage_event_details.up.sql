@@ -0,0 +1,5 @@
+-- Turn duration and number of tool calls, for usage analytics
+ALTER TABLE usage_events ADD COLUMN duration_ms INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE usage_events ADD COLUMN tool_calls INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_usage_events_session ON usage_events(session_id, created_at);
@@ -0,0 +1,3 @@
+ALTER TABLE sessions DROP COLUMN compacted_at;
+ALTER TABLE sessions DROP COLUMN compactions;
+ALTER TABLE sessions DROP COLUMN context_tokens;
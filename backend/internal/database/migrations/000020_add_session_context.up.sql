@@ -0,0 +1,5 @@
+-- Context tracking for conversation compaction
+-- context_tokens: tokens accumulated in the current Claude session since the last compaction
+ALTER TABLE sessions ADD COLUMN context_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE sessions ADD COLUMN compactions INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE sessions ADD COLUMN compacted_at DATETIME;
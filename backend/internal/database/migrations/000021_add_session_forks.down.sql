@@ -0,0 +1,3 @@
+ALTER TABLE sessions DROP COLUMN seed_pending;
+ALTER TABLE sessions DROP COLUMN forked_at_message;
+ALTER TABLE sessions DROP COLUMN forked_from;
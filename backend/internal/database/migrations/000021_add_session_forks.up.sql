@@ -0,0 +1,5 @@
+-- Forked sessions copy the history of their parent up to a message
+ALTER TABLE sessions ADD COLUMN forked_from TEXT;
+ALTER TABLE sessions ADD COLUMN forked_at_message INTEGER;
+-- The next turn starts a new Claude session seeded with the copied transcript
+ALTER TABLE sessions ADD COLUMN seed_pending INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_claude_session_history_session;
+DROP TABLE IF EXISTS claude_session_history;
@@ -0,0 +1,15 @@
+-- Session IDs are permanent: the Claude SDK session behind a session lives in
+-- claude_session_id, and the IDs it replaced are kept here
+CREATE TABLE IF NOT EXISTS claude_session_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL,
+    claude_session_id TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT 'resume' CHECK(reason IN ('resume', 'compaction', 'seed')),
+    replaced_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_claude_session_history_session ON claude_session_history(session_id);
+
+-- Sessions were keyed by their latest SDK session ID
+UPDATE sessions SET claude_session_id = session_id
+WHERE (claude_session_id IS NULL OR claude_session_id = '') AND seed_pending = 0;
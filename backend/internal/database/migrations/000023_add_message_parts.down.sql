@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_message_parts_session;
+DROP INDEX IF EXISTS idx_message_parts_turn;
+DROP INDEX IF EXISTS idx_message_parts_message;
+DROP TABLE IF EXISTS message_parts;
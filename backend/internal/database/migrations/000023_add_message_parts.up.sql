@@ -0,0 +1,21 @@
+-- Ordered parts of an assistant turn: text, thinking, tool_use and tool_result.
+-- Parts are saved as the turn streams, under turn_id; message_id links them to the
+-- assistant message once the turn completes.
+CREATE TABLE IF NOT EXISTS message_parts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL,
+    message_id INTEGER,
+    turn_id TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    type TEXT NOT NULL CHECK(type IN ('text', 'thinking', 'tool_use', 'tool_result')),
+    content TEXT NOT NULL DEFAULT '',
+    tool_use_id TEXT NOT NULL DEFAULT '',
+    tool_name TEXT NOT NULL DEFAULT '',
+    input TEXT NOT NULL DEFAULT '',
+    is_error INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_parts_message ON message_parts(message_id, position);
+CREATE INDEX IF NOT EXISTS idx_message_parts_turn ON message_parts(turn_id);
+CREATE INDEX IF NOT EXISTS idx_message_parts_session ON message_parts(session_id);
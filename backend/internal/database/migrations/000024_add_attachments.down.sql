@@ -0,0 +1,13 @@
+-- Restore the attachment markers of user messages
+UPDATE messages
+SET content = CASE WHEN content = '' THEN '' ELSE content || char(10) || char(10) END
+    || '<!-- attachments:'
+    || (SELECT group_concat(upload_id || '|' || filename || '|/api/uploads/' || stored_name || '|' || type, ',')
+        FROM (SELECT * FROM attachments a WHERE a.message_id = messages.id ORDER BY a.position))
+    || ' -->'
+WHERE id IN (SELECT message_id FROM attachments WHERE message_id IS NOT NULL);
+
+DROP INDEX IF EXISTS idx_attachments_session;
+DROP INDEX IF EXISTS idx_attachments_upload;
+DROP INDEX IF EXISTS idx_attachments_message;
+DROP TABLE IF EXISTS attachments;
@@ -0,0 +1,81 @@
+-- Uploaded files and the messages they were sent with. A row is created at
+-- upload time and linked to its message when the message is sent.
+CREATE TABLE IF NOT EXISTS attachments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    upload_id TEXT NOT NULL,
+    session_id TEXT NOT NULL DEFAULT '',
+    message_id INTEGER,
+    position INTEGER NOT NULL DEFAULT 0,
+    filename TEXT NOT NULL,
+    stored_name TEXT NOT NULL,
+    type TEXT NOT NULL DEFAULT 'file',
+    mime_type TEXT NOT NULL DEFAULT '',
+    size INTEGER NOT NULL DEFAULT 0,
+    checksum TEXT NOT NULL DEFAULT '',
+    uploaded_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachments_message ON attachments(message_id, position);
+CREATE INDEX IF NOT EXISTS idx_attachments_upload ON attachments(upload_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_session ON attachments(session_id);
+
+-- Convert the markers appended to user messages:
+-- <!-- attachments:id|filename|/api/uploads/stored|type,... -->
+-- Filenames may contain "," and "|", so items are not split on them. Each item
+-- is read from its fixed-shape parts: the upload ID, the filename up to the
+-- "|/api/uploads/<id>" path of the file stored under that ID, the stored name
+-- and the type. Markers are only removed from messages whose whole list was
+-- read. Size and checksum are filled in from the files at startup.
+CREATE TEMP TABLE attachment_markers AS
+WITH RECURSIVE
+lists AS (
+    SELECT id, session_id, created_at,
+        substr(content, instr(content, '<!-- attachments:') + 17) AS rest
+    FROM messages
+    WHERE instr(content, '<!-- attachments:') > 0
+),
+items(message_id, session_id, created_at, stage, position, upload_id, filename, stored_name, type, rest) AS (
+    SELECT id, session_id, created_at, 0, -1, '', '', '', '', substr(rest, 1, length(rest) - 4)
+    FROM lists WHERE rest LIKE '% -->'
+    UNION ALL
+    -- The upload ID, at the start of an item
+    SELECT message_id, session_id, created_at, 1, position + 1,
+        substr(rest, 1, instr(rest, '|') - 1), '', '', '', substr(rest, instr(rest, '|') + 1)
+    FROM items WHERE stage IN (0, 4) AND instr(rest, '|') > 1
+    UNION ALL
+    -- The filename, up to the path of the file stored under the upload ID
+    SELECT message_id, session_id, created_at, 2, position, upload_id,
+        substr(rest, 1, instr(rest, '|/api/uploads/' || upload_id) - 1), '', '',
+        substr(rest, instr(rest, '|/api/uploads/' || upload_id) + 14)
+    FROM items WHERE stage = 1 AND instr(rest, '|/api/uploads/' || upload_id) > 1
+    UNION ALL
+    -- The stored name: the upload ID and the file's extension
+    SELECT message_id, session_id, created_at, 3, position, upload_id, filename,
+        substr(rest, 1, instr(rest, '|') - 1), '', substr(rest, instr(rest, '|') + 1)
+    FROM items WHERE stage = 2 AND instr(rest, '|') > 0
+    UNION ALL
+    -- The type, ending the item
+    SELECT message_id, session_id, created_at, 4, position, upload_id, filename, stored_name,
+        CASE WHEN rest GLOB 'image*' THEN 'image' ELSE 'file' END,
+        CASE WHEN rest GLOB 'image*' THEN substr(rest, 7) ELSE substr(rest, 6) END
+    FROM items WHERE stage = 3 AND (rest IN ('image', 'file') OR rest GLOB 'image,*' OR rest GLOB 'file,*')
+)
+SELECT message_id, session_id, created_at, stage, position, upload_id, filename, stored_name, type, rest
+FROM items WHERE stage IN (0, 4);
+
+-- Messages whose list was read to its end (or was empty)
+CREATE TEMP TABLE converted_markers AS
+SELECT DISTINCT message_id FROM attachment_markers WHERE rest = '';
+
+INSERT INTO attachments (upload_id, session_id, message_id, position, filename, stored_name, type, uploaded_at)
+SELECT upload_id, session_id, message_id, position, filename, stored_name, type, created_at
+FROM attachment_markers
+WHERE stage = 4 AND message_id IN (SELECT message_id FROM converted_markers)
+ORDER BY message_id, position;
+
+UPDATE messages
+SET content = rtrim(substr(content, 1, instr(content, '<!-- attachments:') - 1), ' ' || char(10))
+WHERE id IN (SELECT message_id FROM converted_markers);
+
+DROP TABLE attachment_markers;
+DROP TABLE converted_markers;
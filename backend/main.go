@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -29,6 +34,9 @@ type Config struct {
 	WorkspacePath  string // Path prefix for Claude CLI (e.g., /home/user/workspace)
 	ClaudeProxyURL string // URL to Claude Proxy service (required)
 	ClaudeProxyKey string // API key for proxy authentication
+
+	// Semantic search provider, disabled when no URL is set
+	Embeddings services.EmbeddingConfig
 }
 
 // loadConfig loads configuration from environment variables with defaults
@@ -66,6 +74,12 @@ func loadConfig() Config {
 		WorkspacePath:  workspacePath,
 		ClaudeProxyURL: getEnv("CLAUDE_PROXY_URL", ""),
 		ClaudeProxyKey: getEnv("CLAUDE_PROXY_KEY", ""),
+		Embeddings: services.EmbeddingConfig{
+			Provider: getEnv("EMBEDDINGS_PROVIDER", "openai"),
+			BaseURL:  getEnv("EMBEDDINGS_URL", ""),
+			Model:    getEnv("EMBEDDINGS_MODEL", ""),
+			APIKey:   getEnv("EMBEDDINGS_API_KEY", ""),
+		},
 	}
 
 	return config
@@ -102,6 +116,11 @@ func main() {
 	// Load configuration
 	config := loadConfig()
 
+	// Subcommands run against the database and exit
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(config, os.Args[2:]))
+	}
+
 	// Ensure necessary directories exist
 	if err := ensureDirectories(config); err != nil {
 		log.Fatalf("Failed to ensure directories: %v", err)
@@ -131,15 +150,66 @@ func main() {
 	// Initialize repositories
 	sessionRepo := repositories.NewSessionRepository(sqlDB)
 	messageRepo := repositories.NewMessageRepository(sqlDB)
+	messagePartRepo := repositories.NewMessagePartRepository(sqlDB)
+	attachmentRepo := repositories.NewAttachmentRepository(sqlDB)
 	memoryRepo := repositories.NewMemoryRepository(sqlDB)
 	machineRepo := repositories.NewMachineRepository(sqlDB)
 	toolCallRepo := repositories.NewToolCallRepository(sqlDB)
 	settingsRepo := repositories.NewSettingsRepository(sqlDB)
 	searchRepo := repositories.NewSearchRepository(sqlDB)
+	auditRepo := repositories.NewAuditRepository(sqlDB)
+	toolPolicyRepo := repositories.NewToolPolicyRepository(sqlDB)
+	usageRepo := repositories.NewUsageRepository(sqlDB)
+	embeddingRepo := repositories.NewEmbeddingRepository(sqlDB)
+	folderRepo := repositories.NewFolderRepository(sqlDB)
+	maintenanceRepo := repositories.NewMaintenanceRepository(sqlDB)
+	shareRepo := repositories.NewShareRepository(sqlDB)
+
+	// Initialize crypto service for machines
+	cryptoService := services.NewCryptoService(config.DatabasePath)
+
+	// Secrets are masked before messages, tool I/O, logs and audit events are stored
+	redactor := services.NewRedactor(settingsRepo, services.NewMachineSecretSource(machineRepo, cryptoService))
+	toolCallRepo = services.NewRedactingToolCallRepository(toolCallRepo, redactor)
 
 	// Initialize services
-	sessionManager := services.NewSessionManager(sessionRepo, messageRepo)
+	sessionManager := services.NewSessionManager(sessionRepo, messageRepo, messagePartRepo, attachmentRepo, redactor)
 	logService := services.NewLogService(100) // Keep last 100 log entries
+	logService.SetRedactor(redactor)
+	auditService := services.NewAuditService(auditRepo, redactor)
+	toolPolicyService := services.NewToolPolicyService(toolPolicyRepo)
+	budgetService := services.NewBudgetService(usageRepo, settingsRepo)
+	forkService := services.NewForkService(sessionRepo, messageRepo)
+	attachmentService := services.NewAttachmentService(attachmentRepo, config.UploadDir)
+
+	// Background jobs stop when the server shuts down
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Semantic search: embed messages in the background, backfilling existing ones
+	embeddingProvider, err := services.NewEmbeddingProvider(config.Embeddings)
+	if err != nil {
+		log.Printf("Warning: semantic search disabled: %v", err)
+		embeddingProvider = nil
+	}
+	if embeddingProvider != nil {
+		log.Printf("Semantic search enabled with model %s", embeddingProvider.Model())
+		go services.NewEmbeddingIndexer(embeddingRepo, embeddingProvider, 30*time.Second).Run(ctx)
+	}
+	searchService := services.NewSearchService(searchRepo, embeddingRepo, embeddingProvider)
+
+	// Attachments converted from legacy markers get their size and checksum from the files
+	go func() {
+		if n, err := attachmentService.BackfillFileInfo(); err != nil {
+			log.Printf("Warning: failed to backfill attachment details: %v", err)
+		} else if n > 0 {
+			log.Printf("Backfilled details of %d attachments", n)
+		}
+	}()
+
+	// Retention: apply the cleanup rules at startup and every 6 hours
+	retentionService := services.NewRetentionService(maintenanceRepo, sessionRepo, settingsRepo, config.UploadDir, 6*time.Hour)
+	go retentionService.Run(ctx)
 
 	// Validate required configuration
 	if config.ClaudeProxyURL == "" {
@@ -158,9 +228,6 @@ func main() {
 		log.Printf("Warning: Claude proxy not reachable: %v", err)
 	}
 
-	// Initialize crypto service for machines
-	cryptoService := services.NewCryptoService(config.DatabasePath)
-
 	// Initialize handlers with repository injection
 	chatHandler := handlers.NewChatHandler(
 		sessionManager,
@@ -173,14 +240,32 @@ func main() {
 		toolCallRepo,
 		logService,
 		cryptoService,
+		auditService,
+		services.NewApprovalService(),
+		toolPolicyService,
+		budgetService,
+		services.NewCompactionService(sessionRepo, messageRepo, messagePartRepo, settingsRepo, claudeExecutor, budgetService),
+		forkService,
+		services.NewDocumentExtractor(config.UploadDir),
+		services.NewWorkspaceService(config.UploadDir),
 	)
-	wsHandler := handlers.NewWebSocketHandler(chatHandler)
-	uploadHandler := handlers.NewUploadHandler(config.UploadDir)
+	wsHandler := handlers.NewWebSocketHandler(chatHandler, auditService)
+	uploadHandler := handlers.NewUploadHandler(config.UploadDir, attachmentService,
+		services.NewImageService(settingsRepo, config.UploadDir), services.NewUploadPolicy(settingsRepo))
 	memoryHandler := handlers.NewMemoryHandler(memoryRepo)
 	logHandler := handlers.NewLogHandler(logService)
 	updateHandler := handlers.NewUpdateHandler(config.ClaudeProxyURL, config.ClaudeProxyKey)
-	machinesHandler := handlers.NewMachinesHandler(machineRepo, cryptoService)
-	searchHandler := handlers.NewSearchHandler(searchRepo)
+	machinesHandler := handlers.NewMachinesHandler(machineRepo, cryptoService, auditService, redactor)
+	searchHandler := handlers.NewSearchHandler(searchService)
+	auditHandler := handlers.NewAuditHandler(auditRepo)
+	policiesHandler := handlers.NewPoliciesHandler(toolPolicyRepo, toolPolicyService, auditService)
+	usageHandler := handlers.NewUsageHandler(budgetService, usageRepo)
+	importHandler := handlers.NewImportHandler(services.NewClaudeCodeImporter(sessionRepo, messageRepo, toolCallRepo, redactor))
+	foldersHandler := handlers.NewFoldersHandler(folderRepo)
+	retentionHandler := handlers.NewRetentionHandler(retentionService, auditService)
+	exporter := services.NewSessionExporter(sessionRepo, messageRepo, toolCallRepo, attachmentRepo, config.UploadDir)
+	exportHandler := handlers.NewExportHandler(sessionRepo, exporter)
+	shareHandler := handlers.NewShareHandler(sessionRepo, services.NewShareService(shareRepo, exporter), auditService)
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
@@ -189,6 +274,7 @@ func main() {
 		EnablePrintRoutes:     false,
 		ServerHeader:          "Home Agent",
 		ErrorHandler:          customErrorHandler,
+		BodyLimit:             100 * 1024 * 1024, // Uploads and transcript imports
 	})
 
 	// Middleware
@@ -227,13 +313,33 @@ func main() {
 	})
 
 	// Sessions API
+	// Filters: q, tag, folder (ID or "none"), pinned, archived (exclude, only, include), model.
+	// Sort: sort (last_activity, created_at, title, cost) and order (desc, asc); pinned sessions come first.
+	// Pagination: limit (1-200) and offset; without them every match is returned. The response
+	// always carries the sessions with their total.
 	app.Get("/api/sessions", func(c *fiber.Ctx) error {
-		sessions, err := sessionManager.ListSessions()
+		filter, err := parseSessionFilter(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		list, err := sessionManager.ListSessionsFiltered(filter)
 		if err != nil {
 			log.Printf("Error listing sessions: %v", err)
 			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 		}
-		return c.JSON(sessions)
+		if list.Sessions == nil {
+			list.Sessions = []*models.Session{}
+		}
+		return c.JSON(list)
+	})
+
+	app.Get("/api/sessions/tags", func(c *fiber.Ctx) error {
+		tags, err := sessionManager.ListSessionTags()
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(tags)
 	})
 
 	app.Get("/api/sessions/:id", func(c *fiber.Ctx) error {
@@ -245,13 +351,65 @@ func main() {
 		return c.JSON(session)
 	})
 
+	// Without cursor parameters the whole conversation is returned as an array.
+	// With before, after, around (message IDs) or limit, a page is returned.
 	app.Get("/api/sessions/:id/messages", func(c *fiber.Ctx) error {
 		sessionID := c.Params("id")
-		messages, err := sessionManager.GetMessages(sessionID)
+
+		if c.Query("before") == "" && c.Query("after") == "" && c.Query("around") == "" && c.Query("limit") == "" {
+			messages, err := sessionManager.GetMessages(sessionID)
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+			}
+			return c.JSON(messages)
+		}
+
+		cursor := models.MessageCursor{
+			Before: c.QueryInt("before", 0),
+			After:  c.QueryInt("after", 0),
+			Around: c.QueryInt("around", 0),
+			Limit:  c.QueryInt("limit", 50),
+		}
+		set := 0
+		for _, id := range []int{cursor.Before, cursor.After, cursor.Around} {
+			if id < 0 {
+				return c.Status(400).JSON(fiber.Map{"error": "Cursor must be a message ID"})
+			}
+			if id > 0 {
+				set++
+			}
+		}
+		if set > 1 {
+			return c.Status(400).JSON(fiber.Map{"error": "Only one of before, after and around can be set"})
+		}
+		if cursor.Limit < 1 || cursor.Limit > 200 {
+			return c.Status(400).JSON(fiber.Map{"error": "Limit must be between 1 and 200"})
+		}
+
+		page, err := sessionManager.GetMessagePage(sessionID, cursor)
+		if errors.Is(err, repositories.ErrMessageNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Message not found in session"})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(page)
+	})
+
+	// SDK session IDs the session resumed before its current claude_session_id
+	app.Get("/api/sessions/:id/claude-sessions", func(c *fiber.Ctx) error {
+		sessionID := c.Params("id")
+
+		if !sessionManager.SessionExists(sessionID) {
+			return c.Status(404).JSON(fiber.Map{"error": "Session not found"})
+		}
+
+		history, err := sessionManager.GetClaudeSessionHistory(sessionID)
 		if err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 		}
-		return c.JSON(messages)
+
+		return c.JSON(history)
 	})
 
 	// Tool calls API (for lazy loading)
@@ -291,6 +449,10 @@ func main() {
 	app.Patch("/api/sessions/:id/model", func(c *fiber.Ctx) error {
 		sessionID := c.Params("id")
 
+		if !sessionManager.SessionExists(sessionID) {
+			return c.Status(404).JSON(fiber.Map{"error": "Session not found"})
+		}
+
 		var body struct {
 			Model string `json:"model"`
 		}
@@ -311,6 +473,151 @@ func main() {
 		return c.JSON(fiber.Map{"session_id": sessionID, "model": body.Model})
 	})
 
+	// Session organization: pin, archive, folder and tags
+	app.Patch("/api/sessions/:id/pinned", func(c *fiber.Ctx) error {
+		sessionID := c.Params("id")
+
+		if !sessionManager.SessionExists(sessionID) {
+			return c.Status(404).JSON(fiber.Map{"error": "Session not found"})
+		}
+
+		var body struct {
+			Pinned bool `json:"pinned"`
+		}
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+
+		if err := sessionManager.SetSessionPinned(sessionID, body.Pinned); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		return c.JSON(fiber.Map{"session_id": sessionID, "pinned": body.Pinned})
+	})
+
+	app.Patch("/api/sessions/:id/archived", func(c *fiber.Ctx) error {
+		sessionID := c.Params("id")
+
+		if !sessionManager.SessionExists(sessionID) {
+			return c.Status(404).JSON(fiber.Map{"error": "Session not found"})
+		}
+
+		var body struct {
+			Archived bool `json:"archived"`
+		}
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+
+		if err := sessionManager.SetSessionArchived(sessionID, body.Archived); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		return c.JSON(fiber.Map{"session_id": sessionID, "archived": body.Archived})
+	})
+
+	// A null folder_id moves the session out of any folder
+	app.Patch("/api/sessions/:id/folder", func(c *fiber.Ctx) error {
+		sessionID := c.Params("id")
+
+		if !sessionManager.SessionExists(sessionID) {
+			return c.Status(404).JSON(fiber.Map{"error": "Session not found"})
+		}
+
+		var body struct {
+			FolderID *int `json:"folder_id"`
+		}
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+
+		if body.FolderID != nil {
+			folder, err := folderRepo.Get(*body.FolderID)
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+			}
+			if folder == nil {
+				return c.Status(400).JSON(fiber.Map{"error": "Folder not found"})
+			}
+		}
+
+		if err := sessionManager.SetSessionFolder(sessionID, body.FolderID); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		return c.JSON(fiber.Map{"session_id": sessionID, "folder_id": body.FolderID})
+	})
+
+	app.Put("/api/sessions/:id/tags", func(c *fiber.Ctx) error {
+		sessionID := c.Params("id")
+
+		if !sessionManager.SessionExists(sessionID) {
+			return c.Status(404).JSON(fiber.Map{"error": "Session not found"})
+		}
+
+		var body struct {
+			Tags []string `json:"tags"`
+		}
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+
+		if _, err := services.NormalizeTags(body.Tags); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		tags, err := sessionManager.SetSessionTags(sessionID, body.Tags)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		return c.JSON(fiber.Map{"session_id": sessionID, "tags": tags})
+	})
+
+	// Forking copies the history up to at_message (default: all of it) into a new session
+	app.Post("/api/sessions/:id/fork", func(c *fiber.Ctx) error {
+		sessionID := c.Params("id")
+
+		if !sessionManager.SessionExists(sessionID) {
+			return c.Status(404).JSON(fiber.Map{"error": "Session not found"})
+		}
+
+		atMessage := c.QueryInt("at_message", 0)
+		if atMessage < 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid at_message"})
+		}
+
+		fork, err := forkService.Fork(sessionID, atMessage)
+		if errors.Is(err, services.ErrMessageNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		return c.Status(201).JSON(fork)
+	})
+
+	// Compaction replaces the Claude session behind a session with a fresh one
+	// seeded with a summary
+	app.Post("/api/sessions/:id/compact", func(c *fiber.Ctx) error {
+		sessionID := c.Params("id")
+
+		if !sessionManager.SessionExists(sessionID) {
+			return c.Status(404).JSON(fiber.Map{"error": "Session not found"})
+		}
+
+		result, err := chatHandler.CompactSession(c.Context(), sessionID, handlers.RequestActor(c))
+		if errors.Is(err, services.ErrCompactionInProgress) {
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		return c.JSON(result)
+	})
+
 	// Settings API
 	app.Get("/api/settings", func(c *fiber.Ctx) error {
 		settings, err := settingsRepo.GetAll()
@@ -335,9 +642,72 @@ func main() {
 			return c.Status(400).JSON(fiber.Map{"error": "Custom instructions must be 2000 characters or less"})
 		}
 
+		// Validate approval rules before they are used to gate tool calls
+		if key == services.ApprovalSettingsKey {
+			if _, err := services.ParseApprovalConfig(body.Value); err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+			}
+		}
+
+		// Validate redaction patterns before they are applied to stored content
+		if key == services.RedactionSettingsKey {
+			if _, err := services.ParseRedactionPatterns(body.Value); err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+			}
+		}
+
+		// Validate budgets before they are used to block requests
+		if key == services.BudgetSettingsKey {
+			if _, err := services.ParseBudgetConfig(body.Value); err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+			}
+		}
+
+		// Validate retention rules before the cleanup job deletes anything
+		if key == services.RetentionSettingsKey {
+			if _, err := services.ParseRetentionConfig(body.Value); err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+			}
+		}
+
+		// Validate compaction settings before they trigger summarization
+		if key == services.CompactionSettingsKey {
+			if _, err := services.ParseCompactionConfig(body.Value); err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+			}
+		}
+
+		// Validate image and upload settings before uploads are processed with them
+		if key == services.ImageSettingsKey {
+			if _, err := services.ParseImageConfig(body.Value); err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+			}
+		}
+		if key == services.UploadSettingsKey {
+			if _, err := services.ParseUploadConfig(body.Value); err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+			}
+		}
+
+		// Only the key is audited: values may hold instructions or secrets
 		if err := settingsRepo.Set(key, body.Value); err != nil {
+			auditService.Record(models.AuditEvent{
+				EventType: models.AuditSettingsUpdate,
+				Actor:     handlers.RequestActor(c),
+				Target:    key,
+				Outcome:   models.AuditOutcomeError,
+			})
 			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 		}
+		auditService.Record(models.AuditEvent{
+			EventType: models.AuditSettingsUpdate,
+			Actor:     handlers.RequestActor(c),
+			Target:    key,
+		})
+
+		if key == services.RedactionSettingsKey {
+			redactor.Refresh()
+		}
 
 		return c.JSON(fiber.Map{"key": key, "value": body.Value})
 	})
@@ -368,6 +738,30 @@ func main() {
 	// Register search routes
 	searchHandler.RegisterRoutes(app)
 
+	// Register audit routes
+	auditHandler.RegisterRoutes(app)
+
+	// Register tool policy routes
+	policiesHandler.RegisterRoutes(app)
+
+	// Register usage routes
+	usageHandler.RegisterRoutes(app)
+
+	// Register export routes
+	exportHandler.RegisterRoutes(app)
+
+	// Register import routes
+	importHandler.RegisterRoutes(app)
+
+	// Register folder routes
+	foldersHandler.RegisterRoutes(app)
+
+	// Register retention routes
+	retentionHandler.RegisterRoutes(app)
+
+	// Register share routes
+	shareHandler.RegisterRoutes(app)
+
 	// Log startup
 	logService.Info("Home Agent started")
 
@@ -389,6 +783,7 @@ func main() {
 	go func() {
 		<-c
 		log.Println("\nShutting down gracefully...")
+		cancel()
 		app.Shutdown()
 	}()
 
@@ -401,6 +796,121 @@ func main() {
 	}
 }
 
+// runImport implements "home-agent import [path...]": it imports Claude Code
+// transcripts (default ~/.claude/projects) into the database and prints a
+// JSON summary. It returns the process exit code.
+func runImport(config Config, paths []string) int {
+	if len(paths) == 0 {
+		defaultDir := services.DefaultClaudeProjectsDir()
+		if defaultDir == "" {
+			fmt.Fprintln(os.Stderr, "usage: home-agent import [path...]")
+			return 2
+		}
+		paths = []string{defaultDir}
+	}
+
+	if err := ensureDirectories(config); err != nil {
+		log.Printf("Failed to ensure directories: %v", err)
+		return 1
+	}
+
+	db, err := database.New(database.Config{Path: config.DatabasePath})
+	if err != nil {
+		log.Printf("Failed to connect to database: %v", err)
+		return 1
+	}
+	defer db.Close()
+
+	if err := db.Migrate(); err != nil {
+		log.Printf("Failed to run database migrations: %v", err)
+		return 1
+	}
+
+	sqlDB := db.Conn()
+	sessionRepo := repositories.NewSessionRepository(sqlDB)
+	machineRepo := repositories.NewMachineRepository(sqlDB)
+	settingsRepo := repositories.NewSettingsRepository(sqlDB)
+
+	// Imported content is redacted like live conversations
+	redactor := services.NewRedactor(settingsRepo, services.NewMachineSecretSource(machineRepo, services.NewCryptoService(config.DatabasePath)))
+	toolCallRepo := services.NewRedactingToolCallRepository(repositories.NewToolCallRepository(sqlDB), redactor)
+
+	importer := services.NewClaudeCodeImporter(sessionRepo, repositories.NewMessageRepository(sqlDB), toolCallRepo, redactor)
+	result := importer.ImportPaths(paths)
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		log.Printf("Failed to print import result: %v", err)
+		return 1
+	}
+
+	if len(result.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// parseSessionFilter reads the session listing query parameters
+func parseSessionFilter(c *fiber.Ctx) (models.SessionFilter, error) {
+	filter := models.SessionFilter{
+		Query:    strings.TrimSpace(c.Query("q")),
+		Tag:      strings.ToLower(strings.TrimSpace(c.Query("tag"))),
+		Archived: c.Query("archived", models.ArchivedExclude),
+		Model:    c.Query("model"),
+		Sort:     c.Query("sort", models.SessionSortActivity),
+		Order:    c.Query("order", "desc"),
+		Limit:    c.QueryInt("limit", 0),
+		Offset:   c.QueryInt("offset", 0),
+	}
+
+	switch filter.Archived {
+	case models.ArchivedExclude, models.ArchivedOnly, models.ArchivedInclude:
+	default:
+		return filter, fmt.Errorf("archived must be one of: exclude, only, include")
+	}
+	switch filter.Sort {
+	case models.SessionSortActivity, models.SessionSortCreated, models.SessionSortTitle, models.SessionSortCost:
+	default:
+		return filter, fmt.Errorf("sort must be one of: last_activity, created_at, title, cost")
+	}
+	if filter.Order != "desc" && filter.Order != "asc" {
+		return filter, fmt.Errorf("order must be asc or desc")
+	}
+
+	if pinned := c.Query("pinned"); pinned != "" {
+		value, err := strconv.ParseBool(pinned)
+		if err != nil {
+			return filter, fmt.Errorf("pinned must be true or false")
+		}
+		filter.Pinned = &value
+	}
+
+	if folder := c.Query("folder"); folder != "" {
+		id := 0
+		if folder != "none" {
+			var err error
+			id, err = strconv.Atoi(folder)
+			if err != nil || id <= 0 {
+				return filter, fmt.Errorf("folder must be a folder ID or none")
+			}
+		}
+		filter.FolderID = &id
+	}
+
+	if c.Query("limit") != "" && (filter.Limit < 1 || filter.Limit > 200) {
+		return filter, fmt.Errorf("limit must be between 1 and 200")
+	}
+	if c.Query("offset") != "" && filter.Limit == 0 {
+		filter.Limit = 50
+	}
+	if filter.Offset < 0 {
+		return filter, fmt.Errorf("offset must not be negative")
+	}
+
+	return filter, nil
+}
+
 // customErrorHandler handles Fiber errors
 func customErrorHandler(c *fiber.Ctx, err error) error {
 	code := fiber.StatusInternalServerError
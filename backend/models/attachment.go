@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Attachment types
+const (
+	AttachmentImage = "image"
+	AttachmentFile  = "file"
+)
+
+// AttachmentPathPrefix is the API path under which uploads are served
+const AttachmentPathPrefix = "/api/uploads/"
+
+// Attachment is an uploaded file, linked to the message it was sent with
+type Attachment struct {
+	ID         int       `json:"-"`
+	UploadID   string    `json:"id"` // ID returned by the upload API
+	SessionID  string    `json:"-"`
+	MessageID  *int      `json:"-"` // nil until the file is sent with a message
+	Position   int       `json:"-"`
+	Filename   string    `json:"filename"` // Original name
+	StoredName string    `json:"-"`        // Name in the upload directory
+	Path       string    `json:"path"`     // API path serving the file
+	Type       string    `json:"type"`     // "image" or "file"
+	MimeType   string    `json:"mime_type"`
+	Size       int64     `json:"size"`
+	Checksum   string    `json:"checksum"` // SHA-256, hex encoded
+	UploadedAt time.Time `json:"uploaded_at"`
+}
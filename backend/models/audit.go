@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Audit event types
+const (
+	AuditToolExecute      = "tool.execute"
+	AuditMachineTarget    = "machine.target"
+	AuditSettingsUpdate   = "settings.update"
+	AuditCredentialCreate = "credential.create"
+	AuditCredentialUpdate = "credential.update"
+	AuditCredentialDelete = "credential.delete"
+	AuditLogin            = "auth.login"
+	AuditPolicyUpdate     = "policy.update"
+	AuditPolicyDelete     = "policy.delete"
+	AuditBudgetBlocked    = "budget.blocked"
+	AuditRetentionRun     = "retention.run"
+	AuditShareCreate      = "share.create"
+	AuditShareRevoke      = "share.revoke"
+	AuditSessionCompact   = "session.compact"
+)
+
+// Audit event outcomes
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeError   = "error"
+	AuditOutcomeDenied  = "denied"
+)
+
+// AuditEvent represents an entry of the append-only audit log
+type AuditEvent struct {
+	ID        int       `json:"id"`
+	EventType string    `json:"event_type"`
+	Actor     string    `json:"actor"`      // Reverse proxy user or client IP
+	SessionID string    `json:"session_id"` // Not a foreign key: survives session deletion
+	MachineID string    `json:"machine_id"`
+	Target    string    `json:"target"`  // Tool name, setting key, machine name...
+	Details   string    `json:"details"` // Free-form, usually JSON
+	Outcome   string    `json:"outcome"` // "success", "error", "denied"
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditFilter restricts the audit events returned by a query
+type AuditFilter struct {
+	EventType string
+	Actor     string
+	SessionID string
+	MachineID string
+	Outcome   string
+	Since     *time.Time
+	Until     *time.Time
+	Limit     int // 0 means no limit
+	Offset    int
+}
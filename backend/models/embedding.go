@@ -0,0 +1,18 @@
+package models
+
+// EmbeddingChunk is a piece of a message and its embedding vector
+type EmbeddingChunk struct {
+	MessageID  int
+	ChunkIndex int
+	Content    string
+	Vector     []float32
+}
+
+// EmbeddingStats reports the progress of the semantic index for a model
+type EmbeddingStats struct {
+	Model           string `json:"model"`
+	IndexedMessages int    `json:"indexed_messages"`
+	PendingMessages int    `json:"pending_messages"`
+	FailedMessages  int    `json:"failed_messages"` // Not embedded after an error, retried later
+	Chunks          int    `json:"chunks"`
+}
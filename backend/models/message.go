@@ -9,4 +9,46 @@ type Message struct {
 	Role      string    `json:"role"`       // "user" or "assistant"
 	Content   string    `json:"content"`
 	CreatedAt time.Time `json:"created_at"`
+	// Ordered parts of an assistant turn, absent for messages saved without them
+	Parts []*MessagePart `json:"parts,omitempty"`
+	// Files sent with a user message, in the order they were attached
+	Attachments []*Attachment `json:"attachments,omitempty"`
+}
+
+// Message part types
+const (
+	PartText       = "text"
+	PartThinking   = "thinking"
+	PartToolUse    = "tool_use"
+	PartToolResult = "tool_result"
+)
+
+// MessagePart is one step of an assistant turn, in the order it streamed
+type MessagePart struct {
+	ID        int       `json:"id"`
+	MessageID *int      `json:"message_id"` // nil until the turn completes
+	Position  int       `json:"position"`
+	Type      string    `json:"type"`
+	Content   string    `json:"content,omitempty"` // Text, thinking or tool output
+	ToolUseID string    `json:"tool_use_id,omitempty"`
+	ToolName  string    `json:"tool_name,omitempty"`
+	Input     string    `json:"input,omitempty"` // Tool input as JSON
+	IsError   bool      `json:"is_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MessageCursor selects a window of a session's messages by message ID.
+// At most one of Before, After and Around is set; none selects the latest.
+type MessageCursor struct {
+	Before int // Messages older than this ID
+	After  int // Messages newer than this ID
+	Around int // Messages centred on this ID, included
+	Limit  int
+}
+
+// MessagePage is a window of messages in chronological order
+type MessagePage struct {
+	Messages      []*Message `json:"messages"`
+	HasMoreBefore bool       `json:"has_more_before"`
+	HasMoreAfter  bool       `json:"has_more_after"`
 }
@@ -2,12 +2,57 @@ package models
 
 import "time"
 
+// Search result types
+const (
+	SearchTypeMessage  = "message"
+	SearchTypeToolCall = "tool_call"
+	SearchTypeMemory   = "memory"
+	SearchTypeSession  = "session"
+)
+
+// SearchTypes lists every searchable result type
+var SearchTypes = []string{SearchTypeMessage, SearchTypeToolCall, SearchTypeMemory, SearchTypeSession}
+
 // SearchResult represents a search result with snippet
 type SearchResult struct {
-	MessageID    int       `json:"message_id"`
-	SessionID    string    `json:"session_id"`
-	Role         string    `json:"role"`
+	Type         string    `json:"type"` // "message", "tool_call", "memory" or "session"
+	MessageID    int       `json:"message_id,omitempty"`
+	SessionID    string    `json:"session_id,omitempty"`
+	Role         string    `json:"role,omitempty"`
+	ToolUseID    string    `json:"tool_use_id,omitempty"` // Tool card to jump to
+	ToolName     string    `json:"tool_name,omitempty"`
+	MemoryID     string    `json:"memory_id,omitempty"`
 	Snippet      string    `json:"snippet"`
 	Timestamp    time.Time `json:"timestamp"`
-	SessionTitle string    `json:"session_title"`
+	SessionTitle string    `json:"session_title,omitempty"`
+	Rank         float64   `json:"-"`               // BM25 rank relative to the best hit of its index (-1), lower is better
+	Score        float64   `json:"score,omitempty"` // Semantic or hybrid relevance, higher is better
+}
+
+// Search sort orders
+const (
+	SearchSortRank = "rank" // Best matches first
+	SearchSortDate = "date" // Newest first
+)
+
+// Search modes
+const (
+	SearchModeKeyword  = "keyword"  // Full-text (BM25)
+	SearchModeSemantic = "semantic" // Embedding cosine similarity
+	SearchModeHybrid   = "hybrid"   // Both, scores merged
+)
+
+// SearchQuery is a structured search: text plus filters, sort and pagination
+type SearchQuery struct {
+	Text      string     // Words, "phrases", prefix*, OR, NOT, AND and parentheses
+	Types     []string   // Result types to search (empty means all)
+	Substring bool       // Match Text anywhere inside words (trigram index, messages only)
+	Role      string     // "user", "assistant" or "thinking"
+	SessionID string     // Restrict to one session
+	Model     string     // Model of the session
+	Since     *time.Time // Messages created at or after
+	Until     *time.Time // Messages created before
+	Sort      string     // "rank" (default) or "date"
+	Limit     int
+	Offset    int
 }
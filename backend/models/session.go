@@ -5,8 +5,8 @@ import "time"
 // Session represents a conversation session with Claude Code
 type Session struct {
 	ID              int       `json:"id"`
-	SessionID       string    `json:"session_id"`        // Internal session UUID
-	ClaudeSessionID string    `json:"claude_session_id"` // Claude Code CLI session ID for --resume
+	SessionID       string    `json:"session_id"`        // Permanent session ID
+	ClaudeSessionID string    `json:"claude_session_id"` // Current Claude SDK session ID for --resume
 	Title           string    `json:"title"`             // Auto-generated title from first message
 	Model           string    `json:"model"`             // Claude model: haiku, sonnet, opus
 	CreatedAt       time.Time `json:"created_at"`
@@ -15,4 +15,87 @@ type Session struct {
 	InputTokens  int     `json:"input_tokens"`
 	OutputTokens int     `json:"output_tokens"`
 	TotalCostUSD float64 `json:"total_cost_usd"`
+	// Organization
+	Pinned     bool       `json:"pinned"`
+	Archived   bool       `json:"archived"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	FolderID   *int       `json:"folder_id"` // nil when the session is not in a folder
+	Tags       []string   `json:"tags"`
+	// Context compaction
+	ContextTokens int        `json:"context_tokens"` // Tokens in the current Claude session since the last compaction
+	Compactions   int        `json:"compactions"`
+	CompactedAt   *time.Time `json:"compacted_at,omitempty"`
+	// Forking
+	ForkedFrom      string `json:"forked_from,omitempty"`       // Parent session
+	ForkedAtMessage *int   `json:"forked_at_message,omitempty"` // Last message copied from the parent
+	SeedPending     bool   `json:"seed_pending"`                // Next turn starts a Claude session from the transcript
+}
+
+// Reasons a session moved to another Claude SDK session
+const (
+	ClaudeSessionResume     = "resume"     // The SDK returned a new ID on resume
+	ClaudeSessionCompaction = "compaction" // Compaction started a seeded session
+	ClaudeSessionSeed       = "seed"       // A fork started its own session
+)
+
+// ClaudeSessionRecord is a Claude SDK session ID a session used before its current one
+type ClaudeSessionRecord struct {
+	ID              int       `json:"id"`
+	SessionID       string    `json:"session_id"`
+	ClaudeSessionID string    `json:"claude_session_id"`
+	Reason          string    `json:"reason"`
+	ReplacedAt      time.Time `json:"replaced_at"`
+}
+
+// Archived filter values for SessionFilter
+const (
+	ArchivedExclude = "exclude" // Default: hide archived sessions
+	ArchivedOnly    = "only"
+	ArchivedInclude = "include"
+)
+
+// Sort fields for SessionFilter
+const (
+	SessionSortActivity = "last_activity"
+	SessionSortCreated  = "created_at"
+	SessionSortTitle    = "title"
+	SessionSortCost     = "cost"
+)
+
+// SessionFilter narrows, orders and paginates a session listing
+// Pinned sessions always come first
+type SessionFilter struct {
+	Query    string // Case-insensitive substring of the title
+	Tag      string
+	FolderID *int // 0 selects sessions outside any folder
+	Pinned   *bool
+	Archived string // ArchivedExclude (default), ArchivedOnly or ArchivedInclude
+	Model    string
+	Sort     string // SessionSortActivity (default), SessionSortCreated, SessionSortTitle or SessionSortCost
+	Order    string // "desc" (default) or "asc"
+	Limit    int    // 0 returns every matching session
+	Offset   int
+}
+
+// SessionList is a page of sessions with the total number of matches
+type SessionList struct {
+	Sessions []*Session `json:"sessions"`
+	Total    int        `json:"total"`
+	Limit    int        `json:"limit"`
+	Offset   int        `json:"offset"`
+}
+
+// SessionTag is a tag with the number of sessions carrying it
+type SessionTag struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// Folder groups sessions; folders can be nested
+type Folder struct {
+	ID           int       `json:"id"`
+	Name         string    `json:"name"`
+	ParentID     *int      `json:"parent_id"` // nil for top-level folders
+	SessionCount int       `json:"session_count"`
+	CreatedAt    time.Time `json:"created_at"`
 }
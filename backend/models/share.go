@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// SessionShare is a read-only link to a session transcript
+type SessionShare struct {
+	ID              int        `json:"id"`
+	Token           string     `json:"token"` // Unguessable, part of the /share/:token URL
+	SessionID       string     `json:"session_id"`
+	HideToolOutputs bool       `json:"hide_tool_outputs"`
+	HideThinking    bool       `json:"hide_thinking"`
+	ExpiresAt       *time.Time `json:"expires_at"` // nil for a link that never expires
+	CreatedBy       string     `json:"created_by"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// Expired reports whether the link has expired at the given time
+func (s *SessionShare) Expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
+}
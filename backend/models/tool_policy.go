@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Tool policy scopes, from the broadest to the narrowest
+const (
+	PolicyScopeGlobal  = "global"
+	PolicyScopeUser    = "user"
+	PolicyScopeMachine = "machine"
+	PolicyScopeSession = "session"
+)
+
+// ToolPolicy restricts the tools Claude may use within a scope.
+// Narrower scopes can only restrict further: they never re-allow a tool
+// denied by a broader scope.
+type ToolPolicy struct {
+	ID           int       `json:"id"`
+	Scope        string    `json:"scope"`         // "global", "user", "machine" or "session"
+	ScopeID      string    `json:"scope_id"`      // User, machine or session ID; empty for global
+	AllowedTools []string  `json:"allowed_tools"` // Empty means no restriction
+	DeniedTools  []string  `json:"denied_tools"`  // "*" denies every tool
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// UsageEvent records the token usage and cost of one conversation turn
+type UsageEvent struct {
+	ID                       int       `json:"id"`
+	SessionID                string    `json:"session_id"`
+	Actor                    string    `json:"actor"`
+	Model                    string    `json:"model"`
+	InputTokens              int       `json:"input_tokens"`
+	OutputTokens             int       `json:"output_tokens"`
+	CacheCreationInputTokens int       `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int       `json:"cache_read_input_tokens"`
+	TotalCostUSD             float64   `json:"total_cost_usd"`
+	DurationMS               int64     `json:"duration_ms"` // From the user message to the usage report
+	ToolCalls                int       `json:"tool_calls"`
+	CreatedAt                time.Time `json:"created_at"`
+}
+
+// UsageFilter restricts usage aggregations (empty fields match everything)
+type UsageFilter struct {
+	Actor     string
+	Model     string
+	SessionID string
+	Since     *time.Time
+	Until     *time.Time
+}
+
+// Usage aggregation groupings
+const (
+	UsageGroupDay     = "day"
+	UsageGroupWeek    = "week" // Keyed by the Monday starting the week
+	UsageGroupModel   = "model"
+	UsageGroupSession = "session"
+	UsageGroupUser    = "user"
+)
+
+// Usage event orderings
+const (
+	UsageSortRecent   = "recent"
+	UsageSortCost     = "cost"
+	UsageSortDuration = "duration"
+	UsageSortTokens   = "tokens"
+)
+
+// UsageAggregate sums the usage events of a group
+type UsageAggregate struct {
+	Key                      string  `json:"key"`             // Day, week, model, session ID or user
+	Label                    string  `json:"label,omitempty"` // Session title when grouped by session
+	Turns                    int     `json:"turns"`
+	InputTokens              int     `json:"input_tokens"`
+	OutputTokens             int     `json:"output_tokens"`
+	CacheCreationInputTokens int     `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int     `json:"cache_read_input_tokens"`
+	TotalCostUSD             float64 `json:"total_cost_usd"`
+	DurationMS               int64   `json:"duration_ms"`
+	ToolCalls                int     `json:"tool_calls"`
+}
@@ -0,0 +1,204 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/ronan/home-agent/models"
+)
+
+// attachmentColumns lists the columns read by scanAttachment
+const attachmentColumns = `id, upload_id, session_id, message_id, position, filename, stored_name,
+	type, mime_type, size, checksum, uploaded_at`
+
+// SQLiteAttachmentRepository implements AttachmentRepository using SQLite
+type SQLiteAttachmentRepository struct {
+	db *sql.DB
+}
+
+// NewAttachmentRepository creates a new SQLite attachment repository
+func NewAttachmentRepository(db *sql.DB) AttachmentRepository {
+	return &SQLiteAttachmentRepository{db: db}
+}
+
+// scanAttachment scans a row selected with attachmentColumns
+func scanAttachment(row rowScanner) (*models.Attachment, error) {
+	var attachment models.Attachment
+	var messageID sql.NullInt64
+	err := row.Scan(&attachment.ID, &attachment.UploadID, &attachment.SessionID, &messageID, &attachment.Position,
+		&attachment.Filename, &attachment.StoredName, &attachment.Type, &attachment.MimeType,
+		&attachment.Size, &attachment.Checksum, &attachment.UploadedAt)
+	if err != nil {
+		return nil, err
+	}
+	if messageID.Valid {
+		id := int(messageID.Int64)
+		attachment.MessageID = &id
+	}
+	attachment.Path = models.AttachmentPathPrefix + attachment.StoredName
+	return &attachment, nil
+}
+
+// Create records an upload that is not sent with a message yet
+func (r *SQLiteAttachmentRepository) Create(attachment *models.Attachment) error {
+	query := `
+	INSERT INTO attachments (upload_id, filename, stored_name, type, mime_type, size, checksum, uploaded_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := r.db.Exec(query, attachment.UploadID, attachment.Filename, attachment.StoredName,
+		attachment.Type, attachment.MimeType, attachment.Size, attachment.Checksum, attachment.UploadedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create attachment: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	attachment.ID = int(id)
+	attachment.Path = models.AttachmentPathPrefix + attachment.StoredName
+
+	return nil
+}
+
+// Get returns the latest record of an upload, nil if it does not exist
+func (r *SQLiteAttachmentRepository) Get(uploadID string) (*models.Attachment, error) {
+	row := r.db.QueryRow("SELECT "+attachmentColumns+" FROM attachments WHERE upload_id = ? ORDER BY id DESC LIMIT 1", uploadID)
+	attachment, err := scanAttachment(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return attachment, nil
+}
+
+// AttachToMessage links uploads to a message, in order. The pending record of
+// an upload is used when there is one; an upload sent again is recorded anew,
+// from its latest record or, for unknown uploads, from the given fields.
+func (r *SQLiteAttachmentRepository) AttachToMessage(sessionID string, messageID int, attachments []*models.Attachment) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for position, attachment := range attachments {
+		result, err := tx.Exec(`
+		UPDATE attachments SET session_id = ?, message_id = ?, position = ?
+		WHERE id = (SELECT id FROM attachments WHERE upload_id = ? AND message_id IS NULL ORDER BY id DESC LIMIT 1)
+		`, sessionID, messageID, position, attachment.UploadID)
+		if err != nil {
+			return fmt.Errorf("failed to link attachment: %w", err)
+		}
+		if n, err := rowsAffected(result); err != nil {
+			return err
+		} else if n > 0 {
+			continue
+		}
+
+		result, err = tx.Exec(`
+		INSERT INTO attachments (upload_id, session_id, message_id, position, filename, stored_name,
+			type, mime_type, size, checksum, uploaded_at)
+		SELECT upload_id, ?, ?, ?, filename, stored_name, type, mime_type, size, checksum, uploaded_at
+		FROM attachments WHERE upload_id = ? ORDER BY id DESC LIMIT 1
+		`, sessionID, messageID, position, attachment.UploadID)
+		if err != nil {
+			return fmt.Errorf("failed to link attachment: %w", err)
+		}
+		if n, err := rowsAffected(result); err != nil {
+			return err
+		} else if n > 0 {
+			continue
+		}
+
+		_, err = tx.Exec(`
+		INSERT INTO attachments (upload_id, session_id, message_id, position, filename, stored_name,
+			type, mime_type, size, checksum, uploaded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, attachment.UploadID, sessionID, messageID, position, attachment.Filename, attachment.StoredName,
+			attachment.Type, attachment.MimeType, attachment.Size, attachment.Checksum, attachment.UploadedAt)
+		if err != nil {
+			return fmt.Errorf("failed to link attachment: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetByMessages returns the ordered attachments of each message, keyed by message ID
+func (r *SQLiteAttachmentRepository) GetByMessages(messageIDs []int) (map[int][]*models.Attachment, error) {
+	attachments := make(map[int][]*models.Attachment)
+	if len(messageIDs) == 0 {
+		return attachments, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(messageIDs)), ",")
+	args := make([]interface{}, len(messageIDs))
+	for i, id := range messageIDs {
+		args[i] = id
+	}
+
+	rows, err := r.db.Query("SELECT "+attachmentColumns+" FROM attachments WHERE message_id IN ("+placeholders+") ORDER BY message_id, position", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments[*attachment.MessageID] = append(attachments[*attachment.MessageID], attachment)
+	}
+
+	return attachments, rows.Err()
+}
+
+// DeletePending deletes the record of an upload that was never sent
+func (r *SQLiteAttachmentRepository) DeletePending(uploadID string) error {
+	if _, err := r.db.Exec("DELETE FROM attachments WHERE upload_id = ? AND message_id IS NULL", uploadID); err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	return nil
+}
+
+// ListWithoutChecksum returns the attachments whose file details are unknown,
+// such as those converted from legacy attachment markers
+func (r *SQLiteAttachmentRepository) ListWithoutChecksum() ([]*models.Attachment, error) {
+	rows, err := r.db.Query("SELECT " + attachmentColumns + " FROM attachments WHERE checksum = '' ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+	defer rows.Close()
+
+	var attachments []*models.Attachment
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, rows.Err()
+}
+
+// UpdateFileInfo sets the file details of every record of a stored file
+func (r *SQLiteAttachmentRepository) UpdateFileInfo(storedName, mimeType string, size int64, checksum string) error {
+	_, err := r.db.Exec(
+		"UPDATE attachments SET mime_type = ?, size = ?, checksum = ? WHERE stored_name = ?",
+		mimeType, size, checksum, storedName,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update attachment file info: %w", err)
+	}
+	return nil
+}
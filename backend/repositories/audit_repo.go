@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ronan/home-agent/models"
+)
+
+// SQLiteAuditRepository implements AuditRepository using SQLite
+type SQLiteAuditRepository struct {
+	db *sql.DB
+}
+
+// NewAuditRepository creates a new SQLite audit repository
+func NewAuditRepository(db *sql.DB) AuditRepository {
+	return &SQLiteAuditRepository{db: db}
+}
+
+// Record appends an event to the audit log
+func (r *SQLiteAuditRepository) Record(event *models.AuditEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	if event.Outcome == "" {
+		event.Outcome = models.AuditOutcomeSuccess
+	}
+
+	query := `
+	INSERT INTO audit_events (event_type, actor, session_id, machine_id, target, details, outcome, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := r.db.Exec(query,
+		event.EventType,
+		event.Actor,
+		event.SessionID,
+		event.MachineID,
+		event.Target,
+		event.Details,
+		event.Outcome,
+		event.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	event.ID = int(id)
+
+	return nil
+}
+
+// List retrieves audit events matching the filter, newest first, with the total count
+func (r *SQLiteAuditRepository) List(filter models.AuditFilter) ([]*models.AuditEvent, int, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.EventType != "" {
+		conditions = append(conditions, "event_type = ?")
+		args = append(args, filter.EventType)
+	}
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+	if filter.SessionID != "" {
+		conditions = append(conditions, "session_id = ?")
+		args = append(args, filter.SessionID)
+	}
+	if filter.MachineID != "" {
+		conditions = append(conditions, "machine_id = ?")
+		args = append(args, filter.MachineID)
+	}
+	if filter.Outcome != "" {
+		conditions = append(conditions, "outcome = ?")
+		args = append(args, filter.Outcome)
+	}
+	if filter.Since != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, *filter.Since)
+	}
+	if filter.Until != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, *filter.Until)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM audit_events "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit events: %w", err)
+	}
+
+	query := `
+	SELECT id, event_type, actor, COALESCE(session_id, ''), COALESCE(machine_id, ''),
+	       COALESCE(target, ''), COALESCE(details, ''), outcome, created_at
+	FROM audit_events
+	` + where + `
+	ORDER BY created_at DESC, id DESC
+	`
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.EventType,
+			&event.Actor,
+			&event.SessionID,
+			&event.MachineID,
+			&event.Target,
+			&event.Details,
+			&event.Outcome,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		events = append(events, &event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating audit events: %w", err)
+	}
+
+	return events, total, nil
+}
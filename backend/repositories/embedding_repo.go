@@ -0,0 +1,306 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ronan/home-agent/models"
+)
+
+// embeddingSnippetLength is the number of characters of a chunk shown in results
+const embeddingSnippetLength = 200
+
+// Delays before a message the provider failed on is retried, doubling with
+// each attempt
+const (
+	embeddingRetryDelay    = 5 * time.Minute
+	maxEmbeddingRetryDelay = 24 * time.Hour
+)
+
+// pendingEmbeddingCondition selects the messages of m to embed: user and
+// assistant messages with some text, not yet embedded with the model (first
+// argument) nor waiting to be retried (second argument: the current time)
+const pendingEmbeddingCondition = `m.role IN ('user', 'assistant')
+	AND TRIM(m.content, ' ' || char(9, 10, 13)) != ''
+	AND NOT EXISTS (SELECT 1 FROM message_embeddings e WHERE e.message_id = m.id AND e.model = ?1)
+	AND NOT EXISTS (SELECT 1 FROM embedding_failures f WHERE f.message_id = m.id AND f.model = ?1 AND f.retry_at > ?2)`
+
+// SQLiteEmbeddingRepository implements EmbeddingRepository using SQLite.
+// Vectors are compared in Go: a brute-force scan is fast enough for the
+// size of a personal conversation history.
+type SQLiteEmbeddingRepository struct {
+	db *sql.DB
+}
+
+// NewEmbeddingRepository creates a new SQLite embedding repository
+func NewEmbeddingRepository(db *sql.DB) EmbeddingRepository {
+	return &SQLiteEmbeddingRepository{db: db}
+}
+
+// PendingMessages returns the oldest messages not yet embedded with model.
+// Thinking blocks, blank messages and recent failures are not returned.
+func (r *SQLiteEmbeddingRepository) PendingMessages(model string, limit int) ([]*models.Message, error) {
+	query := `
+	SELECT m.id, m.session_id, m.role, m.content, m.created_at
+	FROM messages m
+	WHERE ` + pendingEmbeddingCondition + `
+	ORDER BY m.id ASC
+	LIMIT ?3
+	`
+
+	rows, err := r.db.Query(query, model, time.Now(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*models.Message
+	for rows.Next() {
+		message := &models.Message{}
+		if err := rows.Scan(&message.ID, &message.SessionID, &message.Role, &message.Content, &message.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		messages = append(messages, message)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+// Save replaces the chunks of a message for model
+func (r *SQLiteEmbeddingRepository) Save(messageID int, model string, chunks []models.EmbeddingChunk) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM message_embeddings WHERE message_id = ? AND model = ?", messageID, model); err != nil {
+		return fmt.Errorf("failed to delete embeddings: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM embedding_failures WHERE message_id = ? AND model = ?", messageID, model); err != nil {
+		return fmt.Errorf("failed to delete embedding failure: %w", err)
+	}
+
+	now := time.Now()
+	for _, chunk := range chunks {
+		_, err := tx.Exec(`
+		INSERT INTO message_embeddings (message_id, chunk_index, content, model, dimensions, vector, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, messageID, chunk.ChunkIndex, chunk.Content, model, len(chunk.Vector), encodeVector(chunk.Vector), now)
+		if err != nil {
+			return fmt.Errorf("failed to save embedding: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit embeddings: %w", err)
+	}
+
+	return nil
+}
+
+// RecordFailure records that a message could not be embedded with model. It is
+// skipped until a delay doubling with each failed attempt has passed.
+func (r *SQLiteEmbeddingRepository) RecordFailure(messageID int, model string, cause error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	attempts := 0
+	err = tx.QueryRow("SELECT attempts FROM embedding_failures WHERE message_id = ? AND model = ?", messageID, model).Scan(&attempts)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get embedding failure: %w", err)
+	}
+	attempts++
+
+	delay := maxEmbeddingRetryDelay
+	if attempts < 16 {
+		delay = min(embeddingRetryDelay<<(attempts-1), maxEmbeddingRetryDelay)
+	}
+	now := time.Now()
+	_, err = tx.Exec(`
+	INSERT INTO embedding_failures (message_id, model, attempts, error, failed_at, retry_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT(message_id, model) DO UPDATE SET
+		attempts = excluded.attempts, error = excluded.error, failed_at = excluded.failed_at, retry_at = excluded.retry_at
+	`, messageID, model, attempts, cause.Error(), now, now.Add(delay))
+	if err != nil {
+		return fmt.Errorf("failed to record embedding failure: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit embedding failure: %w", err)
+	}
+
+	return nil
+}
+
+// Search ranks messages by the cosine similarity of their best chunk to
+// vector. Role, session, model and date filters apply; text options do not.
+func (r *SQLiteEmbeddingRepository) Search(model string, vector []float32, query models.SearchQuery) ([]*models.SearchResult, int, error) {
+	conditions := []string{"e.model = ?", "e.dimensions = ?"}
+	args := []interface{}{model, len(vector)}
+
+	if query.Role != "" {
+		conditions = append(conditions, "m.role = ?")
+		args = append(args, query.Role)
+	}
+	if query.SessionID != "" {
+		conditions = append(conditions, "m.session_id = ?")
+		args = append(args, query.SessionID)
+	}
+	if query.Model != "" {
+		conditions = append(conditions, "s.model = ?")
+		args = append(args, query.Model)
+	}
+	if query.Since != nil {
+		conditions = append(conditions, "m.created_at >= ?")
+		args = append(args, *query.Since)
+	}
+	if query.Until != nil {
+		conditions = append(conditions, "m.created_at < ?")
+		args = append(args, *query.Until)
+	}
+
+	searchQuery := `
+	SELECT e.message_id, e.content, e.vector, m.session_id, m.role, m.created_at, COALESCE(s.title, '')
+	FROM message_embeddings e
+	JOIN messages m ON e.message_id = m.id
+	JOIN sessions s ON m.session_id = s.session_id
+	WHERE ` + strings.Join(conditions, " AND ")
+
+	rows, err := r.db.Query(searchQuery, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	// Keep the best chunk of each message
+	best := make(map[int]*models.SearchResult)
+	for rows.Next() {
+		result := &models.SearchResult{Type: models.SearchTypeMessage}
+		var content string
+		var blob []byte
+		if err := rows.Scan(&result.MessageID, &content, &blob, &result.SessionID, &result.Role,
+			&result.Timestamp, &result.SessionTitle); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan embedding: %w", err)
+		}
+
+		result.Score = cosineSimilarity(vector, decodeVector(blob))
+		if existing, ok := best[result.MessageID]; ok && existing.Score >= result.Score {
+			continue
+		}
+		result.Snippet = embeddingSnippet(content)
+		best[result.MessageID] = result
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating embeddings: %w", err)
+	}
+
+	results := make([]*models.SearchResult, 0, len(best))
+	for _, result := range best {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if query.Sort == models.SearchSortDate {
+			return results[i].Timestamp.After(results[j].Timestamp)
+		}
+		return results[i].Score > results[j].Score
+	})
+
+	total := len(results)
+	if query.Offset >= total {
+		return nil, total, nil
+	}
+	results = results[query.Offset:]
+	if len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+
+	return results, total, nil
+}
+
+// Stats counts the indexed and pending messages for model
+func (r *SQLiteEmbeddingRepository) Stats(model string) (*models.EmbeddingStats, error) {
+	stats := &models.EmbeddingStats{Model: model}
+
+	err := r.db.QueryRow(`
+	SELECT COUNT(DISTINCT message_id), COUNT(*) FROM message_embeddings WHERE model = ?
+	`, model).Scan(&stats.IndexedMessages, &stats.Chunks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count embeddings: %w", err)
+	}
+
+	err = r.db.QueryRow(`SELECT COUNT(*) FROM messages m WHERE `+pendingEmbeddingCondition, model, time.Now()).Scan(&stats.PendingMessages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count pending messages: %w", err)
+	}
+
+	err = r.db.QueryRow(`
+	SELECT COUNT(*) FROM embedding_failures f
+	WHERE f.model = ? AND NOT EXISTS (SELECT 1 FROM message_embeddings e WHERE e.message_id = f.message_id AND e.model = f.model)
+	`, model).Scan(&stats.FailedMessages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count failed messages: %w", err)
+	}
+
+	return stats, nil
+}
+
+// encodeVector serializes a vector as little-endian float32
+func encodeVector(vector []float32) []byte {
+	blob := make([]byte, 4*len(vector))
+	for i, value := range vector {
+		binary.LittleEndian.PutUint32(blob[4*i:], math.Float32bits(value))
+	}
+	return blob
+}
+
+// decodeVector parses a vector serialized by encodeVector
+func decodeVector(blob []byte) []float32 {
+	vector := make([]float32, len(blob)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(blob[4*i:]))
+	}
+	return vector
+}
+
+// cosineSimilarity returns the cosine of the angle between two vectors
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// embeddingSnippet returns the escaped beginning of a chunk
+func embeddingSnippet(content string) string {
+	runes := []rune(strings.TrimSpace(content))
+	if len(runes) > embeddingSnippetLength {
+		return html.EscapeString(string(runes[:embeddingSnippetLength])) + "..."
+	}
+	return html.EscapeString(string(runes))
+}
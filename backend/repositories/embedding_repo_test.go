@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ronan/home-agent/models"
+)
+
+func TestEmbeddingPendingMessages(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewEmbeddingRepository(db)
+
+	for _, message := range []struct{ role, content string }{
+		{"user", "first question"},
+		{"assistant", " \n\t "},
+		{"thinking", "not indexed"},
+		{"assistant", "an answer"},
+		{"user", "third"},
+	} {
+		_, err := db.Exec("INSERT INTO messages (session_id, role, content, created_at) VALUES ('s1', ?, ?, ?)",
+			message.role, message.content, time.Now())
+		if err != nil {
+			t.Fatalf("failed to insert message: %v", err)
+		}
+	}
+
+	pendingIDs := func() []int {
+		t.Helper()
+		messages, err := repo.PendingMessages("m", 10)
+		if err != nil {
+			t.Fatalf("PendingMessages failed: %v", err)
+		}
+		var ids []int
+		for _, message := range messages {
+			ids = append(ids, message.ID)
+		}
+		return ids
+	}
+	assertPending := func(want ...int) {
+		t.Helper()
+		if got := pendingIDs(); !reflect.DeepEqual(got, want) {
+			t.Errorf("pending messages = %v, want %v", got, want)
+		}
+	}
+
+	// Blank and thinking messages are never pending
+	assertPending(1, 4, 5)
+
+	// A failed message is skipped, the ones after it are not
+	if err := repo.RecordFailure(1, "m", errors.New("provider rejected the input")); err != nil {
+		t.Fatalf("RecordFailure failed: %v", err)
+	}
+	assertPending(4, 5)
+	stats, err := repo.Stats("m")
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.PendingMessages != 2 || stats.FailedMessages != 1 {
+		t.Errorf("stats = %+v, want 2 pending and 1 failed", stats)
+	}
+
+	// Failures are per model
+	if messages, _ := repo.PendingMessages("other", 10); len(messages) != 3 {
+		t.Errorf("%d messages pending for another model, want 3", len(messages))
+	}
+
+	// The failure is retried once its delay has passed
+	if _, err := db.Exec("UPDATE embedding_failures SET retry_at = ?", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("failed to expire failure: %v", err)
+	}
+	assertPending(1, 4, 5)
+
+	// A second failure doubles the delay
+	if err := repo.RecordFailure(1, "m", errors.New("again")); err != nil {
+		t.Fatalf("RecordFailure failed: %v", err)
+	}
+	var attempts int
+	var retryAt time.Time
+	if err := db.QueryRow("SELECT attempts, retry_at FROM embedding_failures WHERE message_id = 1").Scan(&attempts, &retryAt); err != nil {
+		t.Fatalf("failed to read failure: %v", err)
+	}
+	if delay := time.Until(retryAt); attempts != 2 || delay < 9*time.Minute || delay > 10*time.Minute {
+		t.Errorf("attempts = %d, retry in %v; want 2 and 10m", attempts, delay)
+	}
+
+	// Saving the chunks clears the failure
+	chunks := []models.EmbeddingChunk{{ChunkIndex: 0, Content: "first question", Vector: []float32{1, 0}}}
+	if err := repo.Save(1, "m", chunks); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	assertPending(4, 5)
+	if stats, _ := repo.Stats("m"); stats.IndexedMessages != 1 || stats.FailedMessages != 0 {
+		t.Errorf("stats after save = %+v", stats)
+	}
+
+	// Editing a message drops its embeddings so it is embedded again
+	if _, err := db.Exec("UPDATE messages SET content = 'edited question' WHERE id = 1"); err != nil {
+		t.Fatalf("failed to edit message: %v", err)
+	}
+	assertPending(1, 4, 5)
+	if stats, _ := repo.Stats("m"); stats.IndexedMessages != 0 {
+		t.Errorf("embeddings of the edited message kept: %+v", stats)
+	}
+}
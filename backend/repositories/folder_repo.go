@@ -0,0 +1,218 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ronan/home-agent/models"
+)
+
+// ErrFolderNotFound is returned when a folder or parent folder does not exist
+var ErrFolderNotFound = errors.New("folder not found")
+
+// ErrFolderCycle is returned when a folder would become its own ancestor
+var ErrFolderCycle = errors.New("folder cannot be moved inside itself")
+
+// SQLiteFolderRepository implements FolderRepository using SQLite
+type SQLiteFolderRepository struct {
+	db *sql.DB
+}
+
+// NewFolderRepository creates a new SQLite folder repository
+func NewFolderRepository(db *sql.DB) FolderRepository {
+	return &SQLiteFolderRepository{db: db}
+}
+
+// Create creates a folder, at the top level when parentID is nil
+func (r *SQLiteFolderRepository) Create(name string, parentID *int) (*models.Folder, error) {
+	if err := r.checkExists(parentID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result, err := r.db.Exec("INSERT INTO folders (name, parent_id, created_at) VALUES (?, ?, ?)", name, parentID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create folder: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	log.Printf("Created folder: %s (ID: %d)", name, id)
+
+	return &models.Folder{
+		ID:        int(id),
+		Name:      name,
+		ParentID:  parentID,
+		CreatedAt: now,
+	}, nil
+}
+
+// Get retrieves a folder by ID
+func (r *SQLiteFolderRepository) Get(id int) (*models.Folder, error) {
+	query := `
+	SELECT id, name, parent_id, (SELECT COUNT(*) FROM sessions WHERE sessions.folder_id = folders.id), created_at
+	FROM folders
+	WHERE id = ?
+	`
+
+	folder, err := scanFolder(r.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get folder: %w", err)
+	}
+
+	return folder, nil
+}
+
+// List retrieves all folders ordered by name; the tree is rebuilt from parent_id
+func (r *SQLiteFolderRepository) List() ([]*models.Folder, error) {
+	query := `
+	SELECT id, name, parent_id, (SELECT COUNT(*) FROM sessions WHERE sessions.folder_id = folders.id), created_at
+	FROM folders
+	ORDER BY name COLLATE NOCASE, id
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list folders: %w", err)
+	}
+	defer rows.Close()
+
+	folders := []*models.Folder{}
+	for rows.Next() {
+		folder, err := scanFolder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan folder: %w", err)
+		}
+		folders = append(folders, folder)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating folders: %w", err)
+	}
+
+	return folders, nil
+}
+
+// Update renames a folder and moves it under parentID (nil for the top level)
+func (r *SQLiteFolderRepository) Update(id int, name string, parentID *int) error {
+	if err := r.checkExists(parentID); err != nil {
+		return err
+	}
+
+	if parentID != nil {
+		// Walk up from the new parent; finding the folder itself means a cycle
+		query := `
+		WITH RECURSIVE ancestors(id) AS (
+			SELECT ?
+			UNION ALL
+			SELECT folders.parent_id FROM folders JOIN ancestors ON folders.id = ancestors.id
+			WHERE folders.parent_id IS NOT NULL
+		)
+		SELECT COUNT(*) FROM ancestors WHERE id = ?
+		`
+		var count int
+		if err := r.db.QueryRow(query, *parentID, id).Scan(&count); err != nil {
+			return fmt.Errorf("failed to check folder ancestors: %w", err)
+		}
+		if count > 0 {
+			return ErrFolderCycle
+		}
+	}
+
+	result, err := r.db.Exec("UPDATE folders SET name = ?, parent_id = ? WHERE id = ?", name, parentID, id)
+	if err != nil {
+		return fmt.Errorf("failed to update folder: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrFolderNotFound
+	}
+
+	return nil
+}
+
+// Delete deletes a folder; its sessions and subfolders move to its parent
+func (r *SQLiteFolderRepository) Delete(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var parentID sql.NullInt64
+	err = tx.QueryRow("SELECT parent_id FROM folders WHERE id = ?", id).Scan(&parentID)
+	if err == sql.ErrNoRows {
+		return ErrFolderNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get folder: %w", err)
+	}
+
+	if _, err := tx.Exec("UPDATE sessions SET folder_id = ? WHERE folder_id = ?", parentID, id); err != nil {
+		return fmt.Errorf("failed to move folder sessions: %w", err)
+	}
+
+	if _, err := tx.Exec("UPDATE folders SET parent_id = ? WHERE parent_id = ?", parentID, id); err != nil {
+		return fmt.Errorf("failed to move subfolders: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM folders WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete folder: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Deleted folder: %d", id)
+	return nil
+}
+
+// checkExists returns ErrFolderNotFound when a non-nil folder ID does not exist
+func (r *SQLiteFolderRepository) checkExists(id *int) error {
+	if id == nil {
+		return nil
+	}
+
+	var exists int
+	err := r.db.QueryRow("SELECT 1 FROM folders WHERE id = ?", *id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrFolderNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check folder: %w", err)
+	}
+
+	return nil
+}
+
+// scanFolder reads a folder row with its session count
+func scanFolder(row rowScanner) (*models.Folder, error) {
+	var folder models.Folder
+	var parentID sql.NullInt64
+	if err := row.Scan(&folder.ID, &folder.Name, &parentID, &folder.SessionCount, &folder.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		folder.ParentID = &id
+	}
+
+	return &folder, nil
+}
@@ -1,28 +1,71 @@
 package repositories
 
 import (
+	"time"
+
 	"github.com/ronan/home-agent/models"
 )
 
 // SessionRepository handles session persistence operations
 type SessionRepository interface {
 	Create(sessionID string) (*models.Session, error)
-	CreateWithModel(sessionID, model string) (*models.Session, error)
+	CreateWithModel(sessionID, claudeSessionID, model string) (*models.Session, error)
+	Import(session *models.Session) error
 	Get(sessionID string) (*models.Session, error)
 	List() ([]*models.Session, error)
+	ListFiltered(filter models.SessionFilter) ([]*models.Session, int, error)
+	ListTags() ([]*models.SessionTag, error)
 	UpdateActivity(sessionID string) error
 	UpdateTitle(sessionID, title string) error
 	UpdateModel(sessionID, model string) error
-	UpdateClaudeSessionID(sessionID, claudeSessionID string) error
-	UpdateSessionID(oldSessionID, newSessionID string) error
+	UpdateClaudeSessionID(sessionID, claudeSessionID, reason string) error
+	ListClaudeSessionHistory(sessionID string) ([]*models.ClaudeSessionRecord, error)
 	UpdateUsage(sessionID string, inputTokens, outputTokens int, totalCostUSD float64) error
+	UpdatePinned(sessionID string, pinned bool) error
+	UpdateArchived(sessionID string, archived bool) error
+	UpdateFolder(sessionID string, folderID *int) error
+	UpdateTags(sessionID string, tags []string) error
+	AddContextTokens(sessionID string, tokens int) (int, error)
+	MarkCompacted(sessionID string, contextTokens int) error
+	Fork(sourceID, forkID string, lastMessageID int) (*models.Session, error)
+	UpdateSeedPending(sessionID string, pending bool) error
 	Delete(sessionID string) error
 }
 
+// FolderRepository handles session folder persistence operations
+type FolderRepository interface {
+	Create(name string, parentID *int) (*models.Folder, error)
+	Get(id int) (*models.Folder, error)
+	List() ([]*models.Folder, error)
+	Update(id int, name string, parentID *int) error
+	Delete(id int) error
+}
+
 // MessageRepository handles message persistence operations
 type MessageRepository interface {
 	Save(sessionID, role, content string) (*models.Message, error)
+	Import(message *models.Message) error
 	GetBySession(sessionID string) ([]*models.Message, error)
+	GetPage(sessionID string, cursor models.MessageCursor) (*models.MessagePage, error)
+}
+
+// MessagePartRepository handles assistant turn parts persistence operations
+type MessagePartRepository interface {
+	Add(sessionID, turnID string, part *models.MessagePart) error
+	UpdateToolInput(turnID, toolUseID, input string) error
+	AttachTurn(turnID string, messageID int) error
+	GetByMessages(messageIDs []int) (map[int][]*models.MessagePart, error)
+}
+
+// AttachmentRepository handles uploaded file and message attachment persistence operations
+type AttachmentRepository interface {
+	Create(attachment *models.Attachment) error
+	Get(uploadID string) (*models.Attachment, error)
+	AttachToMessage(sessionID string, messageID int, attachments []*models.Attachment) error
+	GetByMessages(messageIDs []int) (map[int][]*models.Attachment, error)
+	DeletePending(uploadID string) error
+	ListWithoutChecksum() ([]*models.Attachment, error)
+	UpdateFileInfo(storedName, mimeType string, size int64, checksum string) error
 }
 
 // MemoryRepository handles memory entry persistence operations
@@ -38,6 +81,7 @@ type MemoryRepository interface {
 // ToolCallRepository handles tool call persistence operations
 type ToolCallRepository interface {
 	Create(sessionID, toolUseID, toolName, input string) (*models.ToolCall, error)
+	Import(toolCall *models.ToolCall) error
 	UpdateOutput(toolUseID, input, output, status string) error
 	Get(toolUseID string) (*models.ToolCall, error)
 	GetBySession(sessionID string) ([]*models.ToolCall, error)
@@ -63,5 +107,58 @@ type SettingsRepository interface {
 
 // SearchRepository handles full-text search operations
 type SearchRepository interface {
-	SearchMessages(query string, limit, offset int) ([]*models.SearchResult, int, error)
+	Search(query models.SearchQuery) ([]*models.SearchResult, int, error)
+}
+
+// EmbeddingRepository stores message chunk vectors for semantic search
+type EmbeddingRepository interface {
+	PendingMessages(model string, limit int) ([]*models.Message, error)
+	Save(messageID int, model string, chunks []models.EmbeddingChunk) error
+	RecordFailure(messageID int, model string, cause error) error
+	Search(model string, vector []float32, query models.SearchQuery) ([]*models.SearchResult, int, error)
+	Stats(model string) (*models.EmbeddingStats, error)
+}
+
+// AuditRepository handles the append-only audit log
+type AuditRepository interface {
+	Record(event *models.AuditEvent) error
+	List(filter models.AuditFilter) ([]*models.AuditEvent, int, error)
+}
+
+// ToolPolicyRepository handles tool allow/deny policy persistence operations
+type ToolPolicyRepository interface {
+	Get(scope, scopeID string) (*models.ToolPolicy, error)
+	List() ([]*models.ToolPolicy, error)
+	ListByScope(scope string) ([]*models.ToolPolicy, error)
+	Upsert(policy *models.ToolPolicy) error
+	Delete(scope, scopeID string) error
+}
+
+// UsageRepository handles per-turn usage persistence and aggregation
+type UsageRepository interface {
+	Record(event *models.UsageEvent) error
+	TotalCost(filter models.UsageFilter) (float64, error)
+	Aggregate(filter models.UsageFilter, groupBy string) ([]*models.UsageAggregate, error)
+	List(filter models.UsageFilter, sort string, limit, offset int) ([]*models.UsageEvent, int, error)
+}
+
+// MaintenanceRepository handles retention cleanup and database upkeep
+type MaintenanceRepository interface {
+	ArchiveInactive(before time.Time) (int, error)
+	ArchivedBefore(before time.Time) ([]string, error)
+	DeleteThinking(before time.Time) (int, error)
+	ClearToolOutputs(before time.Time) (int, error)
+	ReferencedUploads() ([]string, error)
+	DeleteUnsentAttachments(before time.Time) (int, error)
+	DeleteUnlinkedParts(before time.Time) (int, error)
+	Optimize() error
+}
+
+// ShareRepository handles session share link persistence operations
+type ShareRepository interface {
+	Create(share *models.SessionShare) error
+	Get(id int) (*models.SessionShare, error)
+	GetByToken(token string) (*models.SessionShare, error)
+	ListBySession(sessionID string) ([]*models.SessionShare, error)
+	Delete(id int) error
 }
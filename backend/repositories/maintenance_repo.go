@@ -0,0 +1,176 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ftsTables are the full-text indexes optimized after a cleanup
+var ftsTables = []string{"messages_fts", "messages_trigram", "tool_calls_fts", "memory_fts", "sessions_fts"}
+
+// SQLiteMaintenanceRepository implements MaintenanceRepository using SQLite
+type SQLiteMaintenanceRepository struct {
+	db *sql.DB
+}
+
+// NewMaintenanceRepository creates a new SQLite maintenance repository
+func NewMaintenanceRepository(db *sql.DB) MaintenanceRepository {
+	return &SQLiteMaintenanceRepository{db: db}
+}
+
+// ArchiveInactive archives unpinned sessions without activity since before
+func (r *SQLiteMaintenanceRepository) ArchiveInactive(before time.Time) (int, error) {
+	query := `
+	UPDATE sessions
+	SET archived = 1, archived_at = ?
+	WHERE archived = 0 AND pinned = 0 AND last_activity < ?
+	`
+
+	result, err := r.db.Exec(query, time.Now(), before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive inactive sessions: %w", err)
+	}
+
+	return rowsAffected(result)
+}
+
+// ArchivedBefore returns the unpinned sessions archived before the given time
+func (r *SQLiteMaintenanceRepository) ArchivedBefore(before time.Time) ([]string, error) {
+	query := `
+	SELECT session_id
+	FROM sessions
+	WHERE archived = 1 AND pinned = 0 AND COALESCE(archived_at, last_activity) < ?
+	ORDER BY id
+	`
+
+	rows, err := r.db.Query(query, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archived sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessionIDs []string
+	for rows.Next() {
+		var sessionID string
+		if err := rows.Scan(&sessionID); err != nil {
+			return nil, fmt.Errorf("failed to scan session id: %w", err)
+		}
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating archived sessions: %w", err)
+	}
+
+	return sessionIDs, nil
+}
+
+// DeleteThinking deletes thinking blocks created before the given time.
+// The count covers the thinking messages; their message parts go with them.
+func (r *SQLiteMaintenanceRepository) DeleteThinking(before time.Time) (int, error) {
+	if _, err := r.db.Exec("DELETE FROM message_parts WHERE type = 'thinking' AND created_at < ?", before); err != nil {
+		return 0, fmt.Errorf("failed to delete thinking parts: %w", err)
+	}
+
+	result, err := r.db.Exec("DELETE FROM messages WHERE role = 'thinking' AND created_at < ?", before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete thinking blocks: %w", err)
+	}
+
+	return rowsAffected(result)
+}
+
+// ClearToolOutputs empties the output of finished tool calls created before the given time,
+// in tool calls and tool_result message parts alike
+func (r *SQLiteMaintenanceRepository) ClearToolOutputs(before time.Time) (int, error) {
+	_, err := r.db.Exec("UPDATE message_parts SET content = '' WHERE type = 'tool_result' AND content != '' AND created_at < ?", before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear tool result parts: %w", err)
+	}
+
+	query := `
+	UPDATE tool_calls
+	SET output = ''
+	WHERE output != '' AND status != 'running' AND created_at < ?
+	`
+
+	result, err := r.db.Exec(query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear tool outputs: %w", err)
+	}
+
+	return rowsAffected(result)
+}
+
+// ReferencedUploads returns the stored names of the uploads sent with a message
+func (r *SQLiteMaintenanceRepository) ReferencedUploads() ([]string, error) {
+	rows, err := r.db.Query("SELECT DISTINCT stored_name FROM attachments WHERE message_id IS NOT NULL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list referenced uploads: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan upload name: %w", err)
+		}
+		names = append(names, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating referenced uploads: %w", err)
+	}
+
+	return names, nil
+}
+
+// DeleteUnsentAttachments deletes the records of uploads made before the given
+// time and never sent with a message
+func (r *SQLiteMaintenanceRepository) DeleteUnsentAttachments(before time.Time) (int, error) {
+	result, err := r.db.Exec("DELETE FROM attachments WHERE message_id IS NULL AND uploaded_at < ?", before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete unsent attachments: %w", err)
+	}
+
+	return rowsAffected(result)
+}
+
+// DeleteUnlinkedParts deletes the message parts saved before the given time
+// and never linked to a message, left by turns that did not complete
+func (r *SQLiteMaintenanceRepository) DeleteUnlinkedParts(before time.Time) (int, error) {
+	result, err := r.db.Exec("DELETE FROM message_parts WHERE message_id IS NULL AND created_at < ?", before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete unlinked message parts: %w", err)
+	}
+
+	return rowsAffected(result)
+}
+
+// Optimize merges the full-text index segments and rebuilds the database file
+// to give the space of deleted rows back to the filesystem
+func (r *SQLiteMaintenanceRepository) Optimize() error {
+	for _, table := range ftsTables {
+		query := fmt.Sprintf("INSERT INTO %s(%s) VALUES('optimize')", table, table)
+		if _, err := r.db.Exec(query); err != nil {
+			return fmt.Errorf("failed to optimize %s: %w", table, err)
+		}
+	}
+
+	if _, err := r.db.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+
+	return nil
+}
+
+// rowsAffected returns the number of rows changed by a statement
+func rowsAffected(result sql.Result) (int, error) {
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return int(count), nil
+}
@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ronan/home-agent/models"
+)
+
+func TestDeleteUnlinkedParts(t *testing.T) {
+	db := openTestDB(t)
+	parts := NewMessagePartRepository(db)
+	for _, turnID := range []string{"abandoned", "linked", "streaming"} {
+		if err := parts.Add("s1", turnID, &models.MessagePart{Type: "text", Content: turnID}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := parts.AttachTurn("linked", 1); err != nil {
+		t.Fatalf("AttachTurn failed: %v", err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if _, err := db.Exec("UPDATE message_parts SET created_at = ? WHERE turn_id IN ('abandoned', 'linked')", old); err != nil {
+		t.Fatalf("failed to age parts: %v", err)
+	}
+
+	// Only the old part never linked to a message is deleted
+	removed, err := NewMaintenanceRepository(db).DeleteUnlinkedParts(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("DeleteUnlinkedParts failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("removed %d parts, want 1", removed)
+	}
+	var remaining []string
+	rows, err := db.Query("SELECT turn_id FROM message_parts ORDER BY turn_id")
+	if err != nil {
+		t.Fatalf("failed to list parts: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var turnID string
+		rows.Scan(&turnID)
+		remaining = append(remaining, turnID)
+	}
+	if len(remaining) != 2 || remaining[0] != "linked" || remaining[1] != "streaming" {
+		t.Errorf("remaining turns = %v, want linked and streaming", remaining)
+	}
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ronan/home-agent/models"
+)
+
+// SQLiteMessagePartRepository implements MessagePartRepository using SQLite
+type SQLiteMessagePartRepository struct {
+	db *sql.DB
+}
+
+// NewMessagePartRepository creates a new SQLite message part repository
+func NewMessagePartRepository(db *sql.DB) MessagePartRepository {
+	return &SQLiteMessagePartRepository{db: db}
+}
+
+// Add saves a part of the turn turnID; the part's ID and creation time are set
+func (r *SQLiteMessagePartRepository) Add(sessionID, turnID string, part *models.MessagePart) error {
+	part.CreatedAt = time.Now()
+
+	query := `
+	INSERT INTO message_parts (session_id, turn_id, position, type, content, tool_use_id, tool_name, input, is_error, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := r.db.Exec(query, sessionID, turnID, part.Position, part.Type, part.Content,
+		part.ToolUseID, part.ToolName, part.Input, part.IsError, part.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save message part: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	part.ID = int(id)
+
+	return nil
+}
+
+// UpdateToolInput replaces the input of a tool_use part once the complete input is known
+func (r *SQLiteMessagePartRepository) UpdateToolInput(turnID, toolUseID, input string) error {
+	_, err := r.db.Exec(
+		"UPDATE message_parts SET input = ? WHERE turn_id = ? AND tool_use_id = ? AND type = 'tool_use'",
+		input, turnID, toolUseID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update tool input: %w", err)
+	}
+	return nil
+}
+
+// AttachTurn links the parts of a turn to its assistant message
+func (r *SQLiteMessagePartRepository) AttachTurn(turnID string, messageID int) error {
+	if _, err := r.db.Exec("UPDATE message_parts SET message_id = ? WHERE turn_id = ?", messageID, turnID); err != nil {
+		return fmt.Errorf("failed to attach message parts: %w", err)
+	}
+	return nil
+}
+
+// GetByMessages returns the ordered parts of each message, keyed by message ID
+func (r *SQLiteMessagePartRepository) GetByMessages(messageIDs []int) (map[int][]*models.MessagePart, error) {
+	parts := make(map[int][]*models.MessagePart)
+	if len(messageIDs) == 0 {
+		return parts, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(messageIDs)), ",")
+	args := make([]interface{}, len(messageIDs))
+	for i, id := range messageIDs {
+		args[i] = id
+	}
+
+	query := `
+	SELECT id, message_id, position, type, content, tool_use_id, tool_name, input, is_error, created_at
+	FROM message_parts
+	WHERE message_id IN (` + placeholders + `)
+	ORDER BY message_id, position
+	`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message parts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var part models.MessagePart
+		var messageID int
+		err := rows.Scan(&part.ID, &messageID, &part.Position, &part.Type, &part.Content,
+			&part.ToolUseID, &part.ToolName, &part.Input, &part.IsError, &part.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message part: %w", err)
+		}
+		part.MessageID = &messageID
+		parts[messageID] = append(parts[messageID], &part)
+	}
+
+	return parts, rows.Err()
+}
@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -9,6 +10,9 @@ import (
 	"github.com/ronan/home-agent/models"
 )
 
+// ErrMessageNotFound is returned when a cursor message is not in the session
+var ErrMessageNotFound = errors.New("message not found")
+
 // SQLiteMessageRepository implements MessageRepository using SQLite
 type SQLiteMessageRepository struct {
 	db *sql.DB
@@ -49,6 +53,27 @@ func (r *SQLiteMessageRepository) Save(sessionID, role, content string) (*models
 	}, nil
 }
 
+// Import inserts a message with its original timestamp
+func (r *SQLiteMessageRepository) Import(message *models.Message) error {
+	query := `
+	INSERT INTO messages (session_id, role, content, created_at)
+	VALUES (?, ?, ?, ?)
+	`
+
+	result, err := r.db.Exec(query, message.SessionID, message.Role, message.Content, message.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to import message: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	message.ID = int(id)
+
+	return nil
+}
+
 // GetBySession retrieves all messages for a session, ordered by creation time
 func (r *SQLiteMessageRepository) GetBySession(sessionID string) ([]*models.Message, error) {
 	query := `
@@ -86,3 +111,118 @@ func (r *SQLiteMessageRepository) GetBySession(sessionID string) ([]*models.Mess
 
 	return messages, nil
 }
+
+// GetPage retrieves a window of messages around a cursor, ordered by ID.
+// IDs follow insertion order, so they are stable cursors for lazy loading.
+func (r *SQLiteMessageRepository) GetPage(sessionID string, cursor models.MessageCursor) (*models.MessagePage, error) {
+	var messages []*models.Message
+	var err error
+
+	switch {
+	case cursor.Before > 0:
+		messages, err = r.queryMessages("id < ? ORDER BY id DESC", sessionID, cursor.Before, cursor.Limit)
+		reverseMessages(messages)
+	case cursor.After > 0:
+		messages, err = r.queryMessages("id > ? ORDER BY id ASC", sessionID, cursor.After, cursor.Limit)
+	case cursor.Around > 0:
+		var exists bool
+		err = r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM messages WHERE session_id = ? AND id = ?)",
+			sessionID, cursor.Around).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get message: %w", err)
+		}
+		if !exists {
+			return nil, ErrMessageNotFound
+		}
+
+		// Older half, then the target and the newer half
+		var older, newer []*models.Message
+		older, err = r.queryMessages("id < ? ORDER BY id DESC", sessionID, cursor.Around, cursor.Limit/2)
+		if err == nil {
+			reverseMessages(older)
+			newer, err = r.queryMessages("id >= ? ORDER BY id ASC", sessionID, cursor.Around, cursor.Limit-len(older))
+		}
+		messages = append(older, newer...)
+	default:
+		messages, err = r.queryMessages("id > ? ORDER BY id DESC", sessionID, 0, cursor.Limit)
+		reverseMessages(messages)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.MessagePage{Messages: messages}
+	if len(messages) == 0 {
+		// An empty window past either end still tells which side has more
+		switch {
+		case cursor.Before > 0:
+			page.HasMoreAfter, err = r.hasMessages(sessionID, "id >= ?", cursor.Before)
+		case cursor.After > 0:
+			page.HasMoreBefore, err = r.hasMessages(sessionID, "id <= ?", cursor.After)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return page, nil
+	}
+
+	if page.HasMoreBefore, err = r.hasMessages(sessionID, "id < ?", messages[0].ID); err != nil {
+		return nil, err
+	}
+	if page.HasMoreAfter, err = r.hasMessages(sessionID, "id > ?", messages[len(messages)-1].ID); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// queryMessages retrieves up to limit messages of a session matching condition
+func (r *SQLiteMessageRepository) queryMessages(condition, sessionID string, id, limit int) ([]*models.Message, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	query := `
+	SELECT id, session_id, role, content, created_at
+	FROM messages
+	WHERE session_id = ? AND ` + condition + `
+	LIMIT ?
+	`
+
+	rows, err := r.db.Query(query, sessionID, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*models.Message
+	for rows.Next() {
+		var msg models.Message
+		if err := rows.Scan(&msg.ID, &msg.SessionID, &msg.Role, &msg.Content, &msg.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		messages = append(messages, &msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+// hasMessages reports whether a session has messages matching condition
+func (r *SQLiteMessageRepository) hasMessages(sessionID, condition string, id int) (bool, error) {
+	var exists bool
+	query := "SELECT EXISTS(SELECT 1 FROM messages WHERE session_id = ? AND " + condition + ")"
+	if err := r.db.QueryRow(query, sessionID, id).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check messages: %w", err)
+	}
+	return exists, nil
+}
+
+func reverseMessages(messages []*models.Message) {
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+}
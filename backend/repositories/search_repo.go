@@ -2,12 +2,18 @@ package repositories
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"unicode"
 
 	"github.com/ronan/home-agent/models"
 )
 
+// ErrInvalidSearchQuery is returned when the search text is not a valid query
+var ErrInvalidSearchQuery = errors.New("invalid search query")
+
 // SQLiteSearchRepository implements SearchRepository using SQLite FTS5
 type SQLiteSearchRepository struct {
 	db *sql.DB
@@ -18,56 +24,416 @@ func NewSearchRepository(db *sql.DB) SearchRepository {
 	return &SQLiteSearchRepository{db: db}
 }
 
-// SearchMessages performs full-text search on messages
-func (r *SQLiteSearchRepository) SearchMessages(query string, limit, offset int) ([]*models.SearchResult, int, error) {
-	// Sanitize query for FTS5 (wrap in quotes to handle special characters)
-	safeQuery := `"` + strings.ReplaceAll(query, `"`, `""`) + `"`
+// Search performs a full-text search on messages, tool calls, memory and
+// session titles with filters. Each index returns its best offset+limit hits,
+// which are merged and paginated here. BM25 ranks depend on the size and
+// document lengths of their index: they are scaled by the best hit of each
+// index before being compared.
+// Word search is accent-insensitive; substring search uses the
+// messages_trigram index and only covers messages.
+func (r *SQLiteSearchRepository) Search(query models.SearchQuery) ([]*models.SearchResult, int, error) {
+	var matchQuery string
+	if query.Substring {
+		matchQuery = `"` + strings.ReplaceAll(query.Text, `"`, `""`) + `"`
+	} else {
+		var err error
+		if matchQuery, err = BuildFTSQuery(query.Text); err != nil {
+			return nil, 0, err
+		}
+	}
+	if matchQuery == "" {
+		return nil, 0, nil
+	}
+
+	fetch := query.Offset + query.Limit
+	var results []*models.SearchResult
+	total := 0
+
+	for _, searchType := range searchTypes(query) {
+		var source searchSource
+		switch searchType {
+		case models.SearchTypeMessage:
+			source = messageSource(query)
+		case models.SearchTypeToolCall:
+			source = toolCallSource(query)
+		case models.SearchTypeMemory:
+			source = memorySource(query)
+		case models.SearchTypeSession:
+			source = sessionSource(query)
+		default:
+			continue
+		}
+
+		found, count, err := r.searchSource(source, matchQuery, query.Sort, fetch)
+		if err != nil {
+			return nil, 0, err
+		}
+		results = append(results, found...)
+		total += count
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if query.Sort == models.SearchSortDate {
+			return results[i].Timestamp.After(results[j].Timestamp)
+		}
+		return results[i].Rank < results[j].Rank
+	})
+
+	if query.Offset >= len(results) {
+		return nil, total, nil
+	}
+	results = results[query.Offset:]
+	if len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+
+	return results, total, nil
+}
+
+// searchTypes returns the result types a query can match.
+// Role and substring searches only apply to messages; session and model
+// filters exclude memory, which belongs to no session.
+func searchTypes(query models.SearchQuery) []string {
+	types := query.Types
+	if len(types) == 0 {
+		types = models.SearchTypes
+	}
+
+	var allowed []string
+	for _, searchType := range types {
+		if (query.Role != "" || query.Substring) && searchType != models.SearchTypeMessage {
+			continue
+		}
+		if (query.SessionID != "" || query.Model != "") && searchType == models.SearchTypeMemory {
+			continue
+		}
+		allowed = append(allowed, searchType)
+	}
+	return allowed
+}
+
+// searchSource describes how to query one FTS index
+type searchSource struct {
+	table      string   // FTS5 table
+	joins      string   // Joins from the FTS table to the content tables
+	columns    string   // Selected columns, scanned by scan
+	dateColumn string   // Column used for date filters and sort
+	conditions []string // Filters besides MATCH
+	args       []interface{}
+	scan       func(rows *sql.Rows) (*models.SearchResult, error)
+}
+
+// searchSource runs the count and the ranked query of one index
+func (r *SQLiteSearchRepository) searchSource(source searchSource, matchQuery, sortOrder string, limit int) ([]*models.SearchResult, int, error) {
+	conditions := append([]string{source.table + " MATCH ?"}, source.conditions...)
+	args := append([]interface{}{matchQuery}, source.args...)
+
+	from := `
+		FROM ` + source.table + `
+		` + source.joins + `
+		WHERE ` + strings.Join(conditions, " AND ")
 
 	// Count total matches
 	var total int
-	countQuery := `
-		SELECT COUNT(*) FROM messages_fts
-		WHERE messages_fts MATCH ?
-	`
-	if err := r.db.QueryRow(countQuery, safeQuery).Scan(&total); err != nil {
-		return nil, 0, fmt.Errorf("failed to count search results: %w", err)
-	}
-
-	// Get results with snippets
-	searchQuery := `
-		SELECT
-			m.id,
-			m.session_id,
-			m.role,
-			snippet(messages_fts, 2, '<mark>', '</mark>', '...', 32) as snippet,
-			m.created_at,
-			COALESCE(s.title, '') as session_title
-		FROM messages_fts
-		JOIN messages m ON messages_fts.rowid = m.id
-		JOIN sessions s ON m.session_id = s.session_id
-		WHERE messages_fts MATCH ?
-		ORDER BY rank
-		LIMIT ? OFFSET ?
-	`
-
-	rows, err := r.db.Query(searchQuery, safeQuery, limit, offset)
+	if err := r.db.QueryRow("SELECT COUNT(*) "+from, args...).Scan(&total); err != nil {
+		return nil, 0, searchError("failed to count search results", err)
+	}
+
+	orderBy := source.table + ".rank"
+	if sortOrder == models.SearchSortDate {
+		orderBy = source.dateColumn + " DESC"
+	}
+
+	searchQuery := "SELECT " + source.columns + ", " + source.table + ".rank " + from +
+		" ORDER BY " + orderBy + " LIMIT ?"
+
+	rows, err := r.db.Query(searchQuery, append(args, limit)...)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to search messages: %w", err)
+		return nil, 0, searchError("failed to search "+source.table, err)
 	}
 	defer rows.Close()
 
 	var results []*models.SearchResult
 	for rows.Next() {
-		var result models.SearchResult
-		if err := rows.Scan(&result.MessageID, &result.SessionID, &result.Role, &result.Snippet, &result.Timestamp, &result.SessionTitle); err != nil {
+		result, err := source.scan(rows)
+		if err != nil {
 			return nil, 0, fmt.Errorf("failed to scan search result: %w", err)
 		}
-		results = append(results, &result)
+		results = append(results, result)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, 0, fmt.Errorf("error iterating search results: %w", err)
 	}
 
+	normalizeRanks(results)
 	return results, total, nil
 }
+
+// normalizeRanks divides the BM25 ranks of one index by the best of them,
+// which becomes -1
+func normalizeRanks(results []*models.SearchResult) {
+	best := 0.0
+	for _, result := range results {
+		best = min(best, result.Rank)
+	}
+	if best == 0 {
+		return
+	}
+	for _, result := range results {
+		result.Rank = -result.Rank / best
+	}
+}
+
+// sessionFilters adds the session, model and date filters shared by most sources
+func sessionFilters(source *searchSource, query models.SearchQuery, sessionColumn string) {
+	if query.SessionID != "" {
+		source.conditions = append(source.conditions, sessionColumn+" = ?")
+		source.args = append(source.args, query.SessionID)
+	}
+	if query.Model != "" {
+		source.conditions = append(source.conditions, "s.model = ?")
+		source.args = append(source.args, query.Model)
+	}
+	if query.Since != nil {
+		source.conditions = append(source.conditions, source.dateColumn+" >= ?")
+		source.args = append(source.args, *query.Since)
+	}
+	if query.Until != nil {
+		source.conditions = append(source.conditions, source.dateColumn+" < ?")
+		source.args = append(source.args, *query.Until)
+	}
+}
+
+// messageSource searches message contents
+func messageSource(query models.SearchQuery) searchSource {
+	table := "messages_fts"
+	snippetColumn := 2
+	if query.Substring {
+		table = "messages_trigram"
+		snippetColumn = 0
+	}
+
+	source := searchSource{
+		table: table,
+		joins: `JOIN messages m ON ` + table + `.rowid = m.id
+		JOIN sessions s ON m.session_id = s.session_id`,
+		columns: fmt.Sprintf(`m.id, m.session_id, m.role,
+			snippet(%s, %d, '<mark>', '</mark>', '...', 32),
+			m.created_at, COALESCE(s.title, '')`, table, snippetColumn),
+		dateColumn: "m.created_at",
+		scan: func(rows *sql.Rows) (*models.SearchResult, error) {
+			result := &models.SearchResult{Type: models.SearchTypeMessage}
+			err := rows.Scan(&result.MessageID, &result.SessionID, &result.Role, &result.Snippet,
+				&result.Timestamp, &result.SessionTitle, &result.Rank)
+			return result, err
+		},
+	}
+	if query.Role != "" {
+		source.conditions = append(source.conditions, "m.role = ?")
+		source.args = append(source.args, query.Role)
+	}
+	sessionFilters(&source, query, "m.session_id")
+	return source
+}
+
+// toolCallSource searches tool names, inputs and outputs
+func toolCallSource(query models.SearchQuery) searchSource {
+	source := searchSource{
+		table: "tool_calls_fts",
+		joins: `JOIN tool_calls t ON tool_calls_fts.rowid = t.id
+		JOIN sessions s ON t.session_id = s.session_id`,
+		columns: `t.session_id, t.tool_use_id, t.tool_name,
+			snippet(tool_calls_fts, -1, '<mark>', '</mark>', '...', 32),
+			t.created_at, COALESCE(s.title, '')`,
+		dateColumn: "t.created_at",
+		scan: func(rows *sql.Rows) (*models.SearchResult, error) {
+			result := &models.SearchResult{Type: models.SearchTypeToolCall}
+			err := rows.Scan(&result.SessionID, &result.ToolUseID, &result.ToolName, &result.Snippet,
+				&result.Timestamp, &result.SessionTitle, &result.Rank)
+			return result, err
+		},
+	}
+	sessionFilters(&source, query, "t.session_id")
+	return source
+}
+
+// memorySource searches memory titles and contents
+func memorySource(query models.SearchQuery) searchSource {
+	source := searchSource{
+		table:      "memory_fts",
+		joins:      `JOIN memory mem ON memory_fts.rowid = mem.rowid`,
+		columns:    `mem.id, snippet(memory_fts, -1, '<mark>', '</mark>', '...', 32), mem.updated_at, mem.title`,
+		dateColumn: "mem.updated_at",
+		scan: func(rows *sql.Rows) (*models.SearchResult, error) {
+			result := &models.SearchResult{Type: models.SearchTypeMemory}
+			err := rows.Scan(&result.MemoryID, &result.Snippet, &result.Timestamp, &result.SessionTitle, &result.Rank)
+			return result, err
+		},
+	}
+	sessionFilters(&source, query, "")
+	return source
+}
+
+// sessionSource searches session titles
+func sessionSource(query models.SearchQuery) searchSource {
+	source := searchSource{
+		table:      "sessions_fts",
+		joins:      `JOIN sessions s ON sessions_fts.rowid = s.id`,
+		columns:    `s.session_id, highlight(sessions_fts, 1, '<mark>', '</mark>'), s.last_activity, COALESCE(s.title, '')`,
+		dateColumn: "s.last_activity",
+		scan: func(rows *sql.Rows) (*models.SearchResult, error) {
+			result := &models.SearchResult{Type: models.SearchTypeSession}
+			err := rows.Scan(&result.SessionID, &result.Snippet, &result.Timestamp, &result.SessionTitle, &result.Rank)
+			return result, err
+		},
+	}
+	sessionFilters(&source, query, "s.session_id")
+	return source
+}
+
+// searchError flags FTS5 syntax errors as ErrInvalidSearchQuery
+func searchError(message string, err error) error {
+	if strings.Contains(err.Error(), "fts5") || strings.Contains(err.Error(), "syntax error") {
+		return fmt.Errorf("%w: %v", ErrInvalidSearchQuery, err)
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+// BuildFTSQuery turns user input into a safe FTS5 query.
+// Supported syntax: words, "exact phrases", prefix* searches, the OR, AND
+// and NOT operators and parentheses. Every word is quoted so that other
+// characters (apostrophes, dashes, dots...) are never read as FTS5 syntax;
+// unbalanced parentheses are dropped. An operator without a term on each side
+// returns ErrInvalidSearchQuery: dropping it would change the query's meaning
+// (a leading NOT would match the very term it excludes).
+func BuildFTSQuery(input string) (string, error) {
+	var tokens []string
+	depth := 0
+	var dangling string        // First operator found without a term on one side
+	implicit := map[int]bool{} // Positions of the ANDs added before groups
+
+	isOperator := func(token string) bool {
+		return token == "OR" || token == "AND" || token == "NOT"
+	}
+	// An operator needs an operand on its left
+	canAddOperator := func() bool {
+		if len(tokens) == 0 {
+			return false
+		}
+		last := tokens[len(tokens)-1]
+		return !isOperator(last) && last != "("
+	}
+	// Drop operators left without an operand on their right
+	trimOperators := func() {
+		for len(tokens) > 0 && isOperator(tokens[len(tokens)-1]) {
+			if dangling == "" && !implicit[len(tokens)-1] {
+				dangling = tokens[len(tokens)-1]
+			}
+			delete(implicit, len(tokens)-1)
+			tokens = tokens[:len(tokens)-1]
+		}
+	}
+	// FTS5 only joins consecutive phrases with an implicit AND: a group next
+	// to an operand needs an explicit one
+	addOperand := func(token string) {
+		if canAddOperator() && (token == "(" || tokens[len(tokens)-1] == ")") {
+			implicit[len(tokens)] = true
+			tokens = append(tokens, "AND")
+		}
+		tokens = append(tokens, token)
+	}
+
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		ch := runes[i]
+		switch {
+		case unicode.IsSpace(ch):
+			i++
+
+		case ch == '(':
+			addOperand("(")
+			depth++
+			i++
+
+		case ch == ')':
+			i++
+			if depth == 0 {
+				continue
+			}
+			trimOperators()
+			if len(tokens) > 0 && tokens[len(tokens)-1] == "(" {
+				// Empty group
+				tokens = tokens[:len(tokens)-1]
+			} else {
+				tokens = append(tokens, ")")
+			}
+			depth--
+
+		case ch == '"':
+			// Phrase up to the closing quote (or the end of the input)
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			phrase := strings.TrimSpace(string(runes[i+1 : min(end, len(runes))]))
+			i = end + 1
+			prefix := i < len(runes) && runes[i] == '*'
+			if prefix {
+				i++
+			}
+			if phrase == "" {
+				continue
+			}
+			token := `"` + strings.ReplaceAll(phrase, `"`, `""`) + `"`
+			if prefix {
+				token += "*"
+			}
+			addOperand(token)
+
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '"' && runes[end] != '(' && runes[end] != ')' {
+				end++
+			}
+			word := string(runes[i:end])
+			i = end
+
+			if isOperator(word) {
+				if canAddOperator() {
+					tokens = append(tokens, word)
+				} else if dangling == "" {
+					dangling = word
+				}
+				continue
+			}
+
+			prefix := strings.HasSuffix(word, "*")
+			word = strings.TrimRight(word, "*")
+			if word == "" {
+				continue
+			}
+			token := `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+			if prefix {
+				token += "*"
+			}
+			addOperand(token)
+		}
+	}
+
+	for ; depth > 0; depth-- {
+		trimOperators()
+		if len(tokens) > 0 && tokens[len(tokens)-1] == "(" {
+			tokens = tokens[:len(tokens)-1]
+			continue
+		}
+		tokens = append(tokens, ")")
+	}
+	// Removing an empty group can leave an operator dangling
+	trimOperators()
+
+	if dangling != "" {
+		return "", fmt.Errorf("%w: %s needs a term on each side", ErrInvalidSearchQuery, dangling)
+	}
+	return strings.Join(tokens, " "), nil
+}
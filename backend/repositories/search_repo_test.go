@@ -0,0 +1,176 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ronan/home-agent/models"
+	_ "modernc.org/sqlite"
+)
+
+// openFTS returns an in-memory FTS5 table tokenized like messages_fts
+func openFTS(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1) // Every connection would get its own memory database
+
+	_, err = db.Exec(`CREATE VIRTUAL TABLE docs USING fts5(content, tokenize='unicode61 remove_diacritics 2')`)
+	if err != nil {
+		t.Fatalf("failed to create FTS table: %v", err)
+	}
+	for _, content := range []string{
+		"L'été est chaud à Paris",
+		"the docker compose file",
+		"C'est l'heure du café",
+		"deploy (staging) or production",
+	} {
+		if _, err := db.Exec(`INSERT INTO docs(content) VALUES (?)`, content); err != nil {
+			t.Fatalf("failed to insert: %v", err)
+		}
+	}
+	return db
+}
+
+func TestBuildFTSQuery(t *testing.T) {
+	db := openFTS(t)
+
+	tests := []struct {
+		input   string
+		want    string
+		matches int
+	}{
+		{"docker", `"docker"`, 1},
+		{"docker compose", `"docker" "compose"`, 1},
+		{`"compose file"`, `"compose file"`, 1},
+		{"dock*", `"dock"*`, 1},
+		{`"docker com"*`, `"docker com"*`, 1},
+		{"*", "", 0},
+		{"**dock", `"**dock"`, 0},
+		{"docker OR café", `"docker" OR "café"`, 2},
+		{"docker AND compose", `"docker" AND "compose"`, 1},
+		{"docker NOT paris", `"docker" NOT "paris"`, 1},
+		{"(docker OR café", `( "docker" OR "café" )`, 2},
+		{"docker) OR café", `"docker" OR "café"`, 2},
+		{"((docker))) OR café (", `( ( "docker" ) ) OR "café"`, 2},
+		{"() docker", `"docker"`, 1},
+		{"docker () compose", `"docker" AND "compose"`, 1},
+		{"café (", `"café"`, 1},
+		{"docker (compose OR café)", `"docker" AND ( "compose" OR "café" )`, 1},
+		{"(docker) (café)", `( "docker" ) AND ( "café" )`, 0},
+		{"docker NOT (paris)", `"docker" NOT ( "paris" )`, 1},
+		{`"unterminated phrase`, `"unterminated phrase"`, 0},
+		{`say "hi`, `"say" "hi"`, 0},
+		{`""`, "", 0},
+		{`a"b`, `"a" "b"`, 0},
+		{"l'été", `"l'été"`, 1},
+		{"c'est", `"c'est"`, 1},
+		{"ete", `"ete"`, 1},
+		{"cafe", `"cafe"`, 1},
+		{"deploy-staging", `"deploy-staging"`, 1},
+		{"col:umn ^start +plus -minus", `"col:umn" "^start" "+plus" "-minus"`, 0},
+		{"staging or production", `"staging" "or" "production"`, 1},
+		{"   ", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := BuildFTSQuery(tt.input)
+			if err != nil {
+				t.Fatalf("BuildFTSQuery(%q) failed: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("BuildFTSQuery(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+			if got == "" {
+				return
+			}
+			var count int
+			if err := db.QueryRow(`SELECT COUNT(*) FROM docs WHERE docs MATCH ?`, got).Scan(&count); err != nil {
+				t.Fatalf("BuildFTSQuery(%q) = %q is not a valid FTS5 query: %v", tt.input, got, err)
+			}
+			if count != tt.matches {
+				t.Errorf("query %q matched %d rows, want %d", got, count, tt.matches)
+			}
+		})
+	}
+}
+
+// Dropping an operator would change the query: "NOT paris" must not become "paris"
+func TestBuildFTSQueryDanglingOperators(t *testing.T) {
+	for _, input := range []string{
+		"NOT",
+		"NOT paris",
+		"OR docker",
+		"docker OR",
+		"docker NOT",
+		"docker AND OR NOT",
+		"docker AND NOT paris",
+		"(NOT paris) docker",
+		"(docker OR) café",
+		"docker OR ()",
+		"docker OR (",
+		`docker AND ""`,
+	} {
+		got, err := BuildFTSQuery(input)
+		if !errors.Is(err, ErrInvalidSearchQuery) {
+			t.Errorf("BuildFTSQuery(%q) = %q, %v; want ErrInvalidSearchQuery", input, got, err)
+		}
+	}
+}
+
+func TestSearchMergesIndexesByRelativeRank(t *testing.T) {
+	db := openTestDB(t)
+	now := time.Now()
+	if _, err := db.Exec(`INSERT INTO sessions (session_id, title, created_at, last_activity) VALUES ('s1', 'deploy notes', ?, ?)`, now, now); err != nil {
+		t.Fatalf("failed to insert session: %v", err)
+	}
+	// Many long messages: their BM25 ranks are far from the title's
+	for i := 0; i < 20; i++ {
+		content := fmt.Sprintf("message about the deploy of the staging environment%s", strings.Repeat(" and more", i))
+		if _, err := db.Exec(`INSERT INTO messages (session_id, role, content, created_at) VALUES ('s1', 'user', ?, ?)`, content, now); err != nil {
+			t.Fatalf("failed to insert message: %v", err)
+		}
+	}
+
+	repo := NewSearchRepository(db)
+	query := models.SearchQuery{Text: "deploy", Sort: models.SearchSortRank, Limit: 5}
+	results, total, err := repo.Search(query)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if total != 21 || len(results) != 5 {
+		t.Fatalf("Search = %d results of %d, want 5 of 21", len(results), total)
+	}
+
+	// The best hit of each index ranks -1, so the title is not buried under the messages
+	best := map[string]float64{}
+	for _, result := range results {
+		if result.Rank < -1 || result.Rank >= 0 {
+			t.Errorf("%s rank %v is outside [-1, 0)", result.Type, result.Rank)
+		}
+		best[result.Type] = min(best[result.Type], result.Rank)
+	}
+	if best[models.SearchTypeMessage] != -1 || best[models.SearchTypeSession] != -1 {
+		t.Errorf("best ranks = %v, want -1 for messages and sessions", best)
+	}
+
+	// Renaming a session re-indexes its title; other updates leave the index alone
+	if _, err := db.Exec(`UPDATE sessions SET title = 'release checklist', last_activity = ? WHERE session_id = 's1'`, now); err != nil {
+		t.Fatalf("failed to rename session: %v", err)
+	}
+	if _, err := db.Exec(`UPDATE sessions SET last_activity = ? WHERE session_id = 's1'`, now.Add(time.Minute)); err != nil {
+		t.Fatalf("failed to update session: %v", err)
+	}
+	query = models.SearchQuery{Text: "checklist", Types: []string{models.SearchTypeSession}, Sort: models.SearchSortRank, Limit: 5}
+	if results, _, err := repo.Search(query); err != nil || len(results) != 1 {
+		t.Errorf("renamed session search = %v, %v", results, err)
+	}
+}
@@ -2,8 +2,10 @@ package repositories
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/ronan/home-agent/models"
@@ -21,19 +23,20 @@ func NewSessionRepository(db *sql.DB) SessionRepository {
 
 // Create creates a new session with default model (haiku)
 func (r *SQLiteSessionRepository) Create(sessionID string) (*models.Session, error) {
-	return r.CreateWithModel(sessionID, "haiku")
+	return r.CreateWithModel(sessionID, "", "haiku")
 }
 
-// CreateWithModel creates a new session with specified model
-func (r *SQLiteSessionRepository) CreateWithModel(sessionID, model string) (*models.Session, error) {
+// CreateWithModel creates a new session with specified model, resuming the
+// given Claude SDK session (none when empty)
+func (r *SQLiteSessionRepository) CreateWithModel(sessionID, claudeSessionID, model string) (*models.Session, error) {
 	now := time.Now()
 
 	query := `
 	INSERT INTO sessions (session_id, claude_session_id, title, model, created_at, last_activity)
-	VALUES (?, '', '', ?, ?, ?)
+	VALUES (?, ?, '', ?, ?, ?)
 	`
 
-	result, err := r.db.Exec(query, sessionID, model, now, now)
+	result, err := r.db.Exec(query, sessionID, claudeSessionID, model, now, now)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
@@ -48,7 +51,7 @@ func (r *SQLiteSessionRepository) CreateWithModel(sessionID, model string) (*mod
 	return &models.Session{
 		ID:              int(id),
 		SessionID:       sessionID,
-		ClaudeSessionID: "",
+		ClaudeSessionID: claudeSessionID,
 		Title:           "",
 		Model:           model,
 		CreatedAt:       now,
@@ -56,17 +59,54 @@ func (r *SQLiteSessionRepository) CreateWithModel(sessionID, model string) (*mod
 	}, nil
 }
 
-// Get retrieves a session by its session ID
-func (r *SQLiteSessionRepository) Get(sessionID string) (*models.Session, error) {
+// Import inserts a session with its original timestamps, title and usage
+func (r *SQLiteSessionRepository) Import(session *models.Session) error {
 	query := `
-	SELECT id, session_id, COALESCE(claude_session_id, ''), title, COALESCE(model, 'haiku'), created_at, last_activity,
-	       COALESCE(input_tokens, 0), COALESCE(output_tokens, 0), COALESCE(total_cost_usd, 0)
-	FROM sessions
-	WHERE session_id = ?
+	INSERT INTO sessions (session_id, claude_session_id, title, model, created_at, last_activity,
+		input_tokens, output_tokens, total_cost_usd)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
+	result, err := r.db.Exec(query,
+		session.SessionID,
+		session.ClaudeSessionID,
+		session.Title,
+		session.Model,
+		session.CreatedAt,
+		session.LastActivity,
+		session.InputTokens,
+		session.OutputTokens,
+		session.TotalCostUSD,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to import session: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	session.ID = int(id)
+
+	return nil
+}
+
+// sessionColumns is the column list read by scanSession
+const sessionColumns = `
+	sessions.id, sessions.session_id, COALESCE(sessions.claude_session_id, ''), sessions.title,
+	COALESCE(sessions.model, 'haiku'), sessions.created_at, sessions.last_activity,
+	COALESCE(sessions.input_tokens, 0), COALESCE(sessions.output_tokens, 0), COALESCE(sessions.total_cost_usd, 0),
+	sessions.pinned, sessions.archived, sessions.archived_at, sessions.folder_id, sessions.tags,
+	sessions.context_tokens, sessions.compactions, sessions.compacted_at,
+	COALESCE(sessions.forked_from, ''), sessions.forked_at_message, sessions.seed_pending`
+
+// scanSession reads a row selected with sessionColumns
+func scanSession(row rowScanner) (*models.Session, error) {
 	var session models.Session
-	err := r.db.QueryRow(query, sessionID).Scan(
+	var archivedAt, compactedAt sql.NullTime
+	var folderID, forkedAtMessage sql.NullInt64
+	var tags string
+	err := row.Scan(
 		&session.ID,
 		&session.SessionID,
 		&session.ClaudeSessionID,
@@ -77,8 +117,48 @@ func (r *SQLiteSessionRepository) Get(sessionID string) (*models.Session, error)
 		&session.InputTokens,
 		&session.OutputTokens,
 		&session.TotalCostUSD,
+		&session.Pinned,
+		&session.Archived,
+		&archivedAt,
+		&folderID,
+		&tags,
+		&session.ContextTokens,
+		&session.Compactions,
+		&compactedAt,
+		&session.ForkedFrom,
+		&forkedAtMessage,
+		&session.SeedPending,
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	if archivedAt.Valid {
+		session.ArchivedAt = &archivedAt.Time
+	}
+	if compactedAt.Valid {
+		session.CompactedAt = &compactedAt.Time
+	}
+	if forkedAtMessage.Valid {
+		id := int(forkedAtMessage.Int64)
+		session.ForkedAtMessage = &id
+	}
+	if folderID.Valid {
+		id := int(folderID.Int64)
+		session.FolderID = &id
+	}
+	if err := json.Unmarshal([]byte(tags), &session.Tags); err != nil || session.Tags == nil {
+		session.Tags = []string{}
+	}
+
+	return &session, nil
+}
+
+// Get retrieves a session by its session ID
+func (r *SQLiteSessionRepository) Get(sessionID string) (*models.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE session_id = ?`
 
+	session, err := scanSession(r.db.QueryRow(query, sessionID))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -87,17 +167,12 @@ func (r *SQLiteSessionRepository) Get(sessionID string) (*models.Session, error)
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
 
-	return &session, nil
+	return session, nil
 }
 
 // List retrieves all sessions ordered by last activity
 func (r *SQLiteSessionRepository) List() ([]*models.Session, error) {
-	query := `
-	SELECT id, session_id, COALESCE(claude_session_id, ''), title, COALESCE(model, 'haiku'), created_at, last_activity,
-	       COALESCE(input_tokens, 0), COALESCE(output_tokens, 0), COALESCE(total_cost_usd, 0)
-	FROM sessions
-	ORDER BY last_activity DESC
-	`
+	query := `SELECT ` + sessionColumns + ` FROM sessions ORDER BY last_activity DESC`
 
 	rows, err := r.db.Query(query)
 	if err != nil {
@@ -107,23 +182,11 @@ func (r *SQLiteSessionRepository) List() ([]*models.Session, error) {
 
 	var sessions []*models.Session
 	for rows.Next() {
-		var session models.Session
-		err := rows.Scan(
-			&session.ID,
-			&session.SessionID,
-			&session.ClaudeSessionID,
-			&session.Title,
-			&session.Model,
-			&session.CreatedAt,
-			&session.LastActivity,
-			&session.InputTokens,
-			&session.OutputTokens,
-			&session.TotalCostUSD,
-		)
+		session, err := scanSession(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan session: %w", err)
 		}
-		sessions = append(sessions, &session)
+		sessions = append(sessions, session)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -133,6 +196,131 @@ func (r *SQLiteSessionRepository) List() ([]*models.Session, error) {
 	return sessions, nil
 }
 
+// sessionSortColumns maps SessionFilter sort fields to SQL expressions
+var sessionSortColumns = map[string]string{
+	models.SessionSortActivity: "sessions.last_activity",
+	models.SessionSortCreated:  "sessions.created_at",
+	models.SessionSortTitle:    "sessions.title COLLATE NOCASE",
+	models.SessionSortCost:     "COALESCE(sessions.total_cost_usd, 0)",
+}
+
+// ListFiltered retrieves the sessions matching a filter, pinned sessions first,
+// along with the total number of matches
+func (r *SQLiteSessionRepository) ListFiltered(filter models.SessionFilter) ([]*models.Session, int, error) {
+	var conditions []string
+	var args []interface{}
+
+	switch filter.Archived {
+	case models.ArchivedOnly:
+		conditions = append(conditions, "sessions.archived = 1")
+	case models.ArchivedInclude:
+	default:
+		conditions = append(conditions, "sessions.archived = 0")
+	}
+	if filter.Pinned != nil {
+		conditions = append(conditions, "sessions.pinned = ?")
+		args = append(args, *filter.Pinned)
+	}
+	if filter.FolderID != nil {
+		if *filter.FolderID == 0 {
+			conditions = append(conditions, "sessions.folder_id IS NULL")
+		} else {
+			conditions = append(conditions, "sessions.folder_id = ?")
+			args = append(args, *filter.FolderID)
+		}
+	}
+	if filter.Tag != "" {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM json_each(sessions.tags) WHERE json_each.value = ?)")
+		args = append(args, filter.Tag)
+	}
+	if filter.Model != "" {
+		conditions = append(conditions, "COALESCE(sessions.model, 'haiku') = ?")
+		args = append(args, filter.Model)
+	}
+	if filter.Query != "" {
+		conditions = append(conditions, "sessions.title LIKE ? ESCAPE '\\'")
+		args = append(args, "%"+escapeLike(filter.Query)+"%")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM sessions"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count sessions: %w", err)
+	}
+
+	sortColumn, ok := sessionSortColumns[filter.Sort]
+	if !ok {
+		sortColumn = sessionSortColumns[models.SessionSortActivity]
+	}
+	order := "DESC"
+	if filter.Order == "asc" {
+		order = "ASC"
+	}
+
+	query := `SELECT ` + sessionColumns + ` FROM sessions` + where +
+		fmt.Sprintf(" ORDER BY sessions.pinned DESC, %s %s, sessions.id %s", sortColumn, order, order)
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []*models.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating sessions: %w", err)
+	}
+
+	return sessions, total, nil
+}
+
+// ListTags returns every tag in use with its number of sessions, most used first
+func (r *SQLiteSessionRepository) ListTags() ([]*models.SessionTag, error) {
+	query := `
+	SELECT json_each.value, COUNT(*)
+	FROM sessions, json_each(sessions.tags)
+	GROUP BY json_each.value
+	ORDER BY COUNT(*) DESC, json_each.value
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []*models.SessionTag{}
+	for rows.Next() {
+		var tag models.SessionTag
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan session tag: %w", err)
+		}
+		tags = append(tags, &tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating session tags: %w", err)
+	}
+
+	return tags, nil
+}
+
 // UpdateActivity updates the last activity timestamp for a session
 func (r *SQLiteSessionRepository) UpdateActivity(sessionID string) error {
 	query := `
@@ -209,75 +397,74 @@ func (r *SQLiteSessionRepository) UpdateModel(sessionID, model string) error {
 	return nil
 }
 
-// UpdateClaudeSessionID updates the Claude CLI session ID
-func (r *SQLiteSessionRepository) UpdateClaudeSessionID(sessionID, claudeSessionID string) error {
-	query := `
-	UPDATE sessions
-	SET claude_session_id = ?
-	WHERE session_id = ?
-	`
-
-	result, err := r.db.Exec(query, claudeSessionID, sessionID)
-	if err != nil {
-		return fmt.Errorf("failed to update claude session id: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("session not found: %s", sessionID)
-	}
-
-	log.Printf("Updated Claude session ID for %s: %s", sessionID, claudeSessionID)
-	return nil
-}
-
-// UpdateSessionID updates the session_id of a session and all related records
-func (r *SQLiteSessionRepository) UpdateSessionID(oldSessionID, newSessionID string) error {
+// UpdateClaudeSessionID points a session at another Claude SDK session.
+// The replaced SDK session ID is kept in the history with the reason of the change.
+func (r *SQLiteSessionRepository) UpdateClaudeSessionID(sessionID, claudeSessionID, reason string) error {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Update messages first (they reference session_id)
-	_, err = tx.Exec("UPDATE messages SET session_id = ? WHERE session_id = ?", newSessionID, oldSessionID)
-	if err != nil {
-		return fmt.Errorf("failed to update messages session_id: %w", err)
+	var current string
+	err = tx.QueryRow("SELECT COALESCE(claude_session_id, '') FROM sessions WHERE session_id = ?", sessionID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("session not found: %s", sessionID)
 	}
-
-	// Update tool calls
-	_, err = tx.Exec("UPDATE tool_calls SET session_id = ? WHERE session_id = ?", newSessionID, oldSessionID)
 	if err != nil {
-		return fmt.Errorf("failed to update tool_calls session_id: %w", err)
+		return fmt.Errorf("failed to get claude session id: %w", err)
 	}
-
-	// Update session
-	result, err := tx.Exec("UPDATE sessions SET session_id = ? WHERE session_id = ?", newSessionID, oldSessionID)
-	if err != nil {
-		return fmt.Errorf("failed to update session_id: %w", err)
+	if current == claudeSessionID {
+		return nil
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+	if current != "" {
+		_, err = tx.Exec(`
+		INSERT INTO claude_session_history (session_id, claude_session_id, reason, replaced_at)
+		VALUES (?, ?, ?, ?)
+		`, sessionID, current, reason, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to record claude session history: %w", err)
+		}
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("session not found: %s", oldSessionID)
+	if _, err := tx.Exec("UPDATE sessions SET claude_session_id = ? WHERE session_id = ?", claudeSessionID, sessionID); err != nil {
+		return fmt.Errorf("failed to update claude session id: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	log.Printf("Updated session ID: %s -> %s", oldSessionID, newSessionID)
+	log.Printf("Updated Claude session ID for %s: %s -> %s (%s)", sessionID, current, claudeSessionID, reason)
 	return nil
 }
 
+// ListClaudeSessionHistory returns the SDK session IDs a session used before its current one, oldest first
+func (r *SQLiteSessionRepository) ListClaudeSessionHistory(sessionID string) ([]*models.ClaudeSessionRecord, error) {
+	rows, err := r.db.Query(`
+	SELECT id, session_id, claude_session_id, reason, replaced_at
+	FROM claude_session_history
+	WHERE session_id = ?
+	ORDER BY id
+	`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list claude session history: %w", err)
+	}
+	defer rows.Close()
+
+	records := []*models.ClaudeSessionRecord{}
+	for rows.Next() {
+		var record models.ClaudeSessionRecord
+		if err := rows.Scan(&record.ID, &record.SessionID, &record.ClaudeSessionID, &record.Reason, &record.ReplacedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan claude session history: %w", err)
+		}
+		records = append(records, &record)
+	}
+
+	return records, rows.Err()
+}
+
 // Delete deletes a session and all related records
 func (r *SQLiteSessionRepository) Delete(sessionID string) error {
 	// Delete messages first (foreign key)
@@ -286,12 +473,42 @@ func (r *SQLiteSessionRepository) Delete(sessionID string) error {
 		return fmt.Errorf("failed to delete messages: %w", err)
 	}
 
+	// Delete the parts of assistant turns
+	_, err = r.db.Exec("DELETE FROM message_parts WHERE session_id = ?", sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete message parts: %w", err)
+	}
+
+	// Forget the files sent in the session; unreferenced uploads are removed by retention
+	_, err = r.db.Exec("DELETE FROM attachments WHERE session_id = ?", sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete attachments: %w", err)
+	}
+
 	// Delete tool calls
 	_, err = r.db.Exec("DELETE FROM tool_calls WHERE session_id = ?", sessionID)
 	if err != nil {
 		return fmt.Errorf("failed to delete tool calls: %w", err)
 	}
 
+	// Delete the session's tool policy
+	_, err = r.db.Exec("DELETE FROM tool_policies WHERE scope = 'session' AND scope_id = ?", sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete tool policy: %w", err)
+	}
+
+	// Revoke the session's share links
+	_, err = r.db.Exec("DELETE FROM session_shares WHERE session_id = ?", sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete share links: %w", err)
+	}
+
+	// Forget the SDK sessions it used
+	_, err = r.db.Exec("DELETE FROM claude_session_history WHERE session_id = ?", sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete claude session history: %w", err)
+	}
+
 	// Delete session
 	result, err := r.db.Exec("DELETE FROM sessions WHERE session_id = ?", sessionID)
 	if err != nil {
@@ -335,3 +552,185 @@ func (r *SQLiteSessionRepository) UpdateUsage(sessionID string, inputTokens, out
 
 	return nil
 }
+
+// UpdatePinned pins or unpins a session
+func (r *SQLiteSessionRepository) UpdatePinned(sessionID string, pinned bool) error {
+	return r.update(sessionID, "pinned", "UPDATE sessions SET pinned = ? WHERE session_id = ?", pinned, sessionID)
+}
+
+// UpdateArchived archives or restores a session; archived_at keeps the first archive time
+func (r *SQLiteSessionRepository) UpdateArchived(sessionID string, archived bool) error {
+	query := "UPDATE sessions SET archived = ?, archived_at = CASE WHEN ? THEN COALESCE(archived_at, ?) END WHERE session_id = ?"
+	return r.update(sessionID, "archived", query, archived, archived, time.Now(), sessionID)
+}
+
+// UpdateFolder moves a session into a folder, or out of any folder when folderID is nil
+func (r *SQLiteSessionRepository) UpdateFolder(sessionID string, folderID *int) error {
+	return r.update(sessionID, "folder", "UPDATE sessions SET folder_id = ? WHERE session_id = ?", folderID, sessionID)
+}
+
+// UpdateTags replaces the tags of a session
+func (r *SQLiteSessionRepository) UpdateTags(sessionID string, tags []string) error {
+	if tags == nil {
+		tags = []string{}
+	}
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return fmt.Errorf("failed to encode session tags: %w", err)
+	}
+	return r.update(sessionID, "tags", "UPDATE sessions SET tags = ? WHERE session_id = ?", string(data), sessionID)
+}
+
+// update runs a single-session UPDATE and reports a missing session
+func (r *SQLiteSessionRepository) update(sessionID, field, query string, args ...interface{}) error {
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update session %s: %w", field, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	return nil
+}
+
+// escapeLike escapes LIKE wildcards so a user query matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// AddContextTokens adds tokens to the context usage of a session and returns the new total
+func (r *SQLiteSessionRepository) AddContextTokens(sessionID string, tokens int) (int, error) {
+	var total int
+	err := r.db.QueryRow(
+		"UPDATE sessions SET context_tokens = context_tokens + ? WHERE session_id = ? RETURNING context_tokens",
+		tokens, sessionID,
+	).Scan(&total)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("session not found: %s", sessionID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to update session context tokens: %w", err)
+	}
+	return total, nil
+}
+
+// MarkCompacted records a compaction and resets the context usage to the seeded session's
+func (r *SQLiteSessionRepository) MarkCompacted(sessionID string, contextTokens int) error {
+	return r.update(sessionID, "compaction",
+		"UPDATE sessions SET context_tokens = ?, compactions = compactions + 1, compacted_at = ? WHERE session_id = ?",
+		contextTokens, time.Now(), sessionID)
+}
+
+// Fork creates forkID as a copy of a session with its messages up to lastMessageID,
+// the tool calls made until then and its tool policy. The fork is marked as
+// needing a seeded Claude session, since it has none of its own yet.
+func (r *SQLiteSessionRepository) Fork(sourceID, forkID string, lastMessageID int) (*models.Session, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(`
+	INSERT INTO sessions (session_id, claude_session_id, title, model, created_at, last_activity,
+		folder_id, tags, forked_from, forked_at_message, seed_pending)
+	SELECT ?, '', title, model, ?, ?, folder_id, tags, session_id, ?, 1
+	FROM sessions WHERE session_id = ?
+	`, forkID, now, now, lastMessageID, sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fork: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return nil, fmt.Errorf("session not found: %s", sourceID)
+	}
+
+	_, err = tx.Exec(`
+	INSERT INTO messages (session_id, role, content, created_at)
+	SELECT ?, role, content, created_at FROM messages
+	WHERE session_id = ? AND id <= ?
+	ORDER BY id
+	`, forkID, sourceID, lastMessageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy messages: %w", err)
+	}
+
+	// Copies keep the order of the originals: the nth message of the source maps to the nth of the fork
+	_, err = tx.Exec(`
+	INSERT INTO message_parts (session_id, message_id, turn_id, position, type, content,
+		tool_use_id, tool_name, input, is_error, created_at)
+	SELECT ?, dst.id, p.turn_id || ':' || ?, p.position, p.type, p.content,
+		CASE WHEN p.tool_use_id = '' THEN '' ELSE p.tool_use_id || ':' || ? END,
+		p.tool_name, p.input, p.is_error, p.created_at
+	FROM message_parts p
+	JOIN (SELECT id, ROW_NUMBER() OVER (ORDER BY id) AS n FROM messages WHERE session_id = ? AND id <= ?) src
+		ON src.id = p.message_id
+	JOIN (SELECT id, ROW_NUMBER() OVER (ORDER BY id) AS n FROM messages WHERE session_id = ?) dst
+		ON dst.n = src.n
+	ORDER BY p.message_id, p.position
+	`, forkID, forkID, forkID, sourceID, lastMessageID, forkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy message parts: %w", err)
+	}
+
+	_, err = tx.Exec(`
+	INSERT INTO attachments (upload_id, session_id, message_id, position, filename, stored_name,
+		type, mime_type, size, checksum, uploaded_at)
+	SELECT a.upload_id, ?, dst.id, a.position, a.filename, a.stored_name,
+		a.type, a.mime_type, a.size, a.checksum, a.uploaded_at
+	FROM attachments a
+	JOIN (SELECT id, ROW_NUMBER() OVER (ORDER BY id) AS n FROM messages WHERE session_id = ? AND id <= ?) src
+		ON src.id = a.message_id
+	JOIN (SELECT id, ROW_NUMBER() OVER (ORDER BY id) AS n FROM messages WHERE session_id = ?) dst
+		ON dst.n = src.n
+	ORDER BY a.message_id, a.position
+	`, forkID, sourceID, lastMessageID, forkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy attachments: %w", err)
+	}
+
+	// Tool calls are tied to messages by time; tool_use_id must stay unique
+	_, err = tx.Exec(`
+	INSERT INTO tool_calls (session_id, tool_use_id, tool_name, input, output, status, created_at, completed_at)
+	SELECT ?, tool_use_id || ':' || ?, tool_name, input, output, status, created_at, completed_at
+	FROM tool_calls
+	WHERE session_id = ? AND created_at <= (SELECT created_at FROM messages WHERE id = ?)
+	ORDER BY id
+	`, forkID, forkID, sourceID, lastMessageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy tool calls: %w", err)
+	}
+
+	_, err = tx.Exec(`
+	INSERT INTO tool_policies (scope, scope_id, allowed_tools, denied_tools, created_at, updated_at)
+	SELECT scope, ?, allowed_tools, denied_tools, ?, ?
+	FROM tool_policies WHERE scope = 'session' AND scope_id = ?
+	`, forkID, now, now, sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy tool policy: %w", err)
+	}
+
+	session, err := scanSession(tx.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE session_id = ?", forkID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fork: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Forked session %s into %s at message %d", sourceID, forkID, lastMessageID)
+	return session, nil
+}
+
+// UpdateSeedPending marks whether the next turn must seed a new Claude session
+func (r *SQLiteSessionRepository) UpdateSeedPending(sessionID string, pending bool) error {
+	return r.update(sessionID, "seed", "UPDATE sessions SET seed_pending = ? WHERE session_id = ?", pending, sessionID)
+}
@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/ronan/home-agent/models"
+)
+
+// SQLiteShareRepository implements ShareRepository using SQLite
+type SQLiteShareRepository struct {
+	db *sql.DB
+}
+
+// NewShareRepository creates a new SQLite share repository
+func NewShareRepository(db *sql.DB) ShareRepository {
+	return &SQLiteShareRepository{db: db}
+}
+
+// Create stores a share link and sets its ID
+func (r *SQLiteShareRepository) Create(share *models.SessionShare) error {
+	query := `
+	INSERT INTO session_shares (token, session_id, hide_tool_outputs, hide_thinking, expires_at, created_by, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := r.db.Exec(query,
+		share.Token,
+		share.SessionID,
+		share.HideToolOutputs,
+		share.HideThinking,
+		share.ExpiresAt,
+		share.CreatedBy,
+		share.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create share: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	share.ID = int(id)
+
+	log.Printf("Created share %d for session %s", share.ID, share.SessionID)
+	return nil
+}
+
+// Get retrieves a share link by ID
+func (r *SQLiteShareRepository) Get(id int) (*models.SessionShare, error) {
+	return r.getBy("id", id)
+}
+
+// GetByToken retrieves a share link by token
+func (r *SQLiteShareRepository) GetByToken(token string) (*models.SessionShare, error) {
+	return r.getBy("token", token)
+}
+
+// getBy retrieves a share link by a unique column
+func (r *SQLiteShareRepository) getBy(column string, value interface{}) (*models.SessionShare, error) {
+	query := `
+	SELECT id, token, session_id, hide_tool_outputs, hide_thinking, expires_at, created_by, created_at
+	FROM session_shares
+	WHERE ` + column + ` = ?`
+
+	share, err := scanShare(r.db.QueryRow(query, value))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share: %w", err)
+	}
+
+	return share, nil
+}
+
+// ListBySession retrieves the share links of a session, newest first
+func (r *SQLiteShareRepository) ListBySession(sessionID string) ([]*models.SessionShare, error) {
+	query := `
+	SELECT id, token, session_id, hide_tool_outputs, hide_thinking, expires_at, created_by, created_at
+	FROM session_shares
+	WHERE session_id = ?
+	ORDER BY id DESC
+	`
+
+	rows, err := r.db.Query(query, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shares: %w", err)
+	}
+	defer rows.Close()
+
+	shares := []*models.SessionShare{}
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan share: %w", err)
+		}
+		shares = append(shares, share)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating shares: %w", err)
+	}
+
+	return shares, nil
+}
+
+// Delete revokes a share link
+func (r *SQLiteShareRepository) Delete(id int) error {
+	result, err := r.db.Exec("DELETE FROM session_shares WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete share: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("share not found: %d", id)
+	}
+
+	log.Printf("Revoked share %d", id)
+	return nil
+}
+
+// scanShare reads a share link row
+func scanShare(row rowScanner) (*models.SessionShare, error) {
+	var share models.SessionShare
+	var expiresAt sql.NullTime
+	err := row.Scan(
+		&share.ID,
+		&share.Token,
+		&share.SessionID,
+		&share.HideToolOutputs,
+		&share.HideThinking,
+		&expiresAt,
+		&share.CreatedBy,
+		&share.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		share.ExpiresAt = &expiresAt.Time
+	}
+
+	return &share, nil
+}
@@ -52,6 +52,36 @@ func (r *SQLiteToolCallRepository) Create(sessionID, toolUseID, toolName, input
 	}, nil
 }
 
+// Import inserts a finished tool call with its original timestamps
+func (r *SQLiteToolCallRepository) Import(toolCall *models.ToolCall) error {
+	query := `
+	INSERT INTO tool_calls (session_id, tool_use_id, tool_name, input, output, status, created_at, completed_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := r.db.Exec(query,
+		toolCall.SessionID,
+		toolCall.ToolUseID,
+		toolCall.ToolName,
+		toolCall.Input,
+		toolCall.Output,
+		toolCall.Status,
+		toolCall.CreatedAt,
+		toolCall.CompletedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to import tool call: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	toolCall.ID = int(id)
+
+	return nil
+}
+
 // UpdateOutput updates a tool call with its input, output, and status
 func (r *SQLiteToolCallRepository) UpdateOutput(toolUseID, input, output, status string) error {
 	now := time.Now()
@@ -0,0 +1,198 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ronan/home-agent/models"
+)
+
+// SQLiteToolPolicyRepository implements ToolPolicyRepository using SQLite
+type SQLiteToolPolicyRepository struct {
+	db *sql.DB
+}
+
+// NewToolPolicyRepository creates a new SQLite tool policy repository
+func NewToolPolicyRepository(db *sql.DB) ToolPolicyRepository {
+	return &SQLiteToolPolicyRepository{db: db}
+}
+
+// Get retrieves the policy of a scope, or nil if none is defined
+func (r *SQLiteToolPolicyRepository) Get(scope, scopeID string) (*models.ToolPolicy, error) {
+	query := `
+	SELECT id, scope, scope_id, allowed_tools, denied_tools, created_at, updated_at
+	FROM tool_policies
+	WHERE scope = ? AND scope_id = ?
+	`
+
+	policy, err := scanToolPolicy(r.db.QueryRow(query, scope, scopeID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tool policy: %w", err)
+	}
+
+	return policy, nil
+}
+
+// List retrieves all policies, broadest scope first
+func (r *SQLiteToolPolicyRepository) List() ([]*models.ToolPolicy, error) {
+	query := `
+	SELECT id, scope, scope_id, allowed_tools, denied_tools, created_at, updated_at
+	FROM tool_policies
+	ORDER BY CASE scope WHEN 'global' THEN 0 WHEN 'user' THEN 1 WHEN 'machine' THEN 2 ELSE 3 END, scope_id
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tool policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []*models.ToolPolicy
+	for rows.Next() {
+		policy, err := scanToolPolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tool policy: %w", err)
+		}
+		policies = append(policies, policy)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tool policies: %w", err)
+	}
+
+	return policies, nil
+}
+
+// ListByScope retrieves all policies of a scope
+func (r *SQLiteToolPolicyRepository) ListByScope(scope string) ([]*models.ToolPolicy, error) {
+	query := `
+	SELECT id, scope, scope_id, allowed_tools, denied_tools, created_at, updated_at
+	FROM tool_policies
+	WHERE scope = ?
+	ORDER BY scope_id
+	`
+
+	rows, err := r.db.Query(query, scope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tool policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []*models.ToolPolicy
+	for rows.Next() {
+		policy, err := scanToolPolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tool policy: %w", err)
+		}
+		policies = append(policies, policy)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tool policies: %w", err)
+	}
+
+	return policies, nil
+}
+
+// Upsert creates or replaces the policy of a scope
+func (r *SQLiteToolPolicyRepository) Upsert(policy *models.ToolPolicy) error {
+	if policy.AllowedTools == nil {
+		policy.AllowedTools = []string{}
+	}
+	if policy.DeniedTools == nil {
+		policy.DeniedTools = []string{}
+	}
+
+	allowed, err := json.Marshal(policy.AllowedTools)
+	if err != nil {
+		return fmt.Errorf("failed to marshal allowed tools: %w", err)
+	}
+	denied, err := json.Marshal(policy.DeniedTools)
+	if err != nil {
+		return fmt.Errorf("failed to marshal denied tools: %w", err)
+	}
+
+	query := `
+	INSERT INTO tool_policies (scope, scope_id, allowed_tools, denied_tools, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT(scope, scope_id) DO UPDATE SET allowed_tools = ?, denied_tools = ?, updated_at = ?
+	`
+
+	now := time.Now()
+	_, err = r.db.Exec(query,
+		policy.Scope, policy.ScopeID, string(allowed), string(denied), now, now,
+		string(allowed), string(denied), now,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save tool policy: %w", err)
+	}
+
+	log.Printf("Tool policy updated: %s %s", policy.Scope, policy.ScopeID)
+
+	saved, err := r.Get(policy.Scope, policy.ScopeID)
+	if err != nil {
+		return err
+	}
+	if saved != nil {
+		*policy = *saved
+	}
+	return nil
+}
+
+// Delete removes the policy of a scope
+func (r *SQLiteToolPolicyRepository) Delete(scope, scopeID string) error {
+	result, err := r.db.Exec("DELETE FROM tool_policies WHERE scope = ? AND scope_id = ?", scope, scopeID)
+	if err != nil {
+		return fmt.Errorf("failed to delete tool policy: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("tool policy not found: %s %s", scope, scopeID)
+	}
+
+	log.Printf("Tool policy deleted: %s %s", scope, scopeID)
+	return nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanToolPolicy scans a tool policy row and decodes its tool lists
+func scanToolPolicy(row rowScanner) (*models.ToolPolicy, error) {
+	var policy models.ToolPolicy
+	var allowed, denied string
+	err := row.Scan(
+		&policy.ID,
+		&policy.Scope,
+		&policy.ScopeID,
+		&allowed,
+		&denied,
+		&policy.CreatedAt,
+		&policy.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(allowed), &policy.AllowedTools); err != nil {
+		return nil, fmt.Errorf("invalid allowed_tools: %w", err)
+	}
+	if err := json.Unmarshal([]byte(denied), &policy.DeniedTools); err != nil {
+		return nil, fmt.Errorf("invalid denied_tools: %w", err)
+	}
+
+	return &policy, nil
+}
@@ -0,0 +1,233 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ronan/home-agent/models"
+)
+
+// SQLiteUsageRepository implements UsageRepository using SQLite
+type SQLiteUsageRepository struct {
+	db *sql.DB
+}
+
+// NewUsageRepository creates a new SQLite usage repository
+func NewUsageRepository(db *sql.DB) UsageRepository {
+	return &SQLiteUsageRepository{db: db}
+}
+
+// Record stores the usage of a conversation turn
+func (r *SQLiteUsageRepository) Record(event *models.UsageEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	query := `
+	INSERT INTO usage_events (session_id, actor, model, input_tokens, output_tokens,
+		cache_creation_input_tokens, cache_read_input_tokens, total_cost_usd, duration_ms, tool_calls, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := r.db.Exec(query,
+		event.SessionID,
+		event.Actor,
+		event.Model,
+		event.InputTokens,
+		event.OutputTokens,
+		event.CacheCreationInputTokens,
+		event.CacheReadInputTokens,
+		event.TotalCostUSD,
+		event.DurationMS,
+		event.ToolCalls,
+		event.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record usage event: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	event.ID = int(id)
+
+	return nil
+}
+
+// TotalCost sums the cost of the usage events matching the filter
+func (r *SQLiteUsageRepository) TotalCost(filter models.UsageFilter) (float64, error) {
+	where, args := usageWhere(filter)
+
+	var total float64
+	err := r.db.QueryRow("SELECT COALESCE(SUM(total_cost_usd), 0) FROM usage_events "+where, args...).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum usage cost: %w", err)
+	}
+
+	return total, nil
+}
+
+// usageGroupKeys maps aggregation groupings to their key expression.
+// Timestamps are stored as text starting with the local date.
+var usageGroupKeys = map[string]string{
+	models.UsageGroupDay:     "substr(created_at, 1, 10)",
+	models.UsageGroupWeek:    "date(substr(created_at, 1, 10), 'weekday 0', '-6 days')",
+	models.UsageGroupModel:   "model",
+	models.UsageGroupSession: "session_id",
+	models.UsageGroupUser:    "actor",
+}
+
+// Aggregate sums the usage events matching the filter by group.
+// Time groupings are in chronological order, the others by decreasing cost.
+// An empty groupBy returns a single total keyed "total", zero when nothing matches.
+func (r *SQLiteUsageRepository) Aggregate(filter models.UsageFilter, groupBy string) ([]*models.UsageAggregate, error) {
+	where, args := usageWhere(filter)
+
+	// Without GROUP BY the aggregate query returns its row even for no events
+	key, group := "'total'", ""
+	if groupBy != "" {
+		var ok bool
+		if key, ok = usageGroupKeys[groupBy]; !ok {
+			return nil, fmt.Errorf("invalid usage grouping: %s", groupBy)
+		}
+		group = "GROUP BY usage_key"
+	}
+
+	label := "''"
+	if groupBy == models.UsageGroupSession {
+		label = "COALESCE((SELECT title FROM sessions WHERE sessions.session_id = usage_events.session_id), '')"
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s AS usage_key, %s, COUNT(*),
+		COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0),
+		COALESCE(SUM(cache_creation_input_tokens), 0), COALESCE(SUM(cache_read_input_tokens), 0),
+		COALESCE(SUM(total_cost_usd), 0), COALESCE(SUM(duration_ms), 0), COALESCE(SUM(tool_calls), 0)
+	FROM usage_events
+	%s
+	%s
+	`, key, label, where, group)
+	if groupBy == models.UsageGroupDay || groupBy == models.UsageGroupWeek {
+		query += " ORDER BY usage_key"
+	} else {
+		query += " ORDER BY SUM(total_cost_usd) DESC, usage_key"
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate usage: %w", err)
+	}
+	defer rows.Close()
+
+	aggregates := []*models.UsageAggregate{}
+	for rows.Next() {
+		var a models.UsageAggregate
+		var key sql.NullString
+		err := rows.Scan(&key, &a.Label, &a.Turns, &a.InputTokens, &a.OutputTokens,
+			&a.CacheCreationInputTokens, &a.CacheReadInputTokens, &a.TotalCostUSD, &a.DurationMS, &a.ToolCalls)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan usage aggregate: %w", err)
+		}
+		a.Key = key.String
+		aggregates = append(aggregates, &a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating usage aggregates: %w", err)
+	}
+
+	return aggregates, nil
+}
+
+// usageSortColumns maps usage event orderings to SQL
+var usageSortColumns = map[string]string{
+	models.UsageSortRecent:   "created_at DESC, id DESC",
+	models.UsageSortCost:     "total_cost_usd DESC, id DESC",
+	models.UsageSortDuration: "duration_ms DESC, id DESC",
+	models.UsageSortTokens:   "(input_tokens + output_tokens) DESC, id DESC",
+}
+
+// List returns a page of usage events matching the filter and their total count.
+// A zero limit returns every match.
+func (r *SQLiteUsageRepository) List(filter models.UsageFilter, sort string, limit, offset int) ([]*models.UsageEvent, int, error) {
+	where, args := usageWhere(filter)
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM usage_events "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count usage events: %w", err)
+	}
+
+	order, ok := usageSortColumns[sort]
+	if !ok {
+		order = usageSortColumns[models.UsageSortRecent]
+	}
+
+	query := `
+	SELECT id, session_id, actor, model, COALESCE(input_tokens, 0), COALESCE(output_tokens, 0),
+		COALESCE(cache_creation_input_tokens, 0), COALESCE(cache_read_input_tokens, 0),
+		COALESCE(total_cost_usd, 0), duration_ms, tool_calls, created_at
+	FROM usage_events
+	` + where + " ORDER BY " + order
+	if limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, offset)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list usage events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*models.UsageEvent{}
+	for rows.Next() {
+		var e models.UsageEvent
+		err := rows.Scan(&e.ID, &e.SessionID, &e.Actor, &e.Model, &e.InputTokens, &e.OutputTokens,
+			&e.CacheCreationInputTokens, &e.CacheReadInputTokens, &e.TotalCostUSD, &e.DurationMS, &e.ToolCalls, &e.CreatedAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan usage event: %w", err)
+		}
+		events = append(events, &e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating usage events: %w", err)
+	}
+
+	return events, total, nil
+}
+
+// usageWhere builds the WHERE clause of a usage filter
+func usageWhere(filter models.UsageFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+	if filter.Model != "" {
+		conditions = append(conditions, "model = ?")
+		args = append(args, filter.Model)
+	}
+	if filter.SessionID != "" {
+		conditions = append(conditions, "session_id = ?")
+		args = append(args, filter.SessionID)
+	}
+	if filter.Since != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, *filter.Since)
+	}
+	if filter.Until != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, *filter.Until)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ronan/home-agent/internal/database"
+	"github.com/ronan/home-agent/models"
+)
+
+// openTestDB returns a migrated database in a temporary directory
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := database.New(database.Config{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return db.Conn()
+}
+
+func TestUsageAggregateTotals(t *testing.T) {
+	repo := NewUsageRepository(openTestDB(t))
+
+	// No events: a zero total, and no groups
+	totals, err := repo.Aggregate(models.UsageFilter{}, "")
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	if len(totals) != 1 || totals[0].Key != "total" || totals[0].Turns != 0 || totals[0].TotalCostUSD != 0 {
+		t.Fatalf("empty totals = %+v, want a single zero total", totals)
+	}
+	groups, err := repo.Aggregate(models.UsageFilter{}, models.UsageGroupDay)
+	if err != nil || len(groups) != 0 {
+		t.Fatalf("empty groups = %v, %v", groups, err)
+	}
+
+	for _, event := range []*models.UsageEvent{
+		{SessionID: "s1", Actor: "alice", Model: "sonnet", InputTokens: 100, OutputTokens: 10, TotalCostUSD: 0.5, ToolCalls: 2},
+		{SessionID: "s2", Actor: "bob", Model: "haiku", InputTokens: 50, OutputTokens: 5, TotalCostUSD: 0.25},
+	} {
+		if err := repo.Record(event); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	totals, err = repo.Aggregate(models.UsageFilter{}, "")
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	if len(totals) != 1 || totals[0].Turns != 2 || totals[0].InputTokens != 150 || totals[0].ToolCalls != 2 {
+		t.Errorf("totals = %+v", totals[0])
+	}
+
+	// A filter matching nothing still returns a zero total
+	since := time.Now().Add(24 * time.Hour)
+	for _, filter := range []models.UsageFilter{{Model: "opus"}, {Since: &since}} {
+		totals, err := repo.Aggregate(filter, "")
+		if err != nil {
+			t.Fatalf("Aggregate failed: %v", err)
+		}
+		if len(totals) != 1 || totals[0].Turns != 0 {
+			t.Errorf("Aggregate(%+v) = %v, want a single zero total", filter, totals)
+		}
+	}
+
+	groups, err = repo.Aggregate(models.UsageFilter{}, models.UsageGroupModel)
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	if len(groups) != 2 || groups[0].Key != "sonnet" || groups[1].Key != "haiku" {
+		t.Errorf("model groups not ordered by cost: %+v", groups)
+	}
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// Approval decisions
+const (
+	ApprovalAllow = "allow"
+	ApprovalDeny  = "deny"
+	ApprovalAsk   = "ask"
+)
+
+// ApprovalSettingsKey is the settings key holding the JSON approval configuration
+const ApprovalSettingsKey = "approval_rules"
+
+// ApprovalRule decides what happens when a tool call matches it.
+// Tool is an exact tool name or "*"; Pattern is an optional regular expression
+// matched against the tool input (the command for Bash, the path for file tools).
+type ApprovalRule struct {
+	Tool    string `json:"tool"`
+	Pattern string `json:"pattern,omitempty"`
+	Action  string `json:"action"` // "allow", "deny" or "ask"
+}
+
+// ApprovalConfig holds the approval rules and timeout behaviour
+type ApprovalConfig struct {
+	Rules           []ApprovalRule `json:"rules"`
+	DefaultAction   string         `json:"default_action"`   // Applied when no rule matches
+	TimeoutSeconds  int            `json:"timeout_seconds"`  // How long to wait for the user
+	TimeoutDecision string         `json:"timeout_decision"` // "allow" or "deny" when nobody answers
+
+	patterns []*regexp.Regexp // Compiled rule patterns, nil for rules without one
+}
+
+// DefaultApprovalConfig keeps the historical behaviour: every tool is allowed
+func DefaultApprovalConfig() ApprovalConfig {
+	return ApprovalConfig{
+		DefaultAction:   ApprovalAllow,
+		TimeoutSeconds:  120,
+		TimeoutDecision: ApprovalDeny,
+	}
+}
+
+// ParseApprovalConfig parses and validates the JSON approval configuration.
+// An empty value returns the default configuration.
+func ParseApprovalConfig(raw string) (ApprovalConfig, error) {
+	config := DefaultApprovalConfig()
+	if raw == "" {
+		return config, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &config); err != nil {
+		return DefaultApprovalConfig(), fmt.Errorf("invalid approval configuration: %w", err)
+	}
+
+	if config.DefaultAction == "" {
+		config.DefaultAction = ApprovalAllow
+	}
+	if config.TimeoutSeconds <= 0 {
+		config.TimeoutSeconds = 120
+	}
+	if config.TimeoutDecision == "" {
+		config.TimeoutDecision = ApprovalDeny
+	}
+
+	if !isApprovalAction(config.DefaultAction) {
+		return DefaultApprovalConfig(), fmt.Errorf("invalid default_action: %s", config.DefaultAction)
+	}
+	if config.TimeoutDecision != ApprovalAllow && config.TimeoutDecision != ApprovalDeny {
+		return DefaultApprovalConfig(), fmt.Errorf("invalid timeout_decision: %s (must be 'allow' or 'deny')", config.TimeoutDecision)
+	}
+	for i, rule := range config.Rules {
+		if rule.Tool == "" {
+			return DefaultApprovalConfig(), fmt.Errorf("rule %d: tool is required", i)
+		}
+		if !isApprovalAction(rule.Action) {
+			return DefaultApprovalConfig(), fmt.Errorf("rule %d: invalid action: %s", i, rule.Action)
+		}
+		var re *regexp.Regexp
+		if rule.Pattern != "" {
+			var err error
+			if re, err = regexp.Compile(rule.Pattern); err != nil {
+				return DefaultApprovalConfig(), fmt.Errorf("rule %d: invalid pattern: %w", i, err)
+			}
+		}
+		config.patterns = append(config.patterns, re)
+	}
+
+	return config, nil
+}
+
+// approvalPrecedence ranks actions when several rules match: the most
+// restrictive wins, so an allow rule cannot override a deny rule
+var approvalPrecedence = map[string]int{ApprovalAllow: 1, ApprovalAsk: 2, ApprovalDeny: 3}
+
+// Evaluate returns the most restrictive action of the rules matching the tool
+// call (deny, then ask, then allow), whatever their order, or the default
+// action when no rule matches
+func (c ApprovalConfig) Evaluate(toolName string, input map[string]interface{}) string {
+	subject := ToolInputSubject(toolName, input)
+	action := ""
+	for i, rule := range c.Rules {
+		if rule.Tool != "*" && rule.Tool != toolName {
+			continue
+		}
+		if rule.Pattern != "" {
+			re := c.pattern(i)
+			if re == nil || !re.MatchString(subject) {
+				continue
+			}
+		}
+		if approvalPrecedence[rule.Action] > approvalPrecedence[action] {
+			action = rule.Action
+		}
+	}
+	if action == "" {
+		return c.DefaultAction
+	}
+	return action
+}
+
+// pattern returns the compiled pattern of rule i. Configurations not built by
+// ParseApprovalConfig compile it on each call.
+func (c ApprovalConfig) pattern(i int) *regexp.Regexp {
+	if len(c.patterns) == len(c.Rules) {
+		return c.patterns[i]
+	}
+	re, _ := regexp.Compile(c.Rules[i].Pattern)
+	return re
+}
+
+// ToolInputSubject extracts the part of a tool input that rules match against
+func ToolInputSubject(toolName string, input map[string]interface{}) string {
+	var keys []string
+	switch toolName {
+	case "Bash":
+		keys = []string{"command"}
+	case "Read", "Write", "Edit":
+		keys = []string{"file_path"}
+	case "Glob", "Grep":
+		keys = []string{"pattern", "path"}
+	case "WebFetch":
+		keys = []string{"url"}
+	case "WebSearch":
+		keys = []string{"query"}
+	}
+
+	for _, key := range keys {
+		if value, ok := input[key].(string); ok && value != "" {
+			return value
+		}
+	}
+
+	data, _ := json.Marshal(input)
+	return string(data)
+}
+
+func isApprovalAction(action string) bool {
+	return action == ApprovalAllow || action == ApprovalDeny || action == ApprovalAsk
+}
+
+// ApprovalRequest is a permission request relayed from the proxy
+type ApprovalRequest struct {
+	ID       string
+	ToolName string
+	Input    map[string]interface{}
+	respond  func(decision, message string) error
+}
+
+// Respond sends the decision back to the proxy
+func (ar *ApprovalRequest) Respond(decision, message string) error {
+	if ar.respond == nil {
+		return fmt.Errorf("approval request %s cannot be answered", ar.ID)
+	}
+	return ar.respond(decision, message)
+}
+
+// ApprovalService tracks approval requests waiting for a user decision
+type ApprovalService struct {
+	mu      sync.Mutex
+	pending map[string]chan string
+}
+
+// NewApprovalService creates a new ApprovalService
+func NewApprovalService() *ApprovalService {
+	return &ApprovalService{
+		pending: make(map[string]chan string),
+	}
+}
+
+// Register starts waiting for a decision on an approval request
+func (as *ApprovalService) Register(id string) <-chan string {
+	ch := make(chan string, 1)
+	as.mu.Lock()
+	as.pending[id] = ch
+	as.mu.Unlock()
+	return ch
+}
+
+// Resolve delivers the user's decision for a pending approval request
+func (as *ApprovalService) Resolve(id, decision string) error {
+	if decision != ApprovalAllow && decision != ApprovalDeny {
+		return fmt.Errorf("invalid decision: %s (must be 'allow' or 'deny')", decision)
+	}
+
+	as.mu.Lock()
+	ch, ok := as.pending[id]
+	delete(as.pending, id)
+	as.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("approval request not found or already answered: %s", id)
+	}
+	ch <- decision
+	return nil
+}
+
+// Cancel stops waiting for an approval request (timeout or aborted execution)
+func (as *ApprovalService) Cancel(id string) {
+	as.mu.Lock()
+	delete(as.pending, id)
+	as.mu.Unlock()
+}
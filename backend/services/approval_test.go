@@ -0,0 +1,154 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseApprovalConfig(t *testing.T) {
+	config, err := ParseApprovalConfig("")
+	if err != nil || !reflect.DeepEqual(config, DefaultApprovalConfig()) {
+		t.Errorf("empty config = %+v, %v; want the defaults", config, err)
+	}
+
+	// Missing fields take their defaults
+	config, err = ParseApprovalConfig(`{"rules":[{"tool":"Bash","action":"ask"}]}`)
+	if err != nil {
+		t.Fatalf("ParseApprovalConfig failed: %v", err)
+	}
+	if config.DefaultAction != ApprovalAllow || config.TimeoutSeconds != 120 || config.TimeoutDecision != ApprovalDeny {
+		t.Errorf("defaults not applied: %+v", config)
+	}
+
+	invalid := []string{
+		`not json`,
+		`{"default_action":"maybe"}`,
+		`{"timeout_decision":"ask"}`,
+		`{"rules":[{"action":"allow"}]}`,
+		`{"rules":[{"tool":"Bash","action":"always"}]}`,
+		`{"rules":[{"tool":"Bash","action":""}]}`,
+		`{"rules":[{"tool":"Bash","pattern":"rm (","action":"deny"}]}`,
+		`{"rules":{"tool":"Bash"}}`,
+	}
+	for _, raw := range invalid {
+		config, err := ParseApprovalConfig(raw)
+		if err == nil {
+			t.Errorf("ParseApprovalConfig(%q) should fail", raw)
+		}
+		// Callers fall back to the returned config: it must be the safe default
+		if !reflect.DeepEqual(config, DefaultApprovalConfig()) {
+			t.Errorf("ParseApprovalConfig(%q) returned %+v on error, want the defaults", raw, config)
+		}
+	}
+}
+
+func TestApprovalEvaluate(t *testing.T) {
+	rules := []string{
+		`{"tool": "Bash", "pattern": "^git (status|diff|log)\\b", "action": "allow"}`,
+		`{"tool": "Bash", "pattern": "rm\\s+-rf", "action": "deny"}`,
+		`{"tool": "Bash", "pattern": "--output", "action": "ask"}`,
+		`{"tool": "Read", "pattern": "\\.env$", "action": "deny"}`,
+		`{"tool": "Read", "action": "allow"}`,
+		`{"tool": "*", "pattern": "/etc/", "action": "deny"}`,
+		`{"tool": "Grep", "action": "allow"}`,
+	}
+
+	tests := []struct {
+		name  string
+		tool  string
+		input map[string]interface{}
+		want  string
+	}{
+		{"allowed command", "Bash", map[string]interface{}{"command": "git status"}, ApprovalAllow},
+		{"anchored pattern", "Bash", map[string]interface{}{"command": "echo x && git status"}, ApprovalAsk},
+		{"unanchored pattern matches anywhere", "Bash", map[string]interface{}{"command": "cd /tmp && rm  -rf build"}, ApprovalDeny},
+		{"deny beats allow", "Bash", map[string]interface{}{"command": "git log; rm -rf /"}, ApprovalDeny},
+		{"ask beats allow", "Bash", map[string]interface{}{"command": "git diff --output=/tmp/patch"}, ApprovalAsk},
+		{"no matching rule", "Bash", map[string]interface{}{"command": "ls"}, ApprovalAsk},
+		{"path pattern", "Read", map[string]interface{}{"file_path": "/app/.env"}, ApprovalDeny},
+		{"tool rule without pattern", "Read", map[string]interface{}{"file_path": "/app/main.go"}, ApprovalAllow},
+		{"wildcard deny beats tool allow", "Read", map[string]interface{}{"file_path": "/etc/passwd"}, ApprovalDeny},
+		{"wildcard tool", "Write", map[string]interface{}{"file_path": "/etc/hosts"}, ApprovalDeny},
+		{"wildcard on path", "Grep", map[string]interface{}{"pattern": "", "path": "/etc/ssh"}, ApprovalDeny},
+		{"later tool rule", "Grep", map[string]interface{}{"pattern": "TODO"}, ApprovalAllow},
+		{"default action", "WebFetch", map[string]interface{}{"url": "https://example.com"}, ApprovalAsk},
+		{"tool names are exact", "bash", map[string]interface{}{"command": "git status"}, ApprovalAsk},
+	}
+
+	// The result does not depend on the order of the rules
+	reversed := make([]string, len(rules))
+	for i, rule := range rules {
+		reversed[len(rules)-1-i] = rule
+	}
+	for order, list := range map[string][]string{"in order": rules, "reversed": reversed} {
+		config, err := ParseApprovalConfig(`{"default_action": "ask", "rules": [` + strings.Join(list, ",") + `]}`)
+		if err != nil {
+			t.Fatalf("ParseApprovalConfig failed: %v", err)
+		}
+		for _, tt := range tests {
+			t.Run(order+"/"+tt.name, func(t *testing.T) {
+				if got := config.Evaluate(tt.tool, tt.input); got != tt.want {
+					t.Errorf("Evaluate(%s, %v) = %s, want %s", tt.tool, tt.input, got, tt.want)
+				}
+			})
+		}
+	}
+
+	// Configurations built without ParseApprovalConfig compile their patterns on demand
+	config := ApprovalConfig{DefaultAction: ApprovalAllow, Rules: []ApprovalRule{{Tool: "Bash", Pattern: "^sudo ", Action: ApprovalDeny}}}
+	if got := config.Evaluate("Bash", map[string]interface{}{"command": "sudo reboot"}); got != ApprovalDeny {
+		t.Errorf("Evaluate without compiled patterns = %s, want deny", got)
+	}
+
+	if got := DefaultApprovalConfig().Evaluate("Bash", map[string]interface{}{"command": "rm -rf /"}); got != ApprovalAllow {
+		t.Errorf("default config Evaluate = %s, want allow", got)
+	}
+}
+
+func TestToolInputSubject(t *testing.T) {
+	tests := []struct {
+		tool  string
+		input map[string]interface{}
+		want  string
+	}{
+		{"Bash", map[string]interface{}{"command": "ls -la", "description": "list"}, "ls -la"},
+		{"Edit", map[string]interface{}{"file_path": "/a.go", "old_string": "x"}, "/a.go"},
+		{"Glob", map[string]interface{}{"pattern": "**/*.go"}, "**/*.go"},
+		{"Grep", map[string]interface{}{"pattern": "", "path": "/src"}, "/src"},
+		{"WebSearch", map[string]interface{}{"query": "golang"}, "golang"},
+		// Unknown tools and missing fields are matched against the JSON input
+		{"Task", map[string]interface{}{"prompt": "go"}, `{"prompt":"go"}`},
+		{"Bash", map[string]interface{}{"command": 42}, `{"command":42}`},
+	}
+
+	for _, tt := range tests {
+		if got := ToolInputSubject(tt.tool, tt.input); got != tt.want {
+			t.Errorf("ToolInputSubject(%s, %v) = %q, want %q", tt.tool, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestApprovalServiceResolve(t *testing.T) {
+	as := NewApprovalService()
+	ch := as.Register("req-1")
+
+	if err := as.Resolve("req-1", ApprovalAsk); err == nil {
+		t.Error("Resolve should refuse a decision other than allow or deny")
+	}
+	if err := as.Resolve("req-1", ApprovalAllow); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if decision := <-ch; decision != ApprovalAllow {
+		t.Errorf("decision = %s, want allow", decision)
+	}
+	if err := as.Resolve("req-1", ApprovalDeny); err == nil {
+		t.Error("a request can only be answered once")
+	}
+
+	as.Register("req-2")
+	as.Cancel("req-2")
+	if err := as.Resolve("req-2", ApprovalAllow); err == nil {
+		t.Error("a cancelled request cannot be answered")
+	}
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Limits protecting against zip bombs and oversized archives
+const (
+	maxArchiveEntries   = 10000
+	maxArchiveTotalSize = 512 * 1024 * 1024 // Uncompressed bytes across all files
+	maxArchiveRatio     = 100               // Compression ratio of a single zip entry
+	minArchiveRatioSize = 1024 * 1024       // Entries smaller than this are not ratio checked
+)
+
+// ErrArchiveLimit is returned for archives exceeding the entry, size or
+// compression ratio limits
+var ErrArchiveLimit = errors.New("archive exceeds size limits")
+
+// Archive formats
+const (
+	ArchiveZip   = "zip"
+	ArchiveTar   = "tar"
+	ArchiveTarGz = "tar.gz"
+)
+
+// ArchiveEntry is a regular file of an archive
+type ArchiveEntry struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// ArchiveListing describes the files of an archive. Entries with unsafe paths
+// (absolute, or escaping the archive with "..") and links are skipped.
+type ArchiveListing struct {
+	Format    string         `json:"format"`
+	Entries   []ArchiveEntry `json:"entries"`
+	TotalSize int64          `json:"total_size"`
+	Skipped   []string       `json:"skipped,omitempty"`
+}
+
+// ArchiveFormat returns the archive format of a file name, or "" if it is not an archive
+func ArchiveFormat(filename string) string {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return ArchiveZip
+	case strings.HasSuffix(name, ".tar"):
+		return ArchiveTar
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return ArchiveTarGz
+	}
+	return ""
+}
+
+// IsArchive reports whether a file name is a supported archive
+func IsArchive(filename string) bool {
+	return ArchiveFormat(filename) != ""
+}
+
+// safeArchivePath cleans an entry name, rejecting names that would resolve
+// outside the extraction directory
+func safeArchivePath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':') {
+		return "", false
+	}
+	clean := path.Clean(name)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", false
+	}
+	return clean, true
+}
+
+// walkArchive calls fn for each regular file with a safe path; open is only
+// valid during the call. Unsafe entries and links are passed to skip.
+func walkArchive(archivePath string, fn func(entry ArchiveEntry, open func() (io.ReadCloser, error)) error, skip func(name string)) error {
+	format := ArchiveFormat(archivePath)
+	if format == "" {
+		return fmt.Errorf("unsupported archive: %s", filepath.Base(archivePath))
+	}
+
+	entries := 0
+	var total int64
+	count := func(size int64) error {
+		entries++
+		total += size
+		if entries > maxArchiveEntries || total > maxArchiveTotalSize {
+			return ErrArchiveLimit
+		}
+		return nil
+	}
+
+	if format == ArchiveZip {
+		reader, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return fmt.Errorf("invalid zip archive: %w", err)
+		}
+		defer reader.Close()
+
+		for _, file := range reader.File {
+			if file.FileInfo().IsDir() {
+				continue
+			}
+			name, ok := safeArchivePath(file.Name)
+			if !ok || !file.Mode().IsRegular() {
+				skip(file.Name)
+				continue
+			}
+			size := int64(file.UncompressedSize64)
+			if size > minArchiveRatioSize && size > int64(file.CompressedSize64)*maxArchiveRatio {
+				return ErrArchiveLimit
+			}
+			if err := count(size); err != nil {
+				return err
+			}
+			if err := fn(ArchiveEntry{Path: name, Size: size}, file.Open); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var src io.Reader = file
+	if format == ArchiveTarGz {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("invalid gzip archive: %w", err)
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	reader := tar.NewReader(src)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive: %w", err)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeReg:
+		default:
+			skip(header.Name)
+			continue
+		}
+		name, ok := safeArchivePath(header.Name)
+		if !ok {
+			skip(header.Name)
+			continue
+		}
+		if err := count(header.Size); err != nil {
+			return err
+		}
+		open := func() (io.ReadCloser, error) { return io.NopCloser(reader), nil }
+		if err := fn(ArchiveEntry{Path: name, Size: header.Size}, open); err != nil {
+			return err
+		}
+	}
+}
+
+// ListArchive returns the files of an archive with their sizes
+func ListArchive(archivePath string) (*ArchiveListing, error) {
+	listing := &ArchiveListing{Format: ArchiveFormat(archivePath), Entries: []ArchiveEntry{}}
+	err := walkArchive(archivePath, func(entry ArchiveEntry, _ func() (io.ReadCloser, error)) error {
+		listing.Entries = append(listing.Entries, entry)
+		listing.TotalSize += entry.Size
+		return nil
+	}, func(name string) {
+		listing.Skipped = append(listing.Skipped, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return listing, nil
+}
+
+// ReadArchiveFile returns up to limit bytes of a file of an archive and its full size
+func ReadArchiveFile(archivePath, name string, limit int64) ([]byte, int64, error) {
+	var data []byte
+	size := int64(-1)
+	errFound := errors.New("found")
+	err := walkArchive(archivePath, func(entry ArchiveEntry, open func() (io.ReadCloser, error)) error {
+		if entry.Path != name {
+			return nil
+		}
+		rc, err := open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		if data, err = io.ReadAll(io.LimitReader(rc, limit)); err != nil {
+			return err
+		}
+		size = entry.Size
+		return errFound
+	}, func(string) {})
+	if err != nil && err != errFound {
+		return nil, 0, err
+	}
+	if size < 0 {
+		return nil, 0, fmt.Errorf("%s not found in archive", name)
+	}
+	return data, size, nil
+}
+
+// ExtractArchive extracts the given files of an archive into dest and returns
+// the number of files written. Sizes are enforced on the actual data, not the
+// sizes declared by the archive.
+func ExtractArchive(archivePath, dest string, files []string) (int, error) {
+	wanted := make(map[string]bool)
+	for _, name := range files {
+		if clean, ok := safeArchivePath(name); ok {
+			wanted[clean] = true
+		}
+	}
+
+	extracted := 0
+	var written int64
+	err := walkArchive(archivePath, func(entry ArchiveEntry, open func() (io.ReadCloser, error)) error {
+		if !wanted[entry.Path] {
+			return nil
+		}
+		target := filepath.Join(dest, filepath.FromSlash(entry.Path))
+		if !strings.HasPrefix(target, filepath.Clean(dest)+string(filepath.Separator)) {
+			return fmt.Errorf("unsafe path in archive: %s", entry.Path)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+
+		rc, err := open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		// O_EXCL: never write through a file or link already there
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, fs.ErrExist) {
+			return nil // Duplicate entry: the first one wins
+		}
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(out, io.LimitReader(rc, entry.Size+1))
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(target)
+			return err
+		}
+		written += n
+		if n > entry.Size || written > maxArchiveTotalSize {
+			os.Remove(target)
+			return ErrArchiveLimit
+		}
+		extracted++
+		return nil
+	}, func(string) {})
+	return extracted, err
+}
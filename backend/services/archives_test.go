@@ -0,0 +1,198 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSafeArchivePath(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"docs/readme.md", "docs/readme.md", true},
+		{"./docs/../readme.md", "readme.md", true},
+		{"docs\\readme.md", "docs/readme.md", true},
+		{"../etc/passwd", "", false},
+		{"docs/../../etc/passwd", "", false},
+		{"..", "", false},
+		{".", "", false},
+		{"", "", false},
+		{"/etc/passwd", "", false},
+		{"C:/Windows/win.ini", "", false},
+		{"C:\\Windows\\win.ini", "", false},
+		{"..\\..\\evil.sh", "", false},
+		{"\\evil.sh", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := safeArchivePath(tt.name)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("safeArchivePath(%q) = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// writeTar builds a tar archive from headers, with the given content for regular files
+func writeTar(t *testing.T, path string, headers []*tar.Header, content string) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, header := range headers {
+		if header.Typeflag == tar.TypeReg {
+			header.Size = int64(len(content))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("failed to write tar header: %v", err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			tw.Write([]byte(content))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+}
+
+func TestArchiveSkipsUnsafeEntries(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "test.tar")
+	writeTar(t, archivePath, []*tar.Header{
+		{Name: "ok.txt", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "../escape.txt", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "/abs.txt", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "C:\\drive.txt", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "sub\\..\\..\\back.txt", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+		{Name: "hard", Typeflag: tar.TypeLink, Linkname: "ok.txt"},
+	}, "data")
+
+	listing, err := ListArchive(archivePath)
+	if err != nil {
+		t.Fatalf("ListArchive failed: %v", err)
+	}
+	if len(listing.Entries) != 1 || listing.Entries[0].Path != "ok.txt" {
+		t.Errorf("entries = %+v, want only ok.txt", listing.Entries)
+	}
+	if len(listing.Skipped) != 6 {
+		t.Errorf("skipped = %v, want 6 entries", listing.Skipped)
+	}
+
+	dest := filepath.Join(dir, "out", "dest")
+	os.MkdirAll(dest, 0755)
+	count, err := ExtractArchive(archivePath, dest, []string{"ok.txt", "../escape.txt", "/abs.txt", "link", "hard"})
+	if err != nil {
+		t.Fatalf("ExtractArchive failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("extracted %d files, want 1", count)
+	}
+	for _, path := range []string{
+		filepath.Join(dir, "out", "escape.txt"),
+		filepath.Join(dir, "escape.txt"),
+		filepath.Join(dest, "link"),
+		filepath.Join(dest, "hard"),
+	} {
+		if _, err := os.Lstat(path); err == nil {
+			t.Errorf("%s should not have been written", path)
+		}
+	}
+}
+
+func TestZipSkipsSymlinks(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	header := &zip.FileHeader{Name: "link", Method: zip.Store}
+	header.SetMode(os.ModeSymlink | 0777)
+	w, _ := zw.CreateHeader(header)
+	w.Write([]byte("/etc/passwd"))
+	w, _ = zw.Create("ok.txt")
+	w.Write([]byte("data"))
+	zw.Close()
+
+	archivePath := filepath.Join(t.TempDir(), "test.zip")
+	os.WriteFile(archivePath, buf.Bytes(), 0644)
+	listing, err := ListArchive(archivePath)
+	if err != nil {
+		t.Fatalf("ListArchive failed: %v", err)
+	}
+	if len(listing.Entries) != 1 || len(listing.Skipped) != 1 || listing.Skipped[0] != "link" {
+		t.Errorf("entries = %+v, skipped = %v; want ok.txt listed and link skipped", listing.Entries, listing.Skipped)
+	}
+}
+
+func TestArchiveEntryLimit(t *testing.T) {
+	headers := make([]*tar.Header, maxArchiveEntries+1)
+	for i := range headers {
+		headers[i] = &tar.Header{Name: fmt.Sprintf("f%d", i), Typeflag: tar.TypeReg, Mode: 0644}
+	}
+	archivePath := filepath.Join(t.TempDir(), "many.tar")
+	writeTar(t, archivePath, headers, "")
+
+	if _, err := ListArchive(archivePath); !errors.Is(err, ErrArchiveLimit) {
+		t.Errorf("ListArchive error = %v, want ErrArchiveLimit", err)
+	}
+}
+
+func TestZipCompressionRatioLimit(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("zeros.bin")
+	w.Write(make([]byte, 4*minArchiveRatioSize))
+	zw.Close()
+
+	archivePath := filepath.Join(t.TempDir(), "bomb.zip")
+	os.WriteFile(archivePath, buf.Bytes(), 0644)
+	if _, err := ListArchive(archivePath); !errors.Is(err, ErrArchiveLimit) {
+		t.Errorf("ListArchive error = %v, want ErrArchiveLimit", err)
+	}
+}
+
+// A zip entry whose header declares fewer bytes than it holds must not be
+// extracted beyond its declared size
+func TestZipUnderstatedSize(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	var compressed bytes.Buffer
+	fw, _ := flate.NewWriter(&compressed, flate.BestCompression)
+	fw.Write(data)
+	fw.Close()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "small.txt",
+		Method:             zip.Deflate,
+		CRC32:              crc32.ChecksumIEEE(data),
+		CompressedSize64:   uint64(compressed.Len()),
+		UncompressedSize64: 10,
+	})
+	if err != nil {
+		t.Fatalf("CreateRaw failed: %v", err)
+	}
+	w.Write(compressed.Bytes())
+	zw.Close()
+
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "liar.zip")
+	os.WriteFile(archivePath, buf.Bytes(), 0644)
+	dest := filepath.Join(dir, "dest")
+	os.MkdirAll(dest, 0755)
+
+	if _, err := ExtractArchive(archivePath, dest, []string{"small.txt"}); err == nil {
+		t.Error("ExtractArchive should fail on an understated size")
+	}
+	if info, err := os.Stat(filepath.Join(dest, "small.txt")); err == nil {
+		t.Errorf("small.txt was kept with %d bytes", info.Size())
+	}
+}
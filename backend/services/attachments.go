@@ -0,0 +1,126 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/repositories"
+)
+
+// AttachmentService stores uploaded files and records them as attachments
+type AttachmentService struct {
+	attachments repositories.AttachmentRepository
+	uploadDir   string
+}
+
+// NewAttachmentService creates a new AttachmentService
+func NewAttachmentService(attachments repositories.AttachmentRepository, uploadDir string) *AttachmentService {
+	return &AttachmentService{attachments: attachments, uploadDir: uploadDir}
+}
+
+// Save writes an uploaded file to the upload directory and records it with its
+// size and checksum. The record is linked to a message once the file is sent.
+func (as *AttachmentService) Save(attachment *models.Attachment, src io.Reader) error {
+	if err := os.MkdirAll(as.uploadDir, 0755); err != nil {
+		return fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	path := filepath.Join(as.uploadDir, attachment.StoredName)
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), src)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	attachment.Size = size
+	attachment.Checksum = hex.EncodeToString(hash.Sum(nil))
+	attachment.UploadedAt = time.Now()
+	if err := as.attachments.Create(attachment); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// DeletePending forgets an upload that was not sent with a message
+func (as *AttachmentService) DeletePending(uploadID string) error {
+	return as.attachments.DeletePending(uploadID)
+}
+
+// BackfillFileInfo fills in the size, checksum and MIME type of attachments
+// recorded without them and returns the number of files updated. Attachments
+// whose file is gone are left as they are.
+func (as *AttachmentService) BackfillFileInfo() (int, error) {
+	attachments, err := as.attachments.ListWithoutChecksum()
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	done := make(map[string]bool)
+	for _, attachment := range attachments {
+		if done[attachment.StoredName] {
+			continue
+		}
+		done[attachment.StoredName] = true
+
+		size, checksum, err := fileChecksum(filepath.Join(as.uploadDir, attachment.StoredName))
+		if err != nil {
+			log.Printf("Attachments: cannot read %s: %v", attachment.StoredName, err)
+			continue
+		}
+		mimeType := attachment.MimeType
+		if mimeType == "" {
+			mimeType = mime.TypeByExtension(filepath.Ext(attachment.StoredName))
+		}
+		if err := as.attachments.UpdateFileInfo(attachment.StoredName, mimeType, size, checksum); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+
+	return updated, nil
+}
+
+// DerivedFiles returns the files made from an upload: extracted text, thumbnail
+// and kept original. They are removed with the upload.
+func DerivedFiles(uploadDir, storedName string) []string {
+	return []string{
+		ExtractedTextPath(uploadDir, storedName),
+		ThumbnailPath(uploadDir, storedName),
+		OriginalPath(uploadDir, storedName),
+	}
+}
+
+// fileChecksum returns the size and hex SHA-256 of a file
+func fileChecksum(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/repositories"
+)
+
+// AuditService records security-relevant events to the persistent audit log.
+// Recording never fails the caller: errors are logged and swallowed so that
+// auditing cannot break a conversation.
+type AuditService struct {
+	repo     repositories.AuditRepository
+	redactor *Redactor
+}
+
+// NewAuditService creates a new AuditService
+// Event targets and details are redacted before being recorded
+func NewAuditService(repo repositories.AuditRepository, redactor *Redactor) *AuditService {
+	return &AuditService{repo: repo, redactor: redactor}
+}
+
+// Record appends an event to the audit log
+func (as *AuditService) Record(event models.AuditEvent) {
+	if as == nil || as.repo == nil {
+		return
+	}
+	event.Target = as.redactor.Redact(event.Target)
+	event.Details = as.redactor.Redact(event.Details)
+	if err := as.repo.Record(&event); err != nil {
+		log.Printf("Warning: failed to record audit event %s: %v", event.EventType, err)
+	}
+}
+
+// AuditDetails marshals a details map to the JSON string stored with an event
+func AuditDetails(details map[string]interface{}) string {
+	if len(details) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(details)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
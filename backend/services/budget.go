@@ -0,0 +1,226 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/repositories"
+)
+
+// BudgetSettingsKey is the settings key holding the JSON budget configuration
+const BudgetSettingsKey = "budgets"
+
+// Budget scopes
+const (
+	BudgetScopeGlobal = "global"
+	BudgetScopeUser   = "user"
+	BudgetScopeModel  = "model"
+)
+
+// Budget periods
+const (
+	BudgetPeriodDaily   = "daily"
+	BudgetPeriodMonthly = "monthly"
+)
+
+// Budget states
+const (
+	BudgetStateOK       = "ok"
+	BudgetStateWarning  = "warning"  // Soft limit crossed
+	BudgetStateExceeded = "exceeded" // Hard limit crossed: requests are blocked
+)
+
+// BudgetLimit is a spending limit over a period.
+// A zero soft or hard limit is not enforced.
+type BudgetLimit struct {
+	Scope   string  `json:"scope"`            // "global", "user" or "model"
+	Target  string  `json:"target,omitempty"` // User or model name; empty for global
+	Period  string  `json:"period"`           // "daily" or "monthly"
+	SoftUSD float64 `json:"soft_usd,omitempty"`
+	HardUSD float64 `json:"hard_usd,omitempty"`
+}
+
+// BudgetConfig holds the configured spending limits
+type BudgetConfig struct {
+	Limits []BudgetLimit `json:"limits"`
+}
+
+// BudgetStatus is the consumption of a limit over its current period
+type BudgetStatus struct {
+	BudgetLimit
+	SpentUSD    float64   `json:"spent_usd"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	State       string    `json:"state"`
+}
+
+// Describe returns a short human-readable summary of the status
+func (bs BudgetStatus) Describe() string {
+	name := "global"
+	if bs.Target != "" {
+		name = bs.Scope + " " + bs.Target
+	}
+	limit := bs.SoftUSD
+	if bs.State == BudgetStateExceeded {
+		limit = bs.HardUSD
+	}
+	return fmt.Sprintf("%s budget (%s): $%.2f spent of $%.2f", bs.Period, name, bs.SpentUSD, limit)
+}
+
+// ParseBudgetConfig parses and validates the JSON budget configuration.
+// An empty value means no limits.
+func ParseBudgetConfig(raw string) (BudgetConfig, error) {
+	var config BudgetConfig
+	if raw == "" {
+		return config, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &config); err != nil {
+		return BudgetConfig{}, fmt.Errorf("invalid budget configuration: %w", err)
+	}
+
+	for i, limit := range config.Limits {
+		switch limit.Scope {
+		case BudgetScopeGlobal:
+			if limit.Target != "" {
+				return BudgetConfig{}, fmt.Errorf("limit %d: global limit cannot have a target", i)
+			}
+		case BudgetScopeUser, BudgetScopeModel:
+			if limit.Target == "" {
+				return BudgetConfig{}, fmt.Errorf("limit %d: %s limit requires a target", i, limit.Scope)
+			}
+		default:
+			return BudgetConfig{}, fmt.Errorf("limit %d: invalid scope: %s (must be 'global', 'user' or 'model')", i, limit.Scope)
+		}
+		if limit.Period != BudgetPeriodDaily && limit.Period != BudgetPeriodMonthly {
+			return BudgetConfig{}, fmt.Errorf("limit %d: invalid period: %s (must be 'daily' or 'monthly')", i, limit.Period)
+		}
+		if limit.SoftUSD < 0 || limit.HardUSD < 0 {
+			return BudgetConfig{}, fmt.Errorf("limit %d: limits cannot be negative", i)
+		}
+		if limit.SoftUSD == 0 && limit.HardUSD == 0 {
+			return BudgetConfig{}, fmt.Errorf("limit %d: soft_usd or hard_usd is required", i)
+		}
+		if limit.SoftUSD > 0 && limit.HardUSD > 0 && limit.SoftUSD > limit.HardUSD {
+			return BudgetConfig{}, fmt.Errorf("limit %d: soft_usd cannot exceed hard_usd", i)
+		}
+	}
+
+	return config, nil
+}
+
+// budgetPeriod returns the bounds of the period containing now, in local time
+func budgetPeriod(period string, now time.Time) (time.Time, time.Time) {
+	year, month, day := now.Date()
+	if period == BudgetPeriodMonthly {
+		start := time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(0, 1, 0)
+	}
+	start := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+// BudgetService checks spending against the configured budgets
+type BudgetService struct {
+	usage    repositories.UsageRepository
+	settings repositories.SettingsRepository
+}
+
+// NewBudgetService creates a new BudgetService
+func NewBudgetService(usage repositories.UsageRepository, settings repositories.SettingsRepository) *BudgetService {
+	return &BudgetService{usage: usage, settings: settings}
+}
+
+// RecordUsage stores the usage of a conversation turn
+func (bs *BudgetService) RecordUsage(event models.UsageEvent) error {
+	if bs == nil || bs.usage == nil {
+		return nil
+	}
+	return bs.usage.Record(&event)
+}
+
+// Config returns the current budget configuration
+func (bs *BudgetService) Config() (BudgetConfig, error) {
+	if bs == nil || bs.settings == nil {
+		return BudgetConfig{}, nil
+	}
+	raw, err := bs.settings.Get(BudgetSettingsKey)
+	if err != nil {
+		return BudgetConfig{}, err
+	}
+	return ParseBudgetConfig(raw)
+}
+
+// Status computes the consumption of every configured limit
+func (bs *BudgetService) Status() ([]BudgetStatus, error) {
+	config, err := bs.Config()
+	if err != nil {
+		return nil, err
+	}
+	return bs.evaluate(config.Limits, time.Now())
+}
+
+// Check computes the consumption of the limits that apply to a request
+// from actor using model
+func (bs *BudgetService) Check(actor, model string) ([]BudgetStatus, error) {
+	config, err := bs.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	var applicable []BudgetLimit
+	for _, limit := range config.Limits {
+		switch limit.Scope {
+		case BudgetScopeGlobal:
+			applicable = append(applicable, limit)
+		case BudgetScopeUser:
+			if limit.Target == actor {
+				applicable = append(applicable, limit)
+			}
+		case BudgetScopeModel:
+			if limit.Target == model {
+				applicable = append(applicable, limit)
+			}
+		}
+	}
+
+	return bs.evaluate(applicable, time.Now())
+}
+
+// evaluate sums the spending of each limit over its current period
+func (bs *BudgetService) evaluate(limits []BudgetLimit, now time.Time) ([]BudgetStatus, error) {
+	statuses := make([]BudgetStatus, 0, len(limits))
+	for _, limit := range limits {
+		start, end := budgetPeriod(limit.Period, now)
+		filter := models.UsageFilter{Since: &start, Until: &end}
+		switch limit.Scope {
+		case BudgetScopeUser:
+			filter.Actor = limit.Target
+		case BudgetScopeModel:
+			filter.Model = limit.Target
+		}
+
+		spent, err := bs.usage.TotalCost(filter)
+		if err != nil {
+			return nil, err
+		}
+
+		state := BudgetStateOK
+		if limit.HardUSD > 0 && spent >= limit.HardUSD {
+			state = BudgetStateExceeded
+		} else if limit.SoftUSD > 0 && spent >= limit.SoftUSD {
+			state = BudgetStateWarning
+		}
+
+		statuses = append(statuses, BudgetStatus{
+			BudgetLimit: limit,
+			SpentUSD:    spent,
+			PeriodStart: start,
+			PeriodEnd:   end,
+			State:       state,
+		})
+	}
+	return statuses, nil
+}
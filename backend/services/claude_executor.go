@@ -22,9 +22,15 @@ type UsageInfo struct {
 	TotalCostUSD             float64 `json:"total_cost_usd,omitempty"`
 }
 
+// ImageInput is an image sent to Claude with the prompt as a content block
+type ImageInput struct {
+	MediaType string // "image/jpeg", "image/png", "image/gif" or "image/webp"
+	Data      []byte
+}
+
 // ClaudeResponse represents a chunk of text from Claude's response
 type ClaudeResponse struct {
-	Type      string // "chunk", "thinking", "done", "error", "session_id", "tool_start", "tool_progress", "tool_result", "tool_error", "tool_input_delta", "usage"
+	Type      string // "chunk", "thinking", "done", "error", "session_id", "tool_start", "tool_progress", "tool_result", "tool_error", "tool_input_delta", "usage", "approval_request", "policy_violation"
 	Content   string
 	SessionID string
 	Error     error
@@ -36,6 +42,8 @@ type ClaudeResponse struct {
 	InputDelta         string // JSON delta for streaming tool input
 	// Usage information
 	Usage *UsageInfo
+	// Permission request waiting for a decision
+	Approval *ApprovalRequest
 }
 
 // ClaudeExecutor is the interface for executing Claude CLI commands
@@ -47,9 +55,11 @@ type ClaudeExecutor interface {
 	// Model can be "haiku", "sonnet", or "opus" (defaults to "haiku" if empty).
 	// customInstructions are appended to the system prompt if provided.
 	// thinking: If true, enables extended thinking mode.
+	// toolPolicy: Tools Claude may use; violations are reported as "tool_error" events.
+	// images: Images sent along with the prompt, read by Claude without any tool.
 	// Returns a channel that streams ClaudeResponse events.
 	// The channel will be closed when the execution completes.
-	ExecuteClaude(ctx context.Context, prompt string, sessionID string, isNewSession bool, model string, customInstructions string, thinking bool) (<-chan ClaudeResponse, error)
+	ExecuteClaude(ctx context.Context, prompt string, sessionID string, isNewSession bool, model string, customInstructions string, thinking bool, toolPolicy EffectiveToolPolicy, images []ImageInput) (<-chan ClaudeResponse, error)
 
 	// GenerateTitleSummary generates a short title for a conversation.
 	// Uses a fast model (haiku) for quick generation.
@@ -0,0 +1,438 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/repositories"
+)
+
+// CompactionSettingsKey is the settings key holding the JSON compaction configuration
+const CompactionSettingsKey = "compaction"
+
+const (
+	defaultContextWindow       = 200000 // Tokens, for models without a configured window
+	defaultCompactionThreshold = 80     // Percent of the context window
+	maxTranscriptChars         = 400000 // Characters of transcript sent to Claude (~100k tokens)
+	maxTranscriptToolOutput    = 2000   // Characters of each tool output kept in the transcript
+)
+
+// ErrCompactionInProgress is returned when a session is already being compacted
+var ErrCompactionInProgress = errors.New("session is already being compacted")
+
+// compactionSummaryPrompt asks Claude to summarize a transcript for a fresh session
+const compactionSummaryPrompt = `The conversation below between a user and an assistant is being compacted because it no longer fits in the context window.
+Write a summary that lets the assistant continue the conversation seamlessly. Keep:
+- the user's goals, requests and preferences
+- decisions made, facts established and results obtained
+- files, commands, machines and identifiers that were involved
+- open questions and the work still in progress
+Write the summary in the language of the conversation. Reply with the summary only.
+
+<conversation>
+%s
+</conversation>`
+
+// compactionSeedPrompt starts the fresh session from the summary
+const compactionSeedPrompt = `This conversation continues an earlier one that was compacted. Here is its summary:
+
+<summary>
+%s
+</summary>
+
+Use it as the context of the conversation. Reply only with "OK".`
+
+// CompactionConfig holds the compaction settings
+type CompactionConfig struct {
+	Disabled         bool           `json:"disabled,omitempty"`          // Disables automatic compaction
+	ThresholdPercent int            `json:"threshold_percent,omitempty"` // Percent of the context window (default 80)
+	ContextWindows   map[string]int `json:"context_windows,omitempty"`   // Tokens per model (default 200000)
+}
+
+// ParseCompactionConfig parses and validates the JSON compaction configuration.
+// An empty value means the defaults.
+func ParseCompactionConfig(raw string) (CompactionConfig, error) {
+	var config CompactionConfig
+	if raw == "" {
+		return config, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &config); err != nil {
+		return CompactionConfig{}, fmt.Errorf("invalid compaction configuration: %w", err)
+	}
+
+	if config.ThresholdPercent < 0 || config.ThresholdPercent > 100 {
+		return CompactionConfig{}, fmt.Errorf("threshold_percent must be between 0 and 100")
+	}
+	for model, window := range config.ContextWindows {
+		if window <= 0 {
+			return CompactionConfig{}, fmt.Errorf("context window for %s must be positive", model)
+		}
+	}
+
+	return config, nil
+}
+
+// Threshold returns the context usage, in tokens, past which a session using model is compacted
+func (c CompactionConfig) Threshold(model string) int {
+	window, ok := c.ContextWindows[model]
+	if !ok {
+		window = defaultContextWindow
+	}
+	percent := c.ThresholdPercent
+	if percent == 0 {
+		percent = defaultCompactionThreshold
+	}
+	return window * percent / 100
+}
+
+// CompactionResult describes a compacted session
+type CompactionResult struct {
+	SessionID           string `json:"session_id"`
+	ClaudeSessionID     string `json:"claude_session_id"` // Seeded Claude session the session now resumes
+	Summary             string `json:"summary"`
+	ContextTokensBefore int    `json:"context_tokens_before"`
+	ContextTokens       int    `json:"context_tokens"`
+	Compactions         int    `json:"compactions"`
+}
+
+// CompactionService tracks the context usage of sessions and compacts them.
+// A compacted session keeps its history; only the Claude session behind it is
+// replaced by a fresh one seeded with a summary of the conversation.
+type CompactionService struct {
+	sessions repositories.SessionRepository
+	messages repositories.MessageRepository
+	parts    repositories.MessagePartRepository
+	settings repositories.SettingsRepository
+	executor ClaudeExecutor
+	budgets  *BudgetService
+
+	mu         sync.Mutex
+	compacting map[string]chan struct{} // Closed when the session's compaction ends
+}
+
+// NewCompactionService creates a new CompactionService
+func NewCompactionService(sessions repositories.SessionRepository, messages repositories.MessageRepository, parts repositories.MessagePartRepository, settings repositories.SettingsRepository, executor ClaudeExecutor, budgets *BudgetService) *CompactionService {
+	return &CompactionService{
+		sessions:   sessions,
+		messages:   messages,
+		parts:      parts,
+		settings:   settings,
+		executor:   executor,
+		budgets:    budgets,
+		compacting: make(map[string]chan struct{}),
+	}
+}
+
+// PendingCompaction is a compaction announced before it runs. Messages sent to
+// the session wait until it has run or been released.
+type PendingCompaction struct {
+	cs        *CompactionService
+	sessionID string
+	done      chan struct{}
+	once      sync.Once
+}
+
+// Begin marks a session as being compacted. It fails with ErrCompactionInProgress
+// when the session is already being compacted.
+func (cs *CompactionService) Begin(sessionID string) (*PendingCompaction, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if _, ok := cs.compacting[sessionID]; ok {
+		return nil, ErrCompactionInProgress
+	}
+	done := make(chan struct{})
+	cs.compacting[sessionID] = done
+	return &PendingCompaction{cs: cs, sessionID: sessionID, done: done}, nil
+}
+
+// Run compacts the session, then releases it
+func (p *PendingCompaction) Run(ctx context.Context, actor string) (*CompactionResult, error) {
+	defer p.Release()
+	return p.cs.compact(ctx, p.sessionID, actor)
+}
+
+// Release ends the compaction without running it; it does nothing once released
+func (p *PendingCompaction) Release() {
+	p.once.Do(func() {
+		p.cs.mu.Lock()
+		delete(p.cs.compacting, p.sessionID)
+		p.cs.mu.Unlock()
+		close(p.done)
+	})
+}
+
+// Wait blocks while the session is being compacted, or until ctx ends
+func (cs *CompactionService) Wait(ctx context.Context, sessionID string) error {
+	for {
+		cs.mu.Lock()
+		done, ok := cs.compacting[sessionID]
+		cs.mu.Unlock()
+		if !ok {
+			return nil
+		}
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ErrCompactionInProgress, ctx.Err())
+		}
+	}
+}
+
+// Config returns the current compaction configuration
+func (cs *CompactionService) Config() (CompactionConfig, error) {
+	raw, err := cs.settings.Get(CompactionSettingsKey)
+	if err != nil {
+		return CompactionConfig{}, err
+	}
+	return ParseCompactionConfig(raw)
+}
+
+// contextTokens estimates the tokens a turn added to the context.
+// Cache reads are context that was already counted by earlier turns.
+func contextTokens(usage UsageInfo) int {
+	return usage.InputTokens + usage.CacheCreationInputTokens + usage.OutputTokens
+}
+
+// Track adds the usage of a turn to the session's context usage and reports
+// whether the session crossed the compaction threshold of model
+func (cs *CompactionService) Track(sessionID, model string, usage UsageInfo) (bool, error) {
+	total, err := cs.sessions.AddContextTokens(sessionID, contextTokens(usage))
+	if err != nil {
+		return false, err
+	}
+
+	config, err := cs.Config()
+	if err != nil {
+		return false, fmt.Errorf("failed to load compaction configuration: %w", err)
+	}
+	return !config.Disabled && total >= config.Threshold(model), nil
+}
+
+// Compact summarizes the conversation and moves the session to a fresh Claude
+// session seeded with the summary. The session keeps its ID and messages.
+func (cs *CompactionService) Compact(ctx context.Context, sessionID, actor string) (*CompactionResult, error) {
+	pending, err := cs.Begin(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return pending.Run(ctx, actor)
+}
+
+func (cs *CompactionService) compact(ctx context.Context, sessionID, actor string) (*CompactionResult, error) {
+	session, err := cs.sessions.Get(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	messages, err := cs.messages.GetBySession(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	if err := cs.attachParts(messages); err != nil {
+		return nil, err
+	}
+	transcript := conversationTranscript(messages)
+	if transcript == "" {
+		return nil, fmt.Errorf("session has no messages to compact")
+	}
+
+	// No tools: both turns only produce text
+	noTools := EffectiveToolPolicy{DeniedTools: []string{"*"}, Scopes: []string{"compaction"}}
+
+	summary, _, usage, err := cs.run(ctx, fmt.Sprintf(compactionSummaryPrompt, transcript), session.Model, noTools)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return nil, fmt.Errorf("failed to summarize conversation: empty summary")
+	}
+	cs.recordUsage(sessionID, actor, session.Model, usage)
+
+	_, claudeSessionID, seedUsage, err := cs.run(ctx, fmt.Sprintf(compactionSeedPrompt, summary), session.Model, noTools)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start compacted session: %w", err)
+	}
+	if claudeSessionID == "" {
+		return nil, fmt.Errorf("failed to start compacted session: no session ID returned")
+	}
+	cs.recordUsage(sessionID, actor, session.Model, seedUsage)
+
+	if err := cs.sessions.UpdateClaudeSessionID(sessionID, claudeSessionID, models.ClaudeSessionCompaction); err != nil {
+		return nil, fmt.Errorf("failed to move session to compacted Claude session: %w", err)
+	}
+
+	// The fresh session holds the seed turn, cache reads included
+	seeded := 0
+	if seedUsage != nil {
+		seeded = contextTokens(*seedUsage) + seedUsage.CacheReadInputTokens
+	}
+	if err := cs.sessions.MarkCompacted(sessionID, seeded); err != nil {
+		return nil, err
+	}
+
+	return &CompactionResult{
+		SessionID:           sessionID,
+		ClaudeSessionID:     claudeSessionID,
+		Summary:             summary,
+		ContextTokensBefore: session.ContextTokens,
+		ContextTokens:       seeded,
+		Compactions:         session.Compactions + 1,
+	}, nil
+}
+
+// run executes a one-off prompt in a new Claude session and collects its text,
+// session ID and usage
+func (cs *CompactionService) run(ctx context.Context, prompt, model string, toolPolicy EffectiveToolPolicy) (string, string, *UsageInfo, error) {
+	responses, err := cs.executor.ExecuteClaude(ctx, prompt, "", true, model, "", false, toolPolicy, nil)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	var text strings.Builder
+	var sessionID string
+	var usage *UsageInfo
+	var runErr error
+	for resp := range responses {
+		switch resp.Type {
+		case "chunk":
+			text.WriteString(resp.Content)
+		case "session_id":
+			sessionID = resp.SessionID
+		case "usage":
+			usage = resp.Usage
+		case "approval_request":
+			if resp.Approval != nil {
+				resp.Approval.Respond(ApprovalDeny, "Tools are not available while compacting")
+			}
+		case "error":
+			if runErr == nil {
+				runErr = resp.Error
+			}
+		}
+	}
+	if runErr != nil {
+		return "", "", nil, runErr
+	}
+	return text.String(), sessionID, usage, nil
+}
+
+// recordUsage counts compaction turns against the budgets like any other turn
+func (cs *CompactionService) recordUsage(sessionID, actor, model string, usage *UsageInfo) {
+	if usage == nil {
+		return
+	}
+	if err := cs.budgets.RecordUsage(models.UsageEvent{
+		SessionID:                sessionID,
+		Actor:                    actor,
+		Model:                    model,
+		InputTokens:              usage.InputTokens,
+		OutputTokens:             usage.OutputTokens,
+		CacheCreationInputTokens: usage.CacheCreationInputTokens,
+		CacheReadInputTokens:     usage.CacheReadInputTokens,
+		TotalCostUSD:             usage.TotalCostUSD,
+	}); err != nil {
+		log.Printf("Warning: failed to record compaction usage: %v", err)
+	}
+}
+
+// attachParts fills in the parts of the assistant messages, so that the
+// transcript includes their tool calls
+func (cs *CompactionService) attachParts(messages []*models.Message) error {
+	var assistantIDs []int
+	for _, msg := range messages {
+		if msg.Role == "assistant" {
+			assistantIDs = append(assistantIDs, msg.ID)
+		}
+	}
+	parts, err := cs.parts.GetByMessages(assistantIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get message parts: %w", err)
+	}
+	for _, msg := range messages {
+		if msg.Role == "assistant" {
+			msg.Parts = parts[msg.ID]
+		}
+	}
+	return nil
+}
+
+// messageTranscript returns the text of a message. An assistant turn saved with
+// its parts also lists its tool calls and their outputs, which are shortened.
+func messageTranscript(msg *models.Message) string {
+	if len(msg.Parts) == 0 {
+		return msg.Content
+	}
+
+	var sb strings.Builder
+	for _, part := range msg.Parts {
+		switch part.Type {
+		case models.PartText:
+			sb.WriteString(part.Content)
+		case models.PartToolUse:
+			fmt.Fprintf(&sb, "\n[tool_use %s] %s\n", part.ToolName, part.Input)
+		case models.PartToolResult:
+			label := "tool_result"
+			if part.IsError {
+				label = "tool_error"
+			}
+			output := part.Content
+			if len(output) > maxTranscriptToolOutput {
+				cut := maxTranscriptToolOutput
+				for cut > 0 && !utf8.RuneStart(output[cut]) {
+					cut--
+				}
+				output = output[:cut] + "\n[output truncated]"
+			}
+			fmt.Fprintf(&sb, "[%s %s]\n%s\n", label, part.ToolName, output)
+		}
+	}
+	return sb.String()
+}
+
+// conversationTranscript formats the user and assistant messages of a session.
+// The oldest messages are dropped when the transcript is too long.
+func conversationTranscript(messages []*models.Message) string {
+	var parts []string
+	for _, msg := range messages {
+		if msg.Role != "user" && msg.Role != "assistant" {
+			continue
+		}
+		content := strings.TrimSpace(messageTranscript(msg))
+		if content == "" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("[%s]\n%s", msg.Role, content))
+	}
+
+	size := 0
+	start := len(parts)
+	for start > 0 && size+len(parts[start-1]) <= maxTranscriptChars {
+		start--
+		size += len(parts[start]) + 2
+	}
+	if start == len(parts) && len(parts) > 0 {
+		// A single oversized message: keep its end
+		last := parts[len(parts)-1]
+		cut := len(last) - maxTranscriptChars
+		for cut < len(last) && !utf8.RuneStart(last[cut]) {
+			cut++
+		}
+		return "[earlier messages omitted]\n\n" + last[cut:]
+	}
+	if start > 0 {
+		return "[earlier messages omitted]\n\n" + strings.Join(parts[start:], "\n\n")
+	}
+	return strings.Join(parts, "\n\n")
+}
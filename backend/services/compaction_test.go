@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ronan/home-agent/models"
+)
+
+func TestConversationTranscript(t *testing.T) {
+	messages := []*models.Message{
+		{Role: "user", Content: "  hello  "},
+		{Role: "system", Content: "ignored"},
+		{Role: "assistant", Content: ""},
+		{Role: "assistant", Content: "hi"},
+	}
+	if got, want := conversationTranscript(messages), "[user]\nhello\n\n[assistant]\nhi"; got != want {
+		t.Errorf("conversationTranscript = %q, want %q", got, want)
+	}
+
+	// Older messages are dropped first
+	long := strings.Repeat("a", maxTranscriptChars/2)
+	got := conversationTranscript([]*models.Message{
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: long},
+		{Role: "user", Content: long},
+	})
+	if strings.Contains(got, "first") || !strings.HasPrefix(got, "[earlier messages omitted]") {
+		t.Errorf("oldest message not dropped: %q...", got[:min(len(got), 60)])
+	}
+
+	// A single oversized message is cut on a rune boundary
+	for offset := 0; offset < 4; offset++ {
+		content := strings.Repeat("é€😀", maxTranscriptChars/9+1) + strings.Repeat("x", offset)
+		got := conversationTranscript([]*models.Message{{Role: "assistant", Content: content}})
+		if !utf8.ValidString(got) {
+			t.Errorf("offset %d: transcript is not valid UTF-8", offset)
+		}
+		if !strings.HasSuffix(got, strings.Repeat("x", offset)) || len(got) > maxTranscriptChars+len("[earlier messages omitted]\n\n") {
+			t.Errorf("offset %d: transcript length %d", offset, len(got))
+		}
+	}
+}
+
+func TestConversationTranscriptToolCalls(t *testing.T) {
+	output := strings.Repeat("o", maxTranscriptToolOutput+10)
+	messages := []*models.Message{
+		{Role: "user", Content: "list files"},
+		{Role: "assistant", Content: "Here they are", Parts: []*models.MessagePart{
+			{Type: models.PartThinking, Content: "hidden"},
+			{Type: models.PartToolUse, ToolName: "Bash", Input: `{"command":"ls"}`},
+			{Type: models.PartToolResult, ToolName: "Bash", Content: output},
+			{Type: models.PartToolUse, ToolName: "Read", Input: `{"file_path":"x"}`},
+			{Type: models.PartToolResult, ToolName: "Read", Content: "no such file", IsError: true},
+			{Type: models.PartText, Content: "Here they are"},
+		}},
+	}
+
+	got := conversationTranscript(messages)
+	for _, want := range []string{
+		`[tool_use Bash] {"command":"ls"}`,
+		"[tool_result Bash]\n" + output[:maxTranscriptToolOutput] + "\n[output truncated]",
+		"[tool_error Read]\nno such file",
+		"Here they are",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("transcript does not contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "hidden") || strings.Contains(got, output) {
+		t.Errorf("thinking or the full tool output is in the transcript:\n%s", got)
+	}
+}
+
+func TestCompactionWait(t *testing.T) {
+	cs := NewCompactionService(nil, nil, nil, nil, nil, nil)
+
+	pending, err := cs.Begin("s1")
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if _, err := cs.Begin("s1"); !errors.Is(err, ErrCompactionInProgress) {
+		t.Errorf("second Begin error = %v, want ErrCompactionInProgress", err)
+	}
+	if err := cs.Wait(context.Background(), "s2"); err != nil {
+		t.Errorf("Wait on another session = %v", err)
+	}
+
+	// A message waits until the compaction ends
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := cs.Wait(ctx, "s1"); !errors.Is(err, ErrCompactionInProgress) {
+		t.Errorf("Wait during compaction = %v, want ErrCompactionInProgress", err)
+	}
+
+	waited := make(chan error)
+	go func() { waited <- cs.Wait(context.Background(), "s1") }()
+	pending.Release()
+	pending.Release()
+	select {
+	case err := <-waited:
+		if err != nil {
+			t.Errorf("Wait after release = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after release")
+	}
+
+	if _, err := cs.Begin("s1"); err != nil {
+		t.Errorf("Begin after release failed: %v", err)
+	}
+}
@@ -0,0 +1,701 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Limits applied while extracting documents
+const (
+	maxDocumentSize     = 64 * 1024 * 1024 // Largest file read for extraction
+	maxDocumentPartSize = 64 * 1024 * 1024 // Largest uncompressed entry read from a zip container
+	maxExtractedText    = 8 * 1024 * 1024  // Extracted text beyond this is dropped
+	maxSheetColumns     = 1024             // Columns kept per spreadsheet row
+	maxSheetRepeat      = 1000             // Repeated empty ODS rows/columns materialized before content
+)
+
+// extractedDir is the directory of the upload directory caching extracted text
+const extractedDir = ".extracted"
+
+// documentExtractors maps file extensions to their text extractor
+var documentExtractors = map[string]func([]byte) (string, error){
+	".pdf":  extractPDFText,
+	".docx": extractDOCXText,
+	".xlsx": extractXLSXText,
+	".ods":  extractODSText,
+	".epub": extractEPUBText,
+}
+
+// IsDocument reports whether text can be extracted from a file of this name
+func IsDocument(filename string) bool {
+	_, ok := documentExtractors[strings.ToLower(filepath.Ext(filename))]
+	return ok
+}
+
+// ExtractedTextPath returns the cache file of an upload's extracted text
+func ExtractedTextPath(uploadDir, storedName string) string {
+	return filepath.Join(uploadDir, extractedDir, filepath.Base(storedName)+".txt")
+}
+
+// DocumentExtractor extracts the text of PDF, DOCX, XLSX, ODS and EPUB uploads.
+// Text is cached next to the uploads so each file is only parsed once.
+type DocumentExtractor struct {
+	uploadDir string
+}
+
+// NewDocumentExtractor creates a new DocumentExtractor
+func NewDocumentExtractor(uploadDir string) *DocumentExtractor {
+	return &DocumentExtractor{uploadDir: uploadDir}
+}
+
+// Text returns the text of an uploaded document, with "--- Page N ---",
+// "--- Sheet: name ---" or "--- Chapter N ---" markers
+func (de *DocumentExtractor) Text(filePath string) (string, error) {
+	extract, ok := documentExtractors[strings.ToLower(filepath.Ext(filePath))]
+	if !ok {
+		return "", fmt.Errorf("unsupported document type: %s", filepath.Ext(filePath))
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", err
+	}
+	cachePath := ExtractedTextPath(de.uploadDir, filePath)
+	if cached, err := os.Stat(cachePath); err == nil && !cached.ModTime().Before(info.ModTime()) {
+		if text, err := os.ReadFile(cachePath); err == nil {
+			return string(text), nil
+		}
+	}
+
+	if info.Size() > maxDocumentSize {
+		return "", fmt.Errorf("document too large (%d bytes)", info.Size())
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	text, err := func() (text string, err error) {
+		// A malformed upload must fail its extraction, not crash the server
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("malformed document: %v", r)
+			}
+		}()
+		return extract(data)
+	}()
+	if err != nil {
+		return "", fmt.Errorf("failed to extract text: %w", err)
+	}
+	if len(text) > maxExtractedText {
+		text = strings.ToValidUTF8(text[:maxExtractedText], "")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err == nil {
+		if err := os.WriteFile(cachePath, []byte(text), 0644); err != nil {
+			os.Remove(cachePath)
+		}
+	}
+	return text, nil
+}
+
+// zipDocument gives access to the entries of an OOXML, ODF or EPUB container
+type zipDocument struct {
+	files map[string]*zip.File
+}
+
+func openZipDocument(data []byte) (*zipDocument, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid document archive: %w", err)
+	}
+	doc := &zipDocument{files: make(map[string]*zip.File)}
+	for _, file := range reader.File {
+		doc.files[file.Name] = file
+	}
+	return doc, nil
+}
+
+// read returns an entry, refusing entries that inflate beyond the size limit
+func (z *zipDocument) read(name string) ([]byte, error) {
+	file, ok := z.files[strings.TrimPrefix(name, "/")]
+	if !ok {
+		return nil, fmt.Errorf("missing %s", name)
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxDocumentPartSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDocumentPartSize {
+		return nil, fmt.Errorf("%s is too large", name)
+	}
+	return data, nil
+}
+
+// walkXML calls fn for each start element, end element and text of a document
+func walkXML(data []byte, html bool, fn func(token xml.Token) error) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	if html {
+		decoder.Strict = false
+		decoder.AutoClose = xml.HTMLAutoClose
+		decoder.Entity = xml.HTMLEntity
+	}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(token); err != nil {
+			return err
+		}
+	}
+}
+
+// xmlAttr returns the value of an attribute by local name
+func xmlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// extractDOCXText returns the paragraphs of a Word document; table cells are
+// separated by tabs
+func extractDOCXText(data []byte) (string, error) {
+	doc, err := openZipDocument(data)
+	if err != nil {
+		return "", err
+	}
+	body, err := doc.read("word/document.xml")
+	if err != nil {
+		return "", err
+	}
+
+	var sb, cell strings.Builder
+	var row []string
+	inText := false
+	cellDepth := 0 // Nested tables are flattened into their outer cell
+	out := func() *strings.Builder {
+		if cellDepth > 0 {
+			return &cell
+		}
+		return &sb
+	}
+	err = walkXML(body, false, func(token xml.Token) error {
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				out().WriteString("\t")
+			case "br", "cr":
+				out().WriteString("\n")
+			case "tc":
+				cellDepth++
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				out().WriteString("\n")
+			case "tc":
+				cellDepth--
+				if cellDepth == 0 {
+					row = append(row, strings.Join(strings.Fields(cell.String()), " "))
+					cell.Reset()
+				}
+			case "tr":
+				if cellDepth == 0 {
+					sb.WriteString(strings.Join(row, "\t") + "\n")
+					row = nil
+				}
+			}
+		case xml.CharData:
+			if inText {
+				out().Write(t)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("invalid document.xml: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// sheetWriter renders spreadsheet rows as CSV
+type sheetWriter struct {
+	sb   *strings.Builder
+	rows [][]string
+}
+
+func (sw *sheetWriter) flush(name string) error {
+	sw.sb.WriteString(fmt.Sprintf("--- Sheet: %s ---\n", name))
+	writer := csv.NewWriter(sw.sb)
+	for _, row := range sw.rows {
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	sw.sb.WriteString("\n")
+	sw.rows = nil
+	return writer.Error()
+}
+
+// extractXLSXText returns each worksheet of an Excel workbook as CSV
+func extractXLSXText(data []byte) (string, error) {
+	doc, err := openZipDocument(data)
+	if err != nil {
+		return "", err
+	}
+	workbook, err := doc.read("xl/workbook.xml")
+	if err != nil {
+		return "", err
+	}
+
+	// Sheet targets by relationship ID
+	targets := make(map[string]string)
+	if rels, err := doc.read("xl/_rels/workbook.xml.rels"); err == nil {
+		walkXML(rels, false, func(token xml.Token) error {
+			if t, ok := token.(xml.StartElement); ok && t.Name.Local == "Relationship" {
+				target := xmlAttr(t, "Target")
+				if strings.HasPrefix(target, "/") {
+					target = strings.TrimPrefix(target, "/")
+				} else {
+					target = path.Join("xl", target)
+				}
+				targets[xmlAttr(t, "Id")] = target
+			}
+			return nil
+		})
+	}
+
+	type sheet struct{ name, target string }
+	var sheets []sheet
+	err = walkXML(workbook, false, func(token xml.Token) error {
+		if t, ok := token.(xml.StartElement); ok && t.Name.Local == "sheet" {
+			target := targets[xmlAttr(t, "id")]
+			if target == "" {
+				target = fmt.Sprintf("xl/worksheets/sheet%d.xml", len(sheets)+1)
+			}
+			sheets = append(sheets, sheet{name: xmlAttr(t, "name"), target: target})
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("invalid workbook.xml: %w", err)
+	}
+
+	var shared []string
+	if sharedData, err := doc.read("xl/sharedStrings.xml"); err == nil {
+		shared = readSharedStrings(sharedData)
+	}
+
+	var sb strings.Builder
+	writer := &sheetWriter{sb: &sb}
+	for _, s := range sheets {
+		content, err := doc.read(s.target)
+		if err != nil {
+			continue // Chart sheets have no worksheet
+		}
+		writer.rows, err = readXLSXRows(content, shared)
+		if err != nil {
+			return "", fmt.Errorf("invalid sheet %s: %w", s.name, err)
+		}
+		if err := writer.flush(s.name); err != nil {
+			return "", err
+		}
+	}
+	return sb.String(), nil
+}
+
+// readSharedStrings returns the shared string table of a workbook
+func readSharedStrings(data []byte) []string {
+	var shared []string
+	var current strings.Builder
+	inText, inPhonetic := false, false
+	walkXML(data, false, func(token xml.Token) error {
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				current.Reset()
+			case "t":
+				inText = true
+			case "rPh":
+				inPhonetic = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				shared = append(shared, current.String())
+			case "t":
+				inText = false
+			case "rPh":
+				inPhonetic = false
+			}
+		case xml.CharData:
+			if inText && !inPhonetic {
+				current.Write(t)
+			}
+		}
+		return nil
+	})
+	return shared
+}
+
+// readXLSXRows returns the cell values of a worksheet, placed by their reference
+func readXLSXRows(data []byte, shared []string) ([][]string, error) {
+	var rows [][]string
+	var row []string
+	var cellType, value string
+	column, rowIndex := 0, 0
+	inValue := false
+
+	err := walkXML(data, false, func(token xml.Token) error {
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				row = nil
+				column = 0
+				if r, err := strconv.Atoi(xmlAttr(t, "r")); err == nil && r > rowIndex {
+					rowIndex = r
+				} else {
+					rowIndex++
+				}
+			case "c":
+				cellType, value = xmlAttr(t, "t"), ""
+				if c := cellColumn(xmlAttr(t, "r")); c >= 0 {
+					column = c
+				}
+			case "v", "t":
+				inValue = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "v", "t":
+				inValue = false
+			case "c":
+				switch cellType {
+				case "s":
+					if i, err := strconv.Atoi(value); err == nil && i >= 0 && i < len(shared) {
+						value = shared[i]
+					}
+				case "b":
+					if value == "1" {
+						value = "TRUE"
+					} else {
+						value = "FALSE"
+					}
+				}
+				if column < maxSheetColumns {
+					for len(row) < column {
+						row = append(row, "")
+					}
+					row = append(row, value)
+				}
+				column++
+			case "row":
+				for gap := 0; len(rows) < rowIndex-1 && gap < maxSheetRepeat; gap++ {
+					rows = append(rows, nil)
+				}
+				rows = append(rows, row)
+			}
+		case xml.CharData:
+			if inValue {
+				value += string(t)
+			}
+		}
+		return nil
+	})
+	return rows, err
+}
+
+// cellColumn returns the zero-based column of a cell reference such as "B3"
+func cellColumn(ref string) int {
+	column := 0
+	letters := 0
+	for _, c := range ref {
+		if c >= 'A' && c <= 'Z' {
+			column = column*26 + int(c-'A'+1)
+			letters++
+			continue
+		}
+		break
+	}
+	if letters == 0 {
+		return -1
+	}
+	return column - 1
+}
+
+// extractODSText returns each table of an OpenDocument spreadsheet as CSV
+func extractODSText(data []byte) (string, error) {
+	doc, err := openZipDocument(data)
+	if err != nil {
+		return "", err
+	}
+	content, err := doc.read("content.xml")
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	writer := &sheetWriter{sb: &sb}
+	var name string
+	var row []string
+	var cell strings.Builder
+	cellValue := ""
+	cellRepeat, rowRepeat := 1, 1
+	pendingCells, pendingRows := 0, 0
+	paragraphs := 0
+	inCell := false
+
+	err = walkXML(content, false, func(token xml.Token) error {
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "table":
+				name = xmlAttr(t, "name")
+				writer.rows, pendingRows = nil, 0
+			case "table-row":
+				row, pendingCells = nil, 0
+				rowRepeat = repeatCount(xmlAttr(t, "number-rows-repeated"))
+			case "table-cell", "covered-table-cell":
+				inCell = true
+				cell.Reset()
+				paragraphs = 0
+				cellValue = xmlAttr(t, "value")
+				if cellValue == "" {
+					cellValue = xmlAttr(t, "date-value")
+				}
+				cellRepeat = repeatCount(xmlAttr(t, "number-columns-repeated"))
+			case "p":
+				if inCell && paragraphs > 0 {
+					cell.WriteString("\n")
+				}
+				paragraphs++
+			case "s":
+				if inCell {
+					cell.WriteString(strings.Repeat(" ", repeatCount(xmlAttr(t, "c"))))
+				}
+			case "tab":
+				if inCell {
+					cell.WriteString("\t")
+				}
+			case "line-break":
+				if inCell {
+					cell.WriteString("\n")
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "table-cell", "covered-table-cell":
+				inCell = false
+				text := cell.String()
+				if text == "" {
+					text = cellValue
+				}
+				if text == "" {
+					// Empty cells only matter when content follows them
+					pendingCells += cellRepeat
+					return nil
+				}
+				for i := 0; i < min(pendingCells, maxSheetRepeat) && len(row) < maxSheetColumns; i++ {
+					row = append(row, "")
+				}
+				pendingCells = 0
+				for i := 0; i < min(cellRepeat, maxSheetRepeat) && len(row) < maxSheetColumns; i++ {
+					row = append(row, text)
+				}
+			case "table-row":
+				if len(row) == 0 {
+					pendingRows += rowRepeat
+					return nil
+				}
+				for i := 0; i < min(pendingRows, maxSheetRepeat); i++ {
+					writer.rows = append(writer.rows, nil)
+				}
+				pendingRows = 0
+				for i := 0; i < min(rowRepeat, maxSheetRepeat); i++ {
+					writer.rows = append(writer.rows, row)
+				}
+			case "table":
+				if err := writer.flush(name); err != nil {
+					return err
+				}
+			}
+		case xml.CharData:
+			if inCell && paragraphs > 0 {
+				cell.Write(t)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("invalid content.xml: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// repeatCount parses an ODF repetition attribute, defaulting to 1
+func repeatCount(value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// extractEPUBText returns the chapters of an EPUB book in reading order
+func extractEPUBText(data []byte) (string, error) {
+	doc, err := openZipDocument(data)
+	if err != nil {
+		return "", err
+	}
+	container, err := doc.read("META-INF/container.xml")
+	if err != nil {
+		return "", err
+	}
+
+	var opfPath string
+	walkXML(container, false, func(token xml.Token) error {
+		if t, ok := token.(xml.StartElement); ok && t.Name.Local == "rootfile" && opfPath == "" {
+			opfPath = xmlAttr(t, "full-path")
+		}
+		return nil
+	})
+	if opfPath == "" {
+		return "", fmt.Errorf("no package document in container.xml")
+	}
+	opf, err := doc.read(opfPath)
+	if err != nil {
+		return "", err
+	}
+
+	manifest := make(map[string]string)
+	var spine []string
+	err = walkXML(opf, false, func(token xml.Token) error {
+		if t, ok := token.(xml.StartElement); ok {
+			switch t.Name.Local {
+			case "item":
+				manifest[xmlAttr(t, "id")] = xmlAttr(t, "href")
+			case "itemref":
+				if xmlAttr(t, "linear") != "no" {
+					spine = append(spine, xmlAttr(t, "idref"))
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("invalid package document: %w", err)
+	}
+
+	var sb strings.Builder
+	chapter := 0
+	for _, id := range spine {
+		href, ok := manifest[id]
+		if !ok {
+			continue
+		}
+		if i := strings.IndexByte(href, '#'); i >= 0 {
+			href = href[:i]
+		}
+		content, err := doc.read(path.Join(path.Dir(opfPath), href))
+		if err != nil {
+			continue
+		}
+		text := strings.TrimSpace(htmlText(content))
+		if text == "" {
+			continue // Cover pages and other image-only documents
+		}
+		chapter++
+		sb.WriteString(fmt.Sprintf("--- Chapter %d ---\n%s\n\n", chapter, text))
+	}
+	return sb.String(), nil
+}
+
+// htmlBlocks are the elements rendered on their own line
+var htmlBlocks = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "blockquote": true, "pre": true, "section": true,
+}
+
+// htmlText returns the visible text of an (X)HTML document
+func htmlText(data []byte) string {
+	var sb strings.Builder
+	skip := 0
+	walkXML(data, true, func(token xml.Token) error {
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch strings.ToLower(t.Name.Local) {
+			case "head", "script", "style":
+				skip++
+			case "td", "th":
+				sb.WriteString("\t")
+			default:
+				if htmlBlocks[strings.ToLower(t.Name.Local)] {
+					sb.WriteString("\n")
+				}
+			}
+		case xml.EndElement:
+			switch strings.ToLower(t.Name.Local) {
+			case "head", "script", "style":
+				skip--
+			default:
+				if htmlBlocks[strings.ToLower(t.Name.Local)] {
+					sb.WriteString("\n")
+				}
+			}
+		case xml.CharData:
+			if skip <= 0 && len(t) > 0 {
+				words := strings.Join(strings.Fields(string(t)), " ")
+				if isHTMLSpace(t[0]) || words == "" {
+					sb.WriteString(" ")
+				}
+				sb.WriteString(words)
+				if words != "" && isHTMLSpace(t[len(t)-1]) {
+					sb.WriteString(" ")
+				}
+			}
+		}
+		return nil
+	})
+
+	// Collapse the blank lines left by nested blocks
+	var lines []string
+	for _, line := range strings.Split(sb.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
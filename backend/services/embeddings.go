@@ -0,0 +1,290 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/repositories"
+)
+
+// Chunking parameters, in characters
+const (
+	embeddingChunkSize    = 1000
+	embeddingChunkOverlap = 200
+)
+
+// EmbeddingProvider turns texts into vectors.
+// Implementations must return one vector per input, in order.
+type EmbeddingProvider interface {
+	// Model identifies the vectors: changing it triggers a full re-index
+	Model() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// EmbeddingConfig selects and configures the embedding provider
+type EmbeddingConfig struct {
+	Provider string // "openai" (any OpenAI-compatible /embeddings API)
+	BaseURL  string // e.g. "http://localhost:11434/v1"
+	Model    string // e.g. "nomic-embed-text"
+	APIKey   string // Optional bearer token
+}
+
+// NewEmbeddingProvider creates the provider described by config.
+// It returns nil when semantic search is not configured.
+func NewEmbeddingProvider(config EmbeddingConfig) (EmbeddingProvider, error) {
+	if config.BaseURL == "" {
+		return nil, nil
+	}
+	if config.Model == "" {
+		return nil, fmt.Errorf("embedding model is required")
+	}
+
+	switch config.Provider {
+	case "", "openai":
+		return NewOpenAIEmbeddingProvider(config.BaseURL, config.Model, config.APIKey), nil
+	default:
+		return nil, fmt.Errorf("unknown embedding provider: %s", config.Provider)
+	}
+}
+
+// OpenAIEmbeddingProvider calls an OpenAI-compatible embeddings endpoint
+// (OpenAI, Ollama, llama.cpp, LocalAI, vLLM...)
+type OpenAIEmbeddingProvider struct {
+	baseURL string
+	model   string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewOpenAIEmbeddingProvider creates a provider for baseURL (without /embeddings)
+func NewOpenAIEmbeddingProvider(baseURL, model, apiKey string) *OpenAIEmbeddingProvider {
+	return &OpenAIEmbeddingProvider{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		model:   model,
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Model returns the embedding model name
+func (p *OpenAIEmbeddingProvider) Model() string {
+	return p.model
+}
+
+// openAIEmbeddingRequest is the body of POST /embeddings
+type openAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// openAIEmbeddingResponse is the response of POST /embeddings
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed sends texts to the embeddings endpoint
+func (p *OpenAIEmbeddingProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(openAIEmbeddingRequest{Model: p.model, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("embedding provider returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var embeddingResp openAIEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embeddingResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range embeddingResp.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding provider returned invalid index %d", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return nil, fmt.Errorf("embedding provider returned no vector for input %d", i)
+		}
+	}
+
+	return vectors, nil
+}
+
+// ChunkText splits text into overlapping chunks of at most size characters,
+// cutting at whitespace when possible
+func ChunkText(text string, size, overlap int) []string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) == 0 {
+		return nil
+	}
+	if overlap >= size {
+		overlap = 0
+	}
+
+	var chunks []string
+	start := 0
+	for start < len(runes) {
+		end := start + size
+		if end >= len(runes) {
+			chunks = append(chunks, strings.TrimSpace(string(runes[start:])))
+			break
+		}
+
+		// Back up to the last whitespace in the second half of the chunk
+		for cut := end; cut > start+size/2; cut-- {
+			if unicode.IsSpace(runes[cut]) {
+				end = cut
+				break
+			}
+		}
+		chunks = append(chunks, strings.TrimSpace(string(runes[start:end])))
+
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+
+	return chunks
+}
+
+// EmbeddingIndexer embeds new messages in the background.
+// Its first pass backfills every message not yet indexed with the current model.
+type EmbeddingIndexer struct {
+	repo      repositories.EmbeddingRepository
+	provider  EmbeddingProvider
+	interval  time.Duration
+	batchSize int
+}
+
+// NewEmbeddingIndexer creates a new EmbeddingIndexer
+func NewEmbeddingIndexer(repo repositories.EmbeddingRepository, provider EmbeddingProvider, interval time.Duration) *EmbeddingIndexer {
+	return &EmbeddingIndexer{
+		repo:      repo,
+		provider:  provider,
+		interval:  interval,
+		batchSize: 16,
+	}
+}
+
+// Run indexes pending messages until ctx is cancelled
+func (ei *EmbeddingIndexer) Run(ctx context.Context) {
+	ticker := time.NewTicker(ei.interval)
+	defer ticker.Stop()
+
+	for {
+		indexed, err := ei.IndexPending(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Embedding indexer: %v", err)
+		}
+		if indexed > 0 {
+			log.Printf("Embedding indexer: indexed %d messages", indexed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// IndexPending embeds every message not yet indexed and returns their count.
+// Messages that fail are recorded and skipped until their retry time, so they
+// do not block the others. A whole batch failing, most likely because the
+// provider is unavailable, ends the pass; it returns the last error.
+func (ei *EmbeddingIndexer) IndexPending(ctx context.Context) (int, error) {
+	model := ei.provider.Model()
+	indexed := 0
+	var lastErr error
+
+	for ctx.Err() == nil {
+		messages, err := ei.repo.PendingMessages(model, ei.batchSize)
+		if err != nil {
+			return indexed, err
+		}
+		if len(messages) == 0 {
+			return indexed, lastErr
+		}
+
+		failed := 0
+		for _, message := range messages {
+			err := ei.indexMessage(ctx, model, message)
+			if err == nil {
+				indexed++
+				continue
+			}
+			if ctx.Err() != nil {
+				return indexed, ctx.Err()
+			}
+			failed++
+			lastErr = fmt.Errorf("message %d: %w", message.ID, err)
+			if err := ei.repo.RecordFailure(message.ID, model, err); err != nil {
+				return indexed, err
+			}
+		}
+		if failed == len(messages) {
+			return indexed, lastErr
+		}
+	}
+
+	return indexed, ctx.Err()
+}
+
+// indexMessage chunks and embeds one message
+func (ei *EmbeddingIndexer) indexMessage(ctx context.Context, model string, message *models.Message) error {
+	texts := ChunkText(message.Content, embeddingChunkSize, embeddingChunkOverlap)
+	if len(texts) == 0 {
+		return fmt.Errorf("no text to embed")
+	}
+	vectors, err := ei.provider.Embed(ctx, texts)
+	if err != nil {
+		return err
+	}
+
+	chunks := make([]models.EmbeddingChunk, len(texts))
+	for i, text := range texts {
+		chunks[i] = models.EmbeddingChunk{
+			MessageID:  message.ID,
+			ChunkIndex: i,
+			Content:    text,
+			Vector:     vectors[i],
+		}
+	}
+
+	return ei.repo.Save(message.ID, model, chunks)
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ronan/home-agent/models"
+)
+
+// memoryEmbeddings is an in-memory EmbeddingRepository
+type memoryEmbeddings struct {
+	messages []*models.Message
+	saved    map[int]int // Message ID to chunk count
+	failed   map[int]error
+}
+
+func (m *memoryEmbeddings) PendingMessages(model string, limit int) ([]*models.Message, error) {
+	var pending []*models.Message
+	for _, message := range m.messages {
+		if _, ok := m.saved[message.ID]; ok || m.failed[message.ID] != nil {
+			continue
+		}
+		if pending = append(pending, message); len(pending) == limit {
+			break
+		}
+	}
+	return pending, nil
+}
+
+func (m *memoryEmbeddings) Save(messageID int, model string, chunks []models.EmbeddingChunk) error {
+	m.saved[messageID] = len(chunks)
+	delete(m.failed, messageID)
+	return nil
+}
+
+func (m *memoryEmbeddings) RecordFailure(messageID int, model string, cause error) error {
+	m.failed[messageID] = cause
+	return nil
+}
+
+func (m *memoryEmbeddings) Search(string, []float32, models.SearchQuery) ([]*models.SearchResult, int, error) {
+	return nil, 0, nil
+}
+
+func (m *memoryEmbeddings) Stats(model string) (*models.EmbeddingStats, error) {
+	return &models.EmbeddingStats{Model: model}, nil
+}
+
+// fakeEmbedder fails on texts containing "bad", or on everything when down
+type fakeEmbedder struct {
+	down  bool
+	calls int
+}
+
+func (f *fakeEmbedder) Model() string { return "fake" }
+
+func (f *fakeEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	f.calls++
+	if f.down {
+		return nil, errors.New("connection refused")
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if strings.Contains(text, "bad") {
+			return nil, errors.New("input rejected")
+		}
+		vectors[i] = []float32{1, float32(len(text))}
+	}
+	return vectors, nil
+}
+
+func TestIndexPendingSkipsFailures(t *testing.T) {
+	repo := &memoryEmbeddings{saved: make(map[int]int), failed: make(map[int]error)}
+	for i, content := range []string{"bad input", "hello", " ", "world", "bad again", "more"} {
+		repo.messages = append(repo.messages, &models.Message{ID: i + 1, Role: "user", Content: content})
+	}
+	provider := &fakeEmbedder{}
+	indexer := NewEmbeddingIndexer(repo, provider, 0)
+	indexer.batchSize = 2
+
+	indexed, err := indexer.IndexPending(context.Background())
+	if indexed != 3 {
+		t.Errorf("indexed %d messages, want 3", indexed)
+	}
+	if err == nil || !strings.Contains(err.Error(), "message 5") {
+		t.Errorf("error = %v, want the last failure", err)
+	}
+	if !reflect.DeepEqual(repo.saved, map[int]int{2: 1, 4: 1, 6: 1}) {
+		t.Errorf("saved = %v", repo.saved)
+	}
+	// Blank text is recorded as a failure instead of being retried forever
+	for _, id := range []int{1, 3, 5} {
+		if repo.failed[id] == nil {
+			t.Errorf("failure of message %d not recorded", id)
+		}
+	}
+
+	// Nothing left: the next pass does not call the provider
+	provider.calls = 0
+	if indexed, err := indexer.IndexPending(context.Background()); indexed != 0 || err != nil || provider.calls != 0 {
+		t.Errorf("second pass = %d, %v with %d calls", indexed, err, provider.calls)
+	}
+}
+
+func TestIndexPendingStopsWhenProviderIsDown(t *testing.T) {
+	repo := &memoryEmbeddings{saved: make(map[int]int), failed: make(map[int]error)}
+	for i := 0; i < 10; i++ {
+		repo.messages = append(repo.messages, &models.Message{ID: i + 1, Role: "user", Content: "text"})
+	}
+	provider := &fakeEmbedder{down: true}
+	indexer := NewEmbeddingIndexer(repo, provider, 0)
+	indexer.batchSize = 4
+
+	indexed, err := indexer.IndexPending(context.Background())
+	if indexed != 0 || err == nil {
+		t.Errorf("IndexPending = %d, %v; want an error", indexed, err)
+	}
+	// The pass ends after the first batch instead of trying every message
+	if provider.calls != 4 {
+		t.Errorf("provider called %d times, want 4", provider.calls)
+	}
+}
@@ -0,0 +1,439 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/repositories"
+)
+
+// Export formats
+const (
+	ExportFormatMarkdown = "md"
+	ExportFormatJSON     = "json"
+	ExportFormatHTML     = "html"
+)
+
+// Attachment modes of an export
+const (
+	ExportAttachmentsNone   = "none"   // Referenced by name only
+	ExportAttachmentsInline = "inline" // Embedded (base64 in JSON, data URIs in HTML and Markdown)
+	ExportAttachmentsZip    = "zip"    // Transcript and files bundled in a zip archive
+)
+
+// exportAttachmentDir is the archive folder holding attachment files
+const exportAttachmentDir = "attachments"
+
+// SessionExport is a self-describing snapshot of a conversation
+type SessionExport struct {
+	Version    string             `json:"version"`
+	ExportedAt time.Time          `json:"exported_at"`
+	Session    *models.Session    `json:"session"`
+	Messages   []ExportMessage    `json:"messages"`
+	ToolCalls  []*models.ToolCall `json:"tool_calls"`
+	Usage      ExportUsage        `json:"usage"`
+}
+
+// ExportMessage is a message with the files sent with it
+type ExportMessage struct {
+	ID          int                `json:"id"`
+	Role        string             `json:"role"` // "user", "assistant" or "thinking"
+	Content     string             `json:"content"`
+	CreatedAt   time.Time          `json:"created_at"`
+	Attachments []ExportAttachment `json:"attachments,omitempty"`
+}
+
+// ExportAttachment is a file attached to a message
+type ExportAttachment struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"` // Original name
+	Type     string `json:"type"`     // "image" or "file"
+	MimeType string `json:"mime_type,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	Checksum string `json:"checksum,omitempty"` // SHA-256, hex encoded
+	Path     string `json:"path"`               // Path inside a zip export
+	Data     string `json:"data,omitempty"`     // Base64 content, inline exports only
+	Missing  bool   `json:"missing,omitempty"`
+
+	stored string // Name in the upload directory
+}
+
+// ExportUsage holds the usage totals of a session
+type ExportUsage struct {
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	TotalCostUSD float64 `json:"total_cost_usd"`
+	ToolCalls    int     `json:"tool_calls"`
+}
+
+// SessionExporter renders conversations for download
+type SessionExporter struct {
+	sessions    repositories.SessionRepository
+	messages    repositories.MessageRepository
+	toolCalls   repositories.ToolCallRepository
+	attachments repositories.AttachmentRepository
+	uploadDir   string
+}
+
+// NewSessionExporter creates a new SessionExporter
+func NewSessionExporter(sessions repositories.SessionRepository, messages repositories.MessageRepository, toolCalls repositories.ToolCallRepository, attachments repositories.AttachmentRepository, uploadDir string) *SessionExporter {
+	return &SessionExporter{sessions: sessions, messages: messages, toolCalls: toolCalls, attachments: attachments, uploadDir: uploadDir}
+}
+
+// ValidExportFormat reports whether format is supported
+func ValidExportFormat(format string) bool {
+	return format == ExportFormatMarkdown || format == ExportFormatJSON || format == ExportFormatHTML
+}
+
+// Build loads a session, its messages and tool calls. It returns nil if the
+// session does not exist.
+func (se *SessionExporter) Build(sessionID string) (*SessionExport, error) {
+	session, err := se.sessions.Get(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, nil
+	}
+
+	messages, err := se.messages.GetBySession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	toolCalls, err := se.toolCalls.GetBySession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	messageIDs := make([]int, len(messages))
+	for i, message := range messages {
+		messageIDs[i] = message.ID
+	}
+	attachments, err := se.attachments.GetByMessages(messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	if toolCalls == nil {
+		toolCalls = []*models.ToolCall{}
+	}
+
+	export := &SessionExport{
+		Version:    "1.0",
+		ExportedAt: time.Now(),
+		Session:    session,
+		Messages:   make([]ExportMessage, 0, len(messages)),
+		ToolCalls:  toolCalls,
+		Usage: ExportUsage{
+			InputTokens:  session.InputTokens,
+			OutputTokens: session.OutputTokens,
+			TotalCostUSD: session.TotalCostUSD,
+			ToolCalls:    len(toolCalls),
+		},
+	}
+
+	for _, message := range messages {
+		export.Messages = append(export.Messages, ExportMessage{
+			ID:          message.ID,
+			Role:        message.Role,
+			Content:     message.Content,
+			CreatedAt:   message.CreatedAt,
+			Attachments: exportAttachments(attachments[message.ID]),
+		})
+	}
+
+	return export, nil
+}
+
+// exportAttachments describes the attachments of a message for an export
+func exportAttachments(attachments []*models.Attachment) []ExportAttachment {
+	var exported []ExportAttachment
+	for _, attachment := range attachments {
+		exported = append(exported, ExportAttachment{
+			ID:       attachment.UploadID,
+			Filename: attachment.Filename,
+			Type:     attachment.Type,
+			MimeType: attachment.MimeType,
+			Size:     attachment.Size,
+			Checksum: attachment.Checksum,
+			Path:     exportAttachmentDir + "/" + attachment.StoredName,
+			stored:   attachment.StoredName,
+		})
+	}
+	return exported
+}
+
+// readAttachment returns the content of an uploaded file
+func (se *SessionExporter) readAttachment(attachment ExportAttachment) ([]byte, error) {
+	return os.ReadFile(filepath.Join(se.uploadDir, attachment.stored))
+}
+
+// inlineAttachments embeds the content of every attachment
+func (se *SessionExporter) inlineAttachments(export *SessionExport) {
+	for i := range export.Messages {
+		for j := range export.Messages[i].Attachments {
+			attachment := &export.Messages[i].Attachments[j]
+			data, err := se.readAttachment(*attachment)
+			if err != nil {
+				attachment.Missing = true
+				continue
+			}
+			attachment.Data = base64.StdEncoding.EncodeToString(data)
+		}
+	}
+}
+
+// Render writes an export in format. Attachments are embedded in the output
+// with ExportAttachmentsInline; ExportAttachmentsZip is handled by WriteZip.
+func (se *SessionExporter) Render(w io.Writer, export *SessionExport, format, attachments string) error {
+	if attachments == ExportAttachmentsInline {
+		se.inlineAttachments(export)
+	}
+
+	switch format {
+	case ExportFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(export)
+	case ExportFormatMarkdown:
+		_, err := io.WriteString(w, RenderMarkdown(export))
+		return err
+	case ExportFormatHTML:
+		return RenderHTML(w, export)
+	default:
+		return fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// WriteZip adds a session transcript named name.<format> and its attachment
+// files to an archive. written tracks the attachments already in the archive.
+func (se *SessionExporter) WriteZip(archive *zip.Writer, export *SessionExport, format, name string, written map[string]bool) error {
+	file, err := archive.Create(name + "." + format)
+	if err != nil {
+		return fmt.Errorf("failed to create archive entry: %w", err)
+	}
+	if err := se.Render(file, export, format, ExportAttachmentsNone); err != nil {
+		return err
+	}
+
+	for _, message := range export.Messages {
+		for _, attachment := range message.Attachments {
+			if written[attachment.Path] {
+				continue
+			}
+			data, err := se.readAttachment(attachment)
+			if err != nil {
+				continue
+			}
+			written[attachment.Path] = true
+			file, err := archive.Create(attachment.Path)
+			if err != nil {
+				return fmt.Errorf("failed to create archive entry: %w", err)
+			}
+			if _, err := file.Write(data); err != nil {
+				return fmt.Errorf("failed to write attachment: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ExportFilename returns a readable, unique base filename for a session
+func ExportFilename(session *models.Session) string {
+	slug := strings.Trim(slugRegex.ReplaceAllString(strings.ToLower(session.Title), "-"), "-")
+	if len(slug) > 50 {
+		slug = strings.Trim(slug[:50], "-")
+	}
+	if slug == "" {
+		slug = "session"
+	}
+	id := session.SessionID
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return fmt.Sprintf("%s-%s-%s", session.CreatedAt.Format("2006-01-02"), slug, id)
+}
+
+var slugRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// exportEntry is a message or a tool call in the conversation timeline
+type exportEntry struct {
+	Message  *ExportMessage
+	ToolCall *models.ToolCall
+	Time     time.Time
+}
+
+// timeline interleaves messages and tool calls by time
+func (export *SessionExport) timeline() []exportEntry {
+	entries := make([]exportEntry, 0, len(export.Messages)+len(export.ToolCalls))
+	for i := range export.Messages {
+		entries = append(entries, exportEntry{Message: &export.Messages[i], Time: export.Messages[i].CreatedAt})
+	}
+	for _, toolCall := range export.ToolCalls {
+		entries = append(entries, exportEntry{ToolCall: toolCall, Time: toolCall.CreatedAt})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries
+}
+
+// exportTitle returns the session title or a placeholder
+func exportTitle(session *models.Session) string {
+	if session.Title == "" {
+		return "Sans titre"
+	}
+	return session.Title
+}
+
+// attachmentURI returns the link of an attachment: a data URI when inlined,
+// its archive path otherwise
+func attachmentURI(attachment ExportAttachment) string {
+	if attachment.Data != "" {
+		mimeType := attachment.MimeType
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		return "data:" + mimeType + ";base64," + attachment.Data
+	}
+	return attachment.Path
+}
+
+// RenderMarkdown renders an export as a Markdown document
+func RenderMarkdown(export *SessionExport) string {
+	var sb strings.Builder
+
+	sb.WriteString("# " + exportTitle(export.Session) + "\n\n")
+	sb.WriteString(fmt.Sprintf("- Session: `%s`\n", export.Session.SessionID))
+	if export.Session.Model != "" {
+		sb.WriteString(fmt.Sprintf("- Model: %s\n", export.Session.Model))
+	}
+	sb.WriteString(fmt.Sprintf("- Created: %s\n", export.Session.CreatedAt.Format(time.RFC3339)))
+	sb.WriteString(fmt.Sprintf("- Usage: %d input tokens, %d output tokens, $%.4f, %d tool calls\n\n",
+		export.Usage.InputTokens, export.Usage.OutputTokens, export.Usage.TotalCostUSD, export.Usage.ToolCalls))
+
+	for _, entry := range export.timeline() {
+		if entry.ToolCall != nil {
+			toolCall := entry.ToolCall
+			sb.WriteString(fmt.Sprintf("### Tool: %s (%s)\n\n", toolCall.ToolName, toolCall.Status))
+			sb.WriteString(markdownFence("json", toolCall.Input))
+			if toolCall.Output != "" {
+				sb.WriteString(markdownFence("", toolCall.Output))
+			}
+			continue
+		}
+
+		message := entry.Message
+		switch message.Role {
+		case "thinking":
+			sb.WriteString("<details>\n<summary>Thinking</summary>\n\n")
+			sb.WriteString(message.Content)
+			sb.WriteString("\n\n</details>\n\n")
+			continue
+		case "user":
+			sb.WriteString("## User")
+		default:
+			sb.WriteString("## Assistant")
+		}
+		sb.WriteString(" — " + message.CreatedAt.Format("2006-01-02 15:04") + "\n\n")
+		sb.WriteString(message.Content)
+		sb.WriteString("\n\n")
+
+		for _, attachment := range message.Attachments {
+			if attachment.Type == "image" {
+				sb.WriteString(fmt.Sprintf("![%s](%s)\n\n", attachment.Filename, attachmentURI(attachment)))
+			} else {
+				sb.WriteString(fmt.Sprintf("[%s](%s)\n\n", attachment.Filename, attachmentURI(attachment)))
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// markdownFence wraps content in a code fence longer than any backtick run it contains
+func markdownFence(language, content string) string {
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	return fence + language + "\n" + strings.TrimRight(content, "\n") + "\n" + fence + "\n\n"
+}
+
+// RenderHTML renders an export as a self-contained HTML page
+func RenderHTML(w io.Writer, export *SessionExport) error {
+	var buf bytes.Buffer
+	err := exportHTMLTemplate.Execute(&buf, map[string]interface{}{
+		"Title":    exportTitle(export.Session),
+		"Export":   export,
+		"Timeline": export.timeline(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render HTML export: %w", err)
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+var exportHTMLTemplate = template.Must(template.New("export").Funcs(template.FuncMap{
+	"uri": func(attachment ExportAttachment) template.URL {
+		return template.URL(attachmentURI(attachment))
+	},
+	"date": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04")
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="fr">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 860px; margin: 2rem auto; padding: 0 1rem; color: #1f2328; background: #fff; }
+header { border-bottom: 1px solid #d0d7de; margin-bottom: 1.5rem; }
+header p { color: #656d76; font-size: .9rem; }
+.message { margin: 1rem 0; padding: .75rem 1rem; border-radius: 8px; }
+.user { background: #ddf4ff; margin-left: 15%; }
+.assistant { background: #f6f8fa; margin-right: 15%; }
+.meta { font-size: .75rem; color: #656d76; margin-bottom: .25rem; }
+.content { white-space: pre-wrap; word-wrap: break-word; }
+details { margin: .5rem 0; padding: .5rem 1rem; border: 1px solid #d0d7de; border-radius: 8px; font-size: .9rem; }
+summary { cursor: pointer; color: #656d76; }
+pre { white-space: pre-wrap; word-wrap: break-word; background: #f6f8fa; padding: .5rem; border-radius: 6px; font-size: .8rem; }
+img { max-width: 100%; border-radius: 6px; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<p>Session {{.Export.Session.SessionID}}{{if .Export.Session.Model}} · {{.Export.Session.Model}}{{end}} · {{date .Export.Session.CreatedAt}}<br>
+{{.Export.Usage.InputTokens}} input tokens · {{.Export.Usage.OutputTokens}} output tokens · ${{printf "%.4f" .Export.Usage.TotalCostUSD}} · {{.Export.Usage.ToolCalls}} tool calls</p>
+</header>
+{{range .Timeline}}{{if .ToolCall}}<details class="tool">
+<summary>Tool: {{.ToolCall.ToolName}} ({{.ToolCall.Status}})</summary>
+<pre>{{.ToolCall.Input}}</pre>
+{{if .ToolCall.Output}}<pre>{{.ToolCall.Output}}</pre>{{end}}
+</details>
+{{else if eq .Message.Role "thinking"}}<details class="thinking">
+<summary>Thinking</summary>
+<div class="content">{{.Message.Content}}</div>
+</details>
+{{else}}<div class="message {{.Message.Role}}">
+<div class="meta">{{if eq .Message.Role "user"}}User{{else}}Assistant{{end}} · {{date .Message.CreatedAt}}</div>
+<div class="content">{{.Message.Content}}</div>
+{{range .Message.Attachments}}{{if and (eq .Type "image") (not .Missing)}}<p><img src="{{uri .}}" alt="{{.Filename}}"></p>
+{{else}}<p><a href="{{uri .}}" download="{{.Filename}}">{{.Filename}}</a></p>
+{{end}}{{end}}</div>
+{{end}}{{end}}
+</body>
+</html>
+`))
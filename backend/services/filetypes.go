@@ -0,0 +1,261 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/ronan/home-agent/repositories"
+)
+
+// UploadSettingsKey is the settings key of the upload size limits
+const UploadSettingsKey = "uploads"
+
+// Upload categories, each with its own size limit
+const (
+	UploadImage    = "image"
+	UploadDocument = "document"
+	UploadArchive  = "archive"
+	UploadText     = "text"
+)
+
+// maxUploadSizeMB is the request body limit of the server: no limit can exceed it
+const maxUploadSizeMB = 100
+
+// defaultUploadSizesMB are the limits of categories the configuration leaves out
+var defaultUploadSizesMB = map[string]int64{
+	UploadImage:    20,
+	UploadDocument: 25,
+	UploadArchive:  100,
+	UploadText:     5,
+}
+
+// SniffLength is the number of leading bytes CheckContent needs
+const SniffLength = 1024
+
+// ErrContentMismatch is returned for uploads whose bytes are not of the type
+// their name or MIME type claims
+var ErrContentMismatch = errors.New("file content does not match its type")
+
+// FileType is an accepted upload type
+type FileType struct {
+	Ext      string // Extension files of this type are stored with
+	MimeType string
+	Category string
+	match    func(header []byte) bool
+}
+
+// IsImage reports whether files of this type are sent to Claude as images
+func (ft FileType) IsImage() bool {
+	return ft.Category == UploadImage
+}
+
+// fileTypes are the accepted uploads by extension, with the check of their first bytes
+var fileTypes = map[string]FileType{
+	".png":    {MimeType: "image/png", Category: UploadImage, match: hasPrefix("\x89PNG\r\n\x1a\n")},
+	".jpg":    {MimeType: "image/jpeg", Category: UploadImage, match: hasPrefix("\xff\xd8\xff")},
+	".jpeg":   {MimeType: "image/jpeg", Category: UploadImage, match: hasPrefix("\xff\xd8\xff")},
+	".gif":    {MimeType: "image/gif", Category: UploadImage, match: hasPrefix("GIF87a", "GIF89a")},
+	".webp":   {MimeType: "image/webp", Category: UploadImage, match: isWebP},
+	".heic":   {MimeType: "image/heic", Category: UploadImage, match: isHEIC},
+	".heif":   {MimeType: "image/heif", Category: UploadImage, match: isHEIC},
+	".pdf":    {MimeType: "application/pdf", Category: UploadDocument, match: isPDF},
+	".docx":   {MimeType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", Category: UploadDocument, match: isZip},
+	".xlsx":   {MimeType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Category: UploadDocument, match: isZip},
+	".ods":    {MimeType: "application/vnd.oasis.opendocument.spreadsheet", Category: UploadDocument, match: isZip},
+	".epub":   {MimeType: "application/epub+zip", Category: UploadDocument, match: isZip},
+	".zip":    {MimeType: "application/zip", Category: UploadArchive, match: isZip},
+	".tar":    {MimeType: "application/x-tar", Category: UploadArchive, match: isTar},
+	".tar.gz": {MimeType: "application/gzip", Category: UploadArchive, match: hasPrefix("\x1f\x8b")},
+	".txt":    {MimeType: "text/plain", Category: UploadText, match: isText},
+	".md":     {MimeType: "text/markdown", Category: UploadText, match: isText},
+	".json":   {MimeType: "application/json", Category: UploadText, match: isText},
+	".csv":    {MimeType: "text/csv", Category: UploadText, match: isText},
+	".xml":    {MimeType: "application/xml", Category: UploadText, match: isText},
+	".yaml":   {MimeType: "text/yaml", Category: UploadText, match: isText},
+	".yml":    {MimeType: "text/yaml", Category: UploadText, match: isText},
+	".html":   {MimeType: "text/html", Category: UploadText, match: isText},
+	".svg":    {MimeType: "image/svg+xml", Category: UploadText, match: isText},
+	".css":    {MimeType: "text/css", Category: UploadText, match: isText},
+	".js":     {MimeType: "text/javascript", Category: UploadText, match: isText},
+	".ts":     {MimeType: "text/plain", Category: UploadText, match: isText},
+	".go":     {MimeType: "text/plain", Category: UploadText, match: isText},
+	".py":     {MimeType: "text/plain", Category: UploadText, match: isText},
+	".rs":     {MimeType: "text/plain", Category: UploadText, match: isText},
+	".java":   {MimeType: "text/plain", Category: UploadText, match: isText},
+	".c":      {MimeType: "text/plain", Category: UploadText, match: isText},
+	".cpp":    {MimeType: "text/plain", Category: UploadText, match: isText},
+	".h":      {MimeType: "text/plain", Category: UploadText, match: isText},
+	".sh":     {MimeType: "text/plain", Category: UploadText, match: isText},
+	".sql":    {MimeType: "text/plain", Category: UploadText, match: isText},
+	".log":    {MimeType: "text/plain", Category: UploadText, match: isText},
+}
+
+// mimeExtensions gives the type of files whose name has no accepted extension
+// (pasted images, extensionless scripts) from their declared MIME type
+var mimeExtensions = map[string]string{
+	"image/png":                    ".png",
+	"image/jpeg":                   ".jpg",
+	"image/jpg":                    ".jpg",
+	"image/gif":                    ".gif",
+	"image/webp":                   ".webp",
+	"image/heic":                   ".heic",
+	"image/heif":                   ".heif",
+	"application/pdf":              ".pdf",
+	"application/epub+zip":         ".epub",
+	"application/zip":              ".zip",
+	"application/x-zip-compressed": ".zip",
+	"application/x-tar":            ".tar",
+	"text/plain":                   ".txt",
+	"text/markdown":                ".md",
+	"application/json":             ".json",
+	"text/csv":                     ".csv",
+	"application/xml":              ".xml",
+	"text/xml":                     ".xml",
+	"text/yaml":                    ".yaml",
+	"application/x-yaml":           ".yaml",
+	"text/html":                    ".html",
+	"image/svg+xml":                ".svg",
+	"text/css":                     ".css",
+	"text/javascript":              ".js",
+	"application/javascript":       ".js",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": ".docx",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":       ".xlsx",
+	"application/vnd.oasis.opendocument.spreadsheet":                          ".ods",
+}
+
+// LookupFileType returns the type of an upload from its name, or from its
+// declared MIME type when the name has no accepted extension. The declared
+// type is only a hint: CheckContent verifies the file's bytes.
+func LookupFileType(filename, declaredMime string) (FileType, bool) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	// Archives are recognized by name: ".tar.gz" is not a single extension
+	if format := ArchiveFormat(filename); format != "" {
+		ext = "." + format
+	}
+	if _, ok := fileTypes[ext]; !ok {
+		mediaType, _, _ := strings.Cut(declaredMime, ";")
+		ext = mimeExtensions[strings.ToLower(strings.TrimSpace(mediaType))]
+	}
+	ft, ok := fileTypes[ext]
+	ft.Ext = ext
+	return ft, ok
+}
+
+// CheckContent verifies the first bytes of an upload (SniffLength of them,
+// fewer for small files) match its type
+func CheckContent(ft FileType, header []byte) error {
+	if ft.match == nil || !ft.match(header) {
+		return fmt.Errorf("%w (%s)", ErrContentMismatch, ft.Ext)
+	}
+	return nil
+}
+
+// activeContent are the extensions browsers run scripts from when opened
+var activeContent = map[string]bool{
+	".html":  true,
+	".htm":   true,
+	".xhtml": true,
+	".svg":   true,
+	".xml":   true,
+	".js":    true,
+	".mjs":   true,
+}
+
+// IsActiveContent reports whether a stored file could run scripts if a browser
+// displayed it, and must be downloaded instead
+func IsActiveContent(filename string) bool {
+	return activeContent[strings.ToLower(filepath.Ext(filename))]
+}
+
+func hasPrefix(signatures ...string) func([]byte) bool {
+	return func(header []byte) bool {
+		for _, signature := range signatures {
+			if bytes.HasPrefix(header, []byte(signature)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func isWebP(header []byte) bool {
+	return len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "WEBP"
+}
+
+// isPDF accepts the header anywhere in the first KB, as PDF readers do
+func isPDF(header []byte) bool {
+	return bytes.Contains(header[:min(len(header), SniffLength)], []byte("%PDF-"))
+}
+
+// isZip accepts local file headers and the end record of empty archives
+func isZip(header []byte) bool {
+	return bytes.HasPrefix(header, []byte("PK\x03\x04")) || bytes.HasPrefix(header, []byte("PK\x05\x06"))
+}
+
+// isTar checks the ustar magic of POSIX and GNU archives
+func isTar(header []byte) bool {
+	return len(header) >= 262 && string(header[257:262]) == "ustar"
+}
+
+// isText rejects binary data: NUL and other control bytes text never holds
+func isText(header []byte) bool {
+	return strings.HasPrefix(http.DetectContentType(header), "text/")
+}
+
+// UploadConfig holds the maximum size of uploads per category, in MB
+type UploadConfig struct {
+	MaxSizeMB map[string]int64 `json:"max_size_mb,omitempty"` // image, document, archive or text
+}
+
+// ParseUploadConfig parses and validates the stored upload configuration; an
+// empty value gives the defaults
+func ParseUploadConfig(raw string) (UploadConfig, error) {
+	var config UploadConfig
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &config); err != nil {
+			return config, fmt.Errorf("invalid upload configuration: %w", err)
+		}
+	}
+	for category, size := range config.MaxSizeMB {
+		if _, ok := defaultUploadSizesMB[category]; !ok {
+			return config, fmt.Errorf("unknown upload category %q (image, document, archive or text)", category)
+		}
+		if size < 1 || size > maxUploadSizeMB {
+			return config, fmt.Errorf("max_size_mb.%s must be between 1 and %d", category, maxUploadSizeMB)
+		}
+	}
+	return config, nil
+}
+
+// MaxSize returns the size limit of a category in bytes
+func (c UploadConfig) MaxSize(category string) int64 {
+	size, ok := c.MaxSizeMB[category]
+	if !ok {
+		size = defaultUploadSizesMB[category]
+	}
+	return size * 1024 * 1024
+}
+
+// UploadPolicy reads the upload limits from the settings
+type UploadPolicy struct {
+	settings repositories.SettingsRepository
+}
+
+// NewUploadPolicy creates a new UploadPolicy
+func NewUploadPolicy(settings repositories.SettingsRepository) *UploadPolicy {
+	return &UploadPolicy{settings: settings}
+}
+
+// Config returns the current upload configuration
+func (up *UploadPolicy) Config() (UploadConfig, error) {
+	raw, err := up.settings.Get(UploadSettingsKey)
+	if err != nil {
+		return UploadConfig{}, err
+	}
+	return ParseUploadConfig(raw)
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLookupFileType(t *testing.T) {
+	tests := []struct {
+		filename string
+		mime     string
+		wantExt  string
+		wantMime string
+		category string
+		ok       bool
+	}{
+		{"photo.PNG", "image/png", ".png", "image/png", UploadImage, true},
+		// The extension wins over the declared type
+		{"notes.txt", "image/png", ".txt", "text/plain", UploadText, true},
+		{"report.pdf", "text/html", ".pdf", "application/pdf", UploadDocument, true},
+		{"page.html", "text/plain", ".html", "text/html", UploadText, true},
+		// Double extensions of compressed tarballs
+		{"backup.tar.gz", "application/gzip", ".tar.gz", "application/gzip", UploadArchive, true},
+		{"backup.TGZ", "", ".tar.gz", "application/gzip", UploadArchive, true},
+		{"backup.tar", "", ".tar", "application/x-tar", UploadArchive, true},
+		// Names without an accepted extension fall back to the declared type
+		{"image", "image/jpeg", ".jpg", "image/jpeg", UploadImage, true},
+		{"Makefile", "text/plain; charset=utf-8", ".txt", "text/plain", UploadText, true},
+		{"archive.bin", "Application/X-Zip-Compressed", ".zip", "application/zip", UploadArchive, true},
+		{"data.gz", "application/gzip", "", "", "", false},
+		{"run.exe", "application/octet-stream", "", "", "", false},
+		{"run.exe", "", "", "", "", false},
+	}
+
+	for _, tt := range tests {
+		ft, ok := LookupFileType(tt.filename, tt.mime)
+		if ok != tt.ok {
+			t.Errorf("LookupFileType(%q, %q) ok = %v, want %v", tt.filename, tt.mime, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if ft.Ext != tt.wantExt || ft.MimeType != tt.wantMime || ft.Category != tt.category {
+			t.Errorf("LookupFileType(%q, %q) = %s %s %s, want %s %s %s", tt.filename, tt.mime,
+				ft.Ext, ft.MimeType, ft.Category, tt.wantExt, tt.wantMime, tt.category)
+		}
+	}
+}
+
+func TestCheckContent(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"
+	tar := strings.Repeat("\x00", 257) + "ustar\x0000"
+
+	tests := []struct {
+		filename string
+		header   string
+		ok       bool
+	}{
+		{"a.png", png, true},
+		{"a.pdf", png, false},
+		{"a.txt", png, false},
+		{"a.jpg", "\xff\xd8\xff\xe0\x00\x10JFIF", true},
+		{"a.jpg", png, false},
+		{"a.gif", "GIF89a\x01\x00", true},
+		{"a.webp", "RIFF\x24\x00\x00\x00WEBPVP8 ", true},
+		{"a.webp", "RIFF\x24\x00\x00\x00WAVEfmt ", false},
+		{"a.heic", "\x00\x00\x00\x18ftypheic\x00\x00\x00\x00", true},
+		{"a.heic", "\x00\x00\x00\x18ftypisom\x00\x00\x00\x00", false},
+		{"a.pdf", "%PDF-1.7\n", true},
+		// PDF readers accept junk before the header, within the first KB
+		{"a.pdf", strings.Repeat("x", 600) + "%PDF-1.4", true},
+		{"a.pdf", strings.Repeat("x", SniffLength) + "%PDF-1.4", false},
+		{"a.docx", "PK\x03\x04\x14\x00", true},
+		{"a.docx", "%PDF-1.7", false},
+		{"a.zip", "PK\x05\x06" + strings.Repeat("\x00", 18), true},
+		{"a.tar", tar, true},
+		{"a.tar", "ustar", false},
+		{"a.tar.gz", "\x1f\x8b\x08\x00", true},
+		{"a.tar.gz", "PK\x03\x04", false},
+		{"a.txt", "hello, world\n", true},
+		{"a.txt", "", true},
+		{"a.log", "\x1b[31mred\x1b[0m output", true},
+		{"a.txt", "MZ\x90\x00\x03\x00\x00\x00", false},
+		{"a.html", "<!DOCTYPE html><script>alert(1)</script>", true},
+		{"a.svg", `<svg xmlns="http://www.w3.org/2000/svg"/>`, true},
+		{"a.js", "\x7fELF\x02\x01\x01", false},
+	}
+
+	for _, tt := range tests {
+		ft, ok := LookupFileType(tt.filename, "")
+		if !ok {
+			t.Fatalf("LookupFileType(%q) not accepted", tt.filename)
+		}
+		err := CheckContent(ft, []byte(tt.header))
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckContent(%s, %q) = %v, want ok %v", tt.filename, tt.header[:min(len(tt.header), 16)], err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrContentMismatch) {
+			t.Errorf("CheckContent(%s) error %v is not ErrContentMismatch", tt.filename, err)
+		}
+	}
+}
+
+func TestIsActiveContent(t *testing.T) {
+	for name, want := range map[string]bool{
+		"a.html": true, "a.HTM": true, "a.svg": true, "a.js": true, "a.mjs": true, "a.xml": true,
+		"a.png": false, "a.txt": false, "a.pdf": false, "a.css": false, "html": false,
+	} {
+		if got := IsActiveContent(name); got != want {
+			t.Errorf("IsActiveContent(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestParseUploadConfig(t *testing.T) {
+	config, err := ParseUploadConfig("")
+	if err != nil {
+		t.Fatalf("ParseUploadConfig(\"\") failed: %v", err)
+	}
+	for category, size := range defaultUploadSizesMB {
+		if got := config.MaxSize(category); got != size*1024*1024 {
+			t.Errorf("default MaxSize(%s) = %d, want %d MB", category, got, size)
+		}
+	}
+
+	// Categories left out keep their default
+	config, err = ParseUploadConfig(`{"max_size_mb":{"image":5,"archive":100}}`)
+	if err != nil {
+		t.Fatalf("ParseUploadConfig failed: %v", err)
+	}
+	if config.MaxSize(UploadImage) != 5*1024*1024 || config.MaxSize(UploadArchive) != 100*1024*1024 {
+		t.Errorf("configured limits not applied: %+v", config)
+	}
+	if config.MaxSize(UploadText) != defaultUploadSizesMB[UploadText]*1024*1024 {
+		t.Errorf("text limit = %d, want the default", config.MaxSize(UploadText))
+	}
+
+	for _, raw := range []string{
+		`not json`,
+		`{"max_size_mb":{"video":10}}`,
+		`{"max_size_mb":{"image":0}}`,
+		`{"max_size_mb":{"image":-1}}`,
+		`{"max_size_mb":{"archive":101}}`,
+		`{"max_size_mb":{"text":"5"}}`,
+	} {
+		if _, err := ParseUploadConfig(raw); err == nil {
+			t.Errorf("ParseUploadConfig(%q) should fail", raw)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/repositories"
+)
+
+// ErrMessageNotFound is returned when a fork point is not a message of the session
+var ErrMessageNotFound = errors.New("message not found in session")
+
+// ErrNotUserMessage is returned when editing a message that was not sent by the user
+var ErrNotUserMessage = errors.New("only user messages can be edited")
+
+// forkSeedPrompt starts the Claude session of a fork from the copied transcript
+const forkSeedPrompt = `This conversation continues an earlier one. Here is the transcript so far:
+
+<conversation>
+%s
+</conversation>
+
+Continue the conversation from there. The user's next message follows.
+
+%s`
+
+// ForkService copies sessions up to a message so a conversation can take another path.
+// The SDK cannot fork a session at an earlier message, so a fork gets a new Claude
+// session seeded with its transcript on its first turn.
+type ForkService struct {
+	sessions repositories.SessionRepository
+	messages repositories.MessageRepository
+}
+
+// NewForkService creates a new ForkService
+func NewForkService(sessions repositories.SessionRepository, messages repositories.MessageRepository) *ForkService {
+	return &ForkService{sessions: sessions, messages: messages}
+}
+
+// Fork creates a session with the history of sessionID up to atMessageID included.
+// A zero atMessageID copies the whole history.
+func (fs *ForkService) Fork(sessionID string, atMessageID int) (*models.Session, error) {
+	messages, err := fs.messages.GetBySession(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	lastMessageID := 0
+	if atMessageID == 0 {
+		if len(messages) > 0 {
+			lastMessageID = messages[len(messages)-1].ID
+		}
+	} else {
+		if findMessage(messages, atMessageID) < 0 {
+			return nil, ErrMessageNotFound
+		}
+		lastMessageID = atMessageID
+	}
+
+	return fs.sessions.Fork(sessionID, uuid.New().String(), lastMessageID)
+}
+
+// ForkForEdit creates a session with the history of sessionID before a user message,
+// so the message can be sent again with new content
+func (fs *ForkService) ForkForEdit(sessionID string, messageID int) (*models.Session, error) {
+	messages, err := fs.messages.GetBySession(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	i := findMessage(messages, messageID)
+	if i < 0 {
+		return nil, ErrMessageNotFound
+	}
+	if messages[i].Role != "user" {
+		return nil, ErrNotUserMessage
+	}
+
+	lastMessageID := 0
+	if i > 0 {
+		lastMessageID = messages[i-1].ID
+	}
+
+	return fs.sessions.Fork(sessionID, uuid.New().String(), lastMessageID)
+}
+
+// SeedPrompt prefixes prompt with the transcript of a fork waiting for its Claude session
+func (fs *ForkService) SeedPrompt(sessionID, prompt string) (string, error) {
+	messages, err := fs.messages.GetBySession(sessionID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	transcript := conversationTranscript(messages)
+	if transcript == "" {
+		return prompt, nil
+	}
+	return fmt.Sprintf(forkSeedPrompt, transcript, prompt), nil
+}
+
+// MarkSeeded records that a fork now has its own Claude session
+func (fs *ForkService) MarkSeeded(sessionID string) error {
+	return fs.sessions.UpdateSeedPending(sessionID, false)
+}
+
+// findMessage returns the index of a message by ID, -1 when absent
+func findMessage(messages []*models.Message, messageID int) int {
+	for i, msg := range messages {
+		if msg.ID == messageID {
+			return i
+		}
+	}
+	return -1
+}
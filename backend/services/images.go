@@ -0,0 +1,652 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+
+	"github.com/ronan/home-agent/repositories"
+)
+
+// ImageSettingsKey is the settings key holding the JSON image processing configuration
+const ImageSettingsKey = "images"
+
+const (
+	defaultImageMaxEdge  = 1568       // Pixels; Claude downscales larger images anyway
+	defaultThumbnailEdge = 256        // Pixels
+	maxImagePixels       = 50_000_000 // Larger images are refused rather than decoded
+	jpegQuality          = 90
+	thumbnailQuality     = 80
+	heicConvertTimeout   = 30 * time.Second
+	// The API limit of an image content block applies to its base64 encoding,
+	// which allows 3.75 MB of image data
+	maxInlineImageBase64 = 5 * 1024 * 1024
+	minInlineImageEdge   = 200 // Pixels; smaller images are not worth sending inline
+)
+
+// inlineImageTypes are the image formats Claude accepts as content blocks
+var inlineImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Directories of the upload directory holding derived files
+const (
+	thumbnailsDir = ".thumbnails"
+	originalsDir  = ".originals"
+)
+
+// ErrHEICUnsupported is returned for HEIC images when no converter is installed
+var ErrHEICUnsupported = errors.New("HEIC images need heif-convert or ImageMagick on the server")
+
+// ImageConfig holds the image processing settings
+type ImageConfig struct {
+	MaxEdge       int  `json:"max_edge,omitempty"`       // Longest edge of stored images in pixels (default 1568)
+	ThumbnailEdge int  `json:"thumbnail_edge,omitempty"` // Longest edge of thumbnails in pixels (default 256)
+	KeepOriginal  bool `json:"keep_original,omitempty"`  // Keep the uploaded file, metadata included
+}
+
+// ParseImageConfig parses and validates the JSON image configuration.
+// An empty value means the defaults.
+func ParseImageConfig(raw string) (ImageConfig, error) {
+	var config ImageConfig
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &config); err != nil {
+			return ImageConfig{}, fmt.Errorf("invalid image configuration: %w", err)
+		}
+	}
+
+	if config.MaxEdge != 0 && (config.MaxEdge < 200 || config.MaxEdge > 10000) {
+		return ImageConfig{}, fmt.Errorf("max_edge must be between 200 and 10000")
+	}
+	if config.ThumbnailEdge != 0 && (config.ThumbnailEdge < 32 || config.ThumbnailEdge > 1024) {
+		return ImageConfig{}, fmt.Errorf("thumbnail_edge must be between 32 and 1024")
+	}
+	if config.MaxEdge == 0 {
+		config.MaxEdge = defaultImageMaxEdge
+	}
+	if config.ThumbnailEdge == 0 {
+		config.ThumbnailEdge = defaultThumbnailEdge
+	}
+
+	return config, nil
+}
+
+// ThumbnailPath returns the thumbnail file of an uploaded image
+func ThumbnailPath(uploadDir, storedName string) string {
+	return filepath.Join(uploadDir, thumbnailsDir, filepath.Base(storedName)+".jpg")
+}
+
+// OriginalPath returns the file keeping an uploaded image as it was sent
+func OriginalPath(uploadDir, storedName string) string {
+	return filepath.Join(uploadDir, originalsDir, filepath.Base(storedName))
+}
+
+// ProcessedImage is an uploaded image prepared for storage
+type ProcessedImage struct {
+	Data      []byte // Downscaled, without metadata
+	Ext       string // Extension matching Data: converted HEIC images are JPEG
+	MimeType  string
+	Thumbnail []byte // JPEG, nil when the image could not be decoded
+}
+
+// ImageService downscales uploaded images, strips their metadata and makes thumbnails
+type ImageService struct {
+	settings  repositories.SettingsRepository
+	uploadDir string
+}
+
+// NewImageService creates a new ImageService
+func NewImageService(settings repositories.SettingsRepository, uploadDir string) *ImageService {
+	return &ImageService{settings: settings, uploadDir: uploadDir}
+}
+
+// Config returns the current image configuration
+func (is *ImageService) Config() (ImageConfig, error) {
+	raw, err := is.settings.Get(ImageSettingsKey)
+	if err != nil {
+		return ImageConfig{}, err
+	}
+	return ParseImageConfig(raw)
+}
+
+// Process prepares an uploaded image: HEIC is converted to JPEG, images larger
+// than the configured edge are downscaled, and EXIF (GPS included), XMP and
+// text metadata are removed. JPEG orientation is applied before its EXIF is dropped.
+func (is *ImageService) Process(data []byte) (*ProcessedImage, error) {
+	config, err := is.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if isHEIC(data) {
+		if data, err = convertHEIC(data); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %w", err)
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, fmt.Errorf("image too large (%dx%d)", cfg.Width, cfg.Height)
+	}
+	fits := max(cfg.Width, cfg.Height) <= config.MaxEdge
+
+	result := &ProcessedImage{Data: data}
+	var img image.Image
+	switch format {
+	case "jpeg":
+		orientation := jpegOrientation(data)
+		if img, err = jpeg.Decode(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("invalid image: %w", err)
+		}
+		result.Ext, result.MimeType = ".jpg", "image/jpeg"
+		if fits && orientation <= 1 {
+			// Lossless: only the metadata segments are dropped
+			result.Data = stripJPEGMetadata(data)
+		} else {
+			// Scaling first makes rotating cheaper
+			img = orient(scaleToFit(img, config.MaxEdge), orientation)
+			if result.Data, err = encodeJPEG(img, jpegQuality); err != nil {
+				return nil, err
+			}
+		}
+
+	case "png":
+		if img, err = png.Decode(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("invalid image: %w", err)
+		}
+		// Re-encoding keeps the pixels and drops the ancillary chunks (eXIf, tEXt...)
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, scaleToFit(img, config.MaxEdge)); err != nil {
+			return nil, fmt.Errorf("failed to encode image: %w", err)
+		}
+		result.Data, result.Ext, result.MimeType = buf.Bytes(), ".png", "image/png"
+
+	case "webp":
+		result.Ext, result.MimeType = ".webp", "image/webp"
+		result.Data = stripWebPMetadata(data)
+		// Animated WebP cannot be decoded: it is kept, without metadata or thumbnail
+		if img, err = webp.Decode(bytes.NewReader(data)); err == nil && !fits {
+			// There is no WebP encoder: large images become JPEG, or PNG to keep transparency
+			if hasAlpha(img) {
+				var buf bytes.Buffer
+				if err := png.Encode(&buf, scaleToFit(img, config.MaxEdge)); err != nil {
+					return nil, fmt.Errorf("failed to encode image: %w", err)
+				}
+				result.Data, result.Ext, result.MimeType = buf.Bytes(), ".png", "image/png"
+			} else {
+				if result.Data, err = encodeJPEG(scaleToFit(img, config.MaxEdge), jpegQuality); err != nil {
+					return nil, err
+				}
+				result.Ext, result.MimeType = ".jpg", "image/jpeg"
+			}
+		}
+
+	case "gif":
+		// Not scaled, to preserve animations; comments and XMP are dropped
+		result.Ext, result.MimeType = ".gif", "image/gif"
+		result.Data = stripGIFMetadata(data)
+		img, _ = gif.Decode(bytes.NewReader(data))
+
+	default:
+		return nil, fmt.Errorf("unsupported image format: %s", format)
+	}
+
+	if img != nil {
+		if result.Thumbnail, err = thumbnail(img, config.ThumbnailEdge); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// SaveDerived writes the thumbnail of a stored image and, when configured, the
+// file as it was uploaded
+func (is *ImageService) SaveDerived(storedName string, original []byte, processed *ProcessedImage) error {
+	if processed.Thumbnail != nil {
+		if err := writeDerived(ThumbnailPath(is.uploadDir, storedName), processed.Thumbnail); err != nil {
+			return err
+		}
+	}
+
+	config, err := is.Config()
+	if err != nil {
+		return err
+	}
+	if config.KeepOriginal {
+		return writeDerived(OriginalPath(is.uploadDir, storedName), original)
+	}
+	return nil
+}
+
+// Thumbnail returns the thumbnail file of a stored image, creating it for
+// images uploaded before thumbnails existed
+func (is *ImageService) Thumbnail(storedName string) (string, error) {
+	path := ThumbnailPath(is.uploadDir, storedName)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(is.uploadDir, filepath.Base(storedName)))
+	if err != nil {
+		return "", err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("invalid image: %w", err)
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return "", fmt.Errorf("image too large (%dx%d)", cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("invalid image: %w", err)
+	}
+
+	config, err := is.Config()
+	if err != nil {
+		return "", err
+	}
+	thumb, err := thumbnail(orient(scaleToFit(img, config.ThumbnailEdge), jpegOrientation(data)), config.ThumbnailEdge)
+	if err != nil {
+		return "", err
+	}
+	if err := writeDerived(path, thumb); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func writeDerived(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// InlineImage prepares an image to send as a content block; its media type is
+// detected from the content. An image whose base64 encoding is over the API
+// limit is re-encoded as JPEG, downscaled until it fits.
+func InlineImage(data []byte) (*ImageInput, error) {
+	mediaType := http.DetectContentType(data)
+	if !inlineImageTypes[mediaType] {
+		return nil, fmt.Errorf("unsupported image type %s", mediaType)
+	}
+	if base64.StdEncoding.EncodedLen(len(data)) <= maxInlineImageBase64 {
+		return &ImageInput{MediaType: mediaType, Data: data}, nil
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %w", err)
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, fmt.Errorf("image too large (%dx%d)", cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %w", err)
+	}
+
+	for edge := max(cfg.Width, cfg.Height); edge >= minInlineImageEdge; edge = edge * 3 / 4 {
+		encoded, err := encodeJPEG(flatten(scaleToFit(img, edge)), jpegQuality)
+		if err != nil {
+			return nil, err
+		}
+		if base64.StdEncoding.EncodedLen(len(encoded)) <= maxInlineImageBase64 {
+			return &ImageInput{MediaType: "image/jpeg", Data: encoded}, nil
+		}
+	}
+	return nil, fmt.Errorf("image too large (%d bytes)", len(data))
+}
+
+// scaleToFit downscales an image so its longest edge is at most maxEdge
+func scaleToFit(img image.Image, maxEdge int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if max(width, height) <= maxEdge {
+		return img
+	}
+	if width >= height {
+		width, height = maxEdge, max(1, height*maxEdge/width)
+	} else {
+		width, height = max(1, width*maxEdge/height), maxEdge
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.BiLinear.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// thumbnail returns a JPEG thumbnail, transparency flattened on white
+func thumbnail(img image.Image, edge int) ([]byte, error) {
+	return encodeJPEG(flatten(scaleToFit(img, edge)), thumbnailQuality)
+}
+
+// flatten draws an image on white, for formats without transparency
+func flatten(img image.Image) image.Image {
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	return flat
+}
+
+func encodeJPEG(img image.Image, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// hasAlpha reports whether an image has transparent pixels
+func hasAlpha(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return !opaque.Opaque()
+	}
+	return true
+}
+
+// orient applies an EXIF orientation (1-8) to an image
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // Mirrored horizontally
+				sx, sy = w-1-x, y
+			case 3: // Rotated 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // Mirrored vertically
+				sx, sy = x, h-1-y
+			case 5: // Transposed
+				sx, sy = y, x
+			case 6: // Rotated 90 clockwise
+				sx, sy = y, h-1-x
+			case 7: // Transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // Rotated 90 counterclockwise
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG, 0 when absent
+func jpegOrientation(data []byte) int {
+	for _, segment := range jpegSegments(data) {
+		if segment.marker != 0xE1 || !bytes.HasPrefix(segment.data, []byte("Exif\x00\x00")) {
+			continue
+		}
+		tiff := segment.data[6:]
+		if len(tiff) < 8 {
+			return 0
+		}
+		var order binary.ByteOrder
+		switch string(tiff[:2]) {
+		case "II":
+			order = binary.LittleEndian
+		case "MM":
+			order = binary.BigEndian
+		default:
+			return 0
+		}
+		ifd := int(order.Uint32(tiff[4:8]))
+		if ifd+2 > len(tiff) {
+			return 0
+		}
+		count := int(order.Uint16(tiff[ifd:]))
+		for i := 0; i < count; i++ {
+			entry := ifd + 2 + i*12
+			if entry+12 > len(tiff) {
+				return 0
+			}
+			if order.Uint16(tiff[entry:]) == 0x0112 { // Orientation, SHORT
+				return int(order.Uint16(tiff[entry+8:]))
+			}
+		}
+		return 0
+	}
+	return 0
+}
+
+type jpegSegment struct {
+	marker byte
+	start  int // Offset of the 0xFF marker byte
+	end    int // Offset after the segment
+	data   []byte
+}
+
+// jpegSegments returns the marker segments of a JPEG up to the start of scan
+func jpegSegments(data []byte) []jpegSegment {
+	var segments []jpegSegment
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+	pos := 2
+	for pos+4 <= len(data) && data[pos] == 0xFF {
+		marker := data[pos+1]
+		if marker == 0xFF { // Fill byte
+			pos++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 { // Start of scan, end of image
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			break
+		}
+		segments = append(segments, jpegSegment{marker: marker, start: pos, end: end, data: data[pos+4 : end]})
+		pos = end
+	}
+	return segments
+}
+
+// stripJPEGMetadata removes the EXIF, XMP and comment segments of a JPEG,
+// keeping JFIF (APP0), ICC profiles (APP2) and Adobe color transforms (APP14)
+func stripJPEGMetadata(data []byte) []byte {
+	segments := jpegSegments(data)
+	if len(segments) == 0 {
+		return data
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	for _, segment := range segments {
+		isApp := segment.marker >= 0xE0 && segment.marker <= 0xEF
+		keep := !isApp || segment.marker == 0xE0 || segment.marker == 0xE2 || segment.marker == 0xEE
+		if keep && segment.marker != 0xFE { // COM
+			out = append(out, data[segment.start:segment.end]...)
+		}
+	}
+	return append(out, data[segments[len(segments)-1].end:]...)
+}
+
+// stripWebPMetadata removes the EXIF and XMP chunks of a WebP file
+func stripWebPMetadata(data []byte) []byte {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return data
+	}
+	out := append([]byte{}, data[:12]...)
+	pos := 12
+	for pos+8 <= len(data) {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := pos + 8 + size + size%2
+		if end > len(data) {
+			end = len(data)
+		}
+		switch id {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte{}, data[pos:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF and XMP flags
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out
+}
+
+// stripGIFMetadata removes the comment, plain text and application extensions
+// (XMP...) of a GIF, keeping graphic control blocks (frame delays,
+// transparency) and the looping extension. A malformed tail is dropped.
+func stripGIFMetadata(data []byte) []byte {
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return data
+	}
+	pos := 13
+	if data[10]&0x80 != 0 { // Global color table
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+	if pos > len(data) {
+		return data
+	}
+
+	// subBlocks returns the end of the data sub-blocks starting at start, -1 if truncated
+	subBlocks := func(start int) int {
+		for start < len(data) {
+			size := int(data[start])
+			start += 1 + size
+			if size == 0 {
+				return start
+			}
+		}
+		return -1
+	}
+
+	out := append([]byte{}, data[:pos]...)
+blocks:
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21: // Extension
+			if pos+2 > len(data) {
+				break blocks
+			}
+			end := subBlocks(pos + 2)
+			if end < 0 {
+				break blocks
+			}
+			label, body := data[pos+1], data[pos+2:end]
+			loop := label == 0xFF && len(body) >= 12 && body[0] == 11 &&
+				(string(body[1:12]) == "NETSCAPE2.0" || string(body[1:12]) == "ANIMEXTS1.0")
+			if label == 0xF9 || loop {
+				out = append(out, data[pos:end]...)
+			}
+			pos = end
+		case 0x2C: // Image descriptor, local color table, LZW code size and data
+			start := pos + 10
+			if start > len(data) {
+				break blocks
+			}
+			if data[pos+9]&0x80 != 0 {
+				start += 3 << (data[pos+9]&0x07 + 1)
+			}
+			start++
+			if start > len(data) {
+				break blocks
+			}
+			end := subBlocks(start)
+			if end < 0 {
+				break blocks
+			}
+			out = append(out, data[pos:end]...)
+			pos = end
+		default: // Trailer
+			break blocks
+		}
+	}
+	return append(out, 0x3B)
+}
+
+// isHEIC reports whether data is a HEIF/HEIC image
+func isHEIC(data []byte) bool {
+	if len(data) < 12 || string(data[4:8]) != "ftyp" {
+		return false
+	}
+	switch string(data[8:12]) {
+	case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1":
+		return true
+	}
+	return false
+}
+
+// heicConverters are the commands tried to convert HEIC to JPEG, given input and output paths
+var heicConverters = [][]string{
+	{"heif-convert", "-q", "92"},
+	{"magick"},
+	{"convert"},
+}
+
+// convertHEIC converts a HEIC image to JPEG with the first converter installed
+func convertHEIC(data []byte) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "heic")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	input, output := filepath.Join(dir, "image.heic"), filepath.Join(dir, "image.jpg")
+	if err := os.WriteFile(input, data, 0600); err != nil {
+		return nil, err
+	}
+
+	for _, converter := range heicConverters {
+		path, err := exec.LookPath(converter[0])
+		if err != nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), heicConvertTimeout)
+		args := append(append([]string{}, converter[1:]...), input, output)
+		out, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("%s failed: %v: %s", converter[0], err, strings.TrimSpace(string(out)))
+		}
+		return os.ReadFile(output)
+	}
+	return nil, ErrHEICUnsupported
+}
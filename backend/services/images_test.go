@@ -0,0 +1,118 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+func TestStripGIFMetadata(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{LoopCount: 0}
+	for i := 0; i < 2; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, 4, 4), palette)
+		frame.SetColorIndex(i, i, 1)
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 50)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatalf("failed to encode GIF: %v", err)
+	}
+	encoded := buf.Bytes()
+
+	// Insert a comment and an XMP application extension before the first frame
+	comment := append([]byte{0x21, 0xFE, 12}, "GPS 48.85N 2.35E"[:12]...)
+	comment = append(comment, 0)
+	xmp := append([]byte{0x21, 0xFF, 11}, "XMP DataXMP"...)
+	xmp = append(xmp, 9)
+	xmp = append(xmp, "<x:xmpmeta"[:9]...)
+	xmp = append(xmp, 0)
+	at := bytes.Index(encoded, []byte{0x21, 0xF9}) // First graphic control extension
+	if at < 0 {
+		t.Fatal("encoded GIF has no graphic control extension")
+	}
+	data := append(append(append(append([]byte{}, encoded[:at]...), comment...), xmp...), encoded[at:]...)
+
+	stripped := stripGIFMetadata(data)
+	for _, leaked := range []string{"GPS", "XMP", "xmpmeta"} {
+		if bytes.Contains(stripped, []byte(leaked)) {
+			t.Errorf("stripped GIF still contains %q", leaked)
+		}
+	}
+	if !bytes.Contains(stripped, []byte("NETSCAPE2.0")) {
+		t.Error("the looping extension was removed")
+	}
+	if !bytes.Equal(stripped, encoded) {
+		t.Errorf("stripped GIF differs from the original encoding (%d bytes, want %d)", len(stripped), len(encoded))
+	}
+
+	decoded, err := gif.DecodeAll(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("stripped GIF does not decode: %v", err)
+	}
+	if len(decoded.Image) != 2 || decoded.Delay[1] != 50 || decoded.LoopCount != 0 {
+		t.Errorf("animation changed: %d frames, delays %v, loop %d", len(decoded.Image), decoded.Delay, decoded.LoopCount)
+	}
+
+	// Truncated data keeps the complete blocks and gets a trailer
+	truncated := stripGIFMetadata(data[:len(data)-20])
+	if truncated[len(truncated)-1] != 0x3B || bytes.Contains(truncated, []byte("GPS")) {
+		t.Errorf("truncated GIF not cleaned: % x", truncated[len(truncated)-8:])
+	}
+	if got := stripGIFMetadata([]byte("not a gif")); string(got) != "not a gif" {
+		t.Errorf("non-GIF data changed: %q", got)
+	}
+}
+
+func TestInlineImageSizeLimit(t *testing.T) {
+	// Noise does not compress: the PNG is about 2 MB
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, 800, 800))
+	for i := range img.Pix {
+		img.Pix[i] = byte(rng.Intn(256))
+		if i%4 == 3 {
+			img.Pix[i] = 255
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode PNG: %v", err)
+	}
+
+	// Data after the PNG trailer is ignored by decoders; it sets the file size
+	limit := maxInlineImageBase64 / 4 * 3
+	padded := func(size int) []byte {
+		return append(bytes.Clone(buf.Bytes()), make([]byte, size-buf.Len())...)
+	}
+
+	atLimit := padded(limit)
+	inline, err := InlineImage(atLimit)
+	if err != nil {
+		t.Fatalf("InlineImage at the limit failed: %v", err)
+	}
+	if inline.MediaType != "image/png" || !bytes.Equal(inline.Data, atLimit) {
+		t.Errorf("image at the limit was changed: %s, %d bytes", inline.MediaType, len(inline.Data))
+	}
+
+	inline, err = InlineImage(padded(limit + 1))
+	if err != nil {
+		t.Fatalf("InlineImage over the limit failed: %v", err)
+	}
+	if inline.MediaType != "image/jpeg" || base64.StdEncoding.EncodedLen(len(inline.Data)) > maxInlineImageBase64 {
+		t.Errorf("image over the limit: %s, %d bytes", inline.MediaType, len(inline.Data))
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(inline.Data)); err != nil {
+		t.Errorf("re-encoded image does not decode: %v", err)
+	}
+
+	if _, err := InlineImage([]byte("%PDF-1.4")); err == nil {
+		t.Error("InlineImage accepted a PDF")
+	}
+}
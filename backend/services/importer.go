@@ -0,0 +1,438 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/repositories"
+)
+
+// importTitleLength is the length of titles derived from the first user message
+const importTitleLength = 60
+
+// ImportResult summarizes an import run
+type ImportResult struct {
+	Imported  []string `json:"imported"` // Session IDs created
+	Skipped   []string `json:"skipped"`  // Session IDs already present
+	Errors    []string `json:"errors"`
+	Messages  int      `json:"messages"`
+	ToolCalls int      `json:"tool_calls"`
+}
+
+// NewImportResult creates an empty ImportResult
+func NewImportResult() *ImportResult {
+	return &ImportResult{Imported: []string{}, Skipped: []string{}, Errors: []string{}}
+}
+
+// Merge adds the outcome of another import run
+func (r *ImportResult) Merge(other *ImportResult) {
+	r.Imported = append(r.Imported, other.Imported...)
+	r.Skipped = append(r.Skipped, other.Skipped...)
+	r.Errors = append(r.Errors, other.Errors...)
+	r.Messages += other.Messages
+	r.ToolCalls += other.ToolCalls
+}
+
+// transcriptLine is one line of a Claude Code JSONL transcript
+type transcriptLine struct {
+	Type        string            `json:"type"` // "user", "assistant", "summary"...
+	SessionID   string            `json:"sessionId"`
+	Timestamp   time.Time         `json:"timestamp"`
+	IsSidechain bool              `json:"isSidechain"` // Sub-agent conversation
+	IsMeta      bool              `json:"isMeta"`      // Injected by the CLI, not typed by the user
+	Summary     string            `json:"summary"`
+	Message     transcriptMessage `json:"message"`
+}
+
+// transcriptMessage is the API message carried by a transcript line
+type transcriptMessage struct {
+	ID      string          `json:"id"`
+	Role    string          `json:"role"`
+	Model   string          `json:"model"`
+	Content json.RawMessage `json:"content"` // String or array of content blocks
+	Usage   *struct {
+		InputTokens              int `json:"input_tokens"`
+		OutputTokens             int `json:"output_tokens"`
+		CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+		CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	} `json:"usage"`
+}
+
+// transcriptBlock is a content block of a transcript message
+type transcriptBlock struct {
+	Type      string          `json:"type"` // "text", "thinking", "tool_use", "tool_result", "image"
+	Text      string          `json:"text"`
+	Thinking  string          `json:"thinking"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+	ToolUseID string          `json:"tool_use_id"`
+	Content   json.RawMessage `json:"content"` // Tool result: string or blocks
+	IsError   bool            `json:"is_error"`
+}
+
+// importedTranscript is a parsed transcript, ready to be stored
+type importedTranscript struct {
+	session   models.Session
+	messages  []*models.Message
+	toolCalls []*models.ToolCall
+}
+
+// ClaudeCodeImporter imports conversations from Claude Code CLI transcripts
+// (~/.claude/projects/<project>/<session>.jsonl)
+type ClaudeCodeImporter struct {
+	sessions  repositories.SessionRepository
+	messages  repositories.MessageRepository
+	toolCalls repositories.ToolCallRepository
+	redactor  *Redactor
+}
+
+// NewClaudeCodeImporter creates a new ClaudeCodeImporter
+func NewClaudeCodeImporter(sessions repositories.SessionRepository, messages repositories.MessageRepository, toolCalls repositories.ToolCallRepository, redactor *Redactor) *ClaudeCodeImporter {
+	return &ClaudeCodeImporter{sessions: sessions, messages: messages, toolCalls: toolCalls, redactor: redactor}
+}
+
+// DefaultClaudeProjectsDir returns ~/.claude/projects
+func DefaultClaudeProjectsDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".claude", "projects")
+}
+
+// ImportPaths imports every .jsonl file found in paths (files or directories,
+// searched recursively)
+func (ci *ClaudeCodeImporter) ImportPaths(paths []string) *ImportResult {
+	result := NewImportResult()
+
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || filepath.Ext(path) != ".jsonl" {
+				return nil
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", path, err))
+				return nil
+			}
+			defer file.Close()
+
+			ci.importReader(file, path, result)
+			return nil
+		})
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", root, err))
+		}
+	}
+
+	return result
+}
+
+// ImportReader imports one transcript; name identifies it in errors
+func (ci *ClaudeCodeImporter) ImportReader(r io.Reader, name string) *ImportResult {
+	result := NewImportResult()
+	ci.importReader(r, name, result)
+	return result
+}
+
+func (ci *ClaudeCodeImporter) importReader(r io.Reader, name string, result *ImportResult) {
+	transcript, err := parseTranscript(r)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", name, err))
+		return
+	}
+	if transcript == nil {
+		// No conversation (e.g. only summaries or sub-agent lines)
+		return
+	}
+
+	sessionID := transcript.session.SessionID
+	existing, err := ci.sessions.Get(sessionID)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", name, err))
+		return
+	}
+	if existing != nil {
+		result.Skipped = append(result.Skipped, sessionID)
+		return
+	}
+
+	if err := ci.store(transcript); err != nil {
+		// Remove the partial session so that the import can be retried
+		if deleteErr := ci.sessions.Delete(sessionID); deleteErr != nil {
+			log.Printf("Failed to remove partially imported session %s: %v", sessionID, deleteErr)
+		}
+		result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", name, err))
+		return
+	}
+
+	log.Printf("Imported Claude Code session %s (%d messages, %d tool calls)",
+		sessionID, len(transcript.messages), len(transcript.toolCalls))
+	result.Imported = append(result.Imported, sessionID)
+	result.Messages += len(transcript.messages)
+	result.ToolCalls += len(transcript.toolCalls)
+}
+
+// store writes a parsed transcript, redacting secrets like live conversations
+func (ci *ClaudeCodeImporter) store(transcript *importedTranscript) error {
+	if err := ci.sessions.Import(&transcript.session); err != nil {
+		return err
+	}
+	for _, message := range transcript.messages {
+		message.Content = ci.redactor.Redact(message.Content)
+		if err := ci.messages.Import(message); err != nil {
+			return err
+		}
+	}
+	for _, toolCall := range transcript.toolCalls {
+		if err := ci.toolCalls.Import(toolCall); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseTranscript reads a JSONL transcript. It returns nil when the file
+// holds no main-thread conversation.
+func parseTranscript(r io.Reader) (*importedTranscript, error) {
+	reader := bufio.NewReader(r)
+	transcript := &importedTranscript{}
+	toolCalls := make(map[string]*models.ToolCall)
+	seenUsage := make(map[string]bool)
+	var summary string
+	var assistantText strings.Builder
+	var assistantTime time.Time
+
+	// Consecutive assistant text blocks form one message, as in live sessions
+	flushAssistant := func() {
+		if text := strings.TrimSpace(assistantText.String()); text != "" {
+			transcript.messages = append(transcript.messages, &models.Message{
+				Role:      "assistant",
+				Content:   text,
+				CreatedAt: assistantTime,
+			})
+		}
+		assistantText.Reset()
+	}
+
+	for lineNumber := 1; ; lineNumber++ {
+		raw, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, fmt.Errorf("failed to read transcript: %w", readErr)
+		}
+
+		raw = bytes.TrimSpace(raw)
+		if len(raw) > 0 {
+			var line transcriptLine
+			if err := json.Unmarshal(raw, &line); err != nil {
+				return nil, fmt.Errorf("line %d: invalid JSON: %w", lineNumber, err)
+			}
+
+			switch {
+			case line.Type == "summary":
+				if summary == "" {
+					summary = line.Summary
+				}
+			case line.IsSidechain || (line.Type != "user" && line.Type != "assistant"):
+				// Sub-agent threads and CLI bookkeeping are not part of the conversation
+			default:
+				if transcript.session.SessionID == "" {
+					transcript.session.SessionID = line.SessionID
+					transcript.session.CreatedAt = line.Timestamp
+				}
+				transcript.session.LastActivity = line.Timestamp
+
+				if line.Type == "assistant" {
+					if model := importModel(line.Message.Model); model != "" {
+						transcript.session.Model = model
+					}
+					// Each content block is logged on its own line with the same usage.
+					// Cache reads are the conversation so far, read again by every
+					// request: like live turns, the session only counts new input.
+					if usage := line.Message.Usage; usage != nil && !seenUsage[line.Message.ID] {
+						seenUsage[line.Message.ID] = true
+						transcript.session.InputTokens += usage.InputTokens + usage.CacheCreationInputTokens
+						transcript.session.OutputTokens += usage.OutputTokens
+					}
+				}
+
+				for _, block := range contentBlocks(line.Message.Content) {
+					switch block.Type {
+					case "text":
+						if line.Type == "assistant" {
+							if assistantText.Len() == 0 {
+								assistantTime = line.Timestamp
+							} else {
+								assistantText.WriteString("\n\n")
+							}
+							assistantText.WriteString(block.Text)
+							continue
+						}
+						if line.IsMeta || isCommandOutput(block.Text) || strings.TrimSpace(block.Text) == "" {
+							continue
+						}
+						flushAssistant()
+						transcript.messages = append(transcript.messages, &models.Message{
+							Role:      "user",
+							Content:   block.Text,
+							CreatedAt: line.Timestamp,
+						})
+					case "thinking":
+						if strings.TrimSpace(block.Thinking) == "" {
+							continue
+						}
+						flushAssistant()
+						transcript.messages = append(transcript.messages, &models.Message{
+							Role:      "thinking",
+							Content:   block.Thinking,
+							CreatedAt: line.Timestamp,
+						})
+					case "tool_use":
+						if block.ID == "" || toolCalls[block.ID] != nil {
+							continue
+						}
+						input := string(block.Input)
+						if input == "" || input == "null" {
+							input = "{}"
+						}
+						toolCall := &models.ToolCall{
+							ToolUseID: block.ID,
+							ToolName:  block.Name,
+							Input:     input,
+							Status:    "running",
+							CreatedAt: line.Timestamp,
+						}
+						toolCalls[block.ID] = toolCall
+						transcript.toolCalls = append(transcript.toolCalls, toolCall)
+					case "tool_result":
+						toolCall := toolCalls[block.ToolUseID]
+						if toolCall == nil {
+							continue
+						}
+						toolCall.Output = toolResultText(block.Content)
+						toolCall.Status = "success"
+						if block.IsError {
+							toolCall.Status = "error"
+						}
+						completedAt := line.Timestamp
+						toolCall.CompletedAt = &completedAt
+					}
+				}
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+	flushAssistant()
+
+	if transcript.session.SessionID == "" || len(transcript.messages) == 0 {
+		return nil, nil
+	}
+
+	session := &transcript.session
+	session.ClaudeSessionID = session.SessionID
+	session.Title = summary
+	if session.Title == "" {
+		session.Title = importTitle(transcript.messages)
+	}
+	if session.Model == "" {
+		session.Model = "sonnet"
+	}
+
+	for _, message := range transcript.messages {
+		message.SessionID = session.SessionID
+	}
+	sort.SliceStable(transcript.messages, func(i, j int) bool {
+		return transcript.messages[i].CreatedAt.Before(transcript.messages[j].CreatedAt)
+	})
+	for _, toolCall := range transcript.toolCalls {
+		toolCall.SessionID = session.SessionID
+		// Interrupted before a result was logged
+		if toolCall.Status == "running" {
+			toolCall.Status = "error"
+		}
+	}
+
+	return transcript, nil
+}
+
+// contentBlocks decodes message content, which is either a string or blocks
+func contentBlocks(raw json.RawMessage) []transcriptBlock {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return []transcriptBlock{{Type: "text", Text: text}}
+	}
+
+	var blocks []transcriptBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return nil
+	}
+	return blocks
+}
+
+// toolResultText flattens the content of a tool result to text
+func toolResultText(raw json.RawMessage) string {
+	var parts []string
+	for _, block := range contentBlocks(raw) {
+		switch block.Type {
+		case "text":
+			parts = append(parts, block.Text)
+		case "image":
+			parts = append(parts, "[image]")
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// isCommandOutput detects slash command echoes logged as user messages
+func isCommandOutput(text string) bool {
+	text = strings.TrimSpace(text)
+	return strings.HasPrefix(text, "<command-") || strings.HasPrefix(text, "<local-command-")
+}
+
+// importModel maps a model identifier (e.g. claude-sonnet-4-5-20250929) to
+// the model names used by Home Agent
+func importModel(model string) string {
+	for _, name := range []string{"haiku", "sonnet", "opus"} {
+		if strings.Contains(model, name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// importTitle derives a title from the first user message
+func importTitle(messages []*models.Message) string {
+	for _, message := range messages {
+		if message.Role != "user" {
+			continue
+		}
+		title := []rune(strings.Join(strings.Fields(message.Content), " "))
+		if len(title) > importTitleLength {
+			return string(title[:importTitleLength]) + "..."
+		}
+		return string(title)
+	}
+	return ""
+}
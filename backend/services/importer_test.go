@@ -0,0 +1,86 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ronan/home-agent/internal/database"
+	"github.com/ronan/home-agent/repositories"
+)
+
+// importFixture is a Claude Code transcript: a summary, a sub-agent line, an
+// assistant turn logged one content block per line and a tool call
+const importFixture = `{"type":"summary","summary":"Check the deployment"}
+{"type":"user","sessionId":"cc-1","timestamp":"2025-01-01T10:00:00Z","message":{"role":"user","content":"Is the deploy OK? DB_PASSWORD=hunter2hunter"}}
+{"type":"assistant","sessionId":"cc-1","timestamp":"2025-01-01T10:00:01Z","message":{"id":"msg_1","role":"assistant","model":"claude-sonnet-4-5-20250929","content":[{"type":"text","text":"Let me check."}],"usage":{"input_tokens":10,"output_tokens":20,"cache_creation_input_tokens":100,"cache_read_input_tokens":5000}}}
+{"type":"assistant","sessionId":"cc-1","timestamp":"2025-01-01T10:00:02Z","message":{"id":"msg_1","role":"assistant","model":"claude-sonnet-4-5-20250929","content":[{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"cat .env"}}],"usage":{"input_tokens":10,"output_tokens":20,"cache_creation_input_tokens":100,"cache_read_input_tokens":5000}}}
+{"type":"user","sessionId":"cc-1","timestamp":"2025-01-01T10:00:03Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"ANTHROPIC_API_KEY=sk-ant-REDACTED"}]}}
+{"type":"assistant","sessionId":"cc-1","timestamp":"2025-01-01T10:00:04Z","isSidechain":true,"message":{"id":"msg_side","role":"assistant","content":[{"type":"text","text":"sub-agent"}],"usage":{"input_tokens":1000,"output_tokens":1000}}}
+{"type":"assistant","sessionId":"cc-1","timestamp":"2025-01-01T10:00:05Z","message":{"id":"msg_2","role":"assistant","model":"claude-sonnet-4-5-20250929","content":[{"type":"text","text":"The deploy is fine."}],"usage":{"input_tokens":5,"output_tokens":7,"cache_creation_input_tokens":0,"cache_read_input_tokens":5200}}}
+`
+
+func TestImportClaudeCodeTranscript(t *testing.T) {
+	db, err := database.New(database.Config{Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	redactor := NewRedactor(nil)
+	sessions := repositories.NewSessionRepository(db.Conn())
+	messages := repositories.NewMessageRepository(db.Conn())
+	toolCalls := NewRedactingToolCallRepository(repositories.NewToolCallRepository(db.Conn()), redactor)
+	importer := NewClaudeCodeImporter(sessions, messages, toolCalls, redactor)
+
+	result := importer.ImportReader(strings.NewReader(importFixture), "fixture.jsonl")
+	if len(result.Errors) > 0 || len(result.Imported) != 1 || result.Messages != 2 || result.ToolCalls != 1 {
+		t.Fatalf("import result = %+v", result)
+	}
+
+	session, err := sessions.Get("cc-1")
+	if err != nil || session == nil {
+		t.Fatalf("imported session not found: %v", err)
+	}
+	if session.Title != "Check the deployment" || session.Model != "sonnet" {
+		t.Errorf("session title %q, model %q", session.Title, session.Model)
+	}
+	// Usage is counted once per API message, cache reads and sub-agents excluded
+	if session.InputTokens != 115 || session.OutputTokens != 27 {
+		t.Errorf("session tokens = %d in, %d out; want 115 in, 27 out", session.InputTokens, session.OutputTokens)
+	}
+
+	saved, err := messages.GetBySession("cc-1")
+	if err != nil {
+		t.Fatalf("GetBySession failed: %v", err)
+	}
+	if len(saved) != 2 || saved[0].Role != "user" || saved[1].Role != "assistant" {
+		t.Fatalf("imported messages = %+v", saved)
+	}
+	if strings.Contains(saved[0].Content, "hunter2hunter") || !strings.Contains(saved[0].Content, "[REDACTED:secret]") {
+		t.Errorf("user message not redacted: %q", saved[0].Content)
+	}
+	if saved[1].Content != "Let me check.\n\nThe deploy is fine." {
+		t.Errorf("assistant message = %q", saved[1].Content)
+	}
+
+	calls, err := toolCalls.GetBySession("cc-1")
+	if err != nil || len(calls) != 1 {
+		t.Fatalf("imported tool calls = %v, %v", calls, err)
+	}
+	if calls[0].ToolName != "Bash" || calls[0].Status != "success" || calls[0].Input != `{"command":"cat .env"}` {
+		t.Errorf("tool call = %+v", calls[0])
+	}
+	if strings.Contains(calls[0].Output, "sk-ant-") {
+		t.Errorf("tool output not redacted: %q", calls[0].Output)
+	}
+
+	// A second import skips the session
+	result = importer.ImportReader(strings.NewReader(importFixture), "fixture.jsonl")
+	if len(result.Skipped) != 1 || len(result.Imported) != 0 {
+		t.Errorf("second import result = %+v", result)
+	}
+}
@@ -34,6 +34,9 @@ type LogService struct {
 	subscribers map[LogSubscriber]struct{}
 	subMu       sync.RWMutex
 
+	// Masks secrets before entries are buffered or streamed
+	redactor *Redactor
+
 	// Track highest level for indicator
 	hasWarning  bool
 	hasError    bool
@@ -51,10 +54,19 @@ func NewLogService(maxEntries int) *LogService {
 	}
 }
 
+// SetRedactor enables secret redaction of log messages
+func (ls *LogService) SetRedactor(redactor *Redactor) {
+	ls.mu.Lock()
+	ls.redactor = redactor
+	ls.mu.Unlock()
+}
+
 // Log adds a new log entry and notifies subscribers
 func (ls *LogService) Log(level LogLevel, message string) {
 	ls.mu.Lock()
 
+	message = ls.redactor.Redact(message)
+
 	entry := LogEntry{
 		ID:        ls.nextID,
 		Level:     level,
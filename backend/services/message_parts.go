@@ -0,0 +1,157 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/ronan/home-agent/models"
+)
+
+// TurnRecorder persists the parts of an assistant turn as its events arrive.
+// Parts received before the session exists are kept until SetSessionID.
+type TurnRecorder struct {
+	sm        *SessionManager
+	sessionID string
+	turnID    string
+	position  int
+	text      strings.Builder // Text streamed since the last non-text part
+	pending   []*models.MessagePart
+	finished  bool
+}
+
+// NewTurnRecorder starts recording a turn of sessionID, which may be empty
+// for a conversation whose session is not created yet
+func (sm *SessionManager) NewTurnRecorder(sessionID string) *TurnRecorder {
+	return &TurnRecorder{
+		sm:        sm,
+		sessionID: sessionID,
+		turnID:    uuid.New().String(),
+	}
+}
+
+// SetSessionID sets the session of the turn and saves the parts received so far
+func (tr *TurnRecorder) SetSessionID(sessionID string) {
+	tr.sessionID = sessionID
+	pending := tr.pending
+	tr.pending = nil
+	for _, part := range pending {
+		tr.save(part)
+	}
+}
+
+// Text accumulates streamed text; consecutive chunks form a single part
+func (tr *TurnRecorder) Text(content string) {
+	tr.text.WriteString(content)
+}
+
+// Thinking records a complete thinking block
+func (tr *TurnRecorder) Thinking(content string) {
+	tr.flushText()
+	tr.add(&models.MessagePart{Type: models.PartThinking, Content: content})
+}
+
+// ToolUse records the start of a tool call
+func (tr *TurnRecorder) ToolUse(toolUseID, toolName string, input map[string]interface{}) {
+	tr.flushText()
+	tr.add(&models.MessagePart{
+		Type:      models.PartToolUse,
+		ToolUseID: toolUseID,
+		ToolName:  toolName,
+		Input:     encodeToolInput(input),
+	})
+}
+
+// ToolResult records the output of a tool call. The input, which is only
+// complete once the tool ran, replaces the one recorded at ToolUse.
+func (tr *TurnRecorder) ToolResult(toolUseID, toolName string, input map[string]interface{}, output string, isError bool) {
+	tr.flushText()
+
+	encoded := encodeToolInput(input)
+	if input != nil {
+		for _, part := range tr.pending {
+			if part.Type == models.PartToolUse && part.ToolUseID == toolUseID {
+				part.Input = encoded
+			}
+		}
+		if tr.sessionID != "" {
+			if err := tr.sm.parts.UpdateToolInput(tr.turnID, toolUseID, tr.sm.redactor.Redact(encoded)); err != nil {
+				log.Printf("Warning: failed to update tool input part: %v", err)
+			}
+		}
+	}
+
+	tr.add(&models.MessagePart{
+		Type:      models.PartToolResult,
+		Content:   output,
+		ToolUseID: toolUseID,
+		ToolName:  toolName,
+		IsError:   isError,
+	})
+}
+
+// Finish saves the assistant message of the turn and links the parts to it.
+// Nothing is saved for a turn that produced no parts. Only the first call
+// saves: a turn that fails or is interrupted is finished with what it produced.
+func (tr *TurnRecorder) Finish(content string) error {
+	if tr.finished {
+		return nil
+	}
+	tr.finished = true
+	tr.flushText()
+	if tr.sessionID == "" || tr.position == 0 {
+		return nil
+	}
+
+	message, err := tr.sm.saveMessage(tr.sessionID, "assistant", content)
+	if err != nil {
+		return err
+	}
+	if err := tr.sm.parts.AttachTurn(tr.turnID, message.ID); err != nil {
+		return fmt.Errorf("failed to attach turn to message: %w", err)
+	}
+	return nil
+}
+
+// flushText records the text accumulated since the last part
+func (tr *TurnRecorder) flushText() {
+	if tr.text.Len() == 0 {
+		return
+	}
+	tr.add(&models.MessagePart{Type: models.PartText, Content: tr.text.String()})
+	tr.text.Reset()
+}
+
+// add numbers a part and saves it, or keeps it until the session is known
+func (tr *TurnRecorder) add(part *models.MessagePart) {
+	part.Position = tr.position
+	tr.position++
+	if tr.sessionID == "" {
+		tr.pending = append(tr.pending, part)
+		return
+	}
+	tr.save(part)
+}
+
+// save redacts and persists a part; a failure only loses that part
+func (tr *TurnRecorder) save(part *models.MessagePart) {
+	part.Content = tr.sm.redactor.Redact(part.Content)
+	part.Input = tr.sm.redactor.Redact(part.Input)
+	if err := tr.sm.parts.Add(tr.sessionID, tr.turnID, part); err != nil {
+		log.Printf("Warning: failed to save message part: %v", err)
+	}
+}
+
+// encodeToolInput encodes a tool input as JSON, empty when there is none
+func encodeToolInput(input map[string]interface{}) string {
+	if input == nil {
+		return ""
+	}
+	encoded, err := json.Marshal(input)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
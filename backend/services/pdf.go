@@ -0,0 +1,977 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Minimal PDF text extraction: objects are located by scanning the file (and
+// the object streams of PDF 1.5+), pages are read in page tree order and the
+// text operators of their content streams are decoded with the fonts'
+// ToUnicode maps, or a Latin-1/WinAnsi approximation for simple fonts.
+// Scanned PDFs (images only) yield no text.
+
+// ErrPDFEncrypted is returned for encrypted PDFs, which are not supported
+var ErrPDFEncrypted = errors.New("encrypted PDF documents are not supported")
+
+// ErrPDFTooComplex is returned when extraction exceeds its work budget
+var ErrPDFTooComplex = errors.New("PDF document exceeds the extraction limits")
+
+// Limits protecting against malformed or hostile documents
+const (
+	maxPDFStreamSize  = 64 * 1024 * 1024  // Decoded size of a single stream
+	maxPDFDecodedSize = 256 * 1024 * 1024 // Decoded size of all streams together
+	maxPDFOperations  = 10000000          // Content stream tokens interpreted
+	maxPDFDepth       = 32                // Nesting of page tree nodes and form XObjects
+)
+
+type pdfName string
+type pdfString string
+type pdfKeyword string
+type pdfDict map[string]interface{}
+type pdfRef struct{ num, gen int }
+
+type pdfObject struct {
+	value  interface{}
+	stream []byte // Raw (still encoded) stream data, nil if not a stream
+}
+
+// pdfDocument holds the objects of a parsed PDF and the work spent on it
+type pdfDocument struct {
+	objects    map[int]*pdfObject
+	decoded    int   // Bytes decoded from streams
+	operations int   // Content stream tokens interpreted
+	err        error // Set when a limit is exceeded, stopping extraction
+}
+
+var pdfObjectRegex = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// extractPDFText returns the text of a PDF, one "--- Page N ---" section per page
+func extractPDFText(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF")) {
+		return "", fmt.Errorf("not a PDF document")
+	}
+
+	doc := &pdfDocument{objects: make(map[int]*pdfObject)}
+	doc.scanObjects(data)
+	if len(doc.objects) == 0 {
+		return "", fmt.Errorf("no PDF objects found")
+	}
+	if doc.encrypted(data) {
+		return "", ErrPDFEncrypted
+	}
+
+	var sb strings.Builder
+	for i, page := range doc.pages() {
+		if doc.err != nil || sb.Len() >= maxExtractedText {
+			break
+		}
+		sb.WriteString(fmt.Sprintf("--- Page %d ---\n", i+1))
+		text := strings.TrimSpace(doc.pageText(page, maxExtractedText-sb.Len()))
+		if text != "" {
+			sb.WriteString(text)
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+	if doc.err != nil {
+		return "", doc.err
+	}
+	return sb.String(), nil
+}
+
+// scanObjects finds every "n g obj" definition, later definitions (incremental
+// updates) replacing earlier ones, then unpacks the object streams
+func (d *pdfDocument) scanObjects(data []byte) {
+	for _, match := range pdfObjectRegex.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[match[2]:match[3]]))
+		p := &pdfParser{data: data, pos: match[1]}
+		value, err := p.parseValue(0)
+		if err != nil {
+			continue
+		}
+		object := &pdfObject{value: value}
+		if dict, ok := value.(pdfDict); ok {
+			object.stream = p.streamData(dict)
+		}
+		d.objects[num] = object
+	}
+
+	for _, object := range d.objects {
+		dict, ok := object.value.(pdfDict)
+		if !ok || object.stream == nil || dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		d.unpackObjectStream(dict, object)
+	}
+}
+
+// unpackObjectStream adds the objects compressed in an object stream
+func (d *pdfDocument) unpackObjectStream(dict pdfDict, object *pdfObject) {
+	data, err := d.decodeStream(dict, object.stream)
+	if err != nil {
+		return
+	}
+	count := d.integer(dict["N"])
+	first := d.integer(dict["First"])
+	if first <= 0 || first > len(data) {
+		return
+	}
+
+	header := &pdfParser{data: data[:first]}
+	for i := 0; i < count; i++ {
+		numValue, err1 := header.parseValue(0)
+		offsetValue, err2 := header.parseValue(0)
+		if err1 != nil || err2 != nil {
+			return
+		}
+		num, offset := d.integer(numValue), d.integer(offsetValue)
+		if first+offset >= len(data) {
+			continue
+		}
+		if _, exists := d.objects[num]; exists {
+			continue // Objects defined outside the stream are newer
+		}
+		p := &pdfParser{data: data, pos: first + offset}
+		if value, err := p.parseValue(0); err == nil {
+			d.objects[num] = &pdfObject{value: value}
+		}
+	}
+}
+
+// encrypted reports whether the document declares an encryption dictionary
+func (d *pdfDocument) encrypted(data []byte) bool {
+	for _, object := range d.objects {
+		if dict, ok := object.value.(pdfDict); ok && dict["Type"] == pdfName("XRef") && dict["Encrypt"] != nil {
+			return true
+		}
+	}
+	trailer := bytes.LastIndex(data, []byte("trailer"))
+	return trailer >= 0 && bytes.Contains(data[trailer:], []byte("/Encrypt"))
+}
+
+// resolve follows indirect references
+func (d *pdfDocument) resolve(value interface{}) interface{} {
+	for i := 0; i < maxPDFDepth; i++ {
+		ref, ok := value.(pdfRef)
+		if !ok {
+			return value
+		}
+		object := d.objects[ref.num]
+		if object == nil {
+			return nil
+		}
+		value = object.value
+	}
+	return nil
+}
+
+func (d *pdfDocument) dict(value interface{}) pdfDict {
+	dict, _ := d.resolve(value).(pdfDict)
+	return dict
+}
+
+func (d *pdfDocument) array(value interface{}) []interface{} {
+	array, _ := d.resolve(value).([]interface{})
+	return array
+}
+
+func (d *pdfDocument) integer(value interface{}) int {
+	number, _ := d.resolve(value).(float64)
+	return int(number)
+}
+
+// streamOf returns the decoded data of a stream object
+func (d *pdfDocument) streamOf(value interface{}) ([]byte, pdfDict) {
+	ref, ok := value.(pdfRef)
+	if !ok {
+		return nil, nil
+	}
+	object := d.objects[ref.num]
+	if object == nil || object.stream == nil {
+		return nil, nil
+	}
+	dict, _ := object.value.(pdfDict)
+	data, err := d.decodeStream(dict, object.stream)
+	if err != nil {
+		return nil, nil
+	}
+	return data, dict
+}
+
+// decodeStream applies the stream filters; only FlateDecode is supported
+func (d *pdfDocument) decodeStream(dict pdfDict, raw []byte) ([]byte, error) {
+	var filters []interface{}
+	switch filter := d.resolve(dict["Filter"]).(type) {
+	case pdfName:
+		filters = []interface{}{filter}
+	case []interface{}:
+		filters = filter
+	}
+
+	data := raw
+	for _, filter := range filters {
+		switch d.resolve(filter) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			if d.err != nil {
+				return nil, d.err
+			}
+			reader, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			// Truncated streams are common: keep what could be decoded
+			limit := min(maxPDFStreamSize, maxPDFDecodedSize-d.decoded+1)
+			decoded, err := io.ReadAll(io.LimitReader(reader, int64(limit)))
+			d.decoded += len(decoded)
+			if d.decoded > maxPDFDecodedSize {
+				d.err = ErrPDFTooComplex
+				return nil, d.err
+			}
+			if err != nil && len(decoded) == 0 {
+				return nil, err
+			}
+			data = decoded
+		default:
+			return nil, fmt.Errorf("unsupported filter %v", filter)
+		}
+	}
+	return data, nil
+}
+
+// pdfPage is a page with the resources it inherits
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// pages returns the pages in page tree order, or every page object in object
+// order when the tree cannot be walked
+func (d *pdfDocument) pages() []pdfPage {
+	var pages []pdfPage
+	for _, object := range d.objects {
+		dict, ok := object.value.(pdfDict)
+		if ok && dict["Type"] == pdfName("Catalog") {
+			d.walkPages(dict["Pages"], nil, 0, make(map[pdfRef]bool), &pages)
+			if len(pages) > 0 {
+				return pages
+			}
+		}
+	}
+
+	var nums []int
+	for num, object := range d.objects {
+		if dict, ok := object.value.(pdfDict); ok && dict["Type"] == pdfName("Page") {
+			nums = append(nums, num)
+		}
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		dict := d.objects[num].value.(pdfDict)
+		pages = append(pages, pdfPage{dict: dict, resources: d.dict(dict["Resources"])})
+	}
+	return pages
+}
+
+// walkPages appends the pages below a page tree node. Nodes are visited once,
+// so trees listing the same kids repeatedly do not multiply the work.
+func (d *pdfDocument) walkPages(node interface{}, resources pdfDict, depth int, visited map[pdfRef]bool, pages *[]pdfPage) {
+	if ref, ok := node.(pdfRef); ok {
+		if visited[ref] {
+			return
+		}
+		visited[ref] = true
+	}
+	dict := d.dict(node)
+	if dict == nil || depth > maxPDFDepth {
+		return
+	}
+	if own := d.dict(dict["Resources"]); own != nil {
+		resources = own
+	}
+	if dict["Type"] == pdfName("Page") {
+		*pages = append(*pages, pdfPage{dict: dict, resources: resources})
+		return
+	}
+	for _, kid := range d.array(dict["Kids"]) {
+		d.walkPages(kid, resources, depth+1, visited, pages)
+	}
+}
+
+// pageText decodes the content streams of a page, up to limit bytes of text
+func (d *pdfDocument) pageText(page pdfPage, limit int) string {
+	var content []byte
+	switch contents := page.dict["Contents"].(type) {
+	case pdfRef:
+		if data, _ := d.streamOf(contents); data != nil {
+			content = data
+		} else {
+			for _, part := range d.array(contents) {
+				data, _ := d.streamOf(part)
+				content = append(append(content, data...), '\n')
+			}
+		}
+	case []interface{}:
+		for _, part := range contents {
+			data, _ := d.streamOf(part)
+			content = append(append(content, data...), '\n')
+		}
+	}
+
+	var sb strings.Builder
+	d.runContent(content, page.resources, &sb, limit, make(map[pdfRef]bool), 0)
+	return sb.String()
+}
+
+// runContent interprets the text operators of a content stream until limit
+// bytes of text were written. Each form XObject of the page is run once:
+// forms drawing themselves or another form repeatedly would otherwise recurse
+// exponentially.
+func (d *pdfDocument) runContent(content []byte, resources pdfDict, sb *strings.Builder, limit int, forms map[pdfRef]bool, depth int) {
+	if depth > maxPDFDepth {
+		return
+	}
+	fonts := make(map[string]*pdfFont)
+	fontResources := d.dict(resources["Font"])
+	var font *pdfFont
+	var lastY float64
+	var operands []interface{}
+
+	newline := func() {
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	show := func(s pdfString) {
+		if font == nil {
+			font = &pdfFont{}
+		}
+		sb.WriteString(font.decode([]byte(s)))
+	}
+
+	p := &pdfParser{data: content}
+	for d.err == nil && sb.Len() < limit {
+		d.operations++
+		if d.operations > maxPDFOperations {
+			d.err = ErrPDFTooComplex
+			return
+		}
+		value, err := p.parseValue(0)
+		if err != nil {
+			return
+		}
+		op, ok := value.(pdfKeyword)
+		if !ok {
+			operands = append(operands, value)
+			continue
+		}
+
+		switch op {
+		case "BI":
+			p.skipInlineImage()
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[0].(pdfName); ok {
+					if fonts[string(name)] == nil {
+						fonts[string(name)] = d.loadFont(fontResources[string(name)])
+					}
+					font = fonts[string(name)]
+				}
+			}
+		case "Tj":
+			if len(operands) >= 1 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					show(s)
+				}
+			}
+		case "'", "\"":
+			newline()
+			if len(operands) >= 1 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					show(s)
+				}
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				items, _ := operands[len(operands)-1].([]interface{})
+				for _, item := range items {
+					switch item := item.(type) {
+					case pdfString:
+						show(item)
+					case float64:
+						// Large negative adjustments separate words
+						if item < -200 && !strings.HasSuffix(sb.String(), " ") {
+							sb.WriteString(" ")
+						}
+					}
+				}
+			}
+		case "T*":
+			newline()
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, ok := operands[1].(float64); ok && ty != 0 {
+					newline()
+				} else if !strings.HasSuffix(sb.String(), " ") && !strings.HasSuffix(sb.String(), "\n") {
+					sb.WriteString(" ")
+				}
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				if y, ok := operands[5].(float64); ok {
+					if y != lastY {
+						newline()
+					}
+					lastY = y
+				}
+			}
+		case "ET":
+			newline()
+		case "Do":
+			if len(operands) >= 1 {
+				name, _ := operands[0].(pdfName)
+				ref, ok := d.dict(resources["XObject"])[string(name)].(pdfRef)
+				if ok && !forms[ref] {
+					forms[ref] = true
+					data, dict := d.streamOf(ref)
+					if data != nil && dict["Subtype"] == pdfName("Form") {
+						formResources := d.dict(dict["Resources"])
+						if formResources == nil {
+							formResources = resources
+						}
+						d.runContent(data, formResources, sb, limit, forms, depth+1)
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// pdfFont decodes the strings shown with a font
+type pdfFont struct {
+	toUnicode   map[uint32]string
+	codeLength  int // Bytes per character code in toUnicode
+	differences map[byte]string
+	composite   bool // Type0 font: 2-byte codes, unreadable without toUnicode
+}
+
+func (d *pdfDocument) loadFont(value interface{}) *pdfFont {
+	font := &pdfFont{}
+	dict := d.dict(value)
+	if dict == nil {
+		return font
+	}
+	font.composite = dict["Subtype"] == pdfName("Type0")
+
+	if data, _ := d.streamOf(dict["ToUnicode"]); data != nil {
+		font.toUnicode, font.codeLength = parseToUnicode(data)
+	}
+
+	if encoding := d.dict(dict["Encoding"]); encoding != nil {
+		code := 0
+		for _, item := range d.array(encoding["Differences"]) {
+			switch item := d.resolve(item).(type) {
+			case float64:
+				code = int(item)
+			case pdfName:
+				if code >= 0 && code < 256 {
+					if font.differences == nil {
+						font.differences = make(map[byte]string)
+					}
+					if text := glyphText(string(item)); text != "" {
+						font.differences[byte(code)] = text
+					}
+				}
+				code++
+			}
+		}
+	}
+	return font
+}
+
+func (f *pdfFont) decode(data []byte) string {
+	var sb strings.Builder
+	if len(f.toUnicode) > 0 {
+		length := f.codeLength
+		if length <= 0 {
+			length = 1
+		}
+		for i := 0; i+length <= len(data); i += length {
+			var code uint32
+			for _, b := range data[i : i+length] {
+				code = code<<8 | uint32(b)
+			}
+			sb.WriteString(f.toUnicode[code])
+		}
+		return sb.String()
+	}
+	if f.composite {
+		return ""
+	}
+	for _, b := range data {
+		if text, ok := f.differences[b]; ok {
+			sb.WriteString(text)
+			continue
+		}
+		sb.WriteRune(winAnsiRune(b))
+	}
+	return sb.String()
+}
+
+// parseToUnicode reads the bfchar and bfrange mappings of a ToUnicode CMap
+func parseToUnicode(data []byte) (map[uint32]string, int) {
+	mapping := make(map[uint32]string)
+	codeLength := 0
+	p := &pdfParser{data: data}
+	var operands []interface{}
+
+	code := func(value interface{}) (uint32, bool) {
+		s, ok := value.(pdfString)
+		if !ok || len(s) == 0 || len(s) > 4 {
+			return 0, false
+		}
+		if len(s) > codeLength {
+			codeLength = len(s)
+		}
+		var c uint32
+		for _, b := range []byte(s) {
+			c = c<<8 | uint32(b)
+		}
+		return c, true
+	}
+
+	for {
+		value, err := p.parseValue(0)
+		if err != nil {
+			break
+		}
+		op, ok := value.(pdfKeyword)
+		if !ok {
+			operands = append(operands, value)
+			continue
+		}
+		switch op {
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok := code(operands[i])
+				dst, isString := operands[i+1].(pdfString)
+				if ok && isString {
+					mapping[src] = utf16Text([]byte(dst))
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := code(operands[i])
+				hi, ok2 := code(operands[i+1])
+				if !ok1 || !ok2 || hi < lo || hi-lo > 65535 {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					base := []byte(dst)
+					for c := lo; c <= hi; c++ {
+						mapping[c] = utf16Text(base)
+						incrementLast(base)
+					}
+				case []interface{}:
+					for j, item := range dst {
+						if s, ok := item.(pdfString); ok && lo+uint32(j) <= hi {
+							mapping[lo+uint32(j)] = utf16Text([]byte(s))
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return mapping, codeLength
+}
+
+// incrementLast increments a big-endian code in place
+func incrementLast(b []byte) {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return
+		}
+	}
+}
+
+// utf16Text decodes UTF-16BE text
+func utf16Text(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// winAnsiSpecial maps the 0x80-0x9F range of WinAnsiEncoding
+var winAnsiSpecial = map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡', 0x88: 'ˆ',
+	0x89: '‰', 0x8A: 'Š', 0x8B: '‹', 0x8C: 'Œ', 0x8E: 'Ž', 0x91: '\'', 0x92: '\'', 0x93: '"',
+	0x94: '"', 0x95: '•', 0x96: '–', 0x97: '—', 0x98: '˜', 0x99: '™', 0x9A: 'š', 0x9B: '›',
+	0x9C: 'œ', 0x9E: 'ž', 0x9F: 'Ÿ',
+}
+
+func winAnsiRune(b byte) rune {
+	if r, ok := winAnsiSpecial[b]; ok {
+		return r
+	}
+	if b < 0x20 && b != '\t' && b != '\n' {
+		return ' '
+	}
+	return rune(b)
+}
+
+// glyphNames maps common glyph names that are not single characters
+var glyphNames = map[string]string{
+	"space": " ", "period": ".", "comma": ",", "colon": ":", "semicolon": ";", "hyphen": "-",
+	"endash": "–", "emdash": "—", "quoteright": "'", "quoteleft": "'", "quotedblleft": "\"",
+	"quotedblright": "\"", "parenleft": "(", "parenright": ")", "slash": "/", "question": "?",
+	"exclam": "!", "zero": "0", "one": "1", "two": "2", "three": "3", "four": "4", "five": "5",
+	"six": "6", "seven": "7", "eight": "8", "nine": "9", "eacute": "é", "egrave": "è",
+	"ecircumflex": "ê", "agrave": "à", "acircumflex": "â", "ccedilla": "ç", "ucircumflex": "û",
+	"ugrave": "ù", "ocircumflex": "ô", "icircumflex": "î", "idieresis": "ï", "edieresis": "ë",
+	"fi": "fi", "fl": "fl", "bullet": "•", "percent": "%", "ampersand": "&", "quotesingle": "'",
+}
+
+// glyphText returns the text of a glyph name from an encoding's Differences
+func glyphText(name string) string {
+	if len(name) == 1 {
+		return name
+	}
+	if text, ok := glyphNames[name]; ok {
+		return text
+	}
+	if strings.HasPrefix(name, "uni") && len(name) == 7 {
+		if code, err := strconv.ParseUint(name[3:], 16, 32); err == nil {
+			return string(rune(code))
+		}
+	}
+	return ""
+}
+
+// pdfParser reads PDF values from a byte slice
+type pdfParser struct {
+	data []byte
+	pos  int
+}
+
+var errPDFEnd = errors.New("end of data")
+
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// advance moves past n bytes, stopping at the end of the data: unterminated
+// tokens would otherwise leave pos out of bounds
+func (p *pdfParser) advance(n int) {
+	p.pos = min(p.pos+n, len(p.data))
+}
+
+func (p *pdfParser) skipSpace() {
+	p.advance(0)
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if isPDFWhitespace(c) {
+			p.pos++
+		} else if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+		} else {
+			return
+		}
+	}
+}
+
+// parseValue reads the next value; keywords (operators) are returned as pdfKeyword
+func (p *pdfParser) parseValue(depth int) (interface{}, error) {
+	if depth > maxPDFDepth {
+		return nil, fmt.Errorf("PDF value nested too deeply")
+	}
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, errPDFEnd
+	}
+
+	c := p.data[p.pos]
+	switch {
+	case c == '/':
+		return p.parseName(), nil
+	case c == '(':
+		return p.parseLiteralString(), nil
+	case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+		return p.parseDict(depth)
+	case c == '<':
+		return p.parseHexString(), nil
+	case c == '[':
+		p.advance(1)
+		var array []interface{}
+		for {
+			p.skipSpace()
+			if p.pos >= len(p.data) {
+				return array, nil
+			}
+			if p.data[p.pos] == ']' {
+				p.pos++
+				return array, nil
+			}
+			value, err := p.parseValue(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		p.advance(1)
+		return pdfKeyword(string(c)), nil
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return p.parseNumberOrRef(), nil
+	default:
+		start := p.pos
+		for p.pos < len(p.data) && !isPDFWhitespace(p.data[p.pos]) && !isPDFDelimiter(p.data[p.pos]) {
+			p.pos++
+		}
+		if p.pos == start {
+			p.advance(1)
+		}
+		word := string(p.data[start:p.pos])
+		switch word {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return pdfKeyword(word), nil
+	}
+}
+
+func (p *pdfParser) parseName() pdfName {
+	p.advance(1) // '/'
+	var sb strings.Builder
+	for p.pos < len(p.data) && !isPDFWhitespace(p.data[p.pos]) && !isPDFDelimiter(p.data[p.pos]) {
+		c := p.data[p.pos]
+		if c == '#' && p.pos+2 < len(p.data) {
+			if b, err := strconv.ParseUint(string(p.data[p.pos+1:p.pos+3]), 16, 8); err == nil {
+				sb.WriteByte(byte(b))
+				p.pos += 3
+				continue
+			}
+		}
+		sb.WriteByte(c)
+		p.pos++
+	}
+	return pdfName(sb.String())
+}
+
+func (p *pdfParser) parseLiteralString() pdfString {
+	p.advance(1) // '('
+	var buf []byte
+	level := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			level++
+		case ')':
+			level--
+			if level == 0 {
+				return pdfString(buf)
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				return pdfString(buf)
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				buf = append(buf, '\n')
+			case 'r':
+				buf = append(buf, '\r')
+			case 't':
+				buf = append(buf, '\t')
+			case 'b':
+				buf = append(buf, '\b')
+			case 'f':
+				buf = append(buf, '\f')
+			case '\r':
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					value := int(e - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						value = value*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					buf = append(buf, byte(value))
+				} else {
+					buf = append(buf, e)
+				}
+			}
+			continue
+		}
+		buf = append(buf, c)
+	}
+	return pdfString(buf)
+}
+
+func (p *pdfParser) parseHexString() pdfString {
+	p.advance(1) // '<'
+	var digits []byte
+	for p.pos < len(p.data) && p.data[p.pos] != '>' {
+		c := p.data[p.pos]
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+		p.pos++
+	}
+	p.advance(1) // '>'
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	buf := make([]byte, len(digits)/2)
+	for i := range buf {
+		b, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		buf[i] = byte(b)
+	}
+	return pdfString(buf)
+}
+
+func (p *pdfParser) parseDict(depth int) (interface{}, error) {
+	p.advance(2) // '<<'
+	dict := make(pdfDict)
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return dict, nil
+		}
+		if p.data[p.pos] == '>' {
+			p.advance(2) // '>>'
+			return dict, nil
+		}
+		key, err := p.parseValue(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			continue // Skip garbage
+		}
+		value, err := p.parseValue(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		dict[string(name)] = value
+	}
+}
+
+// parseNumberOrRef reads a number, or an "n g R" indirect reference
+func (p *pdfParser) parseNumberOrRef() interface{} {
+	number := p.parseNumber()
+
+	// Look ahead for "gen R"
+	if number == float64(int(number)) && number >= 0 {
+		save := p.pos
+		p.skipSpace()
+		if p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '9' {
+			gen := p.parseNumber()
+			p.skipSpace()
+			if p.pos < len(p.data) && p.data[p.pos] == 'R' &&
+				(p.pos+1 == len(p.data) || isPDFWhitespace(p.data[p.pos+1]) || isPDFDelimiter(p.data[p.pos+1])) {
+				p.pos++
+				return pdfRef{num: int(number), gen: int(gen)}
+			}
+		}
+		p.pos = save
+	}
+	return number
+}
+
+func (p *pdfParser) parseNumber() float64 {
+	start := p.pos
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	number, _ := strconv.ParseFloat(string(p.data[start:p.pos]), 64)
+	return number
+}
+
+// streamData returns the raw data of the stream following a dictionary, if any
+func (p *pdfParser) streamData(dict pdfDict) []byte {
+	p.skipSpace()
+	if p.pos >= len(p.data) || !bytes.HasPrefix(p.data[p.pos:], []byte("stream")) {
+		return nil
+	}
+	start := p.pos + len("stream")
+	if start < len(p.data) && p.data[start] == '\r' {
+		start++
+	}
+	if start < len(p.data) && p.data[start] == '\n' {
+		start++
+	}
+
+	// Trust a direct /Length when it ends at "endstream"
+	if length, ok := dict["Length"].(float64); ok {
+		end := start + int(length)
+		if end >= start && end <= len(p.data) {
+			rest := bytes.TrimLeft(p.data[end:min(end+20, len(p.data))], " \t\r\n")
+			if bytes.HasPrefix(rest, []byte("endstream")) {
+				return p.data[start:end]
+			}
+		}
+	}
+
+	end := bytes.Index(p.data[start:], []byte("endstream"))
+	if end < 0 {
+		return nil
+	}
+	return bytes.TrimRight(p.data[start:start+end], "\r\n")
+}
+
+// skipInlineImage skips the data of an inline image, up to its EI operator
+func (p *pdfParser) skipInlineImage() {
+	p.advance(0)
+	end := bytes.Index(p.data[p.pos:], []byte("EI"))
+	for end >= 0 {
+		at := p.pos + end
+		before := at == 0 || isPDFWhitespace(p.data[at-1])
+		after := at+2 >= len(p.data) || isPDFWhitespace(p.data[at+2])
+		if before && after {
+			p.pos = at + 2
+			return
+		}
+		next := bytes.Index(p.data[at+2:], []byte("EI"))
+		if next < 0 {
+			break
+		}
+		end = at + 2 + next - p.pos
+	}
+	p.pos = len(p.data)
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const minimalPDF = `%PDF-1.4
+1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj
+2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj
+3 0 obj << /Type /Page /Parent 2 0 R /Contents 4 0 R >> endobj
+4 0 obj << /Length 36 >>
+stream
+BT /F1 12 Tf 72 712 Td (Hello) Tj ET
+endstream
+endobj
+trailer << /Root 1 0 R >>
+%%EOF
+`
+
+func TestExtractPDFText(t *testing.T) {
+	text, err := extractPDFText([]byte(minimalPDF))
+	if err != nil {
+		t.Fatalf("extractPDFText failed: %v", err)
+	}
+	if !strings.Contains(text, "--- Page 1 ---") || !strings.Contains(text, "Hello") {
+		t.Errorf("unexpected text: %q", text)
+	}
+}
+
+// buildPDF numbers the given objects from 1 and wraps them in a PDF file
+func buildPDF(objects ...string) []byte {
+	var sb strings.Builder
+	sb.WriteString("%PDF-1.4\n")
+	for i, object := range objects {
+		fmt.Fprintf(&sb, "%d 0 obj %s endobj\n", i+1, object)
+	}
+	sb.WriteString("trailer << /Root 1 0 R >>\n%%EOF\n")
+	return []byte(sb.String())
+}
+
+func pdfStream(dict, content string) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(content), content)
+}
+
+// Documents built to multiply the work must finish quickly
+func TestExtractPDFTextRepeatedReferences(t *testing.T) {
+	// A form drawing itself twice
+	selfReferencing := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R /Resources << /XObject << /X 5 0 R >> >> >>",
+		pdfStream("", "/X Do"),
+		pdfStream("/Type /XObject /Subtype /Form /Resources << /XObject << /X 5 0 R >> >>", "BT (Form) Tj ET /X Do /X Do"),
+	)
+
+	// Forms each drawing the next one twice, 2^30 runs without deduplication
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R /Resources << /XObject << /X 5 0 R >> >> >>",
+		pdfStream("", "/X Do"),
+	}
+	for i := 0; i < 30; i++ {
+		next := len(objects) + 2
+		objects = append(objects, pdfStream(fmt.Sprintf("/Subtype /Form /Resources << /XObject << /X %d 0 R >> >>", next),
+			fmt.Sprintf("BT (F%d) Tj ET /X Do /X Do", i)))
+	}
+	chained := buildPDF(objects...)
+
+	// A page tree whose nodes list the same kids repeatedly
+	repeatedKids := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 3 0 R 3 0 R 2 0 R] >>",
+		"<< /Type /Pages /Kids [4 0 R 4 0 R 4 0 R 3 0 R 2 0 R] >>",
+		"<< /Type /Page /Contents 5 0 R >>",
+		pdfStream("", "BT (Leaf) Tj ET"),
+	)
+
+	tests := []struct {
+		name  string
+		data  []byte
+		want  string
+		pages int
+	}{
+		{"self-referencing form", selfReferencing, "Form", 1},
+		{"chained forms", chained, "F29", 1},
+		{"repeated page tree kids", repeatedKids, "Leaf", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			text, err := extractPDFText(tt.data)
+			if err != nil {
+				t.Fatalf("extractPDFText failed: %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("extraction took %v", elapsed)
+			}
+			if !strings.Contains(text, tt.want) {
+				t.Errorf("text %q does not contain %q", text, tt.want)
+			}
+			if pages := strings.Count(text, "--- Page "); pages != tt.pages {
+				t.Errorf("%d pages extracted, want %d", pages, tt.pages)
+			}
+		})
+	}
+}
+
+func TestExtractPDFTextBudget(t *testing.T) {
+	// A single content stream with more operators than the budget allows
+	content := strings.Repeat("T* ", maxPDFOperations/2+1)
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 5 0 R] >>",
+		"<< /Type /Page /Contents 4 0 R >>",
+		pdfStream("", content),
+		"<< /Type /Page /Contents 4 0 R >>",
+	)
+	if _, err := extractPDFText(data); err != ErrPDFTooComplex {
+		t.Errorf("extractPDFText error = %v, want ErrPDFTooComplex", err)
+	}
+
+	// Text beyond the extraction limit is not accumulated
+	show := "BT (" + strings.Repeat("x", 1024) + ") Tj ET\n"
+	data = buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] >>",
+		"<< /Type /Page /Contents 4 0 R >>",
+		pdfStream("", strings.Repeat(show, maxExtractedText/1024+100)),
+	)
+	text, err := extractPDFText(data)
+	if err != nil {
+		t.Fatalf("extractPDFText failed: %v", err)
+	}
+	if len(text) > maxExtractedText+2048 {
+		t.Errorf("extracted %d bytes, limit %d", len(text), maxExtractedText)
+	}
+}
+
+// Truncated and malformed files must return an error or some text, never panic
+var malformedPDFs = []string{
+	"%PDF-1.4\n0 0 obj<<<",
+	"%PDF-1.4\n1 0 obj<<>",
+	"%PDF-1.4\n1 0 obj<</A<",
+	"%PDF-1.4\n1 0 obj<</A(",
+	"%PDF-1.4\n1 0 obj<</A[",
+	"%PDF-1.4\n1 0 obj<</A/#4",
+	"%PDF-1.4\n1 0 obj<</Length 99>>stream",
+	"%PDF-1.4\n1 0 obj<</Length -5>>stream\nabc\nendstream",
+	"%PDF-1.4\n1 0 obj<</Type/ObjStm/N 3/First 99>>stream\nx\nendstream",
+	"%PDF-1.4\n1 0 obj<</Type/Page/Contents 2 0 R>>endobj 2 0 obj<</Length 8>>stream\nBI ID EI\nendstream",
+	"%PDF-1.4\n1 0 obj 1 0 R endobj",
+	"%PDF",
+}
+
+func TestExtractPDFTextMalformed(t *testing.T) {
+	for _, input := range malformedPDFs {
+		t.Run(input, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("extractPDFText panicked: %v", r)
+				}
+			}()
+			extractPDFText([]byte(input))
+		})
+	}
+}
+
+func FuzzExtractPDFText(f *testing.F) {
+	f.Add([]byte(minimalPDF))
+	for _, input := range malformedPDFs {
+		f.Add([]byte(input))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		extractPDFText(data)
+	})
+}
@@ -3,12 +3,14 @@ package services
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -40,6 +42,31 @@ type ProxyRequest struct {
 	Model              string `json:"model,omitempty"`               // Claude model: haiku, sonnet, opus
 	CustomInstructions string `json:"custom_instructions,omitempty"` // Optional custom instructions for system prompt
 	Thinking           bool   `json:"thinking,omitempty"`            // Enable extended thinking mode
+	ApprovalRelay      bool   `json:"approval_relay,omitempty"`      // Relay tool permission requests to the backend
+	// Tool restrictions, omitted when every tool is allowed
+	ToolPolicy *ProxyToolPolicy `json:"tool_policy,omitempty"`
+	// Images sent inline, so the proxy host needs no access to the uploads
+	Images []ProxyImage `json:"images,omitempty"`
+}
+
+// ProxyImage is an image content block of the prompt
+type ProxyImage struct {
+	MediaType string `json:"media_type"` // e.g. "image/png"
+	Data      string `json:"data"`       // Base64 encoded
+}
+
+// ProxyToolPolicy tells the proxy which tools Claude may use
+type ProxyToolPolicy struct {
+	AllowedTools []string `json:"allowed_tools,omitempty"` // Omitted: every tool not denied
+	DeniedTools  []string `json:"denied_tools,omitempty"`  // "*" denies every tool
+}
+
+// ProxyApprovalResponse answers an approval_request from the proxy
+type ProxyApprovalResponse struct {
+	Type       string `json:"type"`              // "approval_response"
+	ApprovalID string `json:"approval_id"`       // ID from the approval_request
+	Decision   string `json:"decision"`          // "allow" or "deny"
+	Message    string `json:"message,omitempty"` // Reason given to Claude when denied
 }
 
 // ProxyToolInfo represents tool information from proxy
@@ -61,7 +88,7 @@ type ProxyUsageInfo struct {
 
 // ProxyResponse represents a response from the proxy
 type ProxyResponse struct {
-	Type      string `json:"type"`                 // "chunk", "thinking", "done", "error", "session_id", "tool_start", "tool_progress", "tool_result", "tool_error", "tool_input_delta", "usage"
+	Type      string `json:"type"`                 // "chunk", "thinking", "done", "error", "session_id", "tool_start", "tool_progress", "tool_result", "tool_error", "tool_input_delta", "usage", "approval_request", "policy_violation"
 	Content   string `json:"content,omitempty"`    // Response content
 	SessionID string `json:"session_id,omitempty"` // Session ID from Claude
 	Error     string `json:"error,omitempty"`      // Error message
@@ -73,6 +100,8 @@ type ProxyResponse struct {
 	InputDelta         string          `json:"input_delta,omitempty"` // JSON delta for streaming tool input
 	// Usage information
 	Usage *ProxyUsageInfo `json:"usage,omitempty"`
+	// Permission request
+	ApprovalID string `json:"approval_id,omitempty"`
 }
 
 // TitleRequest represents a request to generate a title
@@ -121,7 +150,7 @@ func NewProxyClaudeExecutor(config ProxyConfig) *ProxyClaudeExecutor {
 }
 
 // ExecuteClaude connects to the proxy service and streams Claude's response
-func (pce *ProxyClaudeExecutor) ExecuteClaude(ctx context.Context, prompt string, sessionID string, isNewSession bool, model string, customInstructions string, thinking bool) (<-chan ClaudeResponse, error) {
+func (pce *ProxyClaudeExecutor) ExecuteClaude(ctx context.Context, prompt string, sessionID string, isNewSession bool, model string, customInstructions string, thinking bool, toolPolicy EffectiveToolPolicy, images []ImageInput) (<-chan ClaudeResponse, error) {
 	// Default to haiku if model not specified
 	if model == "" {
 		model = "haiku"
@@ -148,6 +177,9 @@ func (pce *ProxyClaudeExecutor) ExecuteClaude(ctx context.Context, prompt string
 		}
 		defer conn.Close()
 
+		// Approval responses are written while the loop below keeps reading
+		var writeMu sync.Mutex
+
 		// Send execute request
 		request := ProxyRequest{
 			Type:               "execute",
@@ -157,9 +189,25 @@ func (pce *ProxyClaudeExecutor) ExecuteClaude(ctx context.Context, prompt string
 			Model:              model,
 			CustomInstructions: customInstructions,
 			Thinking:           thinking,
+			ApprovalRelay:      true,
+		}
+		if toolPolicy.Restricted() {
+			request.ToolPolicy = &ProxyToolPolicy{
+				AllowedTools: toolPolicy.AllowedTools,
+				DeniedTools:  toolPolicy.DeniedTools,
+			}
+		}
+		for _, image := range images {
+			request.Images = append(request.Images, ProxyImage{
+				MediaType: image.MediaType,
+				Data:      base64.StdEncoding.EncodeToString(image.Data),
+			})
 		}
 
-		if err := conn.WriteJSON(request); err != nil {
+		writeMu.Lock()
+		err = conn.WriteJSON(request)
+		writeMu.Unlock()
+		if err != nil {
 			log.Printf("ProxyExecutor: Failed to send request: %v", err)
 			responseChan <- ClaudeResponse{
 				Type:  "error",
@@ -246,6 +294,48 @@ func (pce *ProxyClaudeExecutor) ExecuteClaude(ctx context.Context, prompt string
 					IsError:            response.IsError,
 				}
 
+			case "policy_violation":
+				// The proxy blocked a tool forbidden by the policy
+				var toolInfo *ToolCallInfo
+				if response.Tool != nil {
+					toolInfo = &ToolCallInfo{
+						ToolUseID: response.Tool.ToolUseID,
+						ToolName:  response.Tool.ToolName,
+						Input:     response.Tool.Input,
+					}
+				}
+				responseChan <- ClaudeResponse{
+					Type:       "policy_violation",
+					Tool:       toolInfo,
+					ToolOutput: response.Error,
+					IsError:    true,
+				}
+
+			case "approval_request":
+				// Claude wants to use a tool: the decision is sent back on this connection
+				if response.Tool == nil || response.ApprovalID == "" {
+					continue
+				}
+				approvalID := response.ApprovalID
+				responseChan <- ClaudeResponse{
+					Type: "approval_request",
+					Approval: &ApprovalRequest{
+						ID:       approvalID,
+						ToolName: response.Tool.ToolName,
+						Input:    response.Tool.Input,
+						respond: func(decision, message string) error {
+							writeMu.Lock()
+							defer writeMu.Unlock()
+							return conn.WriteJSON(ProxyApprovalResponse{
+								Type:       "approval_response",
+								ApprovalID: approvalID,
+								Decision:   decision,
+								Message:    message,
+							})
+						},
+					},
+				}
+
 			case "tool_input_delta":
 				// Convert proxy tool info to service tool info
 				var toolInfo *ToolCallInfo
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/repositories"
+)
+
+// RedactionSettingsKey is the settings key holding user-defined redaction regexes (JSON array)
+const RedactionSettingsKey = "redaction_patterns"
+
+// minKnownSecretLength avoids masking common words when a secret is very short
+const minKnownSecretLength = 4
+
+// secretDetector masks the matches of a pattern.
+// Group selects the submatch to mask (0 masks the whole match); values whose
+// entropy, in bits per character, is under minEntropy are left alone.
+type secretDetector struct {
+	label      string
+	pattern    *regexp.Regexp
+	group      int
+	minEntropy float64
+}
+
+// builtinDetectors recognize common credential formats
+var builtinDetectors = []secretDetector{
+	{label: "private_key", pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{label: "aws_key", pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{label: "github_token", pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{label: "api_key", pattern: regexp.MustCompile(`\bsk-(?:ant-|proj-)?[A-Za-z0-9_\-]{20,}`)},
+	{label: "slack_token", pattern: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9\-]{10,}`)},
+	{label: "google_api_key", pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`)},
+	{label: "jwt", pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}`)},
+	{label: "bearer_token", pattern: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]{20,}=*)`), group: 1},
+	{label: "url_password", pattern: regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.\-]*://[^:/\s@]+:([^@\s/]+)@`), group: 1},
+	// KEY=value lines as found in .env files and shell exports
+	{label: "secret", pattern: regexp.MustCompile(`(?m)\b[A-Z0-9_]*(?:PASSWORD|PASSWD|SECRET|TOKEN|API_KEY|APIKEY|ACCESS_KEY|PRIVATE_KEY)[A-Z0-9_]*\s*[=:]\s*["']?([^\s"'#\\]{4,})`), group: 1},
+	// Configuration keys in any case ("client_secret: ...") also name settings
+	// such as max_tokens: only random-looking values are masked
+	{label: "secret", pattern: regexp.MustCompile(`(?im)\b[a-z0-9_]*(?:password|passwd|secret|token|api_key|apikey|access_key|private_key)[a-z0-9_]*\s*[=:]\s*["']?([^\s"'#\\]{8,})`), group: 1, minEntropy: 3},
+}
+
+// SecretSource provides secrets to redact by exact match
+type SecretSource interface {
+	KnownSecrets() ([]string, error)
+}
+
+// Redactor masks secrets before text is persisted.
+// Built-in detectors always run; user-defined patterns come from settings and
+// known secrets (machine credentials...) are matched verbatim.
+type Redactor struct {
+	settings repositories.SettingsRepository
+	sources  []SecretSource
+
+	mu           sync.RWMutex
+	custom       []*regexp.Regexp
+	knownSecrets []string
+}
+
+// NewRedactor creates a new Redactor and loads its patterns and secrets
+func NewRedactor(settings repositories.SettingsRepository, sources ...SecretSource) *Redactor {
+	r := &Redactor{settings: settings, sources: sources}
+	r.Refresh()
+	return r
+}
+
+// ParseRedactionPatterns parses and compiles the JSON array of user-defined regexes
+func ParseRedactionPatterns(raw string) ([]*regexp.Regexp, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var patterns []string
+	if err := json.Unmarshal([]byte(raw), &patterns); err != nil {
+		return nil, fmt.Errorf("invalid redaction patterns: %w", err)
+	}
+
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for i, pattern := range patterns {
+		if pattern == "" {
+			return nil, fmt.Errorf("pattern %d is empty", i)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %d: %w", i, err)
+		}
+		compiled = append(compiled, re)
+	}
+
+	return compiled, nil
+}
+
+// Refresh reloads user-defined patterns and known secrets.
+// Call it after changing the redaction settings or machine credentials.
+func (r *Redactor) Refresh() {
+	if r == nil {
+		return
+	}
+
+	var custom []*regexp.Regexp
+	if r.settings != nil {
+		raw, err := r.settings.Get(RedactionSettingsKey)
+		if err != nil {
+			log.Printf("Warning: failed to load redaction patterns: %v", err)
+		} else if custom, err = ParseRedactionPatterns(raw); err != nil {
+			log.Printf("Warning: ignoring redaction patterns: %v", err)
+		}
+	}
+
+	var secrets []string
+	for _, source := range r.sources {
+		values, err := source.KnownSecrets()
+		if err != nil {
+			log.Printf("Warning: failed to load known secrets: %v", err)
+			continue
+		}
+		for _, value := range values {
+			value = strings.TrimSpace(value)
+			if len(value) < minKnownSecretLength {
+				continue
+			}
+			secrets = append(secrets, value)
+			// Tool inputs are stored as JSON: also match the escaped form
+			if escaped, err := json.Marshal(value); err == nil {
+				if inner := string(escaped[1 : len(escaped)-1]); inner != value {
+					secrets = append(secrets, inner)
+				}
+			}
+		}
+	}
+	// Longest first so that a secret containing another is masked whole
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+
+	r.mu.Lock()
+	r.custom = custom
+	r.knownSecrets = secrets
+	r.mu.Unlock()
+}
+
+// Redact masks every secret found in text
+func (r *Redactor) Redact(text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+
+	r.mu.RLock()
+	custom := r.custom
+	secrets := r.knownSecrets
+	r.mu.RUnlock()
+
+	// Exact matches first: detectors could otherwise mask part of a secret
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, "[REDACTED:known_secret]")
+	}
+
+	for _, detector := range builtinDetectors {
+		text = maskMatches(text, detector, "[REDACTED:"+detector.label+"]")
+	}
+
+	for _, re := range custom {
+		text = maskMatches(text, secretDetector{pattern: re}, "[REDACTED:custom]")
+	}
+
+	return text
+}
+
+// redactedMask matches the masks already written into a text
+var redactedMask = regexp.MustCompile(`\[REDACTED:[a-z_]+\]`)
+
+// maskMatches replaces the selected submatch of every match of a detector with mask.
+// Matches overlapping a mask written by a previous detector are left alone,
+// so that "KEY=[REDACTED:jwt]" is not masked again as a secret.
+func maskMatches(text string, detector secretDetector, mask string) string {
+	group := detector.group
+	matches := detector.pattern.FindAllStringSubmatchIndex(text, -1)
+	if matches == nil {
+		return text
+	}
+	masked := redactedMask.FindAllStringIndex(text, -1)
+
+	var builder strings.Builder
+	last := 0
+	for _, match := range matches {
+		start, end := match[2*group], match[2*group+1]
+		if start < 0 || overlapsAny(start, end, masked) || entropy(text[start:end]) < detector.minEntropy {
+			continue
+		}
+		builder.WriteString(text[last:start])
+		builder.WriteString(mask)
+		last = end
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
+
+// entropy returns the Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	var bits float64
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
+
+func overlapsAny(start, end int, spans [][]int) bool {
+	for _, span := range spans {
+		if start < span[1] && span[0] < end {
+			return true
+		}
+	}
+	return false
+}
+
+// MachineSecretSource exposes the decrypted SSH credentials of all machines
+type MachineSecretSource struct {
+	machines repositories.MachineRepository
+	crypto   *CryptoService
+}
+
+// NewMachineSecretSource creates a SecretSource for machine credentials
+func NewMachineSecretSource(machines repositories.MachineRepository, crypto *CryptoService) *MachineSecretSource {
+	return &MachineSecretSource{machines: machines, crypto: crypto}
+}
+
+// KnownSecrets returns the password or private key of every machine
+func (ms *MachineSecretSource) KnownSecrets() ([]string, error) {
+	machines, err := ms.machines.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var secrets []string
+	for _, machine := range machines {
+		machineWithAuth, err := ms.machines.GetWithAuth(machine.ID)
+		if err != nil || machineWithAuth == nil {
+			continue
+		}
+		value, err := ms.crypto.Decrypt(machineWithAuth.AuthValue)
+		if err != nil {
+			continue
+		}
+		secrets = append(secrets, value)
+	}
+
+	return secrets, nil
+}
+
+// redactingToolCallRepository masks secrets in tool inputs and outputs before
+// they reach the database
+type redactingToolCallRepository struct {
+	repositories.ToolCallRepository
+	redactor *Redactor
+}
+
+// NewRedactingToolCallRepository wraps a ToolCallRepository with redaction
+func NewRedactingToolCallRepository(repo repositories.ToolCallRepository, redactor *Redactor) repositories.ToolCallRepository {
+	return &redactingToolCallRepository{ToolCallRepository: repo, redactor: redactor}
+}
+
+// Create redacts the input and creates the tool call
+func (rr *redactingToolCallRepository) Create(sessionID, toolUseID, toolName, input string) (*models.ToolCall, error) {
+	return rr.ToolCallRepository.Create(sessionID, toolUseID, toolName, rr.redactor.Redact(input))
+}
+
+// UpdateOutput redacts the input and output and updates the tool call
+func (rr *redactingToolCallRepository) UpdateOutput(toolUseID, input, output, status string) error {
+	return rr.ToolCallRepository.UpdateOutput(toolUseID, rr.redactor.Redact(input), rr.redactor.Redact(output), status)
+}
+
+// Import redacts the input and output and imports the tool call
+func (rr *redactingToolCallRepository) Import(toolCall *models.ToolCall) error {
+	toolCall.Input = rr.redactor.Redact(toolCall.Input)
+	toolCall.Output = rr.redactor.Redact(toolCall.Output)
+	return rr.ToolCallRepository.Import(toolCall)
+}
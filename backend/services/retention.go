@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ronan/home-agent/repositories"
+)
+
+// RetentionSettingsKey is the settings key holding the JSON retention rules
+const RetentionSettingsKey = "retention"
+
+// orphanUploadGrace protects files uploaded for a message that is not sent yet
+const orphanUploadGrace = 24 * time.Hour
+
+// unlinkedPartGrace protects the parts of turns still streaming
+const unlinkedPartGrace = 24 * time.Hour
+
+// RetentionConfig holds the retention rules, in days. A zero value disables a rule.
+// Pinned sessions are never archived or deleted.
+type RetentionConfig struct {
+	ArchiveAfterDays         int `json:"archive_after_days,omitempty"`           // Archive sessions inactive for N days
+	DeleteArchivedAfterDays  int `json:"delete_archived_after_days,omitempty"`   // Delete sessions archived for N days
+	DropThinkingAfterDays    int `json:"drop_thinking_after_days,omitempty"`     // Delete thinking blocks older than N days
+	DropToolOutputsAfterDays int `json:"drop_tool_outputs_after_days,omitempty"` // Empty tool outputs older than N days
+}
+
+// RetentionReport summarizes a cleanup run
+type RetentionReport struct {
+	StartedAt          time.Time `json:"started_at"`
+	FinishedAt         time.Time `json:"finished_at"`
+	ArchivedSessions   int       `json:"archived_sessions"`
+	DeletedSessions    int       `json:"deleted_sessions"`
+	DroppedThinking    int       `json:"dropped_thinking"`
+	DroppedToolOutputs int       `json:"dropped_tool_outputs"`
+	RemovedUploads     int       `json:"removed_uploads"`
+	FreedUploadBytes   int64     `json:"freed_upload_bytes"`
+	RemovedWorkspaces  int       `json:"removed_workspaces"`
+	RemovedParts       int       `json:"removed_parts"` // Parts of turns that never completed
+	Optimized          bool      `json:"optimized"`     // FTS optimize and VACUUM ran
+	Errors             []string  `json:"errors"`
+}
+
+// changed reports whether the run removed anything from the database
+func (rr *RetentionReport) changed() bool {
+	return rr.DeletedSessions > 0 || rr.DroppedThinking > 0 || rr.DroppedToolOutputs > 0 || rr.RemovedParts > 0
+}
+
+// ParseRetentionConfig parses and validates the JSON retention rules.
+// An empty value means nothing expires.
+func ParseRetentionConfig(raw string) (RetentionConfig, error) {
+	var config RetentionConfig
+	if raw == "" {
+		return config, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &config); err != nil {
+		return RetentionConfig{}, fmt.Errorf("invalid retention configuration: %w", err)
+	}
+
+	if config.ArchiveAfterDays < 0 || config.DeleteArchivedAfterDays < 0 ||
+		config.DropThinkingAfterDays < 0 || config.DropToolOutputsAfterDays < 0 {
+		return RetentionConfig{}, fmt.Errorf("retention days cannot be negative")
+	}
+
+	return config, nil
+}
+
+// RetentionService applies the retention rules and cleans up storage
+type RetentionService struct {
+	maintenance repositories.MaintenanceRepository
+	sessions    repositories.SessionRepository
+	settings    repositories.SettingsRepository
+	uploadDir   string
+	interval    time.Duration
+
+	mu         sync.Mutex // Serializes runs
+	lastReport *RetentionReport
+}
+
+// NewRetentionService creates a new RetentionService running every interval
+func NewRetentionService(maintenance repositories.MaintenanceRepository, sessions repositories.SessionRepository, settings repositories.SettingsRepository, uploadDir string, interval time.Duration) *RetentionService {
+	return &RetentionService{
+		maintenance: maintenance,
+		sessions:    sessions,
+		settings:    settings,
+		uploadDir:   uploadDir,
+		interval:    interval,
+	}
+}
+
+// Config returns the current retention rules
+func (rs *RetentionService) Config() (RetentionConfig, error) {
+	raw, err := rs.settings.Get(RetentionSettingsKey)
+	if err != nil {
+		return RetentionConfig{}, err
+	}
+	return ParseRetentionConfig(raw)
+}
+
+// LastReport returns the report of the last run, nil before the first one
+func (rs *RetentionService) LastReport() *RetentionReport {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.lastReport
+}
+
+// Run applies the retention rules at startup and then every interval until ctx is done
+func (rs *RetentionService) Run(ctx context.Context) {
+	ticker := time.NewTicker(rs.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := rs.Apply(); err != nil {
+			log.Printf("Retention: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Apply runs the retention rules once, then removes orphaned uploads and workspaces.
+// The full-text indexes are optimized and the database vacuumed when rows were removed.
+// Failing steps are recorded in the report and do not stop the following ones.
+func (rs *RetentionService) Apply() (*RetentionReport, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	config, err := rs.Config()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load retention rules: %w", err)
+	}
+
+	now := time.Now()
+	report := &RetentionReport{StartedAt: now, Errors: []string{}}
+	fail := func(step string, err error) {
+		log.Printf("Retention: %s failed: %v", step, err)
+		report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", step, err))
+	}
+
+	if config.ArchiveAfterDays > 0 {
+		count, err := rs.maintenance.ArchiveInactive(daysBefore(now, config.ArchiveAfterDays))
+		if err != nil {
+			fail("archive", err)
+		}
+		report.ArchivedSessions = count
+	}
+
+	if config.DeleteArchivedAfterDays > 0 {
+		sessionIDs, err := rs.maintenance.ArchivedBefore(daysBefore(now, config.DeleteArchivedAfterDays))
+		if err != nil {
+			fail("delete archived", err)
+		}
+		for _, sessionID := range sessionIDs {
+			if err := rs.sessions.Delete(sessionID); err != nil {
+				fail("delete archived", err)
+				continue
+			}
+			report.DeletedSessions++
+		}
+	}
+
+	if config.DropThinkingAfterDays > 0 {
+		count, err := rs.maintenance.DeleteThinking(daysBefore(now, config.DropThinkingAfterDays))
+		if err != nil {
+			fail("drop thinking", err)
+		}
+		report.DroppedThinking = count
+	}
+
+	if config.DropToolOutputsAfterDays > 0 {
+		count, err := rs.maintenance.ClearToolOutputs(daysBefore(now, config.DropToolOutputsAfterDays))
+		if err != nil {
+			fail("drop tool outputs", err)
+		}
+		report.DroppedToolOutputs = count
+	}
+
+	count, err := rs.maintenance.DeleteUnlinkedParts(now.Add(-unlinkedPartGrace))
+	if err != nil {
+		fail("remove unlinked parts", err)
+	}
+	report.RemovedParts = count
+
+	if err := rs.removeOrphanUploads(now, report); err != nil {
+		fail("remove orphan uploads", err)
+	}
+
+	if err := rs.removeOrphanWorkspaces(now, report); err != nil {
+		fail("remove orphan workspaces", err)
+	}
+
+	if report.changed() {
+		if err := rs.maintenance.Optimize(); err != nil {
+			fail("optimize", err)
+		} else {
+			report.Optimized = true
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	rs.lastReport = report
+
+	if report.changed() || report.ArchivedSessions > 0 || report.RemovedUploads > 0 || report.RemovedWorkspaces > 0 {
+		log.Printf("Retention: archived %d sessions, deleted %d sessions, dropped %d thinking blocks and %d tool outputs, removed %d unlinked parts, %d uploads and %d workspaces",
+			report.ArchivedSessions, report.DeletedSessions, report.DroppedThinking, report.DroppedToolOutputs, report.RemovedParts, report.RemovedUploads, report.RemovedWorkspaces)
+	}
+
+	return report, nil
+}
+
+// removeOrphanUploads deletes uploaded files no message refers to.
+// Recent files are kept: they may belong to a message being written.
+func (rs *RetentionService) removeOrphanUploads(now time.Time, report *RetentionReport) error {
+	entries, err := os.ReadDir(rs.uploadDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read upload directory: %w", err)
+	}
+
+	// Uploads never sent are forgotten along with their file
+	if _, err := rs.maintenance.DeleteUnsentAttachments(now.Add(-orphanUploadGrace)); err != nil {
+		return err
+	}
+
+	names, err := rs.maintenance.ReferencedUploads()
+	if err != nil {
+		return err
+	}
+	referenced := make(map[string]bool)
+	for _, name := range names {
+		referenced[name] = true
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || referenced[entry.Name()] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) < orphanUploadGrace {
+			continue
+		}
+		if err := os.Remove(filepath.Join(rs.uploadDir, entry.Name())); err != nil {
+			log.Printf("Retention: failed to remove upload %s: %v", entry.Name(), err)
+			continue
+		}
+		for _, path := range DerivedFiles(rs.uploadDir, entry.Name()) {
+			os.Remove(path)
+		}
+		report.RemovedUploads++
+		report.FreedUploadBytes += info.Size()
+	}
+
+	return nil
+}
+
+// removeOrphanWorkspaces deletes the workspaces of sessions that no longer
+// exist, and the workspaces of new conversations that never got a session
+func (rs *RetentionService) removeOrphanWorkspaces(now time.Time, report *RetentionReport) error {
+	dir := filepath.Join(rs.uploadDir, workspacesDir)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read workspace directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) < orphanUploadGrace {
+			continue
+		}
+		session, err := rs.sessions.Get(entry.Name())
+		if err != nil {
+			return err
+		}
+		if session != nil {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			log.Printf("Retention: failed to remove workspace %s: %v", entry.Name(), err)
+			continue
+		}
+		report.RemovedWorkspaces++
+	}
+
+	return nil
+}
+
+// daysBefore returns the time n days before now
+func daysBefore(now time.Time, n int) time.Time {
+	return now.AddDate(0, 0, -n)
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/repositories"
+)
+
+// ErrSemanticSearchDisabled is returned for semantic searches when no
+// embedding provider is configured
+var ErrSemanticSearchDisabled = errors.New("semantic search is not configured")
+
+// hybridKeywordWeight is the share of the BM25 score in hybrid results
+const hybridKeywordWeight = 0.5
+
+// SearchService runs keyword, semantic and hybrid searches
+type SearchService struct {
+	keyword    repositories.SearchRepository
+	embeddings repositories.EmbeddingRepository
+	provider   EmbeddingProvider // nil when semantic search is disabled
+}
+
+// NewSearchService creates a new SearchService
+func NewSearchService(keyword repositories.SearchRepository, embeddings repositories.EmbeddingRepository, provider EmbeddingProvider) *SearchService {
+	return &SearchService{keyword: keyword, embeddings: embeddings, provider: provider}
+}
+
+// SemanticEnabled reports whether an embedding provider is configured
+func (ss *SearchService) SemanticEnabled() bool {
+	return ss.provider != nil
+}
+
+// Stats returns the progress of the semantic index
+func (ss *SearchService) Stats() (*models.EmbeddingStats, error) {
+	if ss.provider == nil {
+		return nil, ErrSemanticSearchDisabled
+	}
+	return ss.embeddings.Stats(ss.provider.Model())
+}
+
+// Search runs query in the given mode ("keyword", "semantic" or "hybrid")
+func (ss *SearchService) Search(ctx context.Context, mode string, query models.SearchQuery) ([]*models.SearchResult, int, error) {
+	switch mode {
+	case models.SearchModeSemantic:
+		return ss.semantic(ctx, query)
+	case models.SearchModeHybrid:
+		return ss.hybrid(ctx, query)
+	default:
+		return ss.keyword.Search(query)
+	}
+}
+
+// semantic ranks messages by similarity to the embedded query text.
+// Only messages are embedded, and substring matching does not apply.
+func (ss *SearchService) semantic(ctx context.Context, query models.SearchQuery) ([]*models.SearchResult, int, error) {
+	if ss.provider == nil {
+		return nil, 0, ErrSemanticSearchDisabled
+	}
+	if !searchesMessages(query) {
+		return nil, 0, nil
+	}
+
+	vectors, err := ss.provider.Embed(ctx, []string{query.Text})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	return ss.embeddings.Search(ss.provider.Model(), vectors[0], query)
+}
+
+// hybrid merges the keyword and semantic results of the first pages.
+// BM25 ranks are normalized against the best hit, then averaged with the
+// cosine similarity.
+func (ss *SearchService) hybrid(ctx context.Context, query models.SearchQuery) ([]*models.SearchResult, int, error) {
+	candidates := query
+	candidates.Offset = 0
+	candidates.Limit = 2 * (query.Offset + query.Limit)
+
+	keywordResults, keywordTotal, err := ss.keyword.Search(candidates)
+	if err != nil {
+		return nil, 0, err
+	}
+	semanticResults, semanticTotal, err := ss.semantic(ctx, candidates)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	bestRank := 0.0
+	for _, result := range keywordResults {
+		if result.Rank < bestRank {
+			bestRank = result.Rank
+		}
+	}
+
+	merged := make(map[string]*models.SearchResult)
+	var results []*models.SearchResult
+	for _, result := range keywordResults {
+		if bestRank < 0 {
+			result.Score = hybridKeywordWeight * result.Rank / bestRank
+		}
+		merged[searchResultKey(result)] = result
+		results = append(results, result)
+	}
+	for _, result := range semanticResults {
+		similarity := result.Score
+		if similarity < 0 {
+			similarity = 0
+		}
+		// Keep the keyword snippet, which highlights the matched words
+		if existing, ok := merged[searchResultKey(result)]; ok {
+			existing.Score += (1 - hybridKeywordWeight) * similarity
+			continue
+		}
+		result.Score = (1 - hybridKeywordWeight) * similarity
+		results = append(results, result)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if query.Sort == models.SearchSortDate {
+			return results[i].Timestamp.After(results[j].Timestamp)
+		}
+		return results[i].Score > results[j].Score
+	})
+
+	total := keywordTotal
+	if semanticTotal > total {
+		total = semanticTotal
+	}
+
+	if query.Offset >= len(results) {
+		return nil, total, nil
+	}
+	results = results[query.Offset:]
+	if len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+
+	return results, total, nil
+}
+
+// searchesMessages reports whether a query can return messages semantically
+func searchesMessages(query models.SearchQuery) bool {
+	if query.Substring {
+		return false
+	}
+	if len(query.Types) == 0 {
+		return true
+	}
+	for _, searchType := range query.Types {
+		if searchType == models.SearchTypeMessage {
+			return true
+		}
+	}
+	return false
+}
+
+// searchResultKey identifies a result across search modes
+func searchResultKey(result *models.SearchResult) string {
+	switch result.Type {
+	case models.SearchTypeMessage:
+		return result.Type + ":" + strconv.Itoa(result.MessageID)
+	case models.SearchTypeToolCall:
+		return result.Type + ":" + result.ToolUseID
+	case models.SearchTypeMemory:
+		return result.Type + ":" + result.MemoryID
+	default:
+		return result.Type + ":" + result.SessionID
+	}
+}
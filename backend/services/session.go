@@ -3,7 +3,9 @@ package services
 import (
 	"fmt"
 	"log"
+	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/ronan/home-agent/models"
 	"github.com/ronan/home-agent/repositories"
@@ -13,21 +15,29 @@ import (
 type SessionManager struct {
 	sessions    repositories.SessionRepository
 	messages    repositories.MessageRepository
+	parts       repositories.MessagePartRepository
+	attachments repositories.AttachmentRepository
+	redactor    *Redactor
 	sessionsMap sync.Map // Map of web session IDs to Claude session IDs
 	mu          sync.RWMutex
 }
 
 // NewSessionManager creates a new SessionManager instance
-func NewSessionManager(sessions repositories.SessionRepository, messages repositories.MessageRepository) *SessionManager {
+// Message content and parts are redacted before being saved
+func NewSessionManager(sessions repositories.SessionRepository, messages repositories.MessageRepository, parts repositories.MessagePartRepository, attachments repositories.AttachmentRepository, redactor *Redactor) *SessionManager {
 	return &SessionManager{
-		sessions: sessions,
-		messages: messages,
+		sessions:    sessions,
+		messages:    messages,
+		parts:       parts,
+		attachments: attachments,
+		redactor:    redactor,
 	}
 }
 
-// CreateSessionWithID creates a new session with a specific ID (from SDK) and model
-func (sm *SessionManager) CreateSessionWithID(sessionID, model string) (*models.Session, error) {
-	session, err := sm.sessions.CreateWithModel(sessionID, model)
+// CreateSessionWithID creates a new session with a specific ID and model,
+// resuming the given Claude SDK session
+func (sm *SessionManager) CreateSessionWithID(sessionID, claudeSessionID, model string) (*models.Session, error) {
+	session, err := sm.sessions.CreateWithModel(sessionID, claudeSessionID, model)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session in database: %w", err)
 	}
@@ -60,14 +70,34 @@ func (sm *SessionManager) UpdateSessionActivity(sessionID string) error {
 
 // SaveMessage saves a message to the database
 func (sm *SessionManager) SaveMessage(sessionID, role, content string) error {
+	_, err := sm.saveMessage(sessionID, role, content)
+	return err
+}
+
+// SaveUserMessage saves a user message and links the files sent with it
+func (sm *SessionManager) SaveUserMessage(sessionID, content string, attachments []*models.Attachment) error {
+	message, err := sm.saveMessage(sessionID, "user", content)
+	if err != nil {
+		return err
+	}
+	if len(attachments) > 0 {
+		if err := sm.attachments.AttachToMessage(sessionID, message.ID, attachments); err != nil {
+			return fmt.Errorf("failed to save attachments: %w", err)
+		}
+	}
+	return nil
+}
+
+// saveMessage saves a message and returns it with its ID
+func (sm *SessionManager) saveMessage(sessionID, role, content string) (*models.Message, error) {
 	// Validate role
 	if role != "user" && role != "assistant" && role != "thinking" {
-		return fmt.Errorf("invalid role: %s (must be 'user', 'assistant', or 'thinking')", role)
+		return nil, fmt.Errorf("invalid role: %s (must be 'user', 'assistant', or 'thinking')", role)
 	}
 
-	_, err := sm.messages.Save(sessionID, role, content)
+	message, err := sm.messages.Save(sessionID, role, sm.redactor.Redact(content))
 	if err != nil {
-		return fmt.Errorf("failed to save message: %w", err)
+		return nil, fmt.Errorf("failed to save message: %w", err)
 	}
 
 	// Update session activity
@@ -75,7 +105,7 @@ func (sm *SessionManager) SaveMessage(sessionID, role, content string) error {
 		log.Printf("Warning: failed to update session activity: %v", err)
 	}
 
-	return nil
+	return message, nil
 }
 
 // GetMessages retrieves all messages for a session
@@ -84,9 +114,55 @@ func (sm *SessionManager) GetMessages(sessionID string) ([]*models.Message, erro
 	if err != nil {
 		return nil, fmt.Errorf("failed to get messages: %w", err)
 	}
+	if err := sm.attachDetails(messages); err != nil {
+		return nil, err
+	}
 	return messages, nil
 }
 
+// GetMessagePage retrieves a window of messages around a cursor
+func (sm *SessionManager) GetMessagePage(sessionID string, cursor models.MessageCursor) (*models.MessagePage, error) {
+	page, err := sm.messages.GetPage(sessionID, cursor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	if page.Messages == nil {
+		page.Messages = []*models.Message{}
+	}
+	if err := sm.attachDetails(page.Messages); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// attachDetails fills in the parts of the assistant messages and the
+// attachments of the user messages
+func (sm *SessionManager) attachDetails(messages []*models.Message) error {
+	var assistantIDs, userIDs []int
+	for _, msg := range messages {
+		switch msg.Role {
+		case "assistant":
+			assistantIDs = append(assistantIDs, msg.ID)
+		case "user":
+			userIDs = append(userIDs, msg.ID)
+		}
+	}
+
+	parts, err := sm.parts.GetByMessages(assistantIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get message parts: %w", err)
+	}
+	attachments, err := sm.attachments.GetByMessages(userIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get message attachments: %w", err)
+	}
+	for _, msg := range messages {
+		msg.Parts = parts[msg.ID]
+		msg.Attachments = attachments[msg.ID]
+	}
+	return nil
+}
+
 // SessionExists checks if a session exists in the database
 func (sm *SessionManager) SessionExists(sessionID string) bool {
 	session, err := sm.sessions.Get(sessionID)
@@ -97,14 +173,24 @@ func (sm *SessionManager) SessionExists(sessionID string) bool {
 	return session != nil
 }
 
-// UpdateSessionID updates the session ID when SDK returns a new one after resume
-func (sm *SessionManager) UpdateSessionID(oldSessionID, newSessionID string) error {
-	if err := sm.sessions.UpdateSessionID(oldSessionID, newSessionID); err != nil {
-		return fmt.Errorf("failed to update session id: %w", err)
+// UpdateClaudeSessionID records the SDK session a session resumes from now on,
+// e.g. when the SDK returns a new one after resume
+func (sm *SessionManager) UpdateClaudeSessionID(sessionID, claudeSessionID, reason string) error {
+	if err := sm.sessions.UpdateClaudeSessionID(sessionID, claudeSessionID, reason); err != nil {
+		return fmt.Errorf("failed to update claude session id: %w", err)
 	}
 	return nil
 }
 
+// GetClaudeSessionHistory returns the SDK session IDs a session used before its current one
+func (sm *SessionManager) GetClaudeSessionHistory(sessionID string) ([]*models.ClaudeSessionRecord, error) {
+	records, err := sm.sessions.ListClaudeSessionHistory(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get claude session history: %w", err)
+	}
+	return records, nil
+}
+
 // ListSessions returns all sessions ordered by last activity
 func (sm *SessionManager) ListSessions() ([]*models.Session, error) {
 	sessions, err := sm.sessions.List()
@@ -114,6 +200,87 @@ func (sm *SessionManager) ListSessions() ([]*models.Session, error) {
 	return sessions, nil
 }
 
+// ListSessionsFiltered returns the sessions matching a filter, pinned first
+func (sm *SessionManager) ListSessionsFiltered(filter models.SessionFilter) (*models.SessionList, error) {
+	sessions, total, err := sm.sessions.ListFiltered(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return &models.SessionList{Sessions: sessions, Total: total, Limit: filter.Limit, Offset: filter.Offset}, nil
+}
+
+// ListSessionTags returns every tag in use with its number of sessions
+func (sm *SessionManager) ListSessionTags() ([]*models.SessionTag, error) {
+	tags, err := sm.sessions.ListTags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session tags: %w", err)
+	}
+	return tags, nil
+}
+
+// SetSessionPinned pins or unpins a session
+func (sm *SessionManager) SetSessionPinned(sessionID string, pinned bool) error {
+	if err := sm.sessions.UpdatePinned(sessionID, pinned); err != nil {
+		return fmt.Errorf("failed to update session pin: %w", err)
+	}
+	return nil
+}
+
+// SetSessionArchived archives or restores a session
+func (sm *SessionManager) SetSessionArchived(sessionID string, archived bool) error {
+	if err := sm.sessions.UpdateArchived(sessionID, archived); err != nil {
+		return fmt.Errorf("failed to update session archive: %w", err)
+	}
+	return nil
+}
+
+// SetSessionFolder moves a session into a folder, or out of any folder when folderID is nil
+func (sm *SessionManager) SetSessionFolder(sessionID string, folderID *int) error {
+	if err := sm.sessions.UpdateFolder(sessionID, folderID); err != nil {
+		return fmt.Errorf("failed to update session folder: %w", err)
+	}
+	return nil
+}
+
+// SetSessionTags replaces the tags of a session with their normalized form
+func (sm *SessionManager) SetSessionTags(sessionID string, tags []string) ([]string, error) {
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if err := sm.sessions.UpdateTags(sessionID, tags); err != nil {
+		return nil, fmt.Errorf("failed to update session tags: %w", err)
+	}
+	return tags, nil
+}
+
+// Tag limits enforced by NormalizeTags
+const (
+	MaxSessionTags   = 20
+	MaxSessionTagLen = 50
+)
+
+// NormalizeTags trims and lowercases tags, dropping empty and duplicate ones
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > MaxSessionTagLen {
+			return nil, fmt.Errorf("tag too long (max %d characters): %s", MaxSessionTagLen, tag)
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > MaxSessionTags {
+		return nil, fmt.Errorf("too many tags (max %d)", MaxSessionTags)
+	}
+	return normalized, nil
+}
+
 // UpdateSessionTitle updates the title of a session
 func (sm *SessionManager) UpdateSessionTitle(sessionID, title string) error {
 	if err := sm.sessions.UpdateTitle(sessionID, title); err != nil {
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/repositories"
+)
+
+// shareTokenBytes is the entropy of a share token (256 bits)
+const shareTokenBytes = 32
+
+// ShareOptions controls what a share link shows
+type ShareOptions struct {
+	HideToolOutputs bool
+	HideThinking    bool
+	ExpiresIn       time.Duration // 0 for a link that never expires
+}
+
+// ShareService creates share links and renders shared transcripts
+type ShareService struct {
+	shares   repositories.ShareRepository
+	exporter *SessionExporter
+}
+
+// NewShareService creates a new ShareService
+func NewShareService(shares repositories.ShareRepository, exporter *SessionExporter) *ShareService {
+	return &ShareService{shares: shares, exporter: exporter}
+}
+
+// Create creates a share link for a session
+func (ss *ShareService) Create(sessionID, actor string, options ShareOptions) (*models.SessionShare, error) {
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	share := &models.SessionShare{
+		Token:           token,
+		SessionID:       sessionID,
+		HideToolOutputs: options.HideToolOutputs,
+		HideThinking:    options.HideThinking,
+		CreatedBy:       actor,
+		CreatedAt:       now,
+	}
+	if options.ExpiresIn > 0 {
+		expiresAt := now.Add(options.ExpiresIn)
+		share.ExpiresAt = &expiresAt
+	}
+
+	if err := ss.shares.Create(share); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// List returns the share links of a session
+func (ss *ShareService) List(sessionID string) ([]*models.SessionShare, error) {
+	return ss.shares.ListBySession(sessionID)
+}
+
+// Get returns a share link by ID, nil if it does not exist
+func (ss *ShareService) Get(id int) (*models.SessionShare, error) {
+	return ss.shares.Get(id)
+}
+
+// Revoke deletes a share link
+func (ss *ShareService) Revoke(id int) error {
+	return ss.shares.Delete(id)
+}
+
+// Resolve returns the share link for a token, nil if it does not exist or has expired
+func (ss *ShareService) Resolve(token string) (*models.SessionShare, error) {
+	share, err := ss.shares.GetByToken(token)
+	if err != nil || share == nil {
+		return nil, err
+	}
+	if share.Expired(time.Now()) {
+		return nil, nil
+	}
+	return share, nil
+}
+
+// Render writes the read-only HTML transcript of a share link, with images
+// embedded. It returns false if the shared session no longer exists.
+func (ss *ShareService) Render(w io.Writer, share *models.SessionShare) (bool, error) {
+	export, err := ss.exporter.Build(share.SessionID)
+	if err != nil || export == nil {
+		return false, err
+	}
+
+	if share.HideThinking {
+		messages := export.Messages[:0]
+		for _, message := range export.Messages {
+			if message.Role != "thinking" {
+				messages = append(messages, message)
+			}
+		}
+		export.Messages = messages
+	}
+	if share.HideToolOutputs {
+		for _, toolCall := range export.ToolCalls {
+			toolCall.Output = ""
+		}
+	}
+
+	return true, ss.exporter.Render(w, export, ExportFormatHTML, ExportAttachmentsInline)
+}
+
+// newShareToken returns a random URL-safe token
+func newShareToken() (string, error) {
+	buf := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate share token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ronan/home-agent/models"
+	"github.com/ronan/home-agent/repositories"
+)
+
+// KnownTools lists the tools the proxy exposes to Claude
+var KnownTools = []string{"Read", "Write", "Edit", "Bash", "Glob", "Grep", "WebSearch", "WebFetch"}
+
+// AllTools is the wildcard accepted in denied tool lists
+const AllTools = "*"
+
+// EffectiveToolPolicy is the policy applied to one execution, after merging
+// the global, user, machine and session scopes
+type EffectiveToolPolicy struct {
+	AllowedTools []string `json:"allowed_tools"` // nil means every tool not denied
+	DeniedTools  []string `json:"denied_tools"`
+	Scopes       []string `json:"scopes"` // Scopes that contributed, for display and logs
+}
+
+// Restricted reports whether the policy forbids anything
+func (p EffectiveToolPolicy) Restricted() bool {
+	return p.AllowedTools != nil || len(p.DeniedTools) > 0
+}
+
+// Permits reports whether a tool may be used under this policy
+func (p EffectiveToolPolicy) Permits(toolName string) bool {
+	for _, denied := range p.DeniedTools {
+		if denied == AllTools || denied == toolName {
+			return false
+		}
+	}
+	if p.AllowedTools == nil {
+		return true
+	}
+	for _, allowed := range p.AllowedTools {
+		if allowed == toolName {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeToolPolicies combines policies from the broadest to the narrowest scope.
+// Allowed lists are intersected and denied lists are merged, so a narrower
+// scope can only take tools away.
+func MergeToolPolicies(policies ...*models.ToolPolicy) EffectiveToolPolicy {
+	var allowed map[string]bool
+	denied := make(map[string]bool)
+	var effective EffectiveToolPolicy
+
+	for _, policy := range policies {
+		if policy == nil {
+			continue
+		}
+		effective.Scopes = append(effective.Scopes, policyLabel(policy))
+
+		if len(policy.AllowedTools) > 0 {
+			next := make(map[string]bool)
+			for _, tool := range policy.AllowedTools {
+				if allowed == nil || allowed[tool] {
+					next[tool] = true
+				}
+			}
+			allowed = next
+		}
+		for _, tool := range policy.DeniedTools {
+			denied[tool] = true
+		}
+	}
+
+	if allowed != nil {
+		effective.AllowedTools = []string{}
+		for tool := range allowed {
+			if !denied[tool] && !denied[AllTools] {
+				effective.AllowedTools = append(effective.AllowedTools, tool)
+			}
+		}
+		sort.Strings(effective.AllowedTools)
+	}
+	// Nothing left to allow: deny everything explicitly
+	if effective.AllowedTools != nil && len(effective.AllowedTools) == 0 {
+		effective.AllowedTools = nil
+		denied = map[string]bool{AllTools: true}
+	}
+	for tool := range denied {
+		effective.DeniedTools = append(effective.DeniedTools, tool)
+	}
+	sort.Strings(effective.DeniedTools)
+
+	return effective
+}
+
+// ValidateToolPolicy checks the scope and tool names of a policy
+func ValidateToolPolicy(policy *models.ToolPolicy) error {
+	switch policy.Scope {
+	case models.PolicyScopeGlobal:
+		if policy.ScopeID != "" {
+			return fmt.Errorf("global policy cannot have a scope_id")
+		}
+	case models.PolicyScopeUser, models.PolicyScopeMachine, models.PolicyScopeSession:
+		if policy.ScopeID == "" {
+			return fmt.Errorf("%s policy requires a scope_id", policy.Scope)
+		}
+	default:
+		return fmt.Errorf("invalid scope: %s (must be 'global', 'user', 'machine' or 'session')", policy.Scope)
+	}
+
+	for _, tool := range policy.AllowedTools {
+		if !isKnownTool(tool) {
+			return fmt.Errorf("unknown tool in allowed_tools: %s", tool)
+		}
+	}
+	for _, tool := range policy.DeniedTools {
+		if tool != AllTools && !isKnownTool(tool) {
+			return fmt.Errorf("unknown tool in denied_tools: %s", tool)
+		}
+	}
+
+	return nil
+}
+
+func isKnownTool(name string) bool {
+	for _, tool := range KnownTools {
+		if tool == name {
+			return true
+		}
+	}
+	return false
+}
+
+func policyLabel(policy *models.ToolPolicy) string {
+	if policy.ScopeID == "" {
+		return policy.Scope
+	}
+	return policy.Scope + ":" + policy.ScopeID
+}
+
+// ToolPolicyService resolves the effective tool policy of an execution
+type ToolPolicyService struct {
+	repo repositories.ToolPolicyRepository
+}
+
+// NewToolPolicyService creates a new ToolPolicyService
+func NewToolPolicyService(repo repositories.ToolPolicyRepository) *ToolPolicyService {
+	return &ToolPolicyService{repo: repo}
+}
+
+// Effective computes the policy for a user, machine and session.
+// In "auto" machine mode Claude may reach any machine, so every machine
+// policy applies.
+func (tps *ToolPolicyService) Effective(user, machineID, sessionID string) (EffectiveToolPolicy, error) {
+	if tps == nil || tps.repo == nil {
+		return EffectiveToolPolicy{}, nil
+	}
+
+	var policies []*models.ToolPolicy
+
+	global, err := tps.repo.Get(models.PolicyScopeGlobal, "")
+	if err != nil {
+		return EffectiveToolPolicy{}, err
+	}
+	policies = append(policies, global)
+
+	if user != "" {
+		userPolicy, err := tps.repo.Get(models.PolicyScopeUser, user)
+		if err != nil {
+			return EffectiveToolPolicy{}, err
+		}
+		policies = append(policies, userPolicy)
+	}
+
+	switch machineID {
+	case "":
+	case "auto":
+		machinePolicies, err := tps.repo.ListByScope(models.PolicyScopeMachine)
+		if err != nil {
+			return EffectiveToolPolicy{}, err
+		}
+		policies = append(policies, machinePolicies...)
+	default:
+		machinePolicy, err := tps.repo.Get(models.PolicyScopeMachine, machineID)
+		if err != nil {
+			return EffectiveToolPolicy{}, err
+		}
+		policies = append(policies, machinePolicy)
+	}
+
+	if sessionID != "" {
+		sessionPolicy, err := tps.repo.Get(models.PolicyScopeSession, sessionID)
+		if err != nil {
+			return EffectiveToolPolicy{}, err
+		}
+		policies = append(policies, sessionPolicy)
+	}
+
+	return MergeToolPolicies(policies...), nil
+}
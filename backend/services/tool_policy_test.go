@@ -0,0 +1,134 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ronan/home-agent/models"
+)
+
+func TestMergeToolPolicies(t *testing.T) {
+	global := func(allowed, denied []string) *models.ToolPolicy {
+		return &models.ToolPolicy{Scope: models.PolicyScopeGlobal, AllowedTools: allowed, DeniedTools: denied}
+	}
+	session := func(allowed, denied []string) *models.ToolPolicy {
+		return &models.ToolPolicy{Scope: models.PolicyScopeSession, ScopeID: "s1", AllowedTools: allowed, DeniedTools: denied}
+	}
+
+	tests := []struct {
+		name        string
+		policies    []*models.ToolPolicy
+		wantAllowed []string
+		wantDenied  []string
+		wantScopes  []string
+	}{
+		{
+			name: "no policy",
+		},
+		{
+			name:       "nil policies are skipped",
+			policies:   []*models.ToolPolicy{nil, global(nil, []string{"Bash"}), nil},
+			wantDenied: []string{"Bash"},
+			wantScopes: []string{"global"},
+		},
+		{
+			name:        "allow lists are intersected",
+			policies:    []*models.ToolPolicy{global([]string{"Read", "Grep", "Bash"}, nil), session([]string{"Grep", "Read", "Write"}, nil)},
+			wantAllowed: []string{"Grep", "Read"},
+			wantScopes:  []string{"global", "session:s1"},
+		},
+		{
+			name:        "an empty allow list does not restrict",
+			policies:    []*models.ToolPolicy{global([]string{"Read", "Grep"}, nil), session(nil, nil)},
+			wantAllowed: []string{"Grep", "Read"},
+			wantScopes:  []string{"global", "session:s1"},
+		},
+		{
+			name:        "deny lists are merged and remove allowed tools",
+			policies:    []*models.ToolPolicy{global([]string{"Read", "Grep", "Bash"}, []string{"WebFetch"}), session(nil, []string{"Bash"})},
+			wantAllowed: []string{"Grep", "Read"},
+			wantDenied:  []string{"Bash", "WebFetch"},
+			wantScopes:  []string{"global", "session:s1"},
+		},
+		{
+			name:       "a wildcard deny beats allows",
+			policies:   []*models.ToolPolicy{global([]string{"Read", "Grep"}, nil), session([]string{"Read"}, []string{AllTools})},
+			wantDenied: []string{AllTools},
+			wantScopes: []string{"global", "session:s1"},
+		},
+		{
+			name:       "disjoint allow lists leave nothing allowed",
+			policies:   []*models.ToolPolicy{global([]string{"Read"}, nil), session([]string{"Bash"}, nil)},
+			wantDenied: []string{AllTools},
+			wantScopes: []string{"global", "session:s1"},
+		},
+		{
+			name:       "denying every allowed tool leaves nothing allowed",
+			policies:   []*models.ToolPolicy{global([]string{"Read"}, []string{"Bash"}), session(nil, []string{"Read"})},
+			wantDenied: []string{AllTools},
+			wantScopes: []string{"global", "session:s1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MergeToolPolicies(tt.policies...)
+			if !reflect.DeepEqual(got.AllowedTools, tt.wantAllowed) {
+				t.Errorf("AllowedTools = %v, want %v", got.AllowedTools, tt.wantAllowed)
+			}
+			if !reflect.DeepEqual(got.DeniedTools, tt.wantDenied) {
+				t.Errorf("DeniedTools = %v, want %v", got.DeniedTools, tt.wantDenied)
+			}
+			if !reflect.DeepEqual(got.Scopes, tt.wantScopes) {
+				t.Errorf("Scopes = %v, want %v", got.Scopes, tt.wantScopes)
+			}
+		})
+	}
+}
+
+func TestEffectiveToolPolicyPermits(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    EffectiveToolPolicy
+		permitted map[string]bool
+	}{
+		{"unrestricted", EffectiveToolPolicy{}, map[string]bool{"Bash": true, "Read": true}},
+		{"allow list", EffectiveToolPolicy{AllowedTools: []string{"Read"}}, map[string]bool{"Bash": false, "Read": true}},
+		{"deny list", EffectiveToolPolicy{DeniedTools: []string{"Bash"}}, map[string]bool{"Bash": false, "Read": true}},
+		{"wildcard", EffectiveToolPolicy{AllowedTools: []string{"Read"}, DeniedTools: []string{AllTools}}, map[string]bool{"Bash": false, "Read": false}},
+	}
+
+	for _, tt := range tests {
+		for tool, want := range tt.permitted {
+			if got := tt.policy.Permits(tool); got != want {
+				t.Errorf("%s: Permits(%s) = %v, want %v", tt.name, tool, got, want)
+			}
+		}
+		if restricted := tt.policy.Restricted(); restricted != (tt.name != "unrestricted") {
+			t.Errorf("%s: Restricted() = %v", tt.name, restricted)
+		}
+	}
+}
+
+func TestValidateToolPolicy(t *testing.T) {
+	tests := []struct {
+		policy  models.ToolPolicy
+		wantErr bool
+	}{
+		{models.ToolPolicy{Scope: models.PolicyScopeGlobal, AllowedTools: []string{"Read"}, DeniedTools: []string{AllTools}}, false},
+		{models.ToolPolicy{Scope: models.PolicyScopeSession, ScopeID: "s1", DeniedTools: []string{"Bash"}}, false},
+		{models.ToolPolicy{Scope: models.PolicyScopeGlobal, ScopeID: "x"}, true},
+		{models.ToolPolicy{Scope: models.PolicyScopeUser}, true},
+		{models.ToolPolicy{Scope: "team", ScopeID: "x"}, true},
+		{models.ToolPolicy{Scope: models.PolicyScopeGlobal, AllowedTools: []string{"Rm"}}, true},
+		{models.ToolPolicy{Scope: models.PolicyScopeGlobal, AllowedTools: []string{AllTools}}, true},
+		{models.ToolPolicy{Scope: models.PolicyScopeGlobal, DeniedTools: []string{"bash"}}, true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateToolPolicy(&tt.policy)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateToolPolicy(%+v) error = %v, wantErr %v", tt.policy, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// workspacesDir is the directory of the upload directory holding session workspaces
+const workspacesDir = "workspaces"
+
+// WorkspaceService manages the per-session directories archives are extracted
+// into. They live under the upload directory so Claude can read them through
+// the same path mapping as uploads.
+type WorkspaceService struct {
+	uploadDir string
+}
+
+// NewWorkspaceService creates a new WorkspaceService
+func NewWorkspaceService(uploadDir string) *WorkspaceService {
+	return &WorkspaceService{uploadDir: uploadDir}
+}
+
+// Dir returns the workspace directory of a session
+func (ws *WorkspaceService) Dir(sessionID string) string {
+	return filepath.Join(ws.uploadDir, workspacesDir, filepath.Base(sessionID))
+}
+
+// ExtractArchive extracts files of an uploaded archive into the session's
+// workspace, in a directory named after the archive, and returns that directory
+func (ws *WorkspaceService) ExtractArchive(sessionID, archivePath, filename string, files []string) (string, int, error) {
+	name := filepath.Base(filename)
+	if format := ArchiveFormat(name); format != "" {
+		name = name[:len(name)-len(format)-1]
+	} else {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	if name == "" || name == "." || name == ".." {
+		name = "archive"
+	}
+
+	// Another archive of the same name gets its own directory
+	dest := filepath.Join(ws.Dir(sessionID), name)
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(dest); os.IsNotExist(err) {
+			break
+		}
+		dest = filepath.Join(ws.Dir(sessionID), fmt.Sprintf("%s-%d", name, i))
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return "", 0, fmt.Errorf("failed to create workspace: %w", err)
+	}
+
+	count, err := ExtractArchive(archivePath, dest, files)
+	if err != nil {
+		os.RemoveAll(dest)
+		return "", 0, err
+	}
+	return dest, count, nil
+}
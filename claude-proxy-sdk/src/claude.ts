@@ -3,8 +3,8 @@
  * Provides streaming execution with hooks support
  */
 
-import { query, type Options, type SDKMessage, type PreToolUseHookInput, type PostToolUseHookInput } from "@anthropic-ai/claude-agent-sdk";
-import type { ProxyRequest, ProxyResponse, ToolCallInfo, UsageInfo } from "./types.js";
+import { query, type Options, type SDKMessage, type SDKUserMessage, type PreToolUseHookInput, type PostToolUseHookInput } from "@anthropic-ai/claude-agent-sdk";
+import type { ApprovalRequester, ImageInput, ProxyRequest, ProxyResponse, ToolCallInfo, ToolPolicy, UsageInfo, ViolationReporter } from "./types.js";
 import { auditLog } from "./hooks/audit.js";
 import { ExecutionContext } from "./context/ExecutionContext.js";
 
@@ -33,6 +33,21 @@ You have access to various tools:
 - Respond in the same language as the user
 - Be concise but helpful`;
 
+// Tools exposed to the agent when no policy restricts them
+const DEFAULT_TOOLS = ["Read", "Write", "Edit", "Bash", "Glob", "Grep", "WebSearch", "WebFetch"];
+
+// Check a tool against the policy sent by the backend
+function isToolPermitted(toolName: string, policy?: ToolPolicy): boolean {
+  if (!policy) {
+    return true;
+  }
+  const denied = policy.denied_tools || [];
+  if (denied.includes("*") || denied.includes(toolName)) {
+    return false;
+  }
+  return !policy.allowed_tools || policy.allowed_tools.includes(toolName);
+}
+
 // Model aliases supported by Claude Agent SDK
 function mapModel(model?: string): string {
   // SDK supports simple aliases: "haiku", "sonnet", "opus"
@@ -47,12 +62,47 @@ function mapModel(model?: string): string {
   }
 }
 
+/**
+ * Build the SDK prompt: plain text, or a single user message carrying the
+ * images as content blocks followed by the text
+ */
+function buildPrompt(prompt: string, images?: ImageInput[]): string | AsyncIterable<SDKUserMessage> {
+  if (!images || images.length === 0) {
+    return prompt;
+  }
+
+  async function* messages(): AsyncGenerator<SDKUserMessage> {
+    yield {
+      type: "user",
+      message: {
+        role: "user",
+        content: [
+          ...images!.map((image) => ({
+            type: "image" as const,
+            source: { type: "base64" as const, media_type: image.media_type, data: image.data },
+          })),
+          { type: "text" as const, text: prompt },
+        ],
+      },
+      parent_tool_use_id: null,
+      session_id: "",
+    };
+  }
+  return messages();
+}
+
 /**
  * Execute a prompt using Claude Agent SDK
  * Yields ProxyResponse objects compatible with the Go backend protocol
+ * When the backend asks for approval relay, every tool permission check is
+ * forwarded to it through requestApproval
+ * Tool calls forbidden by the tool policy are blocked and reported through
+ * reportViolation
  */
 export async function* executePrompt(
-  request: ProxyRequest
+  request: ProxyRequest,
+  requestApproval?: ApprovalRequester,
+  reportViolation?: ViolationReporter
 ): AsyncGenerator<ProxyResponse> {
   const {
     prompt,
@@ -61,8 +111,16 @@ export async function* executePrompt(
     model,
     custom_instructions,
     thinking,
+    approval_relay,
+    tool_policy,
+    images,
   } = request;
 
+  const relayApprovals = Boolean(approval_relay && requestApproval);
+
+  // Only expose the tools the policy permits
+  const tools = DEFAULT_TOOLS.filter((tool) => isToolPermitted(tool, tool_policy));
+
   // Create execution context local to this request
   const ctx = new ExecutionContext();
 
@@ -75,13 +133,38 @@ export async function* executePrompt(
   // Build Agent SDK options
   const options: Options = {
     // Tools available to the agent
-    tools: ["Read", "Write", "Edit", "Bash", "Glob", "Grep", "WebSearch", "WebFetch"],
-
-    // Auto-allow these tools
-    allowedTools: ["Read", "Write", "Edit", "Bash", "Glob", "Grep", "WebSearch", "WebFetch"],
+    tools,
+    ...(tool_policy && {
+      disallowedTools: DEFAULT_TOOLS.filter((tool) => !tools.includes(tool)),
+    }),
 
-    // Permission mode - accept edits without prompting
-    permissionMode: "acceptEdits",
+    // Without approval relay: auto-allow tools and accept edits without prompting
+    // With approval relay: the backend decides on every permission check
+    ...(relayApprovals
+      ? {
+          permissionMode: "default" as const,
+          canUseTool: async (toolName: string, input: Record<string, unknown>) => {
+            const answer = await requestApproval!(toolName, input);
+            auditLog({
+              timestamp: new Date(),
+              sessionId: session_id,
+              event: "PreToolUse",
+              tool: toolName,
+              details: { approval: answer.decision },
+            });
+            if (answer.decision === "allow") {
+              return { behavior: "allow" as const, updatedInput: input };
+            }
+            return {
+              behavior: "deny" as const,
+              message: answer.message || "Tool call denied by the user",
+            };
+          },
+        }
+      : {
+          allowedTools: tools,
+          permissionMode: "acceptEdits" as const,
+        }),
 
     // Sandbox configuration - allow privileged commands
     sandbox: {
@@ -116,6 +199,40 @@ export async function* executePrompt(
     // Hooks for auditing (using callback hooks instead of shell commands)
     hooks: {
       PreToolUse: [
+        {
+          // Enforce the tool policy on every call, whatever the permission mode
+          hooks: [
+            async (input, toolUseId, _options) => {
+              const hookInput = input as PreToolUseHookInput;
+              if (isToolPermitted(hookInput.tool_name, tool_policy)) {
+                return { continue: true };
+              }
+              const reason = `Tool ${hookInput.tool_name} is not permitted by the tool policy`;
+              auditLog({
+                timestamp: new Date(),
+                sessionId: session_id,
+                event: "PreToolUse",
+                tool: hookInput.tool_name,
+                details: { policy_violation: true },
+              });
+              reportViolation?.(
+                {
+                  tool_use_id: toolUseId || "",
+                  tool_name: hookInput.tool_name,
+                  input: hookInput.tool_input as Record<string, unknown>,
+                },
+                reason
+              );
+              return {
+                hookSpecificOutput: {
+                  hookEventName: "PreToolUse" as const,
+                  permissionDecision: "deny" as const,
+                  permissionDecisionReason: reason,
+                },
+              };
+            },
+          ],
+        },
         {
           matcher: "Bash",
           hooks: [
@@ -158,14 +275,14 @@ export async function* executePrompt(
     timestamp: new Date(),
     sessionId: session_id,
     event: "execute",
-    details: { model, thinking, is_new_session },
+    details: { model, thinking, is_new_session, approval_relay: relayApprovals, tools, images: images?.length ?? 0 },
   });
 
   let detectedSessionId: string | undefined;
   let fullResponse = "";
 
   try {
-    for await (const message of query({ prompt, options })) {
+    for await (const message of query({ prompt: buildPrompt(prompt, images), options })) {
       // Debug: log ALL raw SDK messages to understand the protocol
       console.log(`[SDK RAW] ${JSON.stringify(message).substring(0, 500)}`);
 
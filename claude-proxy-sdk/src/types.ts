@@ -12,8 +12,40 @@ export interface ProxyRequest {
   model?: "haiku" | "sonnet" | "opus";
   custom_instructions?: string;
   thinking?: boolean;
+  approval_relay?: boolean;  // Ask the backend before running tools
+  tool_policy?: ToolPolicy;  // Omitted when every tool is allowed
+  images?: ImageInput[];     // Images sent with the prompt as content blocks
 }
 
+// Image attached to a prompt, sent inline so the proxy needs no access to the uploads
+export interface ImageInput {
+  media_type: "image/jpeg" | "image/png" | "image/gif" | "image/webp";
+  data: string;  // Base64 encoded
+}
+
+// Tools Claude may use, computed by the backend
+export interface ToolPolicy {
+  allowed_tools?: string[];  // Omitted: every tool not denied
+  denied_tools?: string[];   // "*" denies every tool
+}
+
+// Reports a tool call blocked by the tool policy
+export type ViolationReporter = (tool: ToolCallInfo, reason: string) => void;
+
+// Answer to an approval_request, sent by the backend
+export interface ApprovalResponse {
+  type: "approval_response";
+  approval_id: string;
+  decision: "allow" | "deny";
+  message?: string;
+}
+
+// Asks the backend whether a tool call may run
+export type ApprovalRequester = (
+  toolName: string,
+  input: Record<string, unknown>
+) => Promise<ApprovalResponse>;
+
 // Tool call information
 export interface ToolCallInfo {
   tool_use_id: string;
@@ -35,7 +67,7 @@ export interface UsageInfo {
 export interface ProxyResponse {
   type: "chunk" | "thinking" | "session_id" | "done" | "error"
       | "tool_start" | "tool_progress" | "tool_result" | "tool_error"
-      | "tool_input_delta" | "usage";
+      | "tool_input_delta" | "usage" | "approval_request" | "policy_violation";
   content?: string;
   session_id?: string;
   error?: string;
@@ -48,6 +80,8 @@ export interface ProxyResponse {
   input_delta?: string;  // JSON delta for input streaming
   // Usage information
   usage?: UsageInfo;
+  // Permission request
+  approval_id?: string;
 }
 
 // Configuration
@@ -5,7 +5,8 @@
 
 import type { FastifyInstance, FastifyRequest } from "fastify";
 import type { WebSocket } from "@fastify/websocket";
-import type { ProxyRequest, ProxyResponse } from "./types.js";
+import { randomUUID } from "node:crypto";
+import type { ApprovalRequester, ApprovalResponse, ProxyRequest, ProxyResponse, ViolationReporter } from "./types.js";
 import { executePrompt } from "./claude.js";
 import { auditLog } from "./hooks/audit.js";
 
@@ -39,10 +40,44 @@ export function registerWebSocket(
 
         console.log(`[WS] New connection from ${request.ip}`);
 
+        // Approval requests waiting for the backend's decision
+        const pendingApprovals = new Map<string, (answer: ApprovalResponse) => void>();
+
+        const requestApproval: ApprovalRequester = (toolName, input) =>
+          new Promise((resolve) => {
+            const approvalId = randomUUID();
+            pendingApprovals.set(approvalId, resolve);
+            sendResponse(socket, {
+              type: "approval_request",
+              approval_id: approvalId,
+              tool: { tool_use_id: "", tool_name: toolName, input },
+            });
+          });
+
+        const reportViolation: ViolationReporter = (tool, reason) => {
+          sendResponse(socket, {
+            type: "policy_violation",
+            tool,
+            error: reason,
+          });
+        };
+
         socket.on("message", async (data: Buffer) => {
           try {
             const message = data.toString();
-            const request = JSON.parse(message) as ProxyRequest;
+            const parsed = JSON.parse(message) as ProxyRequest | ApprovalResponse;
+
+            // Decisions arrive while an execution is in progress on this socket
+            if (parsed.type === "approval_response") {
+              const resolve = pendingApprovals.get(parsed.approval_id);
+              if (resolve) {
+                pendingApprovals.delete(parsed.approval_id);
+                resolve(parsed);
+              }
+              return;
+            }
+
+            const request = parsed;
 
             console.log(
               `[WS] Request: type=${request.type}, prompt_len=${request.prompt?.length || 0}, ` +
@@ -50,7 +85,7 @@ export function registerWebSocket(
             );
 
             if (request.type === "execute") {
-              await handleExecute(socket, request);
+              await handleExecute(socket, request, requestApproval, reportViolation);
             } else {
               sendError(socket, `Unknown request type: ${request.type}`);
             }
@@ -64,6 +99,11 @@ export function registerWebSocket(
 
         socket.on("close", () => {
           console.log(`[WS] Connection closed from ${request.ip}`);
+          // Nobody is left to answer: deny whatever is still waiting
+          for (const [approvalId, resolve] of pendingApprovals) {
+            resolve({ type: "approval_response", approval_id: approvalId, decision: "deny", message: "Backend disconnected" });
+          }
+          pendingApprovals.clear();
         });
 
         socket.on("error", (error: Error) => {
@@ -112,7 +152,9 @@ export function registerWebSocket(
  */
 async function handleExecute(
   socket: WebSocket,
-  request: ProxyRequest
+  request: ProxyRequest,
+  requestApproval: ApprovalRequester,
+  reportViolation: ViolationReporter
 ): Promise<void> {
   if (!request.prompt) {
     sendError(socket, "Prompt is required");
@@ -132,7 +174,7 @@ async function handleExecute(
 
   try {
     // Stream responses from Claude Agent SDK
-    for await (const response of executePrompt(request)) {
+    for await (const response of executePrompt(request, requestApproval, reportViolation)) {
       sendResponse(socket, response);
 
       // Stop streaming after done or error
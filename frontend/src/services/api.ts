@@ -15,6 +15,60 @@ export interface Session {
   input_tokens: number;
   output_tokens: number;
   total_cost_usd: number;
+  // Organization
+  pinned: boolean;
+  archived: boolean;
+  folder_id: number | null;
+  tags: string[];
+  // Context compaction
+  context_tokens: number;
+  compactions: number;
+  compacted_at?: string;
+  // Forking
+  forked_from?: string;
+  forked_at_message?: number;
+  seed_pending: boolean;
+}
+
+export interface CompactionResult {
+  session_id: string;
+  claude_session_id: string; // Seeded Claude session resumed from now on
+  summary: string;
+  context_tokens_before: number;
+  context_tokens: number;
+  compactions: number;
+}
+
+export interface Folder {
+  id: number;
+  name: string;
+  parent_id: number | null;
+  session_count: number;
+  created_at: string;
+}
+
+export interface SessionTag {
+  tag: string;
+  count: number;
+}
+
+export interface SessionShare {
+  id: number;
+  token: string;
+  session_id: string;
+  hide_tool_outputs: boolean;
+  hide_thinking: boolean;
+  expires_at: string | null;
+  created_by: string;
+  created_at: string;
+  url: string; // Relative, e.g. /share/<token>
+  expired: boolean;
+}
+
+export interface ShareOptions {
+  hide_tool_outputs?: boolean;
+  hide_thinking?: boolean;
+  expires_in_hours?: number; // 0 or absent: never expires
 }
 
 export interface Message {
@@ -23,6 +77,44 @@ export interface Message {
   role: 'user' | 'assistant' | 'thinking';
   content: string;
   created_at: string;
+  parts?: MessagePart[];
+  attachments?: Attachment[];
+}
+
+export interface Attachment {
+  id: string;
+  filename: string;
+  path: string;
+  type: 'image' | 'file';
+  mime_type: string;
+  size: number;
+  checksum: string;
+  uploaded_at: string;
+}
+
+export interface MessagePart {
+  id: number;
+  message_id: number | null;
+  position: number;
+  type: 'text' | 'thinking' | 'tool_use' | 'tool_result';
+  content?: string;
+  tool_use_id?: string;
+  tool_name?: string;
+  input?: string;
+  is_error?: boolean;
+  created_at: string;
+}
+
+export interface ArchiveEntry {
+  path: string;
+  size: number;
+}
+
+export interface ArchiveListing {
+  format: 'zip' | 'tar' | 'tar.gz';
+  entries: ArchiveEntry[];
+  total_size: number;
+  skipped?: string[];
 }
 
 export interface UploadedFile {
@@ -32,6 +124,10 @@ export interface UploadedFile {
   type: 'image' | 'file';
   size: number;
   mime_type: string;
+  archive?: ArchiveListing;
+  // Archive files picked to include in the prompt or extract into the session's workspace
+  inline?: string[];
+  extract?: string[];
 }
 
 export interface MemoryEntry {
@@ -64,7 +160,7 @@ export async function fetchSessions(): Promise<Session[]> {
     throw new Error('Failed to fetch sessions');
   }
   const data = await response.json();
-  return data || [];
+  return data.sessions || [];
 }
 
 /**
@@ -79,6 +175,118 @@ export async function fetchMessages(sessionId: string): Promise<Message[]> {
   return data || [];
 }
 
+export interface MessageCursor {
+  before?: number; // Messages older than this ID
+  after?: number; // Messages newer than this ID
+  around?: number; // Messages centred on this ID
+  limit?: number;
+}
+
+export interface MessagePage {
+  messages: Message[];
+  has_more_before: boolean;
+  has_more_after: boolean;
+}
+
+/**
+ * Fetch a window of messages for a session (latest messages without cursor)
+ */
+export async function fetchMessagePage(sessionId: string, cursor: MessageCursor = {}): Promise<MessagePage> {
+  const params = new URLSearchParams({ limit: (cursor.limit ?? 50).toString() });
+  if (cursor.before) params.set('before', cursor.before.toString());
+  if (cursor.after) params.set('after', cursor.after.toString());
+  if (cursor.around) params.set('around', cursor.around.toString());
+
+  const response = await fetch(`${API_BASE}/sessions/${sessionId}/messages?${params}`);
+  if (!response.ok) {
+    throw new Error('Failed to fetch messages');
+  }
+  return response.json();
+}
+
+/**
+ * Compact a session: its Claude context is replaced by a summary, history is kept
+ */
+export async function compactSession(sessionId: string): Promise<CompactionResult> {
+  const response = await fetch(`${API_BASE}/sessions/${sessionId}/compact`, {
+    method: 'POST',
+  });
+  if (!response.ok) {
+    throw new Error('Failed to compact session');
+  }
+  return response.json();
+}
+
+/**
+ * Fork a session: the new session copies the history up to a message (all of it by default)
+ */
+export async function forkSession(sessionId: string, atMessage?: number): Promise<Session> {
+  const params = atMessage ? `?at_message=${atMessage}` : '';
+  const response = await fetch(`${API_BASE}/sessions/${sessionId}/fork${params}`, {
+    method: 'POST',
+  });
+  if (!response.ok) {
+    throw new Error('Failed to fork session');
+  }
+  return response.json();
+}
+
+export type ExportFormat = 'md' | 'json' | 'html';
+
+/**
+ * URL downloading a session export (HTML inlines attachments)
+ */
+export function sessionExportUrl(sessionId: string, format: ExportFormat = 'html'): string {
+  return `${API_BASE}/sessions/${sessionId}/export?format=${format}`;
+}
+
+/**
+ * Create a read-only share link for a session
+ */
+export async function createSessionShare(sessionId: string, options: ShareOptions = {}): Promise<SessionShare> {
+  const response = await fetch(`${API_BASE}/sessions/${sessionId}/shares`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify(options),
+  });
+  if (!response.ok) {
+    throw new Error('Failed to create share link');
+  }
+  return response.json();
+}
+
+/**
+ * Fetch the share links of a session
+ */
+export async function fetchSessionShares(sessionId: string): Promise<SessionShare[]> {
+  const response = await fetch(`${API_BASE}/sessions/${sessionId}/shares`);
+  if (!response.ok) {
+    throw new Error('Failed to fetch share links');
+  }
+  return response.json();
+}
+
+/**
+ * Revoke a share link
+ */
+export async function revokeSessionShare(id: number): Promise<void> {
+  const response = await fetch(`${API_BASE}/shares/${id}`, {
+    method: 'DELETE',
+  });
+  if (!response.ok) {
+    throw new Error('Failed to revoke share link');
+  }
+}
+
+/**
+ * URL downloading every session as a zip archive
+ */
+export function allSessionsExportUrl(format: ExportFormat = 'md'): string {
+  return `${API_BASE}/export/sessions?format=${format}`;
+}
+
 /**
  * Fetch a single session by ID
  */
@@ -118,6 +326,140 @@ export async function updateSessionModel(sessionId: string, model: ClaudeModel):
   }
 }
 
+/**
+ * Pin or unpin a session
+ */
+export async function setSessionPinned(sessionId: string, pinned: boolean): Promise<void> {
+  const response = await fetch(`${API_BASE}/sessions/${sessionId}/pinned`, {
+    method: 'PATCH',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ pinned }),
+  });
+  if (!response.ok) {
+    throw new Error('Failed to update session pin');
+  }
+}
+
+/**
+ * Archive or restore a session
+ */
+export async function setSessionArchived(sessionId: string, archived: boolean): Promise<void> {
+  const response = await fetch(`${API_BASE}/sessions/${sessionId}/archived`, {
+    method: 'PATCH',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ archived }),
+  });
+  if (!response.ok) {
+    throw new Error('Failed to update session archive');
+  }
+}
+
+/**
+ * Move a session into a folder (null to remove it from its folder)
+ */
+export async function setSessionFolder(sessionId: string, folderId: number | null): Promise<void> {
+  const response = await fetch(`${API_BASE}/sessions/${sessionId}/folder`, {
+    method: 'PATCH',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ folder_id: folderId }),
+  });
+  if (!response.ok) {
+    throw new Error('Failed to update session folder');
+  }
+}
+
+/**
+ * Replace the tags of a session, returns the normalized tags
+ */
+export async function setSessionTags(sessionId: string, tags: string[]): Promise<string[]> {
+  const response = await fetch(`${API_BASE}/sessions/${sessionId}/tags`, {
+    method: 'PUT',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ tags }),
+  });
+  if (!response.ok) {
+    throw new Error('Failed to update session tags');
+  }
+  const data = await response.json();
+  return data.tags || [];
+}
+
+/**
+ * Fetch every tag in use with its number of sessions
+ */
+export async function fetchSessionTags(): Promise<SessionTag[]> {
+  const response = await fetch(`${API_BASE}/sessions/tags`);
+  if (!response.ok) {
+    throw new Error('Failed to fetch session tags');
+  }
+  return response.json();
+}
+
+/**
+ * Fetch all folders (flat list, nested through parent_id)
+ */
+export async function fetchFolders(): Promise<Folder[]> {
+  const response = await fetch(`${API_BASE}/folders`);
+  if (!response.ok) {
+    throw new Error('Failed to fetch folders');
+  }
+  return response.json();
+}
+
+/**
+ * Create a folder
+ */
+export async function createFolder(name: string, parentId: number | null = null): Promise<Folder> {
+  const response = await fetch(`${API_BASE}/folders`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ name, parent_id: parentId }),
+  });
+  if (!response.ok) {
+    throw new Error('Failed to create folder');
+  }
+  return response.json();
+}
+
+/**
+ * Rename or move a folder
+ */
+export async function updateFolder(id: number, name: string, parentId: number | null): Promise<Folder> {
+  const response = await fetch(`${API_BASE}/folders/${id}`, {
+    method: 'PUT',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ name, parent_id: parentId }),
+  });
+  if (!response.ok) {
+    throw new Error('Failed to update folder');
+  }
+  return response.json();
+}
+
+/**
+ * Delete a folder, its sessions and subfolders move to its parent
+ */
+export async function deleteFolder(id: number): Promise<void> {
+  const response = await fetch(`${API_BASE}/folders/${id}`, {
+    method: 'DELETE',
+  });
+  if (!response.ok) {
+    throw new Error('Failed to delete folder');
+  }
+}
+
 /**
  * Upload a file
  */
@@ -435,33 +777,46 @@ export async function testMachineConnection(id: string): Promise<TestConnectionR
 
 // Search API functions
 
+export type SearchMode = 'keyword' | 'semantic' | 'hybrid';
+
+export type SearchResultType = 'message' | 'tool_call' | 'memory' | 'session';
+
 export interface SearchResult {
-  message_id: number;
-  session_id: string;
-  role: 'user' | 'assistant' | 'thinking';
+  type: SearchResultType;
+  message_id?: number;
+  session_id?: string;
+  role?: 'user' | 'assistant' | 'thinking';
+  tool_use_id?: string; // Set for tool_call results
+  tool_name?: string;
+  memory_id?: string; // Set for memory results
   snippet: string; // HTML with <mark> tags for highlighting
   timestamp: string;
-  session_title: string;
+  session_title?: string; // Memory title for memory results
+  score?: number; // Semantic or hybrid relevance
 }
 
 export interface SearchResponse {
   results: SearchResult[];
   total: number;
   query: string;
+  mode: SearchMode;
+  sort: string;
   limit: number;
   offset: number;
 }
 
 /**
- * Search messages across all conversations
+ * Search messages, tool calls, memory and session titles
  */
 export async function searchMessages(
   query: string,
   limit = 20,
-  offset = 0
+  offset = 0,
+  mode: SearchMode = 'keyword'
 ): Promise<SearchResponse> {
   const params = new URLSearchParams({
     q: query,
+    mode,
     limit: limit.toString(),
     offset: offset.toString(),
   });
@@ -472,3 +827,24 @@ export async function searchMessages(
   }
   return response.json();
 }
+
+export interface SearchStatus {
+  modes: SearchMode[];
+  semantic?: {
+    model: string;
+    indexed_messages: number;
+    pending_messages: number;
+    chunks: number;
+  };
+}
+
+/**
+ * Get the available search modes and semantic index progress
+ */
+export async function fetchSearchStatus(): Promise<SearchStatus> {
+  const response = await fetch(`${API_BASE}/search/status`);
+  if (!response.ok) {
+    throw new Error('Echec du chargement du statut de recherche');
+  }
+  return response.json();
+}
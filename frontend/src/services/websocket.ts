@@ -14,6 +14,8 @@ export interface MessageAttachment {
   path: string;
   type: 'image' | 'file';
   mime_type?: string;
+  inline?: string[];
+  extract?: string[];
 }
 
 interface WebSocketConfig {
@@ -189,6 +191,33 @@ export class WebSocketService {
     console.log('[WebSocket] Message sent (sessionId:', sessionId || 'none', ', model:', model || 'default', ', attachments:', attachments?.length || 0, ', thinking:', thinking || false, ', machineId:', machineId || 'none', ')');
   }
 
+  /**
+   * Replace a user message and run the conversation again from there.
+   * The server forks the session before the message and answers with a "forked" event.
+   */
+  sendEdit(messageId: number, content: string, sessionId: string, model?: string, thinking?: boolean, machineId?: string): void {
+    if (!this.ws || this.ws.readyState !== WebSocket.OPEN) {
+      console.error('[WebSocket] Cannot send edit: not connected');
+      throw new Error('WebSocket is not connected');
+    }
+
+    this.ws.send(JSON.stringify({ type: 'edit', messageId, content, sessionId, model, thinking, machineId }));
+    console.log('[WebSocket] Edit sent (sessionId:', sessionId, ', messageId:', messageId, ')');
+  }
+
+  /**
+   * Answer a tool approval request
+   */
+  sendApprovalResponse(approvalId: string, decision: 'allow' | 'deny'): void {
+    if (!this.ws || this.ws.readyState !== WebSocket.OPEN) {
+      console.error('[WebSocket] Cannot send approval: not connected');
+      throw new Error('WebSocket is not connected');
+    }
+
+    this.ws.send(JSON.stringify({ type: 'approval_response', approvalId, decision }));
+    console.log('[WebSocket] Approval sent (approvalId:', approvalId, ', decision:', decision, ')');
+  }
+
   /**
    * Register a message handler
    */
@@ -50,6 +50,16 @@ export interface FlowItem {
   toolCall?: ToolCall;
 }
 
+// Tool call waiting for the user's approval
+export interface ApprovalRequest {
+  id: string;
+  toolName: string;
+  input: Record<string, unknown>;
+  timeoutSeconds: number;
+  defaultDecision: 'allow' | 'deny';
+  receivedAt: Date;
+}
+
 export interface ChatState {
   messages: Message[];
   currentSessionId: string | null;
@@ -63,6 +73,7 @@ export interface ChatState {
   currentThinkingOrderIndex: number | null; // Order index for current thinking block
   activeToolCalls: Map<string, ToolCall>; // Active tool calls keyed by toolUseId
   orderCounter: number; // Global counter for ordering events chronologically
+  pendingApprovals: ApprovalRequest[]; // Tool calls waiting for approval
 }
 
 // Initial state - sessionId is null until SDK provides one
@@ -79,6 +90,7 @@ const initialState: ChatState = {
   currentThinkingOrderIndex: null,
   activeToolCalls: new Map(),
   orderCounter: 0,
+  pendingApprovals: [],
 };
 
 /**
@@ -476,6 +488,26 @@ function createChatStore() {
       }));
     },
 
+    /**
+     * Add a tool call waiting for approval
+     */
+    addApproval: (approval: ApprovalRequest) => {
+      update((state) => ({
+        ...state,
+        pendingApprovals: [...state.pendingApprovals.filter((a) => a.id !== approval.id), approval],
+      }));
+    },
+
+    /**
+     * Remove an approval once answered or timed out
+     */
+    removeApproval: (id: string) => {
+      update((state) => ({
+        ...state,
+        pendingApprovals: state.pendingApprovals.filter((a) => a.id !== id),
+      }));
+    },
+
     /**
      * Reset the entire store
      * SessionId will be set when SDK provides one
@@ -1,8 +1,10 @@
 import { writable, derived } from 'svelte/store';
-import { searchMessages, type SearchResult } from '../services/api';
+import { searchMessages, fetchSearchStatus, type SearchResult, type SearchMode } from '../services/api';
 
 interface SearchState {
   query: string;
+  mode: SearchMode;
+  modes: SearchMode[]; // Modes offered by the backend
   results: SearchResult[];
   total: number;
   isLoading: boolean;
@@ -12,6 +14,8 @@ interface SearchState {
 
 const initialState: SearchState = {
   query: '',
+  mode: 'keyword',
+  modes: ['keyword'],
   results: [],
   total: 0,
   isLoading: false,
@@ -25,6 +29,37 @@ function createSearchStore() {
   // Debounce timer
   let debounceTimer: ReturnType<typeof setTimeout> | null = null;
 
+  function search(query: string, mode: SearchMode) {
+    // Clear previous timer
+    if (debounceTimer) {
+      clearTimeout(debounceTimer);
+    }
+
+    // Debounce search by 300ms
+    if (query.trim().length >= 2) {
+      update(s => ({ ...s, isLoading: true, error: null }));
+      debounceTimer = setTimeout(async () => {
+        try {
+          const response = await searchMessages(query, 20, 0, mode);
+          update(s => ({
+            ...s,
+            results: response.results,
+            total: response.total,
+            isLoading: false,
+          }));
+        } catch (error) {
+          update(s => ({
+            ...s,
+            isLoading: false,
+            error: error instanceof Error ? error.message : 'Recherche echouee',
+          }));
+        }
+      }, 300);
+    } else {
+      update(s => ({ ...s, results: [], total: 0, isLoading: false }));
+    }
+  }
+
   return {
     subscribe,
 
@@ -32,6 +67,24 @@ function createSearchStore() {
       update(s => ({ ...s, isOpen: true }));
     },
 
+    loadModes: async () => {
+      try {
+        const status = await fetchSearchStatus();
+        update(s => ({ ...s, modes: status.modes }));
+      } catch {
+        // Keyword search stays available
+      }
+    },
+
+    setMode: (mode: SearchMode) => {
+      let query = '';
+      update(s => {
+        query = s.query;
+        return { ...s, mode };
+      });
+      search(query, mode);
+    },
+
     close: () => {
       // Clear debounce timer
       if (debounceTimer) {
@@ -42,36 +95,12 @@ function createSearchStore() {
     },
 
     setQuery: (query: string) => {
-      update(s => ({ ...s, query }));
-
-      // Clear previous timer
-      if (debounceTimer) {
-        clearTimeout(debounceTimer);
-      }
-
-      // Debounce search by 300ms
-      if (query.trim().length >= 2) {
-        update(s => ({ ...s, isLoading: true, error: null }));
-        debounceTimer = setTimeout(async () => {
-          try {
-            const response = await searchMessages(query);
-            update(s => ({
-              ...s,
-              results: response.results,
-              total: response.total,
-              isLoading: false,
-            }));
-          } catch (error) {
-            update(s => ({
-              ...s,
-              isLoading: false,
-              error: error instanceof Error ? error.message : 'Recherche echouee',
-            }));
-          }
-        }, 300);
-      } else {
-        update(s => ({ ...s, results: [], total: 0, isLoading: false }));
-      }
+      let mode: SearchMode = 'keyword';
+      update(s => {
+        mode = s.mode;
+        return { ...s, query };
+      });
+      search(query, mode);
     },
 
     reset: () => {
@@ -93,6 +122,8 @@ export const isSearching = derived(searchStore, $s => $s.isLoading);
 export const searchError = derived(searchStore, $s => $s.error);
 export const isSearchOpen = derived(searchStore, $s => $s.isOpen);
 export const searchTotal = derived(searchStore, $s => $s.total);
+export const searchMode = derived(searchStore, $s => $s.mode);
+export const searchModes = derived(searchStore, $s => $s.modes);
 
 // Group results by session for display
 interface GroupedResults {
@@ -105,14 +136,16 @@ export const groupedResults = derived(searchStore, $s => {
   const groups = new Map<string, GroupedResults>();
 
   for (const result of $s.results) {
-    if (!groups.has(result.session_id)) {
-      groups.set(result.session_id, {
-        title: result.session_title || 'Sans titre',
-        sessionId: result.session_id,
+    // Memory entries belong to no session: group them together
+    const key = result.type === 'memory' ? '' : result.session_id ?? '';
+    if (!groups.has(key)) {
+      groups.set(key, {
+        title: result.type === 'memory' ? 'Memoire' : result.session_title || 'Sans titre',
+        sessionId: key,
         results: [],
       });
     }
-    groups.get(result.session_id)!.results.push(result);
+    groups.get(key)!.results.push(result);
   }
 
   return Array.from(groups.values());